@@ -4,5 +4,8 @@ go 1.24.5
 
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	golang.org/x/image v0.25.0
 	golang.org/x/sys v0.20.0
 )
+
+require golang.org/x/text v0.23.0 // indirect
@@ -0,0 +1,335 @@
+package apps
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+// Label is how an application is shown in reports.
+type Label struct {
+	Name     string // friendly name, e.g. "Minecraft"
+	Category string // one of config.BuiltinCategories or a category from the config
+}
+
+// anyBrowser in a rule matches every application with the built-in browser category,
+// so that sites can be recognised by the window title.
+const anyBrowser = "*browser*"
+
+// rule labels app, or only its windows whose title contains title. A rule may set
+// just the name or just the category; the rest comes from the following rules.
+type rule struct {
+	app   string
+	title string
+	label Label
+}
+
+func (r rule) matches(app, title string) bool {
+	if r.title != "" && !strings.Contains(strings.ToLower(title), strings.ToLower(r.title)) {
+		return false
+	}
+	if r.app == anyBrowser {
+		return isBrowser(app)
+	}
+	return SameApp(r.app, app)
+}
+
+// builtinApps are the applications the service knows without any configuration.
+var builtinApps = []rule{
+	// Browsers
+	{app: "chrome.exe", label: Label{"Google Chrome", config.CategoryBrowser}},
+	{app: "chromium", label: Label{"Chromium", config.CategoryBrowser}},
+	{app: "msedge.exe", label: Label{"Microsoft Edge", config.CategoryBrowser}},
+	{app: "firefox.exe", label: Label{"Firefox", config.CategoryBrowser}},
+	{app: "firefox-esr", label: Label{"Firefox", config.CategoryBrowser}},
+	{app: "opera.exe", label: Label{"Opera", config.CategoryBrowser}},
+	{app: "browser.exe", label: Label{"Яндекс Браузер", config.CategoryBrowser}},
+	{app: "brave.exe", label: Label{"Brave", config.CategoryBrowser}},
+	{app: "vivaldi.exe", label: Label{"Vivaldi", config.CategoryBrowser}},
+	{app: "iexplore.exe", label: Label{"Internet Explorer", config.CategoryBrowser}},
+
+	// Games and game stores
+	{app: "javaw.exe", label: Label{"Minecraft (Java)", config.CategoryGames}},
+	{app: "Minecraft.Windows.exe", label: Label{"Minecraft", config.CategoryGames}},
+	{app: "MinecraftLauncher.exe", label: Label{"Minecraft Launcher", config.CategoryGames}},
+	{app: "RobloxPlayerBeta.exe", label: Label{"Roblox", config.CategoryGames}},
+	{app: "RobloxStudioBeta.exe", label: Label{"Roblox Studio", config.CategoryGames}},
+	{app: "steam.exe", label: Label{"Steam", config.CategoryGames}},
+	{app: "steamwebhelper.exe", label: Label{"Steam", config.CategoryGames}},
+	{app: "EpicGamesLauncher.exe", label: Label{"Epic Games", config.CategoryGames}},
+	{app: "FortniteClient-Win64-Shipping.exe", label: Label{"Fortnite", config.CategoryGames}},
+	{app: "GenshinImpact.exe", label: Label{"Genshin Impact", config.CategoryGames}},
+	{app: "Among Us.exe", label: Label{"Among Us", config.CategoryGames}},
+	{app: "Terraria.exe", label: Label{"Terraria", config.CategoryGames}},
+	{app: "cs2.exe", label: Label{"Counter-Strike 2", config.CategoryGames}},
+	{app: "dota2.exe", label: Label{"Dota 2", config.CategoryGames}},
+	{app: "League of Legends.exe", label: Label{"League of Legends", config.CategoryGames}},
+	{app: "VALORANT-Win64-Shipping.exe", label: Label{"Valorant", config.CategoryGames}},
+
+	// Video
+	{app: "vlc.exe", label: Label{"VLC", config.CategoryVideo}},
+	{app: "mpv", label: Label{"mpv", config.CategoryVideo}},
+	{app: "totem", label: Label{"Видео", config.CategoryVideo}},
+	{app: "wmplayer.exe", label: Label{"Windows Media Player", config.CategoryVideo}},
+	{app: "Video.UI.exe", label: Label{"Кино и ТВ", config.CategoryVideo}},
+	{app: "mpc-hc64.exe", label: Label{"Media Player Classic", config.CategoryVideo}},
+	{app: "PotPlayerMini64.exe", label: Label{"PotPlayer", config.CategoryVideo}},
+
+	// Messengers and calls
+	{app: "Telegram.exe", label: Label{"Telegram", config.CategorySocial}},
+	{app: "Discord.exe", label: Label{"Discord", config.CategorySocial}},
+	{app: "WhatsApp.exe", label: Label{"WhatsApp", config.CategorySocial}},
+	{app: "Skype.exe", label: Label{"Skype", config.CategorySocial}},
+	{app: "Zoom.exe", label: Label{"Zoom", config.CategorySocial}},
+	{app: "ms-teams.exe", label: Label{"Microsoft Teams", config.CategorySocial}},
+
+	// Education
+	{app: "Scratch 3.exe", label: Label{"Scratch", config.CategoryEducation}},
+	{app: "GeoGebra.exe", label: Label{"GeoGebra", config.CategoryEducation}},
+	{app: "Anki.exe", label: Label{"Anki", config.CategoryEducation}},
+
+	// Documents
+	{app: "WINWORD.EXE", label: Label{"Word", config.CategoryOffice}},
+	{app: "EXCEL.EXE", label: Label{"Excel", config.CategoryOffice}},
+	{app: "POWERPNT.EXE", label: Label{"PowerPoint", config.CategoryOffice}},
+	{app: "ONENOTE.EXE", label: Label{"OneNote", config.CategoryOffice}},
+	{app: "soffice.bin", label: Label{"LibreOffice", config.CategoryOffice}},
+	{app: "notepad.exe", label: Label{"Блокнот", config.CategoryOffice}},
+	{app: "mspaint.exe", label: Label{"Paint", config.CategoryOffice}},
+	{app: "AcroRd32.exe", label: Label{"Adobe Reader", config.CategoryOffice}},
+
+	// The desktop itself
+	{app: "explorer.exe", label: Label{"Проводник", config.CategorySystem}},
+	{app: "SystemSettings.exe", label: Label{"Параметры", config.CategorySystem}},
+	{app: "Taskmgr.exe", label: Label{"Диспетчер задач", config.CategorySystem}},
+	{app: "LockApp.exe", label: Label{"Экран блокировки", config.CategorySystem}},
+	{app: "LogonUI.exe", label: Label{"Экран входа", config.CategorySystem}},
+	{app: "SearchHost.exe", label: Label{"Поиск", config.CategorySystem}},
+	{app: "SearchApp.exe", label: Label{"Поиск", config.CategorySystem}},
+	{app: "ShellExperienceHost.exe", label: Label{"Панель задач", config.CategorySystem}},
+	{app: "StartMenuExperienceHost.exe", label: Label{"Меню «Пуск»", config.CategorySystem}},
+	{app: "ApplicationFrameHost.exe", label: Label{"Приложения Windows", config.CategorySystem}},
+	{app: "cmd.exe", label: Label{"Командная строка", config.CategorySystem}},
+	{app: "powershell.exe", label: Label{"PowerShell", config.CategorySystem}},
+	{app: "WindowsTerminal.exe", label: Label{"Терминал", config.CategorySystem}},
+}
+
+// builtinSites recognise well-known sites by the title of a browser window.
+var builtinSites = []rule{
+	{app: anyBrowser, title: "YouTube", label: Label{"YouTube", config.CategoryVideo}},
+	{app: anyBrowser, title: "Twitch", label: Label{"Twitch", config.CategoryVideo}},
+	{app: anyBrowser, title: "Кинопоиск", label: Label{"Кинопоиск", config.CategoryVideo}},
+	{app: anyBrowser, title: "TikTok", label: Label{"TikTok", config.CategorySocial}},
+	{app: anyBrowser, title: "ВКонтакте", label: Label{"ВКонтакте", config.CategorySocial}},
+	{app: anyBrowser, title: "Roblox", label: Label{"Roblox", config.CategoryGames}},
+	{app: anyBrowser, title: "Википедия", label: Label{"Википедия", config.CategoryEducation}},
+	{app: anyBrowser, title: "Wikipedia", label: Label{"Wikipedia", config.CategoryEducation}},
+	{app: anyBrowser, title: "Учи.ру", label: Label{"Учи.ру", config.CategoryEducation}},
+	{app: anyBrowser, title: "Khan Academy", label: Label{"Khan Academy", config.CategoryEducation}},
+	{app: anyBrowser, title: "Duolingo", label: Label{"Duolingo", config.CategoryEducation}},
+}
+
+func isBrowser(app string) bool {
+	for _, r := range builtinApps {
+		if r.label.Category == config.CategoryBrowser && SameApp(r.app, app) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classifier maps applications to friendly names and categories. Labels from the
+// config come first, then app_categories, then the built-in rules.
+type Classifier struct {
+	rules      []rule // in order of precedence
+	categories []string
+}
+
+func NewClassifier(labels []config.AppLabel, categories map[string][]string) *Classifier {
+	c := &Classifier{}
+
+	// Labels for particular windows win over labels for the whole application
+	for _, label := range labels {
+		if label.Title != "" {
+			c.rules = append(c.rules, rule{app: label.App, title: label.Title, label: Label{label.Name, label.Category}})
+		}
+	}
+	for _, label := range labels {
+		if label.Title == "" {
+			c.rules = append(c.rules, rule{app: label.App, label: Label{label.Name, label.Category}})
+		}
+	}
+	// In a fixed order, so that classifying never depends on the order of the map
+	custom := make(map[string]bool)
+	for _, category := range slices.Sorted(maps.Keys(categories)) {
+		custom[category] = true
+		for _, app := range categories[category] {
+			c.rules = append(c.rules, rule{app: app, label: Label{Category: category}})
+		}
+	}
+	c.rules = append(c.rules, builtinSites...)
+	c.rules = append(c.rules, builtinApps...)
+
+	for _, label := range labels {
+		if label.Category != "" {
+			custom[label.Category] = true
+		}
+	}
+	c.categories = append([]string(nil), config.BuiltinCategories...)
+	var extra []string
+	for category := range custom {
+		if !containsString(c.categories, category) {
+			extra = append(extra, category)
+		}
+	}
+	sort.Strings(extra)
+	c.categories = append(c.categories, extra...)
+	return c
+}
+
+// Categories returns every category: the built-in ones first, then those from the config.
+func (c *Classifier) Categories() []string {
+	return c.categories
+}
+
+// Classify returns the label of app whose foreground window is titled title.
+// title may be empty when it is unknown.
+func (c *Classifier) Classify(app, title string) Label {
+	var label Label
+	for _, r := range c.rules {
+		if (label.Name != "" || r.label.Name == "") && (label.Category != "" || r.label.Category == "") {
+			continue
+		}
+		if !r.matches(app, title) {
+			continue
+		}
+		if label.Name == "" {
+			label.Name = r.label.Name
+		}
+		if label.Category == "" {
+			label.Category = r.label.Category
+		}
+		if label.Name != "" && label.Category != "" {
+			break
+		}
+	}
+	if label.Name == "" {
+		label.Name = trimExe(app)
+	}
+	if label.Category == "" {
+		label.Category = config.CategoryOther
+	}
+	return label
+}
+
+// Category returns the category of app.
+func (c *Classifier) Category(app string) string {
+	return c.Classify(app, "").Category
+}
+
+// TitleUsage is the time spent in windows with one title.
+type TitleUsage struct {
+	Title string
+	Time  time.Duration
+}
+
+// AppUsage is the time spent in an application, possibly spread over several executables.
+type AppUsage struct {
+	Name        string
+	Executables []string // sorted
+	Time        time.Duration
+	Titles      []TitleUsage // recorded window titles, longest first
+}
+
+// CategoryUsage is the time spent in the applications of a category.
+type CategoryUsage struct {
+	Category string
+	Time     time.Duration
+	Apps     []AppUsage // longest first
+}
+
+// Summarize groups usage per executable by category and friendly name. titles
+// (executable -> window title -> time, may be nil) splits the time of an executable
+// by window title, so that e.g. YouTube in a browser counts as video; time without
+// a recorded title is classified by the executable alone. Categories and
+// applications are sorted by time, longest first.
+func (c *Classifier) Summarize(usage map[string]time.Duration, titles map[string]map[string]time.Duration) []CategoryUsage {
+	byCategory := make(map[string]map[string]*AppUsage)
+	add := func(app, title string, d time.Duration) {
+		if d <= 0 {
+			return
+		}
+		label := c.Classify(app, title)
+		apps := byCategory[label.Category]
+		if apps == nil {
+			apps = make(map[string]*AppUsage)
+			byCategory[label.Category] = apps
+		}
+		entry := apps[label.Name]
+		if entry == nil {
+			entry = &AppUsage{Name: label.Name}
+			apps[label.Name] = entry
+		}
+		if !containsString(entry.Executables, app) {
+			entry.Executables = append(entry.Executables, app)
+		}
+		entry.Time += d
+		if title != "" {
+			entry.Titles = append(entry.Titles, TitleUsage{Title: title, Time: d})
+		}
+	}
+
+	for app, d := range usage {
+		for title, td := range titles[app] {
+			// Titles may be kept longer than applications; never exceed the application's time
+			td = min(td, d)
+			add(app, title, td)
+			d -= td
+		}
+		add(app, "", d)
+	}
+
+	result := make([]CategoryUsage, 0, len(byCategory))
+	for category, apps := range byCategory {
+		cu := CategoryUsage{Category: category}
+		for _, entry := range apps {
+			sort.Strings(entry.Executables)
+			sort.Slice(entry.Titles, func(i, j int) bool {
+				if entry.Titles[i].Time != entry.Titles[j].Time {
+					return entry.Titles[i].Time > entry.Titles[j].Time
+				}
+				return entry.Titles[i].Title < entry.Titles[j].Title
+			})
+			cu.Apps = append(cu.Apps, *entry)
+			cu.Time += entry.Time
+		}
+		sort.Slice(cu.Apps, func(i, j int) bool {
+			if cu.Apps[i].Time != cu.Apps[j].Time {
+				return cu.Apps[i].Time > cu.Apps[j].Time
+			}
+			return cu.Apps[i].Name < cu.Apps[j].Name
+		})
+		result = append(result, cu)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Time != result[j].Time {
+			return result[i].Time > result[j].Time
+		}
+		return result[i].Category < result[j].Category
+	})
+	return result
+}
+
+// containsString reports whether list has s, case-sensitively.
+func containsString(list []string, s string) bool {
+	for _, c := range list {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package apps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+func TestClassify(t *testing.T) {
+	classifier := NewClassifier(
+		[]config.AppLabel{
+			{App: "javaw.exe", Name: "Minecraft"},
+			{App: "chrome.exe", Title: "Scratch", Name: "Scratch online", Category: config.CategoryEducation},
+			{App: "Teams.exe", Name: "School Teams"},
+		},
+		map[string][]string{
+			"fun":    {"vlc.exe", "Minecraft.Windows"},
+			"school": {"teams", "homework.exe"},
+		},
+	)
+
+	tests := []struct {
+		app, title string
+		want       Label
+	}{
+		// A label names the application, the built-in rules give the category
+		{"javaw.exe", "", Label{"Minecraft", config.CategoryGames}},
+		{"JAVAW", "", Label{"Minecraft", config.CategoryGames}},
+		// A label for some windows wins over everything for the application
+		{"chrome.exe", "Scratch - Imagine, Program, Share", Label{"Scratch online", config.CategoryEducation}},
+		// Built-in sites win over the browser itself
+		{"chrome.exe", "Funny cats - YouTube", Label{"YouTube", config.CategoryVideo}},
+		{"firefox.exe", "Wikipedia, the free encyclopedia", Label{"Wikipedia", config.CategoryEducation}},
+		{"chrome.exe", "News", Label{"Google Chrome", config.CategoryBrowser}},
+		{"chrome.exe", "", Label{"Google Chrome", config.CategoryBrowser}},
+		// Sites are recognised in browsers only
+		{"notepad.exe", "YouTube ideas.txt", Label{"Блокнот", config.CategoryOffice}},
+		// Custom categories win over the built-in ones, the built-in name stays
+		{"vlc.exe", "", Label{"VLC", "fun"}},
+		{"Minecraft.Windows.exe", "", Label{"Minecraft", "fun"}},
+		// A label without a category takes it from the custom categories
+		{"teams.exe", "", Label{"School Teams", "school"}},
+		{"homework", "", Label{"homework", "school"}},
+		{"unknown.exe", "", Label{"unknown", config.CategoryOther}},
+	}
+	for _, tt := range tests {
+		if got := classifier.Classify(tt.app, tt.title); got != tt.want {
+			t.Errorf("Classify(%q, %q) = %+v, want %+v", tt.app, tt.title, got, tt.want)
+		}
+	}
+
+	want := append(append([]string(nil), config.BuiltinCategories...), "fun", "school")
+	if got := classifier.Categories(); len(got) != len(want) || got[len(got)-2] != "fun" || got[len(got)-1] != "school" {
+		t.Errorf("Categories() = %v, want %v", got, want)
+	}
+}
+
+// summary is a CategoryUsage reduced to what the tests compare.
+type summary struct {
+	category string
+	time     time.Duration
+	apps     []string // names, in order
+}
+
+func assertSummary(t *testing.T, got []CategoryUsage, want []summary) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Summarize() = %+v, want %d categories", got, len(want))
+	}
+	for i, cu := range got {
+		ok := cu.Category == want[i].category && cu.Time == want[i].time && len(cu.Apps) == len(want[i].apps)
+		for j := 0; ok && j < len(cu.Apps); j++ {
+			ok = cu.Apps[j].Name == want[i].apps[j]
+		}
+		if !ok {
+			t.Errorf("category %d = %+v, want %+v", i, cu, want[i])
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	classifier := NewClassifier(nil, nil)
+	usage := map[string]time.Duration{
+		"chrome.exe":         60 * time.Minute,
+		"vlc.exe":            30 * time.Minute,
+		"steam.exe":          5 * time.Minute,
+		"steamwebhelper.exe": 5 * time.Minute,
+		"idle.exe":           0,
+	}
+
+	// Without titles every executable counts for its own category
+	got := classifier.Summarize(usage, nil)
+	assertSummary(t, got, []summary{
+		{config.CategoryBrowser, 60 * time.Minute, []string{"Google Chrome"}},
+		{config.CategoryVideo, 30 * time.Minute, []string{"VLC"}},
+		{config.CategoryGames, 10 * time.Minute, []string{"Steam"}},
+	})
+	if steam := got[2].Apps[0]; len(steam.Executables) != 2 || steam.Executables[0] != "steam.exe" || steam.Executables[1] != "steamwebhelper.exe" {
+		t.Errorf("Steam executables = %v, want both, sorted", steam.Executables)
+	}
+
+	// Titles split the browser time; the rest stays browser time
+	titles := map[string]map[string]time.Duration{
+		"chrome.exe": {"Funny cats - YouTube": 40 * time.Minute, "Wikipedia": 10 * time.Minute},
+	}
+	got = classifier.Summarize(usage, titles)
+	assertSummary(t, got, []summary{
+		{config.CategoryVideo, 70 * time.Minute, []string{"YouTube", "VLC"}},
+		{config.CategoryBrowser, 10 * time.Minute, []string{"Google Chrome"}},
+		{config.CategoryEducation, 10 * time.Minute, []string{"Wikipedia"}},
+		{config.CategoryGames, 10 * time.Minute, []string{"Steam"}},
+	})
+	if youtube := got[0].Apps[0]; len(youtube.Titles) != 1 || youtube.Titles[0] != (TitleUsage{"Funny cats - YouTube", 40 * time.Minute}) {
+		t.Errorf("YouTube titles = %+v, want the recorded title", youtube.Titles)
+	}
+
+	// Titles kept longer than the application never add time
+	titles = map[string]map[string]time.Duration{"chrome.exe": {"Funny cats - YouTube": 90 * time.Minute}}
+	assertSummary(t, classifier.Summarize(map[string]time.Duration{"chrome.exe": time.Hour}, titles), []summary{
+		{config.CategoryVideo, time.Hour, []string{"YouTube"}},
+	})
+}
@@ -0,0 +1,402 @@
+// Package apps names and categorises applications for reports and enforces the
+// per-child application rules: blocked applications, allow lists and daily time
+// limits per application or category of applications.
+package apps
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// Reasons an application is stopped.
+const (
+	ReasonBlocked    = "blocked"     // listed in AppRules.Blocked
+	ReasonNotAllowed = "not_allowed" // missing from a non-empty AppRules.Allowed
+	ReasonLimit      = "limit"       // the daily limit of the application is used up
+	ReasonCategory   = "category"    // the daily limit of the application's category is used up
+)
+
+const (
+	// childNoticeInterval limits how often the child is told about the same application.
+	childNoticeInterval = time.Minute
+	// parentNoticeInterval limits how often parents hear about the same application.
+	parentNoticeInterval = 15 * time.Minute
+	// lockInterval limits how often the session of a child is locked because of an
+	// application, as the locked desktop may still report it in the foreground.
+	lockInterval = time.Minute
+)
+
+// Violation describes an application the enforcer stopped.
+type Violation struct {
+	Username string
+	App      string
+	Reason   string
+	Category string        // category whose limit is used up, for ReasonCategory
+	Limit    time.Duration // daily limit for ReasonLimit and ReasonCategory
+	Action   string        // config.AppActionClose, AppActionMinimize or AppActionLock
+	Err      error         // non-nil if the application could not be stopped
+}
+
+// LimitStatus is today's usage of an application or category with a daily limit.
+type LimitStatus struct {
+	Name     string // application or category as configured
+	Category bool
+	Used     time.Duration
+	Limit    time.Duration
+}
+
+// UsageFunc returns how long username has used each application today.
+type UsageFunc func(username string) map[string]time.Duration
+
+// TitleUsageFunc returns how long username has spent in each window title of each
+// application today, for the applications whose titles are recorded.
+type TitleUsageFunc func(username string) map[string]map[string]time.Duration
+
+// Sessions tells children about stopped applications and locks their sessions.
+// session.Manager satisfies it.
+type Sessions interface {
+	NotifyChild(username, text string) bool
+	EnforceLock(username, action string) error
+}
+
+// ViolationHandler is told about violations parents should hear about.
+type ViolationHandler func(v Violation)
+
+type Enforcer struct {
+	processes  platform.Processes
+	systemApps []string // never stopped by an allow list: without them the desktop is unusable
+	usage      UsageFunc
+	title      func(platform.Process) string // title recorded for a window, "" if none
+	titleUsage TitleUsageFunc
+	sessions   Sessions
+	audit      *audit.Log
+	handler    ViolationHandler
+	rules      map[string]*config.AppRules // child username -> rules
+	classifier *Classifier
+	noticed    map[string]time.Time // child/app/reason -> last message to the child
+	reported   map[string]time.Time // child/app/reason -> last report to parents
+	locked     map[string]time.Time // child -> last lock
+	mutex      sync.Mutex
+}
+
+// NewEnforcer creates an enforcer without rules. usage and sessions may be nil, in
+// which case daily limits are not enforced and the child is neither told nor locked.
+func NewEnforcer(processes platform.Processes, usage UsageFunc, sessions Sessions, auditLog *audit.Log) *Enforcer {
+	return &Enforcer{
+		processes:  processes,
+		systemApps: processes.SystemApps(),
+		usage:      usage,
+		sessions:   sessions,
+		audit:      auditLog,
+		rules:      make(map[string]*config.AppRules),
+		classifier: NewClassifier(nil, nil),
+		noticed:    make(map[string]time.Time),
+		reported:   make(map[string]time.Time),
+		locked:     make(map[string]time.Time),
+	}
+}
+
+// SetHandler registers the callback told about violations.
+func (e *Enforcer) SetHandler(handler ViolationHandler) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.handler = handler
+}
+
+// SetRules replaces the application rules of username; nil removes them.
+func (e *Enforcer) SetRules(username string, rules *config.AppRules) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if rules.Empty() {
+		delete(e.rules, username)
+		return
+	}
+	e.rules[username] = rules.Clone()
+}
+
+// SetTitleUsage lets category limits split the time of an application by window
+// title, as reports do, so that e.g. YouTube in a browser counts as video. title
+// returns the title recorded for the window of a process, "" when titles are not
+// recorded for it; such windows and those of children without recorded titles
+// count for the category of their executable, as their time does.
+func (e *Enforcer) SetTitleUsage(title func(platform.Process) string, titleUsage TitleUsageFunc) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.title = title
+	e.titleUsage = titleUsage
+}
+
+// SetClassifier replaces the classifier that puts applications in categories for
+// category limits. Without one only the built-in rules are used.
+func (e *Enforcer) SetClassifier(classifier *Classifier) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.classifier = classifier
+}
+
+// Check stops process if its user may not use it. It is meant to be called with
+// the foreground process on every tracker tick.
+func (e *Enforcer) Check(process platform.Process) {
+	// PID 0 means the application could not be identified, e.g. under Wayland
+	if process.PID == 0 {
+		return
+	}
+
+	e.mutex.Lock()
+	username, rules := e.rulesForLocked(process.Username)
+	classifier := e.classifier
+	recordedTitle := e.title
+	e.mutex.Unlock()
+	if rules == nil {
+		return
+	}
+
+	// The window is classified by the title its time is recorded under, if any
+	title := ""
+	if recordedTitle != nil {
+		title = recordedTitle(process)
+	}
+	v, violated := e.evaluate(username, process.Name, title, rules, classifier)
+	if !violated {
+		return
+	}
+
+	v.Action = rules.Action
+	if v.Action == "" {
+		v.Action = config.AppActionClose
+	}
+	switch v.Action {
+	case config.AppActionMinimize:
+		v.Err = e.processes.MinimizeProcess(process.PID)
+	case config.AppActionLock:
+		if e.sessions == nil {
+			return
+		}
+		e.mutex.Lock()
+		recent := time.Since(e.locked[username]) < lockInterval
+		if !recent {
+			e.locked[username] = time.Now()
+		}
+		e.mutex.Unlock()
+		if recent {
+			return
+		}
+		v.Err = e.sessions.EnforceLock(username, audit.ActionAppLock)
+	default:
+		v.Err = e.processes.TerminateProcess(process.PID)
+	}
+	if v.Err != nil {
+		log.Printf("Failed to %s %s (%d) of %s: %v", v.Action, process.Name, process.PID, username, v.Err)
+	} else {
+		log.Printf("Applied %s to %s (%d) of %s: %s", v.Action, process.Name, process.PID, username, v.Reason)
+	}
+
+	e.notify(v)
+}
+
+// rulesForLocked returns the configured username and rules for the OS user username.
+func (e *Enforcer) rulesForLocked(username string) (string, *config.AppRules) {
+	for child, rules := range e.rules {
+		if strings.EqualFold(child, username) {
+			return child, rules
+		}
+	}
+	return "", nil
+}
+
+// evaluate reports whether username may not use app, whose foreground window is
+// recorded as title ("" if not recorded), right now and why.
+func (e *Enforcer) evaluate(username, app, title string, rules *config.AppRules, classifier *Classifier) (Violation, bool) {
+	v := Violation{Username: username, App: app}
+	if contains(rules.Blocked, app) {
+		v.Reason = ReasonBlocked
+		return v, true
+	}
+	if len(rules.Allowed) > 0 && !contains(rules.Allowed, app) && !contains(e.systemApps, app) && !SameApp(app, filepath.Base(os.Args[0])) {
+		v.Reason = ReasonNotAllowed
+		return v, true
+	}
+	if e.usage == nil || (len(rules.Limits) == 0 && len(rules.CategoryLimits) == 0) {
+		return v, false
+	}
+
+	usage := e.usage(username)
+	titles := e.todayTitles(username, rules)
+	for name, minutes := range rules.Limits {
+		limit := time.Duration(minutes) * time.Minute
+		if SameApp(name, app) && usageOf(usage, name) >= limit {
+			v.Reason, v.Limit = ReasonLimit, limit
+			return v, true
+		}
+	}
+	for category, minutes := range rules.CategoryLimits {
+		limit := time.Duration(minutes) * time.Minute
+		if classifier.Classify(app, title).Category == category && categoryUsage(usage, titles, category, classifier) >= limit {
+			v.Reason, v.Category, v.Limit = ReasonCategory, category, limit
+			return v, true
+		}
+	}
+	return v, false
+}
+
+// LimitStatus returns today's usage of username against each application and
+// category limit, applications first, each group sorted by name.
+func (e *Enforcer) LimitStatus(username string) []LimitStatus {
+	e.mutex.Lock()
+	_, rules := e.rulesForLocked(username)
+	classifier := e.classifier
+	e.mutex.Unlock()
+	if rules == nil {
+		return nil
+	}
+
+	var usage map[string]time.Duration
+	if e.usage != nil {
+		usage = e.usage(username)
+	}
+	titles := e.todayTitles(username, rules)
+
+	var result []LimitStatus
+	for _, name := range sortedKeys(rules.Limits) {
+		result = append(result, LimitStatus{
+			Name:  name,
+			Used:  usageOf(usage, name),
+			Limit: time.Duration(rules.Limits[name]) * time.Minute,
+		})
+	}
+	for _, category := range sortedKeys(rules.CategoryLimits) {
+		result = append(result, LimitStatus{
+			Name:     category,
+			Category: true,
+			Used:     categoryUsage(usage, titles, category, classifier),
+			Limit:    time.Duration(rules.CategoryLimits[category]) * time.Minute,
+		})
+	}
+	return result
+}
+
+// usageOf sums the usage of every executable matching name.
+func usageOf(usage map[string]time.Duration, name string) time.Duration {
+	var total time.Duration
+	for app, d := range usage {
+		if SameApp(app, name) {
+			total += d
+		}
+	}
+	return total
+}
+
+// todayTitles returns today's usage of username per window title when category
+// limits need it, or nil.
+func (e *Enforcer) todayTitles(username string, rules *config.AppRules) map[string]map[string]time.Duration {
+	e.mutex.Lock()
+	titleUsage := e.titleUsage
+	e.mutex.Unlock()
+	if titleUsage == nil || len(rules.CategoryLimits) == 0 {
+		return nil
+	}
+	return titleUsage(username)
+}
+
+// categoryUsage sums the usage in category, classifying the time of each application
+// by window title where titles (may be nil) has it, the same way reports do.
+func categoryUsage(usage map[string]time.Duration, titles map[string]map[string]time.Duration, category string, classifier *Classifier) time.Duration {
+	for _, cu := range classifier.Summarize(usage, titles) {
+		if cu.Category == category {
+			return cu.Time
+		}
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// notify tells the child and parents about v, each at most once per interval.
+func (e *Enforcer) notify(v Violation) {
+	key := strings.ToLower(v.Username + "/" + v.App + "/" + v.Reason + "/" + v.Category)
+	now := time.Now()
+
+	e.mutex.Lock()
+	tellChild := now.Sub(e.noticed[key]) >= childNoticeInterval
+	if tellChild {
+		e.noticed[key] = now
+	}
+	tellParents := now.Sub(e.reported[key]) >= parentNoticeInterval
+	if tellParents {
+		e.reported[key] = now
+	}
+	handler := e.handler
+	e.mutex.Unlock()
+
+	if tellChild && e.sessions != nil {
+		e.sessions.NotifyChild(v.Username, childMessage(v))
+	}
+	if !tellParents {
+		return
+	}
+
+	params := map[string]interface{}{"app": v.App, "reason": v.Reason, "action": v.Action}
+	if v.Category != "" {
+		params["category"] = v.Category
+	}
+	if v.Limit > 0 {
+		params["limit_minutes"] = int(v.Limit / time.Minute)
+	}
+	e.audit.Record(audit.System, v.Username, audit.ActionAppBlocked, params, v.Err)
+	if handler != nil {
+		handler(v)
+	}
+}
+
+func childMessage(v Violation) string {
+	switch v.Reason {
+	case ReasonLimit:
+		return fmt.Sprintf("Время для %s на сегодня закончилось (%d мин).", v.App, int(v.Limit/time.Minute))
+	case ReasonCategory:
+		return fmt.Sprintf("Время для категории «%s» на сегодня закончилось (%d мин).", v.Category, int(v.Limit/time.Minute))
+	case ReasonNotAllowed:
+		return fmt.Sprintf("Программа %s не входит в список разрешённых.", v.App)
+	default:
+		return fmt.Sprintf("Программа %s запрещена родителями.", v.App)
+	}
+}
+
+// SameApp reports whether a and b name the same executable: case-insensitively
+// and with or without the ".exe" extension.
+func SameApp(a, b string) bool {
+	return strings.EqualFold(trimExe(a), trimExe(b))
+}
+
+func trimExe(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasSuffix(strings.ToLower(name), ".exe") {
+		return name[:len(name)-len(".exe")]
+	}
+	return name
+}
+
+func contains(apps []string, app string) bool {
+	for _, a := range apps {
+		if SameApp(a, app) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package apps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+func TestAllowListKeepsSystemApps(t *testing.T) {
+	fake := platform.NewFake()
+	fake.SetSystemApps("gnome-shell", "explorer.exe")
+	enforcer := NewEnforcer(fake, nil, nil, nil)
+	enforcer.SetRules("alice", &config.AppRules{Allowed: []string{"Minecraft.exe"}})
+
+	processes := []struct {
+		name    string
+		stopped bool
+	}{
+		{"Minecraft.exe", false},
+		{"gnome-shell", false},
+		{"Explorer.EXE", false},
+		{"steam", true},
+	}
+	for i, p := range processes {
+		enforcer.Check(platform.Process{PID: uint32(100 + i), Name: p.name, Username: "alice"})
+	}
+
+	// Only steam is stopped
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0] != "TerminateProcess [103]" {
+		t.Errorf("calls %v, want only steam terminated", calls)
+	}
+}
+
+func TestCategoryLimitByTitle(t *testing.T) {
+	fake := platform.NewFake()
+	usage := func(username string) map[string]time.Duration {
+		return map[string]time.Duration{"chrome.exe": time.Hour}
+	}
+	// Titles are recorded for alice only, as the tracker does with the filter below
+	titles := func(username string) map[string]map[string]time.Duration {
+		if username != "alice" {
+			return nil
+		}
+		return map[string]map[string]time.Duration{"chrome.exe": {"Funny cats - YouTube": 40 * time.Minute}}
+	}
+	enforcer := NewEnforcer(fake, usage, nil, nil)
+	rules := &config.AppRules{CategoryLimits: map[string]int{
+		config.CategoryVideo:   30,
+		config.CategoryBrowser: 30,
+	}}
+	enforcer.SetRules("alice", rules)
+	enforcer.SetRules("bob", rules)
+
+	// Without titles all the time is browser time, whatever the window shows
+	enforcer.Check(platform.Process{PID: 1, Name: "chrome.exe", Title: "News - Google Chrome", Username: "alice"})
+	enforcer.Check(platform.Process{PID: 2, Name: "chrome.exe", Title: "Cats - YouTube - Google Chrome", Username: "alice"})
+	if calls := fake.Calls(); len(calls) != 2 || calls[0] != "TerminateProcess [1]" || calls[1] != "TerminateProcess [2]" {
+		t.Errorf("calls without titles %v, want both browser windows stopped", calls)
+	}
+
+	// With titles 40 min are video and 20 min browser; Wikipedia has no limit
+	enforcer.SetTitleUsage(NewTitleFilter([]string{"alice"}, nil).Title, titles)
+	enforcer.Check(platform.Process{PID: 3, Name: "chrome.exe", Title: "Wikipedia - Google Chrome", Username: "alice"})
+	enforcer.Check(platform.Process{PID: 4, Name: "chrome.exe", Title: "Cats - YouTube - Google Chrome", Username: "alice"})
+	enforcer.Check(platform.Process{PID: 5, Name: "chrome.exe", Title: "News - Google Chrome", Username: "alice"})
+	if calls := fake.Calls()[2:]; len(calls) != 1 || calls[0] != "TerminateProcess [4]" {
+		t.Errorf("calls with titles %v, want only the YouTube window stopped", calls)
+	}
+	status := enforcer.LimitStatus("alice")
+	if len(status) != 2 || status[0].Used != 20*time.Minute || status[1].Used != 40*time.Minute {
+		t.Errorf("LimitStatus() = %+v, want 20 min of browser and 40 min of video", status)
+	}
+
+	// bob's titles are not recorded: his YouTube window is browser time, which is used up
+	enforcer.Check(platform.Process{PID: 6, Name: "chrome.exe", Title: "Cats - YouTube - Google Chrome", Username: "bob"})
+	if calls := fake.Calls()[3:]; len(calls) != 1 || calls[0] != "TerminateProcess [6]" {
+		t.Errorf("calls without recorded titles %v, want the YouTube window stopped", calls)
+	}
+	status = enforcer.LimitStatus("bob")
+	if len(status) != 2 || status[0].Used != time.Hour || status[1].Used != 0 {
+		t.Errorf("LimitStatus() = %+v, want 60 min of browser and no video", status)
+	}
+}
@@ -0,0 +1,69 @@
+package apps
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// maxTitleLength limits how much of a window title is stored, in characters.
+const maxTitleLength = 120
+
+// redacted replaces the parts of titles matched by a redaction pattern.
+const redacted = "…"
+
+var (
+	// browserSuffix is the browser name browsers append to the tab title.
+	browserSuffix = regexp.MustCompile(`\s+[-—–]\s+(?:Google Chrome|Chromium|Microsoft\x{200b}? Edge|Mozilla Firefox|Firefox|Opera|Яндекс Браузер|Яндекс\.Браузер|Brave|Vivaldi|Internet Explorer)$`)
+	// otherTabs is how Edge mentions the other tabs of the window.
+	otherTabs = regexp.MustCompile(`\s+(?:and \d+ more pages?|и ещё \d+ \S+)$`)
+	// unreadCount is the counter sites put in front of the title, e.g. "(3) Inbox".
+	unreadCount = regexp.MustCompile(`^\(\d+\+?\)\s*`)
+)
+
+// NormalizeTitle reduces the window title of app to what identifies the content:
+// for browsers the tab title without the browser name and unread counters.
+func NormalizeTitle(app, title string) string {
+	title = strings.TrimSpace(title)
+	if isBrowser(app) {
+		title = browserSuffix.ReplaceAllString(title, "")
+		title = otherTabs.ReplaceAllString(title, "")
+		title = unreadCount.ReplaceAllString(title, "")
+	}
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength]) + "…"
+	}
+	return strings.TrimSpace(title)
+}
+
+// TitleFilter decides which window titles are recorded: only those of children who
+// opted in, normalised and with the redaction patterns applied.
+type TitleFilter struct {
+	children map[string]bool // lower-case usernames
+	redact   []*regexp.Regexp
+}
+
+func NewTitleFilter(children []string, redact []*regexp.Regexp) *TitleFilter {
+	f := &TitleFilter{
+		children: make(map[string]bool, len(children)),
+		redact:   redact,
+	}
+	for _, child := range children {
+		f.children[strings.ToLower(child)] = true
+	}
+	return f
+}
+
+// Title returns the title of the foreground window of process to record, or ""
+// when it must not be recorded.
+func (f *TitleFilter) Title(process platform.Process) string {
+	if !f.children[strings.ToLower(process.Username)] {
+		return ""
+	}
+	title := NormalizeTitle(process.Name, process.Title)
+	for _, re := range f.redact {
+		title = re.ReplaceAllString(title, redacted)
+	}
+	return strings.TrimSpace(title)
+}
@@ -0,0 +1,53 @@
+package apps
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Hepri/parental/internal/platform"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	long := strings.Repeat("я", 200)
+	tests := []struct {
+		app, title, want string
+	}{
+		{"chrome.exe", "Funny cats - YouTube - Google Chrome", "Funny cats - YouTube"},
+		{"firefox.exe", "(3) Inbox — Mozilla Firefox", "Inbox"},
+		{"msedge.exe", "Homework and 3 more pages - Microsoft​ Edge", "Homework"},
+		{"browser.exe", "Учи.ру — Яндекс Браузер", "Учи.ру"},
+		{"CHROME", "  News - Google Chrome  ", "News"},
+		// Only browsers lose suffixes and counters
+		{"notepad.exe", "(3) notes.txt - Notepad", "(3) notes.txt - Notepad"},
+		{"chrome.exe", "", ""},
+		{"notepad.exe", long, strings.Repeat("я", maxTitleLength) + "…"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTitle(tt.app, tt.title); got != tt.want {
+			t.Errorf("NormalizeTitle(%q, %q) = %q, want %q", tt.app, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestTitleFilter(t *testing.T) {
+	filter := NewTitleFilter([]string{"Alice"}, []*regexp.Regexp{regexp.MustCompile(`\d{4,}`), regexp.MustCompile(`(?i)secret`)})
+
+	tests := []struct {
+		user, app, title, want string
+	}{
+		{"alice", "chrome.exe", "Funny cats - YouTube - Google Chrome", "Funny cats - YouTube"},
+		{"ALICE", "chrome.exe", "Order 123456 - Shop - Google Chrome", "Order … - Shop"},
+		{"alice", "notepad.exe", "SECRET", "…"},
+		{"alice", "notepad.exe", "   ", ""},
+		// Titles of children who did not opt in are never recorded
+		{"bob", "chrome.exe", "Funny cats - YouTube - Google Chrome", ""},
+		{"", "explorer.exe", "Documents", ""},
+	}
+	for _, tt := range tests {
+		process := platform.Process{PID: 1, Name: tt.app, Title: tt.title, Username: tt.user}
+		if got := filter.Title(process); got != tt.want {
+			t.Errorf("Title(%s: %q) = %q, want %q", tt.user, tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Package atomicfile replaces files so that readers, and the file left behind by
+// a crash or power loss, see either the old content or the new one, never a mix.
+package atomicfile
+
+import "os"
+
+// WriteFile replaces path with data: the data is written and synced to a temporary
+// file next to path, which is then renamed over it. perm is the mode of the new file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	// A temporary file left over by a crash keeps its old mode
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
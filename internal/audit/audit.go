@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the audit log next to the executable.
+const FileName = "audit.jsonl"
+
+// System is the actor of actions the service takes on its own.
+const System int64 = 0
+
+// Actions recorded in the audit log.
+const (
+	ActionGrant           = "grant"
+	ActionExtend          = "extend"
+	ActionPause           = "pause"
+	ActionResume          = "resume"
+	ActionLock            = "lock"
+	ActionLockAll         = "lock_all"
+	ActionResetPassword   = "reset_password"
+	ActionExpired         = "session_expired"
+	ActionOutsideSchedule = "outside_schedule"
+	ActionSetQuota        = "set_quota"
+	ActionSetSchedule     = "set_schedule"
+	ActionSetHolidays     = "set_holidays"
+	ActionSetAppRules     = "set_app_rules"
+	ActionSetSites        = "set_blocked_sites"
+	ActionAppBlocked      = "app_blocked"
+	ActionAppLock         = "app_lock"
+	ActionRequestApprove  = "time_request_approved"
+	ActionRequestDeny     = "time_request_denied"
+	ActionRequestExpire   = "time_request_expired"
+	ActionShutdown        = "shutdown"
+	ActionCancelShutdown  = "shutdown_cancelled"
+	ActionAccessDenied    = "access_denied"
+	ActionForbidden       = "permission_denied"
+)
+
+// OutcomeOK is the outcome of an action that succeeded.
+const OutcomeOK = "ok"
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	UserID  int64                  `json:"user_id"`         // Telegram user who acted, System for the service itself
+	Child   string                 `json:"child,omitempty"` // child account the action concerns
+	Action  string                 `json:"action"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	Outcome string                 `json:"outcome"` // OutcomeOK or the error
+}
+
+// Log appends entries to a JSON lines file. Entries are never rewritten or removed.
+// A nil *Log discards everything, so components work without an audit log.
+type Log struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Record appends an entry for action; a nil err is recorded as OutcomeOK.
+func (l *Log) Record(userID int64, child, action string, params map[string]interface{}, err error) {
+	if l == nil {
+		return
+	}
+	entry := Entry{
+		Time:    time.Now(),
+		UserID:  userID,
+		Child:   child,
+		Action:  action,
+		Params:  params,
+		Outcome: OutcomeOK,
+	}
+	if err != nil {
+		entry.Outcome = err.Error()
+	}
+
+	if err := l.append(entry); err != nil {
+		log.Printf("Failed to write audit entry %s: %v", action, err)
+	}
+}
+
+func (l *Log) append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	// After a line cut short by a crash the entry starts on a line of its own
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Range returns the entries recorded in [from, to), oldest first. A zero bound is open.
+func (l *Log) Range(from, to time.Time) ([]Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A line cut short by a crash must not hide the rest of the log
+			log.Printf("Skipping malformed audit entry on line %d: %v", line, err)
+			continue
+		}
+		if !from.IsZero() && entry.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !entry.Time.Before(to) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return entries, nil
+}
+
+// Export writes the entries recorded in [from, to) to w, one JSON object per line.
+func (l *Log) Export(w io.Writer, from, to time.Time) error {
+	entries, err := l.Range(from, to)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
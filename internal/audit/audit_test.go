@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestLog(t *testing.T) *Log {
+	t.Helper()
+	return New(filepath.Join(t.TempDir(), FileName))
+}
+
+// appendAt appends an entry of action recorded at t.
+func appendAt(t *testing.T, l *Log, at time.Time, action string) {
+	t.Helper()
+	if err := l.append(Entry{Time: at, UserID: 7, Action: action, Outcome: OutcomeOK}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// actions returns the actions of entries in order.
+func actions(entries []Entry) []string {
+	var result []string
+	for _, e := range entries {
+		result = append(result, e.Action)
+	}
+	return result
+}
+
+func equalActions(got []Entry, want ...string) bool {
+	actions := actions(got)
+	if len(actions) != len(want) {
+		return false
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	l := newTestLog(t)
+	before := time.Now()
+	l.Record(7, "alice", ActionGrant, map[string]interface{}{"minutes": 30, "reason": "homework"}, nil)
+	l.Record(System, "bob", ActionExpired, nil, errors.New("session not found"))
+
+	entries, err := l.Range(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("read %d entries, want 2: %+v", len(entries), entries)
+	}
+	grant := entries[0]
+	if grant.UserID != 7 || grant.Child != "alice" || grant.Action != ActionGrant || grant.Outcome != OutcomeOK {
+		t.Errorf("first entry = %+v, want alice's grant by 7", grant)
+	}
+	// Numbers come back as JSON numbers
+	if grant.Params["minutes"] != float64(30) || grant.Params["reason"] != "homework" {
+		t.Errorf("params = %v, want the recorded ones", grant.Params)
+	}
+	if grant.Time.Before(before.Add(-time.Second)) || grant.Time.After(time.Now()) {
+		t.Errorf("recorded at %v, want now", grant.Time)
+	}
+	expired := entries[1]
+	if expired.UserID != System || expired.Outcome != "session not found" || expired.Params != nil {
+		t.Errorf("second entry = %+v, want the error as outcome", expired)
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	var l *Log
+	l.Record(7, "alice", ActionGrant, nil, nil)
+	if entries, err := l.Range(time.Time{}, time.Time{}); entries != nil || err != nil {
+		t.Errorf("Range() of a nil log = %v, %v, want nothing", entries, err)
+	}
+	if entries, err := newTestLog(t).Range(time.Time{}, time.Time{}); entries != nil || err != nil {
+		t.Errorf("Range() without a file = %v, %v, want nothing", entries, err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	l := newTestLog(t)
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	appendAt(t, l, day.Add(-time.Second), "before")
+	appendAt(t, l, day, "start")
+	appendAt(t, l, day.Add(12*time.Hour), "noon")
+	appendAt(t, l, day.AddDate(0, 0, 1).Add(-time.Nanosecond), "last")
+	appendAt(t, l, day.AddDate(0, 0, 1), "next")
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"everything", time.Time{}, time.Time{}, []string{"before", "start", "noon", "last", "next"}},
+		{"one day", day, day.AddDate(0, 0, 1), []string{"start", "noon", "last"}},
+		{"from only", day.Add(12 * time.Hour), time.Time{}, []string{"noon", "last", "next"}},
+		{"to only", time.Time{}, day, []string{"before"}},
+		{"empty", day.Add(time.Hour), day.Add(2 * time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Range(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalActions(entries, tt.want...) {
+				t.Errorf("Range() = %v, want %v", actions(entries), tt.want)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	l := newTestLog(t)
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	appendAt(t, l, day.Add(-time.Hour), "before")
+	grant := Entry{Time: day.Add(time.Hour), UserID: 7, Child: "alice", Action: ActionGrant, Params: map[string]interface{}{"minutes": 30}, Outcome: OutcomeOK}
+	if err := l.append(grant); err != nil {
+		t.Fatal(err)
+	}
+	appendAt(t, l, day.Add(2*time.Hour), ActionLock)
+
+	var out bytes.Buffer
+	if err := l.Export(&out, day, day.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("exported %d lines, want 2:\n%s", len(lines), out.String())
+	}
+	var first Entry
+	if err := json.Unmarshal(lines[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Child != "alice" || first.Action != ActionGrant || first.Params["minutes"] != float64(30) || !first.Time.Equal(day.Add(time.Hour)) {
+		t.Errorf("first exported entry = %+v, want alice's grant", first)
+	}
+
+	out.Reset()
+	if err := newTestLog(t).Export(&out, time.Time{}, time.Time{}); err != nil || out.Len() != 0 {
+		t.Errorf("Export() without entries = %q, %v, want nothing", out.String(), err)
+	}
+}
+
+func TestTruncatedLastLine(t *testing.T) {
+	l := newTestLog(t)
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	appendAt(t, l, day, "first")
+	appendAt(t, l, day.Add(time.Hour), "second")
+	// A crash in the middle of writing the next entry
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"time":"2026-10-12T02:00:00Z","user_id":7,"act`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// The cut entry is skipped, the ones before it are kept
+	entries, err := l.Range(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalActions(entries, "first", "second") {
+		t.Errorf("Range() = %v, want the complete entries", actions(entries))
+	}
+
+	// Entries recorded afterwards are not glued to the cut one
+	appendAt(t, l, day.Add(3*time.Hour), "third")
+	appendAt(t, l, day.Add(4*time.Hour), "fourth")
+	if entries, err = l.Range(time.Time{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if !equalActions(entries, "first", "second", "third", "fourth") {
+		t.Errorf("Range() after appending = %v, want the new entries too", actions(entries))
+	}
+}
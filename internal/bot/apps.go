@@ -0,0 +1,410 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+)
+
+func (tb *TelegramBot) handleApps(data string, chatID int64, messageID int, parent *config.Parent) error {
+	switch {
+	case data == "apps_menu":
+		return tb.showAppsMenu(chatID, messageID, parent)
+	case strings.HasPrefix(data, "apps_user_"):
+		return tb.showChildApps(chatID, messageID, strings.TrimPrefix(data, "apps_user_"))
+	case strings.HasPrefix(data, "apps_action_"):
+		username := strings.TrimPrefix(data, "apps_action_")
+		rules := tb.childAppRules(username)
+		if rules == nil {
+			rules = &config.AppRules{}
+		}
+		// Cycle close -> minimize -> lock -> close
+		switch rules.Action {
+		case config.AppActionMinimize:
+			rules.Action = config.AppActionLock
+		case config.AppActionLock:
+			rules.Action = config.AppActionClose
+		default:
+			rules.Action = config.AppActionMinimize
+		}
+		if err := tb.updateAppRules(parent.UserID, username, rules); err != nil {
+			msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось сохранить правила для %s: %v", username, err))
+			tb.bot.Send(msg)
+			return err
+		}
+		return tb.showChildApps(chatID, messageID, username)
+	case strings.HasPrefix(data, "apps_block_"), strings.HasPrefix(data, "apps_allow_"),
+		strings.HasPrefix(data, "apps_limit_"), strings.HasPrefix(data, "apps_remove_"):
+		state, username, _ := strings.Cut(strings.TrimPrefix(data, "apps_"), "_")
+		tb.userStates[chatID] = "apps_" + state
+		tb.userData[chatID] = map[string]interface{}{
+			"selected_user": username,
+		}
+
+		var prompt string
+		switch state {
+		case "block":
+			prompt = "Введите имена программ через запятую, например:\n`Roblox.exe, Fortnite.exe`"
+		case "allow":
+			prompt = "Введите программы, которые разрешены, через запятую, например:\n`chrome.exe, WINWORD.EXE`\n\nОстальные программы будут закрываться. Проводник и системные окна разрешены всегда.\nЧтобы снять ограничение, отправьте `-`"
+		case "limit":
+			prompt = "Введите программу и лимит в минутах в день, например:\n`Minecraft.exe 60`\n\nЧтобы снять лимит: `Minecraft.exe 0`"
+			if categories := tb.categoryNames(); len(categories) > 0 {
+				prompt += "\n\nВместо программы можно указать категорию: " + formatAppList(categories)
+			}
+		default:
+			prompt = "Введите имя программы, правила для которой нужно удалить."
+		}
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⌨️ *Программы*\n\nПользователь: *%s*\n\n%s", username, prompt))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+				{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "apps_user_"+username)},
+			},
+		}
+		_, err := tb.bot.Send(msg)
+		return err
+	default:
+		return nil
+	}
+}
+
+func (tb *TelegramBot) showAppsMenu(chatID int64, messageID int, parent *config.Parent) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+
+	for _, account := range tb.children(parent) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(account.FullName, "apps_user_"+account.Username),
+		))
+	}
+
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "🚫 *Программы*\n\nВыберите аккаунт ребёнка:")
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &keyboard
+	_, err := tb.bot.Send(editMsg)
+	return err
+}
+
+func (tb *TelegramBot) showChildApps(chatID int64, messageID int, username string) error {
+	rules := tb.childAppRules(username)
+
+	var msgText strings.Builder
+	msgText.WriteString(fmt.Sprintf("🚫 *Программы*\n\nПользователь: *%s*\n\n", username))
+	if rules.Empty() {
+		msgText.WriteString("Ограничений нет — разрешены любые программы.\n")
+	} else {
+		if len(rules.Blocked) > 0 {
+			msgText.WriteString("Запрещены: " + formatAppList(rules.Blocked) + "\n")
+		}
+		if len(rules.Allowed) > 0 {
+			msgText.WriteString("Разрешены только: " + formatAppList(rules.Allowed) + "\n")
+		}
+		if limits := tb.formatAppLimits(username); limits != "" {
+			msgText.WriteString("\nЛимиты в день:\n" + limits)
+		}
+	}
+
+	action := "закрывать"
+	if rules != nil && rules.Action == config.AppActionMinimize {
+		action = "сворачивать"
+	} else if rules != nil && rules.Action == config.AppActionLock {
+		action = "блокировать сеанс"
+	}
+	msgText.WriteString(fmt.Sprintf("\nПри нарушении: %s", action))
+
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("➕ Запретить", "apps_block_"+username),
+			tgbotapi.NewInlineKeyboardButtonData("⏱ Лимит", "apps_limit_"+username),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("✅ Только разрешённые", "apps_allow_"+username),
+		},
+	}
+	if !rules.Empty() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить правило", "apps_remove_"+username),
+		))
+	}
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔁 Изменить действие", "apps_action_"+username)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "apps_menu")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	if messageID > 0 {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+		editMsg.ParseMode = "Markdown"
+		editMsg.ReplyMarkup = &keyboard
+		_, err := tb.bot.Send(editMsg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, msgText.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+// formatAppLimits lists today's usage of username against each application and
+// category limit, one Markdown line each.
+func (tb *TelegramBot) formatAppLimits(username string) string {
+	if tb.enforcer == nil {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, status := range tb.enforcer.LimitStatus(username) {
+		name := "`" + status.Name + "`"
+		if status.Category {
+			name = tgbotapi.EscapeText(tgbotapi.ModeMarkdown, categoryTitle(status.Name))
+		}
+		mark := ""
+		if status.Used >= status.Limit {
+			mark = " ⛔"
+		}
+		text.WriteString(fmt.Sprintf("• %s: %d из %d мин%s\n", name, int(status.Used/time.Minute), int(status.Limit/time.Minute), mark))
+	}
+	return text.String()
+}
+
+// categoryNames returns the application categories limits may refer to.
+func (tb *TelegramBot) categoryNames() []string {
+	return tb.classifier.Categories()
+}
+
+// editAppRules applies the text entered by the parent in state to the rules of the selected child.
+func (tb *TelegramBot) editAppRules(message *tgbotapi.Message, state string) error {
+	chatID := message.Chat.ID
+
+	userData, ok := tb.userData[chatID]
+	username, _ := userData["selected_user"].(string)
+	if !ok || username == "" {
+		delete(tb.userStates, message.From.ID)
+		msg := tgbotapi.NewMessage(chatID, "Выберите ребёнка в меню «🚫 Программы».")
+		_, err := tb.bot.Send(msg)
+		return err
+	}
+
+	rules := tb.childAppRules(username)
+	if rules == nil {
+		rules = &config.AppRules{}
+	}
+
+	text := strings.TrimSpace(message.Text)
+	switch state {
+	case "apps_block":
+		names := parseAppList(text)
+		if len(names) == 0 {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Введите хотя бы одно имя программы."))
+			return nil
+		}
+		for _, name := range names {
+			rules.Blocked = addApp(rules.Blocked, name)
+		}
+	case "apps_allow":
+		if text == "-" {
+			rules.Allowed = nil
+			break
+		}
+		names := parseAppList(text)
+		if len(names) == 0 {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Введите хотя бы одно имя программы или «-»."))
+			return nil
+		}
+		rules.Allowed = nil
+		for _, name := range names {
+			rules.Allowed = addApp(rules.Allowed, name)
+		}
+	case "apps_limit":
+		name, minutes, err := parseAppLimit(text)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %v\n\nПример: `Minecraft.exe 60`", err))
+			msg.ParseMode = "Markdown"
+			tb.bot.Send(msg)
+			return nil
+		}
+		if category := tb.findCategory(name); category != "" {
+			delete(rules.CategoryLimits, category)
+			if minutes > 0 {
+				if rules.CategoryLimits == nil {
+					rules.CategoryLimits = make(map[string]int)
+				}
+				rules.CategoryLimits[category] = minutes
+			}
+			break
+		}
+		for app := range rules.Limits {
+			if apps.SameApp(app, name) {
+				delete(rules.Limits, app)
+			}
+		}
+		if minutes > 0 {
+			if rules.Limits == nil {
+				rules.Limits = make(map[string]int)
+			}
+			rules.Limits[name] = minutes
+		}
+	case "apps_remove":
+		if text == "" {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Введите имя программы."))
+			return nil
+		}
+		rules.Blocked = removeApp(rules.Blocked, text)
+		rules.Allowed = removeApp(rules.Allowed, text)
+		for app := range rules.Limits {
+			if apps.SameApp(app, text) {
+				delete(rules.Limits, app)
+			}
+		}
+		if category := tb.findCategory(text); category != "" {
+			delete(rules.CategoryLimits, category)
+		}
+	}
+
+	delete(tb.userStates, message.From.ID)
+	delete(tb.userData, chatID)
+
+	if err := tb.updateAppRules(message.From.ID, username, rules); err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось сохранить правила для %s: %v", username, err))
+		tb.bot.Send(msg)
+		return err
+	}
+	return tb.showChildApps(chatID, 0, username)
+}
+
+// findCategory returns the category named name, or "".
+func (tb *TelegramBot) findCategory(name string) string {
+	for _, category := range tb.classifier.Categories() {
+		if strings.EqualFold(category, name) {
+			return category
+		}
+	}
+	return ""
+}
+
+// childAppRules returns a copy of the configured application rules of username, or nil.
+func (tb *TelegramBot) childAppRules(username string) *config.AppRules {
+	for _, acc := range tb.currentConfig().ChildAccounts {
+		if acc.Username == username {
+			return acc.Apps.Clone()
+		}
+	}
+	return nil
+}
+
+// updateAppRules stores the rules in config.json and applies them to the enforcer.
+// The change is recorded in the audit log on behalf of actor.
+func (tb *TelegramBot) updateAppRules(actor int64, username string, rules *config.AppRules) (err error) {
+	// Rules that restrict nothing and keep the default action are dropped from config
+	if rules.Empty() && (rules == nil || rules.Action == "" || rules.Action == config.AppActionClose) {
+		rules = nil
+	}
+	defer func() {
+		tb.audit.Record(actor, username, audit.ActionSetAppRules, map[string]interface{}{"apps": rules}, err)
+	}()
+
+	if err := tb.updateChild(username, func(account *config.ChildAccount) { account.Apps = rules }); err != nil {
+		return err
+	}
+
+	if tb.enforcer != nil {
+		tb.enforcer.SetRules(username, rules)
+	}
+	return nil
+}
+
+// NotifyAppViolation tells parents that an application of a child was closed or minimized.
+func (tb *TelegramBot) NotifyAppViolation(v apps.Violation) {
+	var reason string
+	switch v.Reason {
+	case apps.ReasonLimit:
+		reason = fmt.Sprintf("дневной лимит %d мин исчерпан", int(v.Limit/time.Minute))
+	case apps.ReasonCategory:
+		reason = fmt.Sprintf("дневной лимит категории «%s» (%d мин) исчерпан", v.Category, int(v.Limit/time.Minute))
+	case apps.ReasonNotAllowed:
+		reason = "программы нет в списке разрешённых"
+	default:
+		reason = "программа запрещена"
+	}
+
+	title := "Программа закрыта"
+	switch v.Action {
+	case config.AppActionMinimize:
+		title = "Программа свёрнута"
+	case config.AppActionLock:
+		title = "Сеанс заблокирован из-за программы"
+	}
+	text := fmt.Sprintf("🚫 *%s*\n\nПользователь: %s\nПрограмма: `%s`\nПричина: %s", title, v.Username, v.App, reason)
+	if v.Err != nil {
+		text = fmt.Sprintf("⚠️ *Не удалось остановить программу*\n\nПользователь: %s\nПрограмма: `%s`\nПричина: %s\nОшибка: %v", v.Username, v.App, reason, v.Err)
+	}
+	tb.notifyParents(v.Username, text)
+}
+
+// parseAppList splits a comma or newline separated list of executable names.
+func parseAppList(text string) []string {
+	var names []string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if name := strings.TrimSpace(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseAppLimit parses "<executable> <minutes>"; the name may contain spaces.
+func parseAppLimit(text string) (string, int, error) {
+	i := strings.LastIndexAny(text, " \t")
+	if i < 0 {
+		return "", 0, fmt.Errorf("Укажите программу и число минут")
+	}
+	name := strings.TrimSpace(text[:i])
+	minutes, err := strconv.Atoi(strings.TrimSpace(text[i+1:]))
+	if name == "" || err != nil || minutes < 0 || minutes > 1440 {
+		return "", 0, fmt.Errorf("Укажите программу и число минут от 0 до 1440")
+	}
+	return name, minutes, nil
+}
+
+func addApp(list []string, name string) []string {
+	for _, app := range list {
+		if apps.SameApp(app, name) {
+			return list
+		}
+	}
+	return append(list, name)
+}
+
+func removeApp(list []string, name string) []string {
+	var result []string
+	for _, app := range list {
+		if !apps.SameApp(app, name) {
+			result = append(result, app)
+		}
+	}
+	return result
+}
+
+func formatAppList(list []string) string {
+	parts := make([]string, len(list))
+	for i, app := range list {
+		parts[i] = "`" + app + "`"
+	}
+	return strings.Join(parts, ", ")
+}
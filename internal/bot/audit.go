@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+)
+
+const (
+	defaultAuditEntries = 20
+	maxAuditEntries     = 100
+	maxMessageLength    = 4000 // Telegram allows 4096 characters per message
+)
+
+// showAudit sends the newest audit entries about the children parent manages.
+// args is the optional number of entries from "/audit N".
+func (tb *TelegramBot) showAudit(chatID int64, parent *config.Parent, args string) error {
+	if !can(parent, permAudit) {
+		tb.audit.Record(parent.UserID, "", audit.ActionForbidden, map[string]interface{}{"command": "/audit"}, nil)
+		_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, "⛔ Недостаточно прав для просмотра журнала."))
+		return err
+	}
+
+	n := defaultAuditEntries
+	if args != "" {
+		var err error
+		if n, err = strconv.Atoi(args); err != nil || n < 1 || n > maxAuditEntries {
+			_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Укажите число записей от 1 до %d, например: /audit 20", maxAuditEntries)))
+			return err
+		}
+	}
+
+	// Read everything: entries about other children are filtered out below
+	entries, err := tb.audit.Range(time.Time{}, time.Time{})
+	if err != nil {
+		_, sendErr := tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось прочитать журнал: %v", err)))
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	var lines []string
+	for i := len(entries) - 1; i >= 0 && len(lines) < n; i-- {
+		e := entries[i]
+		if (e.Child == "" && !parent.ManagesAll()) || (e.Child != "" && !parent.Manages(e.Child)) {
+			continue
+		}
+		lines = append(lines, tb.formatAuditEntry(e))
+	}
+
+	if len(lines) == 0 {
+		_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, "📜 Журнал действий пуст."))
+		return err
+	}
+
+	// Newest first; drop the oldest lines that do not fit into one message
+	text := fmt.Sprintf("📜 Журнал действий (последние %d):\n", len(lines))
+	for _, line := range lines {
+		if len(text)+len(line)+1 > maxMessageLength {
+			break
+		}
+		text += "\n" + line
+	}
+
+	// Plain text: actions and parameters contain characters Markdown would interpret
+	_, err = tb.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// formatAuditEntry renders e as one line of the /audit reply.
+func (tb *TelegramBot) formatAuditEntry(e audit.Entry) string {
+	parts := []string{e.Time.Local().Format("02.01 15:04"), tb.actorName(e.UserID), e.Action}
+	if e.Child != "" {
+		parts = append(parts, e.Child)
+	}
+	if params := formatAuditParams(e.Params); params != "" {
+		parts = append(parts, params)
+	}
+	outcome := "✅"
+	if e.Outcome != audit.OutcomeOK {
+		outcome = "❌ " + e.Outcome
+	}
+	return strings.Join(parts, " · ") + " " + outcome
+}
+
+// actorName returns the configured name of the Telegram user userID.
+func (tb *TelegramBot) actorName(userID int64) string {
+	if userID == audit.System {
+		return "сервис"
+	}
+	if parent := tb.currentConfig().FindParent(userID); parent != nil && parent.Name != "" {
+		return parent.Name
+	}
+	return strconv.FormatInt(userID, 10)
+}
+
+func formatAuditParams(params map[string]interface{}) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		value := fmt.Sprint(params[k])
+		if _, ok := params[k].(string); !ok {
+			if data, err := json.Marshal(params[k]); err == nil {
+				value = string(data)
+			}
+		}
+		parts = append(parts, k+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
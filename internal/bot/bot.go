@@ -1,30 +1,42 @@
-//go:build windows
-
 package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/audit"
 	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/request"
 	"github.com/Hepri/parental/internal/session"
 	"github.com/Hepri/parental/internal/shutdown"
+	"github.com/Hepri/parental/internal/sites"
 	"github.com/Hepri/parental/internal/tracker"
 )
 
 type TelegramBot struct {
 	bot               *tgbotapi.BotAPI
-	config            *config.Config
+	config            *config.Config // replaced as a whole on changes, see updateConfig
+	configMutex       sync.RWMutex
 	sessionMgr        *session.Manager
 	tracker           *tracker.TimeTracker
 	shutdownMgr       *shutdown.ShutdownManager
+	requests          *request.Broker
+	enforcer          *apps.Enforcer
+	classifier        *apps.Classifier
+	sites             *sites.Filter
+	audit             *audit.Log
+	requestMessages   map[string][]sentMessage // time request ID -> messages sent to parents
+	requestMutex      sync.Mutex
 	userStates        map[int64]string                 // userID -> state
 	userData          map[int64]map[string]interface{} // userID -> data
 	reconnectAttempts int                              // Количество попыток переподключения
@@ -37,26 +49,79 @@ type BotCommand struct {
 	Handler     func(update tgbotapi.Update) error
 }
 
-func NewBot(cfg *config.Config, sessionMgr *session.Manager, tracker *tracker.TimeTracker, shutdownMgr *shutdown.ShutdownManager) (*TelegramBot, error) {
+func NewBot(cfg *config.Config, sessionMgr *session.Manager, tracker *tracker.TimeTracker, shutdownMgr *shutdown.ShutdownManager, requests *request.Broker, enforcer *apps.Enforcer, siteFilter *sites.Filter, auditLog *audit.Log) (*TelegramBot, error) {
 	// Не создаем подключение здесь - это будет сделано в connectAndRun()
 	// Это позволяет создать бота даже при отсутствии интернета
-	return &TelegramBot{
+	tb := &TelegramBot{
 		bot:               nil, // Будет создан при первом подключении
 		config:            cfg,
 		sessionMgr:        sessionMgr,
 		tracker:           tracker,
 		shutdownMgr:       shutdownMgr,
+		requests:          requests,
+		enforcer:          enforcer,
+		classifier:        apps.NewClassifier(cfg.AppLabels, cfg.AppCategories),
+		sites:             siteFilter,
+		audit:             auditLog,
+		requestMessages:   make(map[string][]sentMessage),
 		userStates:        make(map[int64]string),
 		userData:          make(map[int64]map[string]interface{}),
 		reconnectAttempts: 0,
 		isConnected:       false,
-	}, nil
+	}
+	if requests != nil {
+		requests.SetHandler(tb)
+	}
+	if enforcer != nil {
+		enforcer.SetHandler(tb.NotifyAppViolation)
+	}
+	return tb, nil
+}
+
+// currentConfig returns the configuration in effect. It is shared with other
+// goroutines and must not be modified; changes go through updateConfig.
+func (tb *TelegramBot) currentConfig() *config.Config {
+	tb.configMutex.RLock()
+	defer tb.configMutex.RUnlock()
+	return tb.config
+}
+
+// updateConfig applies change to a copy of the configuration, saves the copy to
+// config.json and makes it current, so that readers never see a half-made change.
+// change may replace child accounts and fields, but not modify what they point to.
+func (tb *TelegramBot) updateConfig(change func(cfg *config.Config) error) error {
+	tb.configMutex.Lock()
+	defer tb.configMutex.Unlock()
+
+	updated := *tb.config
+	updated.ChildAccounts = append([]config.ChildAccount(nil), tb.config.ChildAccounts...)
+	if err := change(&updated); err != nil {
+		return err
+	}
+	if err := config.SaveConfig(&updated); err != nil {
+		return err
+	}
+	tb.config = &updated
+	return nil
+}
+
+// updateChild applies change to the configuration of the child account username, see updateConfig.
+func (tb *TelegramBot) updateChild(username string, change func(account *config.ChildAccount)) error {
+	return tb.updateConfig(func(cfg *config.Config) error {
+		for i := range cfg.ChildAccounts {
+			if cfg.ChildAccounts[i].Username == username {
+				change(&cfg.ChildAccounts[i])
+				return nil
+			}
+		}
+		return fmt.Errorf("child account %s not found", username)
+	})
 }
 
 func (tb *TelegramBot) Start(ctx context.Context) error {
 	log.Printf("Starting Telegram bot with reconnect mechanism...")
 	log.Printf("Reconnect settings: interval=%ds, max_attempts=%s",
-		tb.config.ReconnectInterval, tb.getMaxAttemptsString())
+		tb.currentConfig().ReconnectInterval, tb.getMaxAttemptsString())
 
 	// Бесконечный цикл переподключения - программа не останавливается при отсутствии интернета
 	for {
@@ -74,14 +139,14 @@ func (tb *TelegramBot) Start(ctx context.Context) error {
 				// Всегда продолжаем попытки переподключения (бесконечно)
 				// shouldReconnect() проверяет MaxReconnectAttempts, но по умолчанию оно = 0 (бесконечно)
 				if !tb.shouldReconnect() {
-					log.Printf("Max reconnect attempts reached (%d), but continuing anyway...", tb.config.MaxReconnectAttempts)
+					log.Printf("Max reconnect attempts reached (%d), but continuing anyway...", tb.currentConfig().MaxReconnectAttempts)
 					// Даже если достигнут максимум, продолжаем попытки - программа не должна останавливаться
 				}
 
 				// Увеличиваем счетчик попыток
 				tb.reconnectAttempts++
 				log.Printf("Attempting to reconnect in %d seconds (attempt %d/%s)...",
-					tb.config.ReconnectInterval,
+					tb.currentConfig().ReconnectInterval,
 					tb.reconnectAttempts,
 					tb.getMaxAttemptsString())
 
@@ -90,7 +155,7 @@ func (tb *TelegramBot) Start(ctx context.Context) error {
 				case <-ctx.Done():
 					log.Println("Context cancelled during reconnect wait")
 					return nil
-				case <-time.After(time.Duration(tb.config.ReconnectInterval) * time.Second):
+				case <-time.After(time.Duration(tb.currentConfig().ReconnectInterval) * time.Second):
 					continue
 				}
 			} else {
@@ -120,7 +185,7 @@ func (tb *TelegramBot) connectAndRun(ctx context.Context) error {
 		}
 
 		// Создаем новый экземпляр бота
-		bot, err := tgbotapi.NewBotAPI(tb.config.TelegramBotToken)
+		bot, err := tgbotapi.NewBotAPI(tb.currentConfig().TelegramBotToken)
 		if err != nil {
 			return fmt.Errorf("failed to create bot connection: %v", err)
 		}
@@ -192,20 +257,20 @@ func (tb *TelegramBot) runMessageLoop(ctx context.Context) error {
 // shouldReconnect определяет, нужно ли продолжать попытки переподключения
 func (tb *TelegramBot) shouldReconnect() bool {
 	// Если MaxReconnectAttempts = 0, то бесконечные попытки
-	if tb.config.MaxReconnectAttempts == 0 {
+	if tb.currentConfig().MaxReconnectAttempts == 0 {
 		return true
 	}
 
 	// Проверяем, не превышено ли максимальное количество попыток
-	return tb.reconnectAttempts < tb.config.MaxReconnectAttempts
+	return tb.reconnectAttempts < tb.currentConfig().MaxReconnectAttempts
 }
 
 // getMaxAttemptsString возвращает строковое представление максимального количества попыток
 func (tb *TelegramBot) getMaxAttemptsString() string {
-	if tb.config.MaxReconnectAttempts == 0 {
+	if tb.currentConfig().MaxReconnectAttempts == 0 {
 		return "∞"
 	}
-	return fmt.Sprintf("%d", tb.config.MaxReconnectAttempts)
+	return fmt.Sprintf("%d", tb.currentConfig().MaxReconnectAttempts)
 }
 
 // isCriticalError определяет, является ли ошибка критической для переподключения
@@ -273,7 +338,9 @@ func (tb *TelegramBot) handleUpdate(update tgbotapi.Update) error {
 	}
 
 	// Check authorization
-	if !tb.isAuthorized(userID) {
+	parent := tb.currentConfig().FindParent(userID)
+	if parent == nil {
+		tb.audit.Record(userID, "", audit.ActionAccessDenied, nil, nil)
 		msg := tgbotapi.NewMessage(chatID, "⛔ Доступ запрещён. Этот бот предназначен только для авторизованных родителей.")
 		tb.bot.Send(msg)
 		return nil
@@ -281,106 +348,157 @@ func (tb *TelegramBot) handleUpdate(update tgbotapi.Update) error {
 
 	// Handle callback queries
 	if update.CallbackQuery != nil {
-		return tb.handleCallbackQuery(update.CallbackQuery)
+		return tb.handleCallbackQuery(update.CallbackQuery, parent)
 	}
 
 	// Handle text messages
 	if update.Message != nil {
-		return tb.handleMessage(update.Message)
+		return tb.handleMessage(update.Message, parent)
 	}
 
 	return nil
 }
 
-func (tb *TelegramBot) isAuthorized(userID int64) bool {
-	for _, authorizedID := range tb.config.AuthorizedUserIDs {
-		if userID == authorizedID {
-			return true
-		}
-	}
-	return false
-}
-
-func (tb *TelegramBot) handleMessage(message *tgbotapi.Message) error {
+func (tb *TelegramBot) handleMessage(message *tgbotapi.Message, parent *config.Parent) error {
 	text := message.Text
 	chatID := message.Chat.ID
 
-	switch text {
-	case "/start":
-		return tb.showMainMenu(chatID)
+	switch {
+	case text == "/start":
+		return tb.showMainMenu(chatID, parent)
+	case text == "/audit" || strings.HasPrefix(text, "/audit "):
+		return tb.showAudit(chatID, parent, strings.TrimSpace(strings.TrimPrefix(text, "/audit")))
+	case text == "/digest" || text == "/digest week":
+		return tb.sendDigestNow(chatID, parent, text == "/digest week")
+	case text == "/export" || strings.HasPrefix(text, "/export "):
+		return tb.handleExportCommand(chatID, parent, strings.TrimPrefix(text, "/export"))
 	default:
 		// Check if user is in a state that expects input
 		if state, exists := tb.userStates[message.From.ID]; exists {
-			return tb.handleStateInput(message, state)
+			return tb.handleStateInput(message, state, parent)
 		}
 
 		// Unknown command
-		msg := tgbotapi.NewMessage(chatID, "Неизвестная команда. Используйте /start, чтобы открыть главное меню.")
+		msg := tgbotapi.NewMessage(chatID, "Неизвестная команда. Используйте /start, чтобы открыть главное меню, /audit N, чтобы посмотреть последние N записей журнала, или /export, чтобы выгрузить отчёт в файл.")
 		tb.bot.Send(msg)
 		return nil
 	}
 }
 
-func (tb *TelegramBot) handleCallbackQuery(query *tgbotapi.CallbackQuery) error {
+func (tb *TelegramBot) handleCallbackQuery(query *tgbotapi.CallbackQuery, parent *config.Parent) error {
 	data := query.Data
 	chatID := query.Message.Chat.ID
 	messageID := query.Message.MessageID
 
+	if !callbackAllowed(parent, data) {
+		log.Printf("User %d (%s) is not allowed to use %q", parent.UserID, parent.Role, data)
+		tb.audit.Record(parent.UserID, "", audit.ActionForbidden, map[string]interface{}{"callback": data}, nil)
+		tb.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID, "⛔ Недостаточно прав для этого действия."))
+		return nil
+	}
+
 	// Answer callback query
 	callback := tgbotapi.NewCallback(query.ID, "")
 	tb.bot.Request(callback)
 
 	switch {
 	case data == "lock_all":
-		return tb.handleLockAllNow(chatID, messageID)
+		return tb.handleLockAllNow(chatID, messageID, parent)
 	case strings.HasPrefix(data, "grant_"):
-		return tb.handleGrantAccess(data, chatID, messageID)
+		return tb.handleGrantAccess(data, chatID, messageID, parent)
 	case strings.HasPrefix(data, "duration_"):
-		return tb.handleDurationSelection(data, chatID, messageID)
+		return tb.handleDurationSelection(data, chatID, messageID, parent)
 	case strings.HasPrefix(data, "lock_"):
-		return tb.handleLockSession(data, chatID, messageID)
+		return tb.handleLockSession(data, chatID, messageID, parent)
 	case strings.HasPrefix(data, "extend_"):
-		return tb.handleExtendSession(data, chatID, messageID)
+		return tb.handleExtendSession(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "pause_"):
+		return tb.handlePauseSession(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "resume_"):
+		return tb.handleResumeSession(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "treq_"):
+		return tb.handleTimeRequest(data, query.From, parent, chatID, messageID)
+	case strings.HasPrefix(data, "quota_"):
+		return tb.handleQuota(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "sched_"):
+		return tb.handleSchedule(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "apps_"):
+		return tb.handleApps(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "sites_"):
+		return tb.handleSites(data, chatID, messageID, parent)
 	case data == "resetpw_all":
-		return tb.handleResetAllPasswords(chatID, messageID)
+		return tb.handleResetAllPasswords(chatID, messageID, parent)
 	case strings.HasPrefix(data, "resetpw_"):
-		return tb.handleResetPassword(data, chatID, messageID)
+		return tb.handleResetPassword(data, chatID, messageID, parent)
 	case data == "stats_menu":
-		return tb.showStatsMenu(chatID, messageID)
-	case data == "stats_today":
-		return tb.showTodayStats(chatID, messageID)
-	case data == "stats_week":
-		return tb.showWeekStats(chatID, messageID)
+		return tb.showStatsMenu(chatID, messageID, parent)
+	case strings.HasPrefix(data, "stats_child_"):
+		return tb.showChildStatsMenu(chatID, messageID, strings.TrimPrefix(data, "stats_child_"))
+	case strings.HasPrefix(data, "stats_cat_"):
+		return tb.handleCategoryStats(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "stats_tl_"):
+		return tb.handleTimeline(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "stats_bars_"):
+		return tb.sendWeekChart(chatID, strings.TrimPrefix(data, "stats_bars_"))
+	case strings.HasPrefix(data, "stats_pie_"):
+		return tb.sendAppsChart(chatID, strings.TrimPrefix(data, "stats_pie_"))
+	case strings.HasPrefix(data, "stats_expf_"):
+		return tb.handleExport(data, chatID, messageID, parent)
+	case strings.HasPrefix(data, "stats_exp_"):
+		return tb.showExportMenu(chatID, messageID, strings.TrimPrefix(data, "stats_exp_"))
+	case strings.HasPrefix(data, "stats_today"):
+		return tb.showTodayStats(chatID, messageID, strings.TrimPrefix(strings.TrimPrefix(data, "stats_today"), "_"))
+	case strings.HasPrefix(data, "stats_week"):
+		return tb.showWeekStats(chatID, messageID, strings.TrimPrefix(strings.TrimPrefix(data, "stats_week"), "_"))
 	case data == "computer_menu":
-		return tb.showComputerMenu(chatID, messageID)
+		return tb.showComputerMenu(chatID, messageID, parent)
 	case data == "computer_status":
-		return tb.showComputerStatus(chatID, messageID)
+		return tb.showComputerStatus(chatID, messageID, parent)
 	case data == "shutdown_now":
-		return tb.shutdownNow(chatID, messageID)
+		return tb.shutdownNow(chatID, messageID, parent)
 	case strings.HasPrefix(data, "shutdown_"):
-		return tb.scheduleShutdown(data, chatID, messageID)
+		return tb.scheduleShutdown(data, chatID, messageID, parent)
 	case data == "cancel_shutdown":
-		return tb.cancelShutdown(chatID, messageID)
+		return tb.cancelShutdown(chatID, messageID, parent)
 	case data == "resetpw_menu":
-		return tb.showResetPasswordMenu(chatID, messageID)
+		return tb.showResetPasswordMenu(chatID, messageID, parent)
 	case data == "main_menu":
-		return tb.showMainMenu(chatID)
+		return tb.showMainMenu(chatID, parent)
 	default:
 		return nil
 	}
 }
 
-func (tb *TelegramBot) showMainMenu(chatID int64) error {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
+func (tb *TelegramBot) showMainMenu(chatID int64, parent *config.Parent) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+
+	if can(parent, permSessions) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🟢 Выдать доступ", "grant_menu"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔒 Завершить сеанс", "lock_all"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
+		))
+		// Locking everyone at once is only offered to those who manage every child
+		lockButton := tgbotapi.NewInlineKeyboardButtonData("🔒 Завершить сеанс", "lock_all")
+		if !parent.ManagesAll() {
+			lockButton = tgbotapi.NewInlineKeyboardButtonData("🔒 Сеансы", "lock_menu")
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(lockButton))
+	}
+	if can(parent, permPasswords) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔁 Сбросить пароль", "resetpw_menu"),
-		),
+		))
+	}
+	if can(parent, permSettings) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏳ Дневные лимиты", "quota_menu"),
+			tgbotapi.NewInlineKeyboardButtonData("🕒 Расписание", "sched_menu"),
+		))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Программы", "apps_menu"),
+			tgbotapi.NewInlineKeyboardButtonData("🌐 Сайты", "sites_menu"),
+		))
+	}
+	buttons = append(buttons,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📊 Статистика", "stats_menu"),
 		),
@@ -388,8 +506,9 @@ func (tb *TelegramBot) showMainMenu(chatID int64) error {
 			tgbotapi.NewInlineKeyboardButtonData("⚙️ Управление компьютером", "computer_menu"),
 		),
 	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
 
-	msg := tgbotapi.NewMessage(chatID, "🏠 *Родительский контроль*\n\nВыберите действие:")
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🏠 *Родительский контроль*\n\nВаша роль: %s\nВыберите действие:", roleTitles[parent.Role]))
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = keyboard
 
@@ -397,15 +516,17 @@ func (tb *TelegramBot) showMainMenu(chatID int64) error {
 	return err
 }
 
-func (tb *TelegramBot) showResetPasswordMenu(chatID int64, messageID int) error {
+func (tb *TelegramBot) showResetPasswordMenu(chatID int64, messageID int, parent *config.Parent) error {
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
 	// Add "reset all" action first
-	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔁 Сбросить пароли всех", "resetpw_all"),
-	))
+	if parent.ManagesAll() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Сбросить пароли всех", "resetpw_all"),
+		))
+	}
 
-	for _, account := range tb.config.ChildAccounts {
+	for _, account := range tb.children(parent) {
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(account.FullName, "resetpw_"+account.Username),
 		))
@@ -432,12 +553,12 @@ func (tb *TelegramBot) showResetPasswordMenu(chatID int64, messageID int) error
 	return err
 }
 
-func (tb *TelegramBot) handleResetPassword(data string, chatID int64, messageID int) error {
+func (tb *TelegramBot) handleResetPassword(data string, chatID int64, messageID int, parent *config.Parent) error {
 	// data format: resetpw_<username>
 	username := strings.TrimPrefix(data, "resetpw_")
 
 	var configured string
-	for _, acc := range tb.config.ChildAccounts {
+	for _, acc := range tb.currentConfig().ChildAccounts {
 		if acc.Username == username {
 			configured = acc.Password
 			break
@@ -446,10 +567,10 @@ func (tb *TelegramBot) handleResetPassword(data string, chatID int64, messageID
 
 	if configured == "" {
 		// Fallback: reset all child passwords
-		return tb.handleResetAllPasswords(chatID, messageID)
+		return tb.handleResetAllPasswords(chatID, messageID, parent)
 	}
 
-	if err := config.SetUserPassword(username, configured); err != nil {
+	if err := tb.sessionMgr.RestorePassword(parent.UserID, username); err != nil {
 		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось сбросить пароль для %s: %v", username, err))
 		tb.bot.Send(msg)
 		return err
@@ -466,17 +587,18 @@ func (tb *TelegramBot) handleResetPassword(data string, chatID int64, messageID
 	return err
 }
 
-func (tb *TelegramBot) handleResetAllPasswords(chatID int64, messageID int) error {
-	total := len(tb.config.ChildAccounts)
+func (tb *TelegramBot) handleResetAllPasswords(chatID int64, messageID int, parent *config.Parent) error {
+	accounts := tb.currentConfig().ChildAccounts
+	total := len(accounts)
 	success := 0
 	failed := 0
-	for _, acc := range tb.config.ChildAccounts {
+	for _, acc := range accounts {
 		if acc.Password == "" {
 			// Skip accounts without configured password
 			failed++
 			continue
 		}
-		if err := config.SetUserPassword(acc.Username, acc.Password); err != nil {
+		if err := tb.sessionMgr.RestorePassword(parent.UserID, acc.Username); err != nil {
 			failed++
 		} else {
 			success++
@@ -497,9 +619,9 @@ func (tb *TelegramBot) handleResetAllPasswords(chatID int64, messageID int) erro
 	return err
 }
 
-func (tb *TelegramBot) handleGrantAccess(data string, chatID int64, messageID int) error {
+func (tb *TelegramBot) handleGrantAccess(data string, chatID int64, messageID int, parent *config.Parent) error {
 	if data == "grant_menu" {
-		return tb.showGrantAccessMenu(chatID, messageID)
+		return tb.showGrantAccessMenu(chatID, messageID, parent)
 	}
 
 	// Extract username from callback data
@@ -511,13 +633,13 @@ func (tb *TelegramBot) handleGrantAccess(data string, chatID int64, messageID in
 		"selected_user": username,
 	}
 
-	return tb.showDurationMenu(chatID, messageID)
+	return tb.showDurationMenu(chatID, messageID, parent)
 }
 
-func (tb *TelegramBot) showGrantAccessMenu(chatID int64, messageID int) error {
+func (tb *TelegramBot) showGrantAccessMenu(chatID int64, messageID int, parent *config.Parent) error {
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
-	for _, account := range tb.config.ChildAccounts {
+	for _, account := range tb.children(parent) {
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(account.FullName, "grant_"+account.Username),
 		))
@@ -544,16 +666,30 @@ func (tb *TelegramBot) showGrantAccessMenu(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) showDurationMenu(chatID int64, messageID int) error {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("15 минут", "duration_15"),
-			tgbotapi.NewInlineKeyboardButtonData("30 минут", "duration_30"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("1 час", "duration_60"),
-			tgbotapi.NewInlineKeyboardButtonData("2 часа", "duration_120"),
-		),
+// durationOptions are the preset grant durations offered by showDurationMenu.
+var durationOptions = []struct {
+	label   string
+	minutes int
+}{
+	{"15 минут", 15},
+	{"30 минут", 30},
+	{"1 час", 60},
+	{"2 часа", 120},
+}
+
+func (tb *TelegramBot) showDurationMenu(chatID int64, messageID int, parent *config.Parent) error {
+	// Presets longer than the parent may grant are not offered
+	var presets []tgbotapi.InlineKeyboardButton
+	for _, option := range durationOptions {
+		if limit := maxGrant(parent); limit == 0 || time.Duration(option.minutes)*time.Minute <= limit {
+			presets = append(presets, tgbotapi.NewInlineKeyboardButtonData(option.label, fmt.Sprintf("duration_%d", option.minutes)))
+		}
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(presets); i += 2 {
+		rows = append(rows, presets[i:min(i+2, len(presets))])
+	}
+	rows = append(rows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Другая длительность", "duration_custom"),
 		),
@@ -561,19 +697,24 @@ func (tb *TelegramBot) showDurationMenu(chatID int64, messageID int) error {
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "grant_menu"),
 		),
 	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
 	userData, ok := tb.userData[chatID]
 	if !ok {
 		tb.userStates[chatID] = "grant_duration"
-		return tb.showGrantAccessMenu(chatID, messageID)
+		return tb.showGrantAccessMenu(chatID, messageID, parent)
 	}
 	username, ok := userData["selected_user"].(string)
 	if !ok || username == "" {
 		tb.userStates[chatID] = "grant_duration"
-		return tb.showGrantAccessMenu(chatID, messageID)
+		return tb.showGrantAccessMenu(chatID, messageID, parent)
 	}
 
-	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⏰ *Выбор длительности*\n\nПользователь: *%s*\n\nНа сколько выдать доступ?", username))
+	text := fmt.Sprintf("⏰ *Выбор длительности*\n\nПользователь: *%s*\n\nНа сколько выдать доступ?", username)
+	if parent.MaxGrantMinutes > 0 {
+		text += fmt.Sprintf("\nВы можете выдать не больше %d мин.", parent.MaxGrantMinutes)
+	}
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
 	editMsg.ParseMode = "Markdown"
 	editMsg.ReplyMarkup = &keyboard
 
@@ -581,10 +722,18 @@ func (tb *TelegramBot) showDurationMenu(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) handleDurationSelection(data string, chatID int64, messageID int) error {
+// maxCustomMinutes is the longest duration accepted by the custom duration input.
+func maxCustomMinutes(parent *config.Parent) int {
+	if parent.MaxGrantMinutes > 0 && parent.MaxGrantMinutes < 480 {
+		return parent.MaxGrantMinutes
+	}
+	return 480
+}
+
+func (tb *TelegramBot) handleDurationSelection(data string, chatID int64, messageID int, parent *config.Parent) error {
 	if data == "duration_custom" {
 		tb.userStates[chatID] = "custom_duration"
-		msg := tgbotapi.NewEditMessageText(chatID, messageID, "⌨️ *Своя длительность*\n\nВведите длительность в минутах (1–480):")
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⌨️ *Своя длительность*\n\nВведите длительность в минутах (1–%d):", maxCustomMinutes(parent)))
 		msg.ParseMode = "Markdown"
 		msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
 			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
@@ -602,18 +751,19 @@ func (tb *TelegramBot) handleDurationSelection(data string, chatID int64, messag
 		return err
 	}
 
-	return tb.grantAccess(chatID, messageID, duration)
+	return tb.grantAccess(chatID, messageID, duration, parent)
 }
 
-func (tb *TelegramBot) handleStateInput(message *tgbotapi.Message, state string) error {
+func (tb *TelegramBot) handleStateInput(message *tgbotapi.Message, state string, parent *config.Parent) error {
 	chatID := message.Chat.ID
 	text := message.Text
 
 	switch state {
 	case "custom_duration":
+		limit := maxCustomMinutes(parent)
 		duration, err := strconv.Atoi(text)
-		if err != nil || duration < 1 || duration > 480 {
-			msg := tgbotapi.NewMessage(chatID, "❌ Некорректная длительность. Введите число от 1 до 480 минут.")
+		if err != nil || duration < 1 || duration > limit {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Некорректная длительность. Введите число от 1 до %d минут.", limit))
 			tb.bot.Send(msg)
 			return nil
 		}
@@ -621,30 +771,58 @@ func (tb *TelegramBot) handleStateInput(message *tgbotapi.Message, state string)
 		// Clear state
 		delete(tb.userStates, message.From.ID)
 
-		return tb.grantAccess(chatID, 0, duration)
+		return tb.grantAccess(chatID, 0, duration, parent)
+	case "quota_weekday", "quota_weekend":
+		minutes, err := strconv.Atoi(text)
+		if err != nil || minutes < 0 || minutes > 1440 {
+			msg := tgbotapi.NewMessage(chatID, "❌ Некорректное значение. Введите число минут от 0 до 1440.")
+			tb.bot.Send(msg)
+			return nil
+		}
+
+		delete(tb.userStates, message.From.ID)
+
+		return tb.setQuota(chatID, parent.UserID, state == "quota_weekend", minutes)
+	case "sched_exception":
+		return tb.addScheduleException(message)
+	case "sched_holiday":
+		return tb.addHoliday(message)
+	case "apps_block", "apps_allow", "apps_limit", "apps_remove":
+		return tb.editAppRules(message, state)
+	case "sites_add", "sites_del":
+		return tb.editSiteRules(message, state)
 	}
 
 	return nil
 }
 
-func (tb *TelegramBot) grantAccess(chatID int64, messageID int, durationMinutes int) error {
+func (tb *TelegramBot) grantAccess(chatID int64, messageID int, durationMinutes int, parent *config.Parent) error {
 	userData, ok := tb.userData[chatID]
 	if !ok {
 		// guide user to select child first
-		_ = tb.showGrantAccessMenu(chatID, messageID)
+		_ = tb.showGrantAccessMenu(chatID, messageID, parent)
 		return fmt.Errorf("no child selected")
 	}
 	username, ok := userData["selected_user"].(string)
-	if !ok || username == "" {
-		_ = tb.showGrantAccessMenu(chatID, messageID)
+	if !ok || username == "" || !parent.Manages(username) {
+		_ = tb.showGrantAccessMenu(chatID, messageID, parent)
 		return fmt.Errorf("no child selected")
 	}
 
 	duration := time.Duration(durationMinutes) * time.Minute
+	if limit := maxGrant(parent); limit > 0 && duration > limit {
+		duration = limit
+		durationMinutes = parent.MaxGrantMinutes
+	}
 
-	err := tb.sessionMgr.GrantAccess(username, duration)
+	granted, pin, err := tb.sessionMgr.GrantAccess(parent.UserID, username, duration)
 	if err != nil {
 		msgText := fmt.Sprintf("❌ Не удалось выдать доступ для %s: %v", username, err)
+		if errors.Is(err, session.ErrQuotaExhausted) {
+			msgText = fmt.Sprintf("⛔ Дневной лимит для %s исчерпан. Доступ не выдан.", username)
+		} else if errors.Is(err, session.ErrOutsideSchedule) {
+			msgText = fmt.Sprintf("⛔ Сейчас для %s нет разрешённого времени по расписанию. Доступ не выдан.", username)
+		}
 		if messageID > 0 {
 			editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
 			tb.bot.Send(editMsg)
@@ -658,7 +836,14 @@ func (tb *TelegramBot) grantAccess(chatID int64, messageID int, durationMinutes
 	// Clear user data
 	delete(tb.userData, chatID)
 
-	msgText := fmt.Sprintf("✅ *Доступ выдан*\n\n👤 Пользователь: %s\n⏰ Длительность: %d мин\n\nПо окончании времени сеанс будет завершён, а пароль — восстановлен.", username, durationMinutes)
+	grantedMinutes := int(granted / time.Minute)
+	msgText := fmt.Sprintf("✅ *Доступ выдан*\n\n👤 Пользователь: %s\n⏰ Длительность: %d мин\n🔑 PIN для входа: `%s`\n\nПо окончании времени сеанс будет завершён, а PIN перестанет действовать.", username, grantedMinutes, pin)
+	if grantedMinutes < durationMinutes {
+		msgText += fmt.Sprintf("\n\n⏳ Запрошено %d мин, но с учётом дневного лимита и расписания доступно только %d мин.", durationMinutes, grantedMinutes)
+	}
+	if tb.currentConfig().PinRecipients == config.PinToAll {
+		tb.notifyParentsExcept(chatID, username, permSessions, fmt.Sprintf("✅ *Доступ выдан* для %s на %d мин\n🔑 PIN для входа: `%s`", username, grantedMinutes, pin))
+	}
 	if messageID > 0 {
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
 		editMsg.ParseMode = "Markdown"
@@ -686,14 +871,14 @@ func (tb *TelegramBot) grantAccess(chatID int64, messageID int, durationMinutes
 	return err
 }
 
-func (tb *TelegramBot) handleLockSession(data string, chatID int64, messageID int) error {
+func (tb *TelegramBot) handleLockSession(data string, chatID int64, messageID int, parent *config.Parent) error {
 	if data == "lock_menu" {
-		return tb.showLockMenu(chatID, messageID)
+		return tb.showLockMenu(chatID, messageID, parent)
 	}
 
 	username := strings.TrimPrefix(data, "lock_")
 
-	err := tb.sessionMgr.LockSession(username)
+	err := tb.sessionMgr.LockSession(parent.UserID, username)
 	if err != nil {
 		msgText := fmt.Sprintf("❌ Не удалось завершить сеанс %s: %v", username, err)
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
@@ -714,33 +899,115 @@ func (tb *TelegramBot) handleLockSession(data string, chatID int64, messageID in
 	return err
 }
 
-func (tb *TelegramBot) handleExtendSession(data string, chatID int64, messageID int) error {
+func (tb *TelegramBot) handleExtendSession(data string, chatID int64, messageID int, parent *config.Parent) error {
 	username := strings.TrimPrefix(data, "extend_")
 	if tb.sessionMgr == nil {
 		return nil
 	}
 	// Extend by 15 minutes
-	if err := tb.sessionMgr.ExtendSession(username, 15*time.Minute); err != nil {
-		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось продлить сеанс для %s: %v", username, err))
+	extra := tb.limitExtension(parent, username, 15*time.Minute)
+	if extra <= 0 {
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⛔ У сеанса %s уже осталось %d мин — больше, чем вы можете выдать.", username, parent.MaxGrantMinutes))
+		tb.bot.Send(msg)
+		return nil
+	}
+	extended, err := tb.sessionMgr.ExtendSession(parent.UserID, username, extra)
+	if err != nil {
+		text := fmt.Sprintf("❌ Не удалось продлить сеанс для %s: %v", username, err)
+		if errors.Is(err, session.ErrQuotaExhausted) {
+			text = fmt.Sprintf("⛔ Дневной лимит для %s исчерпан. Продление невозможно.", username)
+		} else if errors.Is(err, session.ErrOutsideSchedule) {
+			text = fmt.Sprintf("⛔ Разрешённое по расписанию время для %s заканчивается. Продление невозможно.", username)
+		}
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
 		tb.bot.Send(msg)
 		return err
 	}
-	msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("✅ Сеанс %s продлён на 15 минут.", username))
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("✅ Сеанс %s продлён на %d мин.", username, int(extended/time.Minute)))
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
 			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
 		},
 	}
-	_, err := tb.bot.Send(msg)
+	_, err = tb.bot.Send(msg)
 	return err
 }
 
-func (tb *TelegramBot) handleLockAllNow(chatID int64, messageID int) error {
+func (tb *TelegramBot) handlePauseSession(data string, chatID int64, messageID int, parent *config.Parent) error {
+	username := strings.TrimPrefix(data, "pause_")
 	if tb.sessionMgr == nil {
 		return nil
 	}
-	if err := tb.sessionMgr.ForceLogoffAllChildSessions(); err != nil {
+	remaining, err := tb.sessionMgr.PauseSession(parent.UserID, username)
+	if err != nil {
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось поставить сеанс %s на паузу: %v", username, err))
+		tb.bot.Send(msg)
+		return err
+	}
+	msgText := fmt.Sprintf("⏸ *Сеанс на паузе*\n\nПользователь %s заблокирован, пароль восстановлен.\nОставшееся время (%v) сохранено до возобновления.", username, remaining.Round(time.Minute))
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+			{tgbotapi.NewInlineKeyboardButtonData("▶️ Продолжить", "resume_"+username)},
+			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
+		},
+	}
+	_, err = tb.bot.Send(msg)
+	return err
+}
+
+func (tb *TelegramBot) handleResumeSession(data string, chatID int64, messageID int, parent *config.Parent) error {
+	username := strings.TrimPrefix(data, "resume_")
+	if tb.sessionMgr == nil {
+		return nil
+	}
+	remaining, pin, err := tb.sessionMgr.ResumeSession(parent.UserID, username)
+	if err != nil {
+		text := fmt.Sprintf("❌ Не удалось возобновить сеанс %s: %v", username, err)
+		if errors.Is(err, session.ErrQuotaExhausted) {
+			text = fmt.Sprintf("⛔ Дневной лимит для %s исчерпан. Сеанс остаётся на паузе.", username)
+		} else if errors.Is(err, session.ErrOutsideSchedule) {
+			text = fmt.Sprintf("⛔ Сейчас %s вне разрешённого расписания. Сеанс остаётся на паузе.", username)
+		}
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		tb.bot.Send(msg)
+		return err
+	}
+	msgText := fmt.Sprintf("▶️ *Сеанс возобновлён*\n\n👤 Пользователь: %s\n⏰ Осталось: %v\n🔑 PIN для входа: `%s`", username, remaining.Round(time.Minute), pin)
+	if tb.currentConfig().PinRecipients == config.PinToAll {
+		tb.notifyParentsExcept(chatID, username, permSessions, fmt.Sprintf("▶️ *Сеанс возобновлён* для %s, осталось %v\n🔑 PIN для входа: `%s`", username, remaining.Round(time.Minute), pin))
+	}
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+			{
+				tgbotapi.NewInlineKeyboardButtonData("⏸ Пауза", "pause_"+username),
+				tgbotapi.NewInlineKeyboardButtonData("🔒 Завершить сейчас", "lock_"+username),
+			},
+			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
+		},
+	}
+	_, err = tb.bot.Send(msg)
+	return err
+}
+
+// sessionControlRow returns the pause or resume button of a session followed by the extension button.
+func sessionControlRow(username string, s *session.ActiveSession) []tgbotapi.InlineKeyboardButton {
+	toggle := tgbotapi.NewInlineKeyboardButtonData("⏸ Пауза", "pause_"+username)
+	if s.Paused {
+		toggle = tgbotapi.NewInlineKeyboardButtonData("▶️ Продолжить", "resume_"+username)
+	}
+	return tgbotapi.NewInlineKeyboardRow(toggle, tgbotapi.NewInlineKeyboardButtonData("➕ +15 мин", "extend_"+username))
+}
+
+func (tb *TelegramBot) handleLockAllNow(chatID int64, messageID int, parent *config.Parent) error {
+	if tb.sessionMgr == nil {
+		return nil
+	}
+	if err := tb.sessionMgr.ForceLogoffAllChildSessions(parent.UserID); err != nil {
 		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось завершить все сеансы: %v", err))
 		tb.bot.Send(msg)
 		return err
@@ -756,8 +1023,8 @@ func (tb *TelegramBot) handleLockAllNow(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) showLockMenu(chatID int64, messageID int) error {
-	activeSessions := tb.sessionMgr.GetActiveSessions()
+func (tb *TelegramBot) showLockMenu(chatID int64, messageID int, parent *config.Parent) error {
+	activeSessions := tb.managedSessions(parent)
 
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
@@ -775,27 +1042,245 @@ func (tb *TelegramBot) showLockMenu(chatID int64, messageID int) error {
 	}
 
 	for username, session := range activeSessions {
-		remaining := session.Duration - time.Since(session.StartTime)
+		remaining := session.Remaining(time.Now())
 		buttonText := fmt.Sprintf("🔒 %s (осталось %v)", username, remaining.Round(time.Minute))
+		if session.Paused {
+			buttonText = fmt.Sprintf("🔒 %s (⏸ осталось %v)", username, remaining.Round(time.Minute))
+		}
+		buttons = append(buttons,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(buttonText, "lock_"+username)),
+			sessionControlRow(username, session),
+		)
+	}
+
+	if len(activeSessions) > 1 && parent.ManagesAll() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔒 Завершить все", "lock_all"),
+		))
+	}
+
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "🔒 *Сеансы*\n\nВыберите сеанс для завершения, паузы или продления:")
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &keyboard
+
+	_, err := tb.bot.Send(editMsg)
+	return err
+}
+
+func (tb *TelegramBot) handleQuota(data string, chatID int64, messageID int, parent *config.Parent) error {
+	switch {
+	case data == "quota_menu":
+		return tb.showQuotaMenu(chatID, messageID, parent)
+	case strings.HasPrefix(data, "quota_user_"):
+		return tb.showChildQuota(chatID, messageID, strings.TrimPrefix(data, "quota_user_"))
+	case strings.HasPrefix(data, "quota_weekday_"), strings.HasPrefix(data, "quota_weekend_"):
+		state := data[:len("quota_weekday")]
+		username := data[len("quota_weekday_"):]
+		tb.userStates[chatID] = state
+		tb.userData[chatID] = map[string]interface{}{
+			"selected_user": username,
+		}
+
+		dayType := "будние дни"
+		if state == "quota_weekend" {
+			dayType = "выходные"
+		}
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⌨️ *Дневной лимит*\n\nПользователь: *%s*\nВведите лимит на %s в минутах (0–1440):", username, dayType))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+				{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "quota_user_"+username)},
+			},
+		}
+		_, err := tb.bot.Send(msg)
+		return err
+	case strings.HasPrefix(data, "quota_clear_"):
+		username := strings.TrimPrefix(data, "quota_clear_")
+		if err := tb.updateQuota(parent.UserID, username, nil); err != nil {
+			msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось снять лимит для %s: %v", username, err))
+			tb.bot.Send(msg)
+			return err
+		}
+		return tb.showChildQuota(chatID, messageID, username)
+	default:
+		return nil
+	}
+}
+
+func (tb *TelegramBot) showQuotaMenu(chatID int64, messageID int, parent *config.Parent) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+
+	for _, account := range tb.children(parent) {
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttonText, "lock_"+username),
-			tgbotapi.NewInlineKeyboardButtonData("➕ +15 мин", "extend_"+username),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%s)", account.FullName, formatQuota(account.Quota)), "quota_user_"+account.Username),
 		))
 	}
 
-	if len(activeSessions) > 1 {
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "⏳ *Дневные лимиты*\n\nВыберите аккаунт ребёнка:")
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &keyboard
+	_, err := tb.bot.Send(editMsg)
+	return err
+}
+
+func (tb *TelegramBot) showChildQuota(chatID int64, messageID int, username string) error {
+	var quota *config.DailyQuota
+	for _, acc := range tb.currentConfig().ChildAccounts {
+		if acc.Username == username {
+			quota = acc.Quota
+			break
+		}
+	}
+
+	var msgText strings.Builder
+	msgText.WriteString(fmt.Sprintf("⏳ *Дневной лимит*\n\nПользователь: *%s*\n", username))
+	if quota == nil {
+		msgText.WriteString("Лимит не установлен\n")
+	} else {
+		msgText.WriteString(fmt.Sprintf("Будни: %d мин\nВыходные: %d мин\n", quota.WeekdayMinutes, quota.WeekendMinutes))
+	}
+	if tb.sessionMgr != nil {
+		if allowance, used, limited := tb.sessionMgr.QuotaStatus(username); limited {
+			left := allowance - used
+			if left < 0 {
+				left = 0
+			}
+			msgText.WriteString(fmt.Sprintf("\nСегодня использовано: %d мин, осталось: %d мин", int(used/time.Minute), int(left/time.Minute)))
+		}
+	}
+
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("📅 Будни", "quota_weekday_"+username),
+			tgbotapi.NewInlineKeyboardButtonData("🎉 Выходные", "quota_weekend_"+username),
+		},
+	}
+	if quota != nil {
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔒 Завершить все", "lock_all"),
+			tgbotapi.NewInlineKeyboardButtonData("♾ Снять лимит", "quota_clear_"+username),
 		))
 	}
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "quota_menu")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	if messageID > 0 {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+		editMsg.ParseMode = "Markdown"
+		editMsg.ReplyMarkup = &keyboard
+		_, err := tb.bot.Send(editMsg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, msgText.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+// setQuota applies minutes entered by the parent to the weekday or weekend part
+// of the selected child's quota. A child without a quota gets the same value for both.
+func (tb *TelegramBot) setQuota(chatID, actor int64, weekend bool, minutes int) error {
+	userData, ok := tb.userData[chatID]
+	if !ok {
+		return tb.showQuotaMenuMessage(chatID)
+	}
+	username, ok := userData["selected_user"].(string)
+	if !ok || username == "" {
+		return tb.showQuotaMenuMessage(chatID)
+	}
+	delete(tb.userData, chatID)
+
+	quota := config.DailyQuota{WeekdayMinutes: minutes, WeekendMinutes: minutes}
+	for _, acc := range tb.currentConfig().ChildAccounts {
+		if acc.Username == username && acc.Quota != nil {
+			quota = *acc.Quota
+		}
+	}
+	if weekend {
+		quota.WeekendMinutes = minutes
+	} else {
+		quota.WeekdayMinutes = minutes
+	}
+
+	if err := tb.updateQuota(actor, username, &quota); err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось сохранить лимит для %s: %v", username, err))
+		tb.bot.Send(msg)
+		return err
+	}
+	return tb.showChildQuota(chatID, 0, username)
+}
+
+// updateQuota stores the quota in config.json and applies it to the session manager.
+// The change is recorded in the audit log on behalf of actor.
+func (tb *TelegramBot) updateQuota(actor int64, username string, quota *config.DailyQuota) (err error) {
+	defer func() {
+		tb.audit.Record(actor, username, audit.ActionSetQuota, map[string]interface{}{"daily_quota": quota}, err)
+	}()
+
+	if err := tb.updateChild(username, func(account *config.ChildAccount) { account.Quota = quota }); err != nil {
+		return err
+	}
+
+	if tb.sessionMgr != nil {
+		var managerQuota *config.DailyQuota
+		if quota != nil {
+			q := *quota
+			managerQuota = &q
+		}
+		return tb.sessionMgr.SetDailyQuota(username, managerQuota)
+	}
+	return nil
+}
+
+func (tb *TelegramBot) showQuotaMenuMessage(chatID int64) error {
+	msg := tgbotapi.NewMessage(chatID, "Выберите ребёнка в меню «⏳ Дневные лимиты».")
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+func formatQuota(q *config.DailyQuota) string {
+	if q == nil {
+		return "без лимита"
+	}
+	return fmt.Sprintf("%d/%d мин", q.WeekdayMinutes, q.WeekendMinutes)
+}
+
+func (tb *TelegramBot) showStatsMenu(chatID int64, messageID int, parent *config.Parent) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
 
+	if parent.ManagesAll() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👨‍👩‍👧 Все пользователи", "stats_child_"),
+		))
+	}
+	for _, account := range tb.children(parent) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(account.FullName, "stats_child_"+account.Username),
+		))
+	}
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
 	))
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
 
-	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "🔒 *Сеансы*\n\nВыберите сеанс для завершения:")
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "📊 *Статистика*\n\nЧью активность показать?")
 	editMsg.ParseMode = "Markdown"
 	editMsg.ReplyMarkup = &keyboard
 
@@ -803,20 +1288,32 @@ func (tb *TelegramBot) showLockMenu(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) showStatsMenu(chatID int64, messageID int) error {
+// showChildStatsMenu offers report periods for one child; an empty username means all users.
+func (tb *TelegramBot) showChildStatsMenu(chatID int64, messageID int, username string) error {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📊 Отчёт за сегодня", "stats_today"),
+			tgbotapi.NewInlineKeyboardButtonData("📊 Отчёт за сегодня", statsCallback("stats_today", username)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📊 Отчёт за неделю", statsCallback("stats_week", username)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📊 Отчёт за неделю", "stats_week"),
+			tgbotapi.NewInlineKeyboardButtonData("🕒 Хронология за сегодня", statsCallback("stats_tl_"+time.Now().Format("2006-01-02"), username)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📊 График по дням", "stats_bars_"+username),
+			tgbotapi.NewInlineKeyboardButtonData("🥧 Программы", "stats_pie_"+username),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📤 Экспорт в файл", "stats_exp_"+username),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "stats_menu"),
 			tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
 		),
 	)
 
-	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "📊 *Статистика*\n\nВыберите период для отчёта:")
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("📊 *Статистика: %s*\n\nВыберите период для отчёта:", tb.statsSubject(username)))
 	editMsg.ParseMode = "Markdown"
 	editMsg.ReplyMarkup = &keyboard
 
@@ -824,20 +1321,46 @@ func (tb *TelegramBot) showStatsMenu(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) showComputerMenu(chatID int64, messageID int) error {
+// statsCallback builds stats callback data, appending the child's username when one is selected.
+func statsCallback(prefix, username string) string {
+	if username == "" {
+		return prefix
+	}
+	return prefix + "_" + username
+}
+
+// statsSubject returns a display name for a stats report subject.
+func (tb *TelegramBot) statsSubject(username string) string {
+	if username == "" {
+		return "все пользователи"
+	}
+	for _, acc := range tb.currentConfig().ChildAccounts {
+		if acc.Username == username {
+			return acc.FullName
+		}
+	}
+	return username
+}
+
+func (tb *TelegramBot) showComputerMenu(chatID int64, messageID int, parent *config.Parent) error {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("💻 Состояние", "computer_status"),
 		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔴 Выключить сейчас", "shutdown_now"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⏰ Запланировать выключение", "shutdown_menu"),
-		),
 	)
 
-	if tb.shutdownMgr.IsShutdownScheduled() {
+	if can(parent, permComputer) {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔴 Выключить сейчас", "shutdown_now"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⏰ Запланировать выключение", "shutdown_menu"),
+			),
+		)
+	}
+
+	if tb.shutdownMgr.IsShutdownScheduled() && can(parent, permComputer) {
 		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("❌ Отменить выключение", "cancel_shutdown"),
@@ -859,16 +1382,20 @@ func (tb *TelegramBot) showComputerMenu(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) showTodayStats(chatID int64, messageID int) error {
+func (tb *TelegramBot) showTodayStats(chatID int64, messageID int, username string) error {
 	report := tb.tracker.GetTodayReport()
+	if username != "" {
+		report = tb.tracker.GetTodayReportForUser(username)
+	}
+	title := fmt.Sprintf("📊 *Отчёт за сегодня: %s*", tb.statsSubject(username))
 
 	if len(report) == 0 {
-		msgText := "📊 *Отчёт за сегодня*\n\nДанных об активности нет."
+		msgText := title + "\n\nДанных об активности нет."
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
 		editMsg.ParseMode = "Markdown"
 		editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
 			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-				{tgbotapi.NewInlineKeyboardButtonData("📊 За неделю", "stats_week")},
+				{tgbotapi.NewInlineKeyboardButtonData("📊 За неделю", statsCallback("stats_week", username))},
 				{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
 			},
 		}
@@ -877,40 +1404,53 @@ func (tb *TelegramBot) showTodayStats(chatID int64, messageID int) error {
 	}
 
 	var msgText strings.Builder
-	msgText.WriteString("📊 *Отчёт за сегодня*\n\n")
+	msgText.WriteString(title + "\n\n")
+
+	summary := tb.summarizeReport(report, 1, username)
+	buttons := tb.writeCategoryReport(&msgText, summary, "today", username)
 
-	totalTime := int64(0)
-	for app, seconds := range report {
-		totalTime += seconds
-		minutes := seconds / 60
-		msgText.WriteString(fmt.Sprintf("• %s: %d мин\n", app, minutes))
+	var totalTime time.Duration
+	for _, cu := range summary {
+		totalTime += cu.Time
+	}
+	msgText.WriteString(fmt.Sprintf("\n📈 Итого: %d мин", int(totalTime/time.Minute)))
+	if idle := report[tracker.IdleApp]; idle > 0 {
+		msgText.WriteString(fmt.Sprintf("\n💤 Бездействие: %d мин", idle/60))
+	}
+	if username != "" {
+		if limits := tb.formatAppLimits(username); limits != "" {
+			msgText.WriteString("\n\n⏱ Лимиты программ:\n" + limits)
+		}
 	}
 
-	msgText.WriteString(fmt.Sprintf("\n📈 Итого: %d мин", totalTime/60))
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📊 За неделю", statsCallback("stats_week", username))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
 
 	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
 	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-			{tgbotapi.NewInlineKeyboardButtonData("📊 За неделю", "stats_week")},
-			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
-		},
-	}
+	editMsg.ReplyMarkup = &keyboard
 
 	_, err := tb.bot.Send(editMsg)
 	return err
 }
 
-func (tb *TelegramBot) showWeekStats(chatID int64, messageID int) error {
+func (tb *TelegramBot) showWeekStats(chatID int64, messageID int, username string) error {
 	report := tb.tracker.GetWeekReport()
+	if username != "" {
+		report = tb.tracker.GetWeekReportForUser(username)
+	}
+	title := fmt.Sprintf("📊 *Отчёт за неделю: %s*", tb.statsSubject(username))
 
 	if len(report) == 0 {
-		msgText := "📊 *Отчёт за неделю*\n\nДанных об активности нет."
+		msgText := title + "\n\nДанных об активности нет."
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
 		editMsg.ParseMode = "Markdown"
 		editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
 			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-				{tgbotapi.NewInlineKeyboardButtonData("📊 За сегодня", "stats_today")},
+				{tgbotapi.NewInlineKeyboardButtonData("📊 За сегодня", statsCallback("stats_today", username))},
 				{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
 			},
 		}
@@ -919,34 +1459,39 @@ func (tb *TelegramBot) showWeekStats(chatID int64, messageID int) error {
 	}
 
 	var msgText strings.Builder
-	msgText.WriteString("📊 *Отчёт за неделю*\n\n")
+	msgText.WriteString(title + "\n\n")
 
-	totalTime := int64(0)
-	for app, seconds := range report {
-		totalTime += seconds
-		minutes := seconds / 60
-		msgText.WriteString(fmt.Sprintf("• %s: %d мин\n", app, minutes))
+	summary := tb.summarizeReport(report, 7, username)
+	buttons := tb.writeCategoryReport(&msgText, summary, "week", username)
+
+	var totalTime time.Duration
+	for _, cu := range summary {
+		totalTime += cu.Time
+	}
+	msgText.WriteString(fmt.Sprintf("\n📈 Итого: %d мин", int(totalTime/time.Minute)))
+	if idle := report[tracker.IdleApp]; idle > 0 {
+		msgText.WriteString(fmt.Sprintf("\n💤 Бездействие: %d мин", idle/60))
 	}
 
-	msgText.WriteString(fmt.Sprintf("\n📈 Итого: %d мин", totalTime/60))
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📊 За сегодня", statsCallback("stats_today", username))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
 
 	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
 	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-			{tgbotapi.NewInlineKeyboardButtonData("📊 За сегодня", "stats_today")},
-			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
-		},
-	}
+	editMsg.ReplyMarkup = &keyboard
 
 	_, err := tb.bot.Send(editMsg)
 	return err
 }
 
-func (tb *TelegramBot) showComputerStatus(chatID int64, messageID int) error {
-	activeSessions := tb.sessionMgr.GetActiveSessions()
+func (tb *TelegramBot) showComputerStatus(chatID int64, messageID int, parent *config.Parent) error {
+	activeSessions := tb.managedSessions(parent)
 
 	var msgText strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
 	msgText.WriteString("💻 *Состояние компьютера*\n\n")
 
 	if len(activeSessions) == 0 {
@@ -954,8 +1499,15 @@ func (tb *TelegramBot) showComputerStatus(chatID int64, messageID int) error {
 	} else {
 		msgText.WriteString("🟢 Активные сеансы:\n")
 		for username, session := range activeSessions {
-			remaining := session.Duration - time.Since(session.StartTime)
-			msgText.WriteString(fmt.Sprintf("• %s: осталось %v\n", username, remaining.Round(time.Minute)))
+			remaining := session.Remaining(time.Now())
+			if session.Paused {
+				msgText.WriteString(fmt.Sprintf("• %s: ⏸ на паузе с %s, осталось %v\n", username, session.PausedAt.Format("15:04"), remaining.Round(time.Minute)))
+			} else {
+				msgText.WriteString(fmt.Sprintf("• %s: осталось %v\n", username, remaining.Round(time.Minute)))
+			}
+			if can(parent, permSessions) {
+				buttons = append(buttons, sessionControlRow(username, session))
+			}
 		}
 	}
 
@@ -964,22 +1516,24 @@ func (tb *TelegramBot) showComputerStatus(chatID int64, messageID int) error {
 		msgText.WriteString(fmt.Sprintf("\n⏰ Выключение запланировано: %s", scheduledTime.Format("15:04")))
 	}
 
+	if can(parent, permComputer) {
+		buttons = append(buttons,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔴 Выключить сейчас", "shutdown_now")),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⏰ Запланировать выключение", "shutdown_menu")),
+		)
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")))
+
 	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
 	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-			{tgbotapi.NewInlineKeyboardButtonData("🔴 Выключить сейчас", "shutdown_now")},
-			{tgbotapi.NewInlineKeyboardButtonData("⏰ Запланировать выключение", "shutdown_menu")},
-			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
-		},
-	}
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 
 	_, err := tb.bot.Send(editMsg)
 	return err
 }
 
-func (tb *TelegramBot) shutdownNow(chatID int64, messageID int) error {
-	err := tb.shutdownMgr.ShutdownNow()
+func (tb *TelegramBot) shutdownNow(chatID int64, messageID int, parent *config.Parent) error {
+	err := tb.shutdownMgr.ShutdownNow(parent.UserID)
 	if err != nil {
 		msgText := fmt.Sprintf("❌ *Не удалось выключить*\n\nОшибка: %v", err)
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
@@ -1008,7 +1562,7 @@ func (tb *TelegramBot) shutdownNow(chatID int64, messageID int) error {
 	return err
 }
 
-func (tb *TelegramBot) scheduleShutdown(data string, chatID int64, messageID int) error {
+func (tb *TelegramBot) scheduleShutdown(data string, chatID int64, messageID int, parent *config.Parent) error {
 	if data == "shutdown_menu" {
 		keyboard := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
@@ -1039,7 +1593,7 @@ func (tb *TelegramBot) scheduleShutdown(data string, chatID int64, messageID int
 		return err
 	}
 
-	err = tb.shutdownMgr.ScheduleShutdown(mins)
+	err = tb.shutdownMgr.ScheduleShutdown(parent.UserID, mins)
 	if err != nil {
 		msgText := fmt.Sprintf("❌ *Не удалось запланировать выключение*\n\nОшибка: %v", err)
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
@@ -1066,8 +1620,8 @@ func (tb *TelegramBot) scheduleShutdown(data string, chatID int64, messageID int
 	return err
 }
 
-func (tb *TelegramBot) cancelShutdown(chatID int64, messageID int) error {
-	err := tb.shutdownMgr.CancelShutdown()
+func (tb *TelegramBot) cancelShutdown(chatID int64, messageID int, parent *config.Parent) error {
+	err := tb.shutdownMgr.CancelShutdown(parent.UserID)
 	if err != nil {
 		msgText := fmt.Sprintf("❌ *Не удалось отменить выключение*\n\nОшибка: %v", err)
 		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
@@ -1094,18 +1648,37 @@ func (tb *TelegramBot) cancelShutdown(chatID int64, messageID int) error {
 }
 
 func (tb *TelegramBot) NotifySessionExpired(username string) {
+	tb.notifyParents(username, fmt.Sprintf("⏰ *Сеанс истек*\n\nСессия пользователя %s истекла и заблокирована.", username))
+}
+
+// NotifyOutsideSchedule tells parents that a session was locked at the end of the allowed hours.
+func (tb *TelegramBot) NotifyOutsideSchedule(username string) {
+	tb.notifyParents(username, fmt.Sprintf("🕒 *Время по расписанию закончилось*\n\nСеанс пользователя %s заблокирован.", username))
+}
+
+// notifyParents sends a Markdown message about username to every parent who manages the child.
+func (tb *TelegramBot) notifyParents(username, text string) {
+	tb.notifyParentsExcept(0, username, permView, text)
+}
+
+// notifyParentsExcept sends text to every parent except skipID who manages username
+// and whose role includes perm.
+func (tb *TelegramBot) notifyParentsExcept(skipID int64, username string, perm permission, text string) {
 	// Проверяем, что бот подключен перед отправкой уведомлений
 	if tb.bot == nil || !tb.isConnected {
-		log.Printf("Cannot notify about expired session for %s: bot not connected", username)
+		log.Printf("Cannot send notification: bot not connected")
 		return
 	}
 
-	// Notify all authorized users about expired session
-	for _, userID := range tb.config.AuthorizedUserIDs {
-		msg := tgbotapi.NewMessage(userID, fmt.Sprintf("⏰ *Сеанс истек*\n\nСессия пользователя %s истекла и заблокирована.", username))
+	for _, parent := range tb.currentConfig().AllParents() {
+		userID := parent.UserID
+		if userID == skipID || !parent.Manages(username) || !can(&parent, perm) {
+			continue
+		}
+		msg := tgbotapi.NewMessage(userID, text)
 		msg.ParseMode = "Markdown"
 		if _, err := tb.bot.Send(msg); err != nil {
-			log.Printf("Failed to send session expired notification to user %d: %v", userID, err)
+			log.Printf("Failed to send notification to user %d: %v", userID, err)
 			// Если ошибка критическая, помечаем соединение как потерянное
 			if tb.isCriticalError(err) {
 				tb.isConnected = false
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+func TestUpdateConfigCopyOnWrite(t *testing.T) {
+	cfg := &config.Config{
+		ChildAccounts: []config.ChildAccount{{Username: "alice"}, {Username: "bob"}},
+		Holidays:      []string{"2026-01-01"},
+	}
+	tb := &TelegramBot{config: cfg}
+
+	// Other goroutines read the configuration while the bot changes it
+	var readers sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, account := range tb.currentConfig().ChildAccounts {
+					if account.Quota != nil && account.Quota.WeekdayMinutes < 0 {
+						t.Error("negative quota")
+					}
+				}
+			}
+		}()
+	}
+	for minutes := 1; minutes <= 20; minutes++ {
+		quota := &config.DailyQuota{WeekdayMinutes: minutes, WeekendMinutes: minutes}
+		if err := tb.updateChild("bob", func(account *config.ChildAccount) { account.Quota = quota }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	readers.Wait()
+
+	// The configuration the bot started with is left as it was
+	if cfg.ChildAccounts[1].Quota != nil {
+		t.Errorf("original configuration modified: %+v", cfg.ChildAccounts[1])
+	}
+	current := tb.currentConfig()
+	if q := current.ChildAccounts[1].Quota; q == nil || q.WeekdayMinutes != 20 {
+		t.Errorf("current quota of bob = %+v, want 20 min", q)
+	}
+
+	if err := tb.updateChild("carol", func(account *config.ChildAccount) {}); err == nil {
+		t.Error("updateChild() of an unknown child succeeded")
+	}
+	if tb.currentConfig() != current {
+		t.Error("failed update replaced the configuration")
+	}
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/chart"
+	"github.com/Hepri/parental/internal/report"
+)
+
+// chartDays is how many days the charts cover, ending today.
+const chartDays = 7
+
+// chartSlices is how many applications the pie chart shows; the rest form one slice.
+const chartSlices = 8
+
+// weekdayAbbreviations label the bars of the weekly chart.
+var weekdayAbbreviations = map[time.Weekday]string{
+	time.Monday:    "Пн",
+	time.Tuesday:   "Вт",
+	time.Wednesday: "Ср",
+	time.Thursday:  "Чт",
+	time.Friday:    "Пт",
+	time.Saturday:  "Сб",
+	time.Sunday:    "Вс",
+}
+
+// chartReport returns the usage of username over the days the charts cover.
+func (tb *TelegramBot) chartReport(username string) *report.Report {
+	now := time.Now()
+	return report.Build(tb.tracker.DailyUsage(), tb.classifier, username, now.AddDate(0, 0, 1-chartDays), now)
+}
+
+// sendWeekChart sends a bar chart of the last days of username, one bar per day
+// stacked by category; an empty username means all users.
+func (tb *TelegramBot) sendWeekChart(chatID int64, username string) error {
+	r := tb.chartReport(username)
+
+	days := r.Days()
+	labels := make([]string, len(days))
+	index := make(map[string]int, len(days))
+	for i, day := range days {
+		date, _ := time.Parse("2006-01-02", day.Date)
+		labels[i] = fmt.Sprintf("%s %s", weekdayAbbreviations[date.Weekday()], date.Format("02.01"))
+		index[day.Date] = i
+	}
+
+	// One series per category with time, in the order of the classifier
+	values := make(map[string][]time.Duration)
+	for _, row := range r.Rows {
+		if row.Category == report.CategoryIdle {
+			continue
+		}
+		if values[row.Category] == nil {
+			values[row.Category] = make([]time.Duration, len(days))
+		}
+		values[row.Category][index[row.Date]] += time.Duration(row.Seconds) * time.Second
+	}
+	var series []chart.Series
+	for _, category := range tb.classifier.Categories() {
+		if values[category] != nil {
+			series = append(series, chart.Series{Label: report.CategoryName(category), Values: values[category]})
+		}
+	}
+	if len(series) == 0 {
+		return tb.sendNoChartData(chatID, username)
+	}
+
+	png, err := chart.StackedBars(fmt.Sprintf("Время за компьютером: %s", tb.statsSubject(username)), labels, series)
+	if err != nil {
+		return fmt.Errorf("failed to render chart: %v", err)
+	}
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "week.png", Bytes: png})
+	photo.Caption = fmt.Sprintf("📊 По дням и категориям: %s, %s — %s", tb.statsSubject(username), r.From, r.To)
+	_, err = tb.bot.Send(photo)
+	return err
+}
+
+// sendAppsChart sends a pie chart of the applications username used over the last
+// days; an empty username means all users.
+func (tb *TelegramBot) sendAppsChart(chatID int64, username string) error {
+	r := tb.chartReport(username)
+
+	apps := r.Apps()
+	shown := len(apps)
+	if shown > chartSlices {
+		shown = chartSlices - 1
+	}
+	var slices []chart.Slice
+	for _, app := range apps[:shown] {
+		slices = append(slices, chart.Slice{Label: app.Name, Value: time.Duration(app.Seconds) * time.Second})
+	}
+	if shown < len(apps) {
+		rest := chart.Slice{Label: "Остальные"}
+		for _, app := range apps[shown:] {
+			rest.Value += time.Duration(app.Seconds) * time.Second
+		}
+		slices = append(slices, rest)
+	}
+	if len(slices) == 0 {
+		return tb.sendNoChartData(chatID, username)
+	}
+
+	png, err := chart.Pie(fmt.Sprintf("Программы: %s", tb.statsSubject(username)), slices)
+	if err != nil {
+		return fmt.Errorf("failed to render chart: %v", err)
+	}
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "apps.png", Bytes: png})
+	photo.Caption = fmt.Sprintf("🥧 Программы: %s, %s — %s", tb.statsSubject(username), r.From, r.To)
+	_, err = tb.bot.Send(photo)
+	return err
+}
+
+func (tb *TelegramBot) sendNoChartData(chatID int64, username string) error {
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("📊 Данных об активности за %d дней нет: %s.", chartDays, tb.statsSubject(username)))
+	_, err := tb.bot.Send(msg)
+	return err
+}
@@ -0,0 +1,349 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/report"
+	"github.com/Hepri/parental/internal/tracker"
+)
+
+// DigestStateFileName is the file next to the executable that remembers which
+// digests were sent, so that a restart does not send them again.
+const DigestStateFileName = "digest_state.json"
+
+// digestCatchUp is how late a digest is still sent, e.g. when the computer was off
+// at the scheduled time.
+const digestCatchUp = 12 * time.Hour
+
+// digestTopApps is how many applications a digest names per child.
+const digestTopApps = 3
+
+// digestKind is a digest schedule: the summary of the days up to the day it is sent on.
+type digestKind struct {
+	name  string
+	days  int
+	title string
+}
+
+var (
+	dailyDigest  = digestKind{name: "daily", days: 1, title: "Сводка за день"}
+	weeklyDigest = digestKind{name: "weekly", days: 7, title: "Сводка за неделю"}
+)
+
+// RunDigests sends the scheduled digests of every parent until ctx is done.
+func (tb *TelegramBot) RunDigests(ctx context.Context) {
+	statePath := filepath.Join(filepath.Dir(os.Args[0]), DigestStateFileName)
+	sent := loadDigestState(statePath)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if tb.sendDueDigests(time.Now(), sent) {
+				if err := saveDigestState(statePath, sent); err != nil {
+					log.Printf("Failed to save digest state: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// sendDueDigests sends every digest whose time has come since it was last sent and
+// records it in sent (parent/kind -> last day covered). It reports whether any was sent.
+func (tb *TelegramBot) sendDueDigests(now time.Time, sent map[string]string) bool {
+	if tb.bot == nil || !tb.isConnected {
+		// Tried again on the next tick, within the catch-up time
+		return false
+	}
+
+	cfg := tb.currentConfig()
+	changed := false
+	for _, parent := range cfg.AllParents() {
+		settings := cfg.DigestFor(&parent)
+		for _, schedule := range digestSchedules(settings) {
+			key := fmt.Sprintf("%d/%s", parent.UserID, schedule.kind.name)
+			due, ok := schedule.due(now, sent[key])
+			if !ok {
+				continue
+			}
+
+			if err := tb.sendDigest(&parent, settings, schedule.kind, coveredDay(due)); err != nil {
+				log.Printf("Failed to send %s digest to %d: %v", schedule.kind.name, parent.UserID, err)
+				continue
+			}
+			sent[key] = due.Format("2006-01-02")
+			changed = true
+		}
+	}
+	return changed
+}
+
+// digestSchedule is when a digest of kind is sent: at the time at ("HH:MM", empty
+// for never) on the days accepted by on.
+type digestSchedule struct {
+	kind digestKind
+	at   string
+	on   func(time.Time) bool
+}
+
+// digestSchedules returns the daily and weekly schedules of settings.
+func digestSchedules(settings config.Digest) []digestSchedule {
+	weekday := settings.WeeklyWeekday()
+	return []digestSchedule{
+		{dailyDigest, settings.DailyAt, func(time.Time) bool { return true }},
+		{weeklyDigest, settings.WeeklyAt, func(day time.Time) bool { return day.Weekday() == weekday }},
+	}
+}
+
+// due returns the last time the digest was due at or before now and whether it is
+// still to be sent, given the day it was last sent for (YYYY-MM-DD, empty if never).
+func (s digestSchedule) due(now time.Time, lastSent string) (time.Time, bool) {
+	if s.at == "" {
+		return time.Time{}, false
+	}
+	clock, err := config.ParseClock(s.at)
+	if err != nil {
+		return time.Time{}, false
+	}
+	due := lastOccurrence(now, clock, s.on)
+	if due.IsZero() || now.Sub(due) > digestCatchUp || lastSent >= due.Format("2006-01-02") {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+// coveredDay returns the last day a digest due at due is about: the days up to the
+// time it is due, so one due at midnight covers the day that just ended.
+func coveredDay(due time.Time) time.Time {
+	last := due.Add(-time.Nanosecond)
+	return time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, last.Location())
+}
+
+// lastOccurrence returns the latest time at or before now that is clock minutes
+// past midnight on a day accepted by on, looking back a week at most.
+func lastOccurrence(now time.Time, clock int, on func(time.Time) bool) time.Time {
+	for back := 0; back <= 7; back++ {
+		t := time.Date(now.Year(), now.Month(), now.Day()-back, clock/60, clock%60, 0, 0, now.Location())
+		if !t.After(now) && on(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// sendDigestNow sends parent the daily or weekly digest up to now, outside the schedule.
+func (tb *TelegramBot) sendDigestNow(chatID int64, parent *config.Parent, weekly bool) error {
+	kind := dailyDigest
+	if weekly {
+		kind = weeklyDigest
+	}
+	if len(tb.children(parent)) == 0 {
+		_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, "📬 Нет детей, по которым можно составить сводку."))
+		return err
+	}
+	return tb.sendDigest(parent, tb.currentConfig().DigestFor(parent), kind, time.Now())
+}
+
+// sendDigest sends parent the digest of kind for the days ending on the day of last.
+func (tb *TelegramBot) sendDigest(parent *config.Parent, settings config.Digest, kind digestKind, last time.Time) error {
+	children := tb.children(parent)
+	if len(children) == 0 {
+		return nil
+	}
+
+	lastDay := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, last.Location())
+	firstDay := lastDay.AddDate(0, 0, 1-kind.days)
+
+	var text strings.Builder
+	if kind.days == 1 {
+		text.WriteString(fmt.Sprintf("📬 *%s: %s*\n", kind.title, lastDay.Format("02.01.2006")))
+	} else {
+		text.WriteString(fmt.Sprintf("📬 *%s: %s — %s*\n", kind.title, firstDay.Format("02.01"), lastDay.Format("02.01.2006")))
+	}
+
+	// Granted sessions come from the audit log, read once for all children
+	var grants []audit.Entry
+	if settings.Includes(config.DigestSessions) {
+		entries, err := tb.audit.Range(firstDay, lastDay.AddDate(0, 0, 1))
+		if err != nil {
+			log.Printf("Failed to read audit log for digest: %v", err)
+		}
+		for _, e := range entries {
+			if e.Action == audit.ActionGrant && e.Outcome == audit.OutcomeOK {
+				grants = append(grants, e)
+			}
+		}
+	}
+
+	usage := tb.tracker.DailyUsage()
+	for _, account := range children {
+		text.WriteString(fmt.Sprintf("\n👤 *%s*\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, account.FullName)))
+		text.WriteString(tb.digestChild(usage, grants, account.Username, settings, firstDay, kind.days))
+	}
+
+	msg := tgbotapi.NewMessage(parent.UserID, text.String())
+	msg.ParseMode = "Markdown"
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+// digestChild returns the lines of a digest about username for the days days
+// starting on firstDay.
+func (tb *TelegramBot) digestChild(usage map[string]map[string]map[string]int64, grants []audit.Entry, username string, settings config.Digest, firstDay time.Time, days int) string {
+	lastDay := firstDay.AddDate(0, 0, days-1)
+	current := report.Build(usage, tb.classifier, username, firstDay, lastDay)
+	active, _ := current.Total()
+
+	var lines []string
+	line := "⏱ За компьютером: " + report.FormatDuration(active)
+	if settings.Includes(config.DigestCompare) {
+		previous := report.Build(usage, tb.classifier, username, firstDay.AddDate(0, 0, -days), firstDay.AddDate(0, 0, -1))
+		if before, _ := previous.Total(); before > 0 {
+			line += " (" + formatChange(active, before) + ")"
+		}
+	}
+	lines = append(lines, line)
+
+	if settings.Includes(config.DigestQuota) && tb.sessionMgr != nil {
+		var allowance, used time.Duration
+		limited := false
+		for day := firstDay; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+			a, u, l := tb.sessionMgr.QuotaOn(username, day)
+			allowance, used, limited = allowance+a, used+u, limited || l
+		}
+		if limited {
+			lines = append(lines, fmt.Sprintf("⏳ Лимит: %s из %s", report.FormatDuration(int64(used.Seconds())), report.FormatDuration(int64(allowance.Seconds()))))
+		}
+	}
+
+	if settings.Includes(config.DigestApps) {
+		var top []string
+		for i, app := range current.Apps() {
+			if i == digestTopApps {
+				break
+			}
+			top = append(top, fmt.Sprintf("%s — %s", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, app.Name), report.FormatDuration(app.Seconds)))
+		}
+		if len(top) > 0 {
+			lines = append(lines, "🏆 Чаще всего: "+strings.Join(top, ", "))
+		}
+	}
+
+	if settings.Includes(config.DigestSessions) {
+		count := 0
+		for _, e := range grants {
+			if strings.EqualFold(e.Child, username) {
+				count++
+			}
+		}
+		lines = append(lines, fmt.Sprintf("🔑 Выдано сеансов: %d", count))
+	}
+
+	if settings.Includes(config.DigestLateNight) {
+		intervals, err := tb.tracker.Timeline(username, firstDay, lastDay.AddDate(0, 0, 1))
+		if err != nil {
+			log.Printf("Failed to read timeline for digest: %v", err)
+		} else if late := nightTime(intervals, settings.Night); late >= time.Minute {
+			lines = append(lines, fmt.Sprintf("🌙 В поздние часы (%s–%s): %s", settings.Night.Start, settings.Night.End, report.FormatDuration(int64(late.Seconds()))))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// formatChange describes how now compares to before, e.g. "↑ 25% к прошлому периоду".
+func formatChange(now, before int64) string {
+	percent := (now - before) * 100 / before
+	switch {
+	case percent > 0:
+		return fmt.Sprintf("↑ %d%% к прошлому периоду", percent)
+	case percent < 0:
+		return fmt.Sprintf("↓ %d%% к прошлому периоду", -percent)
+	default:
+		return "как в прошлый период"
+	}
+}
+
+// nightTime returns how much of the active intervals falls into the night hours,
+// which may span midnight.
+func nightTime(intervals []tracker.Interval, night config.TimeWindow) time.Duration {
+	start, err1 := config.ParseClock(night.Start)
+	end, err2 := config.ParseClock(night.End)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	windows := [][2]int{{start, end}}
+	if start > end {
+		windows = [][2]int{{0, end}, {start, 24 * 60}}
+	}
+
+	var total time.Duration
+	for _, iv := range intervals {
+		if iv.Idle {
+			continue
+		}
+		// Intervals never cross midnight
+		day := time.Date(iv.Start.Year(), iv.Start.Month(), iv.Start.Day(), 0, 0, 0, 0, iv.Start.Location())
+		for _, w := range windows {
+			from := maxTime(iv.Start, day.Add(time.Duration(w[0])*time.Minute))
+			to := minTime(iv.End, day.Add(time.Duration(w[1])*time.Minute))
+			if to.After(from) {
+				total += to.Sub(from)
+			}
+		}
+	}
+	return total
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func loadDigestState(path string) map[string]string {
+	sent := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read digest state: %v", err)
+		}
+		return sent
+	}
+	if err := json.Unmarshal(data, &sent); err != nil {
+		log.Printf("Failed to parse digest state: %v", err)
+		return make(map[string]string)
+	}
+	return sent
+}
+
+func saveDigestState(path string, sent map[string]string) error {
+	data, err := json.MarshalIndent(sent, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
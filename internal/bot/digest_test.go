@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+// at returns 2026-10-<day> hh:mm in the local time zone; the 12th is a Monday.
+func at(day, hh, mm int) time.Time {
+	return time.Date(2026, 10, day, hh, mm, 0, 0, time.Local)
+}
+
+func TestLastOccurrence(t *testing.T) {
+	everyDay := func(time.Time) bool { return true }
+	onMonday := func(day time.Time) bool { return day.Weekday() == time.Monday }
+	never := func(time.Time) bool { return false }
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		clock int
+		on    func(time.Time) bool
+		want  time.Time
+	}{
+		{"later today", at(14, 20, 59), 21 * 60, everyDay, at(13, 21, 0)},
+		{"exactly now", at(14, 21, 0), 21 * 60, everyDay, at(14, 21, 0)},
+		{"earlier today", at(14, 23, 0), 21 * 60, everyDay, at(14, 21, 0)},
+		{"midnight", at(14, 0, 0), 0, everyDay, at(14, 0, 0)},
+		{"midnight before now", at(14, 23, 59), 0, everyDay, at(14, 0, 0)},
+		{"weekday today", at(12, 9, 30), 9 * 60, onMonday, at(12, 9, 0)},
+		{"weekday later today", at(12, 8, 59), 9 * 60, onMonday, at(5, 9, 0)},
+		{"weekday earlier in the week", at(16, 12, 0), 9 * 60, onMonday, at(12, 9, 0)},
+		{"across the month", at(1, 8, 0), 9 * 60, everyDay, time.Date(2026, 9, 30, 9, 0, 0, 0, time.Local)},
+		{"no day accepted", at(14, 12, 0), 9 * 60, never, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastOccurrence(tt.now, tt.clock, tt.on); !got.Equal(tt.want) {
+				t.Errorf("lastOccurrence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestScheduleDue(t *testing.T) {
+	schedules := func(settings config.Digest) (daily, weekly digestSchedule) {
+		s := digestSchedules(settings)
+		return s[0], s[1]
+	}
+	daily, weekly := schedules(config.Digest{DailyAt: "21:00", WeeklyAt: "09:00", WeeklyDay: "mon"})
+	midnight, midnightWeekly := schedules(config.Digest{DailyAt: "00:00", WeeklyAt: "00:00"})
+	off, _ := schedules(config.Digest{})
+
+	tests := []struct {
+		name     string
+		schedule digestSchedule
+		now      time.Time
+		lastSent string
+		want     time.Time // zero when nothing is to be sent
+		covered  time.Time
+	}{
+		{"daily before time", daily, at(14, 20, 59), "2026-10-13", time.Time{}, time.Time{}},
+		{"daily on time", daily, at(14, 21, 0), "2026-10-13", at(14, 21, 0), at(14, 0, 0)},
+		{"daily already sent", daily, at(14, 21, 1), "2026-10-14", time.Time{}, time.Time{}},
+		{"daily never sent", daily, at(14, 21, 1), "", at(14, 21, 0), at(14, 0, 0)},
+		{"daily caught up next morning", daily, at(15, 9, 0), "2026-10-13", at(14, 21, 0), at(14, 0, 0)},
+		{"daily missed for too long", daily, at(15, 9, 1), "2026-10-13", time.Time{}, time.Time{}},
+		{"weekly on its day", weekly, at(12, 9, 0), "2026-10-05", at(12, 9, 0), at(12, 0, 0)},
+		{"weekly caught up the same day", weekly, at(12, 20, 0), "2026-10-05", at(12, 9, 0), at(12, 0, 0)},
+		{"weekly not on other days", weekly, at(13, 9, 0), "2026-10-05", time.Time{}, time.Time{}},
+		{"weekly already sent", weekly, at(12, 10, 0), "2026-10-12", time.Time{}, time.Time{}},
+		{"midnight covers the day before", midnight, at(14, 0, 0), "2026-10-13", at(14, 0, 0), at(13, 0, 0)},
+		{"midnight caught up", midnight, at(14, 11, 59), "2026-10-13", at(14, 0, 0), at(13, 0, 0)},
+		{"midnight missed for too long", midnight, at(14, 12, 1), "2026-10-13", time.Time{}, time.Time{}},
+		{"midnight already sent", midnight, at(14, 23, 59), "2026-10-14", time.Time{}, time.Time{}},
+		{"weekly on sunday by default", midnightWeekly, at(11, 0, 0), "", at(11, 0, 0), at(10, 0, 0)},
+		{"weekly not on monday by default", midnightWeekly, at(12, 0, 0), "2026-10-11", time.Time{}, time.Time{}},
+		{"not scheduled", off, at(14, 21, 0), "", time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, ok := tt.schedule.due(tt.now, tt.lastSent)
+			if ok != !tt.want.IsZero() || (ok && !due.Equal(tt.want)) {
+				t.Fatalf("due() = %v, %v, want %v", due, ok, tt.want)
+			}
+			if ok && !coveredDay(due).Equal(tt.covered) {
+				t.Errorf("coveredDay(%v) = %v, want %v", due, coveredDay(due), tt.covered)
+			}
+		})
+	}
+}
+
+func TestDigestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DigestStateFileName)
+	if sent := loadDigestState(path); len(sent) != 0 {
+		t.Errorf("loaded %v without a state file", sent)
+	}
+
+	sent := map[string]string{"7/daily": "2026-10-14", "7/weekly": "2026-10-11"}
+	if err := saveDigestState(path, sent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	loaded := loadDigestState(path)
+	if len(loaded) != len(sent) || loaded["7/daily"] != sent["7/daily"] || loaded["7/weekly"] != sent["7/weekly"] {
+		t.Errorf("loaded %v, want %v", loaded, sent)
+	}
+
+	// A damaged file means nothing was sent rather than failing
+	if err := os.WriteFile(path, []byte(`{"7/daily": "2026-`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loadDigestState(path); len(loaded) != 0 {
+		t.Errorf("loaded %v from a damaged file", loaded)
+	}
+}
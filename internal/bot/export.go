@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/report"
+)
+
+// exportPeriods are the numbers of days offered in the export menu, ending today.
+var exportPeriods = []struct {
+	days  int
+	title string
+}{
+	{1, "Сегодня"},
+	{7, "7 дн."},
+	{30, "30 дн."},
+}
+
+const exportUsage = "📤 Экспорт отчёта за любой период:\n/export ГГГГ-ММ-ДД ГГГГ-ММ-ДД [csv|json|html] [пользователь]\n\nНапример: /export 2025-10-01 2025-10-31 html"
+
+// showExportMenu offers report files of one child; an empty username means all users.
+func (tb *TelegramBot) showExportMenu(chatID int64, messageID int, username string) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, period := range exportPeriods {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, format := range report.Formats {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s · %s", period.title, strings.ToUpper(string(format))),
+				statsCallback(fmt.Sprintf("stats_expf_%d_%s", period.days, format), username),
+			))
+		}
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "stats_child_"+username),
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	text := fmt.Sprintf("📤 *Экспорт: %s*\n\nВыберите период и формат файла. CSV и JSON подходят для таблиц и программ, HTML открывается в браузере.\n\n%s",
+		tb.statsSubject(username), tgbotapi.EscapeText(tgbotapi.ModeMarkdown, exportUsage))
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &keyboard
+
+	_, err := tb.bot.Send(editMsg)
+	return err
+}
+
+// handleExport sends a report file chosen in the export menu. The data is
+// "stats_expf_<days>_<format>[_<username>]".
+func (tb *TelegramBot) handleExport(data string, chatID int64, messageID int, parent *config.Parent) error {
+	daysText, rest, _ := strings.Cut(strings.TrimPrefix(data, "stats_expf_"), "_")
+	formatText, username, _ := strings.Cut(rest, "_")
+
+	days, err := strconv.Atoi(daysText)
+	if err != nil || days < 1 {
+		return fmt.Errorf("invalid export callback %q", data)
+	}
+	format, err := report.ParseFormat(formatText)
+	if err != nil {
+		return fmt.Errorf("invalid export callback %q", data)
+	}
+	if !callbackAllowed(parent, "stats_exp_"+username) {
+		tb.audit.Record(parent.UserID, username, audit.ActionForbidden, map[string]interface{}{"callback": data}, nil)
+		tb.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "⛔ Недостаточно прав для этого действия."))
+		return nil
+	}
+
+	to := time.Now()
+	return tb.sendReport(chatID, username, to.AddDate(0, 0, 1-days), to, format)
+}
+
+// handleExportCommand sends the report file asked for with
+// "/export FROM TO [format] [username]".
+func (tb *TelegramBot) handleExportCommand(chatID int64, parent *config.Parent, args string) error {
+	fields := strings.Fields(args)
+	if len(fields) < 2 || len(fields) > 4 {
+		_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, exportUsage))
+		return err
+	}
+
+	from, err1 := time.ParseInLocation("2006-01-02", fields[0], time.Local)
+	to, err2 := time.ParseInLocation("2006-01-02", fields[1], time.Local)
+	if err1 != nil || err2 != nil || to.Before(from) {
+		_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Некорректный период.\n\n"+exportUsage))
+		return err
+	}
+
+	format, username := report.FormatHTML, ""
+	for _, field := range fields[2:] {
+		if f, err := report.ParseFormat(field); err == nil {
+			format = f
+		} else {
+			username = field
+		}
+	}
+	if username != "" {
+		known := false
+		for _, account := range tb.currentConfig().ChildAccounts {
+			if strings.EqualFold(account.Username, username) {
+				username, known = account.Username, true
+			}
+		}
+		if !known {
+			_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Пользователь %s не найден.", username)))
+			return err
+		}
+	}
+
+	allowed := parent.ManagesAll()
+	if username != "" {
+		allowed = parent.Manages(username)
+	}
+	if !can(parent, permView) || !allowed {
+		tb.audit.Record(parent.UserID, username, audit.ActionForbidden, map[string]interface{}{"command": "/export"}, nil)
+		_, err := tb.bot.Send(tgbotapi.NewMessage(chatID, "⛔ Недостаточно прав для этого действия."))
+		return err
+	}
+
+	return tb.sendReport(chatID, username, from, to, format)
+}
+
+// sendReport sends the usage of username from the day of from to the day of to
+// as a file in format.
+func (tb *TelegramBot) sendReport(chatID int64, username string, from, to time.Time, format report.Format) error {
+	r := report.Build(tb.tracker.DailyUsage(), tb.classifier, username, from, to)
+	if username != "" {
+		r.Name = tb.statsSubject(username)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, format); err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось подготовить отчёт: %v", err)))
+		return err
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: r.FileName(format), Bytes: buf.Bytes()})
+	doc.Caption = fmt.Sprintf("📤 Отчёт: %s, %s — %s", r.Subject(), r.From, r.To)
+	if len(r.Rows) == 0 {
+		doc.Caption += "\nДанных об активности за этот период нет."
+	}
+	_, err := tb.bot.Send(doc)
+	return err
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/session"
+)
+
+// permission is a group of bot actions that a role may be allowed to perform.
+type permission int
+
+const (
+	permView      permission = iota // statistics and computer status
+	permSessions                    // grant, extend, pause and lock sessions, answer time requests
+	permSettings                    // daily quotas, schedules, holidays, application and website rules
+	permPasswords                   // restore child passwords
+	permComputer                    // shut down the computer
+	permAudit                       // read the audit log
+)
+
+// rolePermissions lists what each role in config may do.
+var rolePermissions = map[string][]permission{
+	config.RoleOwner:     {permView, permSessions, permSettings, permPasswords, permComputer, permAudit},
+	config.RoleParent:    {permView, permSessions, permSettings, permPasswords, permAudit},
+	config.RoleCaretaker: {permView, permSessions},
+	config.RoleViewer:    {permView},
+}
+
+// roleTitles are the role names shown to parents.
+var roleTitles = map[string]string{
+	config.RoleOwner:     "владелец",
+	config.RoleParent:    "родитель",
+	config.RoleCaretaker: "помощник",
+	config.RoleViewer:    "наблюдатель",
+}
+
+// callbackRule is the permission required by callback data starting with prefix.
+type callbackRule struct {
+	prefix string
+	perm   permission
+	child  bool // the rest of the data is a child username; empty means every child
+	all    bool // the action covers every child
+}
+
+// callbackRules is checked in order and the first matching prefix wins, so more
+// specific prefixes come first. Callbacks without a rule are refused.
+var callbackRules = []callbackRule{
+	{prefix: "main_menu", perm: permView},
+
+	{prefix: "grant_menu", perm: permSessions},
+	{prefix: "grant_", perm: permSessions, child: true},
+	{prefix: "duration_", perm: permSessions},
+	{prefix: "lock_menu", perm: permSessions},
+	{prefix: "lock_all", perm: permSessions, all: true},
+	{prefix: "lock_", perm: permSessions, child: true},
+	{prefix: "extend_", perm: permSessions, child: true},
+	{prefix: "pause_", perm: permSessions, child: true},
+	{prefix: "resume_", perm: permSessions, child: true},
+	{prefix: "treq_", perm: permSessions}, // the child is checked by handleTimeRequest
+
+	{prefix: "quota_menu", perm: permSettings},
+	{prefix: "quota_user_", perm: permSettings, child: true},
+	{prefix: "quota_weekday_", perm: permSettings, child: true},
+	{prefix: "quota_weekend_", perm: permSettings, child: true},
+	{prefix: "quota_clear_", perm: permSettings, child: true},
+	{prefix: "sched_menu", perm: permSettings},
+	{prefix: "sched_user_", perm: permSettings, child: true},
+	{prefix: "sched_exc_", perm: permSettings, child: true},
+	{prefix: "sched_clearexc_", perm: permSettings, child: true},
+	{prefix: "sched_", perm: permSettings, all: true}, // holidays apply to every child
+	{prefix: "apps_menu", perm: permSettings},
+	{prefix: "apps_user_", perm: permSettings, child: true},
+	{prefix: "apps_block_", perm: permSettings, child: true},
+	{prefix: "apps_allow_", perm: permSettings, child: true},
+	{prefix: "apps_limit_", perm: permSettings, child: true},
+	{prefix: "apps_remove_", perm: permSettings, child: true},
+	{prefix: "apps_action_", perm: permSettings, child: true},
+	{prefix: "sites_menu", perm: permSettings},
+	{prefix: "sites_user_", perm: permSettings, child: true},
+	{prefix: "sites_add_", perm: permSettings, child: true},
+	{prefix: "sites_del_", perm: permSettings, child: true},
+	{prefix: "sites_clear_", perm: permSettings, child: true},
+
+	{prefix: "resetpw_menu", perm: permPasswords},
+	{prefix: "resetpw_all", perm: permPasswords, all: true},
+	{prefix: "resetpw_", perm: permPasswords, child: true},
+
+	{prefix: "stats_menu", perm: permView},
+	{prefix: "stats_child_", perm: permView, child: true},
+	{prefix: "stats_cat_", perm: permView},  // the children are checked by handleCategoryStats
+	{prefix: "stats_tl_", perm: permView},   // the child is checked by handleTimeline
+	{prefix: "stats_expf_", perm: permView}, // the child is checked by handleExport
+	{prefix: "stats_exp_", perm: permView, child: true},
+	{prefix: "stats_bars_", perm: permView, child: true},
+	{prefix: "stats_pie_", perm: permView, child: true},
+	{prefix: "stats_today_", perm: permView, child: true},
+	{prefix: "stats_today", perm: permView, all: true},
+	{prefix: "stats_week_", perm: permView, child: true},
+	{prefix: "stats_week", perm: permView, all: true},
+	{prefix: "computer_menu", perm: permView},
+	{prefix: "computer_status", perm: permView},
+
+	{prefix: "shutdown_", perm: permComputer},
+	{prefix: "cancel_shutdown", perm: permComputer},
+}
+
+// can reports whether the role of parent includes perm.
+func can(parent *config.Parent, perm permission) bool {
+	for _, p := range rolePermissions[parent.Role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// callbackAllowed reports whether parent may run the callback data.
+func callbackAllowed(parent *config.Parent, data string) bool {
+	for _, rule := range callbackRules {
+		if !strings.HasPrefix(data, rule.prefix) {
+			continue
+		}
+		if !can(parent, rule.perm) {
+			return false
+		}
+		if rule.all {
+			return parent.ManagesAll()
+		}
+		if rule.child {
+			username := strings.TrimPrefix(data, rule.prefix)
+			if username == "" {
+				return parent.ManagesAll()
+			}
+			return parent.Manages(username)
+		}
+		return true
+	}
+	return false
+}
+
+// children returns the child accounts parent manages.
+func (tb *TelegramBot) children(parent *config.Parent) []config.ChildAccount {
+	var result []config.ChildAccount
+	for _, account := range tb.currentConfig().ChildAccounts {
+		if parent.Manages(account.Username) {
+			result = append(result, account)
+		}
+	}
+	return result
+}
+
+// managedSessions returns the active sessions of the children parent manages.
+func (tb *TelegramBot) managedSessions(parent *config.Parent) map[string]*session.ActiveSession {
+	sessions := tb.sessionMgr.GetActiveSessions()
+	for username := range sessions {
+		if !parent.Manages(username) {
+			delete(sessions, username)
+		}
+	}
+	return sessions
+}
+
+// maxGrant returns the longest session parent may grant; 0 means no limit.
+func maxGrant(parent *config.Parent) time.Duration {
+	return time.Duration(parent.MaxGrantMinutes) * time.Minute
+}
+
+// limitExtension trims extra so that after the extension the session of username
+// has no more time left than parent may grant at once.
+func (tb *TelegramBot) limitExtension(parent *config.Parent, username string, extra time.Duration) time.Duration {
+	limit := maxGrant(parent)
+	if limit == 0 {
+		return extra
+	}
+	var remaining time.Duration
+	if s, ok := tb.sessionMgr.GetActiveSessions()[username]; ok {
+		remaining = s.Remaining(time.Now())
+	}
+	if remaining+extra > limit {
+		extra = limit - remaining
+	}
+	if extra < 0 {
+		return 0
+	}
+	return extra
+}
@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+func TestCallbackAllowed(t *testing.T) {
+	// Who may do what, written out independently of rolePermissions
+	var (
+		everyone = []string{config.RoleOwner, config.RoleParent, config.RoleCaretaker, config.RoleViewer}
+		sessions = []string{config.RoleOwner, config.RoleParent, config.RoleCaretaker}
+		settings = []string{config.RoleOwner, config.RoleParent}
+		owner    = []string{config.RoleOwner}
+	)
+	// Scopes of a callback: any parent with the role, one of the children, every child
+	const (
+		anyChild = ""
+		allChild = "*"
+	)
+
+	tests := []struct {
+		data  string
+		roles []string
+		child string
+	}{
+		{"main_menu", everyone, anyChild},
+		{"grant_menu", sessions, anyChild},
+		{"grant_alice", sessions, "alice"},
+		{"grant_bob", sessions, "bob"},
+		{"grant_", sessions, allChild},
+		{"duration_30", sessions, anyChild},
+		{"lock_menu", sessions, anyChild},
+		{"lock_all", sessions, allChild},
+		{"lock_alice", sessions, "alice"},
+		{"lock_bob", sessions, "bob"},
+		{"extend_alice", sessions, "alice"},
+		{"pause_bob", sessions, "bob"},
+		{"resume_alice", sessions, "alice"},
+		{"treq_approve_r1", sessions, anyChild},
+		{"quota_menu", settings, anyChild},
+		{"quota_user_alice", settings, "alice"},
+		{"quota_weekday_bob", settings, "bob"},
+		{"quota_weekend_alice", settings, "alice"},
+		{"quota_clear_bob", settings, "bob"},
+		{"sched_menu", settings, anyChild},
+		{"sched_user_alice", settings, "alice"},
+		{"sched_exc_bob", settings, "bob"},
+		{"sched_clearexc_alice", settings, "alice"},
+		{"sched_holidays", settings, allChild},
+		{"apps_menu", settings, anyChild},
+		{"apps_user_bob", settings, "bob"},
+		{"apps_block_alice", settings, "alice"},
+		{"apps_allow_bob", settings, "bob"},
+		{"apps_limit_alice", settings, "alice"},
+		{"apps_remove_bob", settings, "bob"},
+		{"apps_action_bob", settings, "bob"},
+		{"sites_menu", settings, anyChild},
+		{"sites_user_alice", settings, "alice"},
+		{"sites_add_alice", settings, "alice"},
+		{"sites_del_bob", settings, "bob"},
+		{"sites_clear_bob", settings, "bob"},
+		{"resetpw_menu", settings, anyChild},
+		{"resetpw_all", settings, allChild},
+		{"resetpw_alice", settings, "alice"},
+		{"resetpw_bob", settings, "bob"},
+		{"stats_menu", everyone, anyChild},
+		{"stats_child_bob", everyone, "bob"},
+		{"stats_cat_7", everyone, anyChild},
+		{"stats_tl_bob_0", everyone, anyChild},
+		{"stats_expf_bob_csv", everyone, anyChild},
+		{"stats_exp_alice", everyone, "alice"},
+		{"stats_exp_bob", everyone, "bob"},
+		{"stats_bars_bob", everyone, "bob"},
+		{"stats_pie_alice", everyone, "alice"},
+		{"stats_today", everyone, allChild},
+		{"stats_today_alice", everyone, "alice"},
+		{"stats_today_bob", everyone, "bob"},
+		{"stats_week", everyone, allChild},
+		{"stats_week_bob", everyone, "bob"},
+		{"computer_menu", everyone, anyChild},
+		{"computer_status", everyone, anyChild},
+		{"shutdown_now", owner, anyChild},
+		{"cancel_shutdown", owner, anyChild},
+		// Unknown callbacks
+		{"", nil, anyChild},
+		{"bogus", nil, anyChild},
+		{"lock", nil, anyChild},
+		{"stats", nil, anyChild},
+		{"MAIN_MENU", nil, anyChild},
+	}
+
+	// Every rule is the first match of one of the callbacks above
+	for _, rule := range callbackRules {
+		covered := false
+		for _, tt := range tests {
+			covered = covered || firstRule(tt.data) == rule.prefix
+		}
+		if !covered {
+			t.Errorf("no test for callbacks starting with %q", rule.prefix)
+		}
+	}
+
+	roles := append(everyone, "", "admin")
+	for _, tt := range tests {
+		for _, role := range roles {
+			// A parent of every child and one of alice only
+			for _, children := range [][]string{nil, {"Alice"}} {
+				parent := &config.Parent{UserID: 7, Role: role, Children: children}
+
+				want := false
+				for _, r := range tt.roles {
+					want = want || r == role
+				}
+				switch tt.child {
+				case anyChild:
+				case allChild:
+					want = want && parent.ManagesAll()
+				default:
+					want = want && (parent.ManagesAll() || tt.child == "alice")
+				}
+
+				if got := callbackAllowed(parent, tt.data); got != want {
+					t.Errorf("callbackAllowed(%s of %v, %q) = %v, want %v", role, children, tt.data, got, want)
+				}
+			}
+		}
+	}
+}
+
+// firstRule returns the prefix of the rule data is checked against, "" if none.
+func firstRule(data string) string {
+	for _, rule := range callbackRules {
+		if strings.HasPrefix(data, rule.prefix) {
+			return rule.prefix
+		}
+	}
+	return ""
+}
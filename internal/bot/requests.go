@@ -0,0 +1,186 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/request"
+	"github.com/Hepri/parental/internal/session"
+)
+
+// errGrantLimit is the outcome of an approval that would leave the session with
+// more time than the parent may grant at once.
+var errGrantLimit = errors.New("session already has as much time as the parent may grant")
+
+// sentMessage identifies a message the bot sent, so that it can be edited later.
+type sentMessage struct {
+	chatID    int64
+	messageID int
+}
+
+// RequestCreated forwards a child's request for more time to every parent who may
+// answer it. It fails if no parent got the request, so that the child learns about it.
+func (tb *TelegramBot) RequestCreated(req request.TimeRequest) error {
+	if tb.bot == nil || !tb.isConnected {
+		return errors.New("bot not connected")
+	}
+
+	text := fmt.Sprintf("🙋 %s просит ещё %d мин.", req.Username, req.Minutes)
+	if req.Reason != "" {
+		text += fmt.Sprintf("\n💬 %s", req.Reason)
+	}
+	text += fmt.Sprintf("\n\n⌛ Запрос действует до %s.", req.ExpiresAt.Format("15:04"))
+
+	buttons := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ +%d мин", req.Minutes), "treq_ok_"+req.ID),
+	}
+	if req.Minutes != 15 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("✅ +15 мин", "treq_ok15_"+req.ID))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("❌ Отказать", "treq_no_"+req.ID))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
+
+	var sent []sentMessage
+	for _, parent := range tb.currentConfig().AllParents() {
+		if !parent.Manages(req.Username) || !can(&parent, permSessions) {
+			continue
+		}
+		userID := parent.UserID
+		msg := tgbotapi.NewMessage(userID, text)
+		msg.ReplyMarkup = keyboard
+		m, err := tb.bot.Send(msg)
+		if err != nil {
+			log.Printf("Failed to forward time request to user %d: %v", userID, err)
+			continue
+		}
+		sent = append(sent, sentMessage{chatID: userID, messageID: m.MessageID})
+	}
+	if len(sent) == 0 {
+		return errors.New("no parent received the request")
+	}
+
+	tb.requestMutex.Lock()
+	tb.requestMessages[req.ID] = sent
+	tb.requestMutex.Unlock()
+	return nil
+}
+
+// RequestExpired updates the parents' messages once nobody answered in time.
+func (tb *TelegramBot) RequestExpired(req request.TimeRequest) {
+	tb.audit.Record(audit.System, req.Username, audit.ActionRequestExpire, requestParams(req), nil)
+	tb.finishRequest(req.ID, fmt.Sprintf("⌛ Запрос %s на %d мин истёк без ответа.", req.Username, req.Minutes))
+	tb.sessionMgr.NotifyChild(req.Username, "Родители не ответили на запрос дополнительного времени.")
+}
+
+// handleTimeRequest answers a request from the buttons sent by RequestCreated.
+// data format: treq_ok_<id>, treq_ok15_<id> or treq_no_<id>
+func (tb *TelegramBot) handleTimeRequest(data string, from *tgbotapi.User, parent *config.Parent, chatID int64, messageID int) error {
+	parts := strings.SplitN(data, "_", 3)
+	if len(parts) != 3 || tb.requests == nil {
+		return nil
+	}
+	action, id := parts[1], parts[2]
+
+	if pending, err := tb.requests.Get(id); err == nil && !parent.Manages(pending.Username) {
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⛔ Вы не можете отвечать на запросы %s.", pending.Username))
+		tb.bot.Send(msg)
+		return nil
+	}
+
+	req, err := tb.requests.Take(id)
+	if err != nil {
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, "ℹ️ На этот запрос уже ответили, или он истёк.")
+		tb.bot.Send(msg)
+		return nil
+	}
+
+	parentName := from.FirstName
+	if parentName == "" {
+		parentName = from.UserName
+	}
+
+	if action == "no" {
+		tb.audit.Record(parent.UserID, req.Username, audit.ActionRequestDeny, requestParams(req), nil)
+		tb.finishRequest(id, fmt.Sprintf("❌ %s отказал(а): %s просил(а) %d мин.", parentName, req.Username, req.Minutes))
+		tb.sessionMgr.NotifyChild(req.Username, "Родители отклонили запрос дополнительного времени.")
+		return nil
+	}
+
+	minutes := req.Minutes
+	if action == "ok15" {
+		minutes = 15
+	}
+
+	extended, err := tb.approveRequest(parent, req, minutes)
+	if errors.Is(err, errGrantLimit) {
+		tb.finishRequest(id, fmt.Sprintf("⛔ %s одобрил(а) запрос %s, но не может выдать больше %d мин.", parentName, req.Username, parent.MaxGrantMinutes))
+		tb.sessionMgr.NotifyChild(req.Username, "Родители не смогли добавить время.")
+		return nil
+	}
+	if err != nil {
+		text := fmt.Sprintf("❌ %s одобрил(а) запрос %s, но продлить сеанс не удалось: %v", parentName, req.Username, err)
+		if errors.Is(err, session.ErrQuotaExhausted) {
+			text = fmt.Sprintf("⛔ %s одобрил(а) запрос %s, но дневной лимит исчерпан.", parentName, req.Username)
+		} else if errors.Is(err, session.ErrOutsideSchedule) {
+			text = fmt.Sprintf("⛔ %s одобрил(а) запрос %s, но разрешённое по расписанию время заканчивается.", parentName, req.Username)
+		}
+		tb.finishRequest(id, text)
+		return err
+	}
+
+	extendedMinutes := int(extended / time.Minute)
+	tb.finishRequest(id, fmt.Sprintf("✅ %s одобрил(а): сеанс %s продлён на %d мин.", parentName, req.Username, extendedMinutes))
+	tb.sessionMgr.NotifyChild(req.Username, fmt.Sprintf("Родители добавили %d мин.", extendedMinutes))
+	return nil
+}
+
+// approveRequest extends the session of the child who sent req by minutes, trimmed to
+// what parent may grant, and records the answer in the audit log with its outcome.
+func (tb *TelegramBot) approveRequest(parent *config.Parent, req request.TimeRequest, minutes int) (extended time.Duration, err error) {
+	defer func() {
+		params := requestParams(req)
+		params["approved_minutes"] = minutes
+		params["extended_minutes"] = int(extended / time.Minute)
+		tb.audit.Record(parent.UserID, req.Username, audit.ActionRequestApprove, params, err)
+	}()
+
+	extra := tb.limitExtension(parent, req.Username, time.Duration(minutes)*time.Minute)
+	if extra <= 0 {
+		return 0, errGrantLimit
+	}
+	return tb.sessionMgr.ExtendSession(parent.UserID, req.Username, extra)
+}
+
+// requestParams describes req for the audit log.
+func requestParams(req request.TimeRequest) map[string]interface{} {
+	params := map[string]interface{}{"request_id": req.ID, "minutes": req.Minutes}
+	if req.Reason != "" {
+		params["reason"] = req.Reason
+	}
+	return params
+}
+
+// finishRequest replaces the buttons of every message about request id with text.
+func (tb *TelegramBot) finishRequest(id, text string) {
+	tb.requestMutex.Lock()
+	sent := tb.requestMessages[id]
+	delete(tb.requestMessages, id)
+	tb.requestMutex.Unlock()
+
+	if tb.bot == nil {
+		return
+	}
+	for _, m := range sent {
+		msg := tgbotapi.NewEditMessageText(m.chatID, m.messageID, text)
+		if _, err := tb.bot.Send(msg); err != nil {
+			log.Printf("Failed to update time request message for user %d: %v", m.chatID, err)
+		}
+	}
+}
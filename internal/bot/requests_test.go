@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+	"github.com/Hepri/parental/internal/request"
+	"github.com/Hepri/parental/internal/session"
+)
+
+func TestApproveRequestAudit(t *testing.T) {
+	fake := platform.NewFake()
+	if err := fake.CreateUser("alice", "Alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	auditLog := audit.New(filepath.Join(dir, audit.FileName))
+	sessionMgr, err := session.NewManager(dir, []config.ChildAccount{{Username: "alice"}}, fake, auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sessionMgr.Cleanup)
+	tb := &TelegramBot{sessionMgr: sessionMgr, audit: auditLog}
+	parent := &config.Parent{UserID: 7, MaxGrantMinutes: 60}
+	req := request.TimeRequest{ID: "r1", Username: "alice", Minutes: 30}
+
+	// No session to extend
+	if _, err := tb.approveRequest(parent, req, 30); err == nil {
+		t.Fatal("approveRequest() succeeded without a session")
+	}
+
+	// Within the parent's limit
+	if _, _, err := sessionMgr.GrantAccess(parent.UserID, "alice", 20*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if extended, err := tb.approveRequest(parent, req, 30); err != nil || extended != 30*time.Minute {
+		t.Fatalf("approveRequest() = %v, %v, want 30m", extended, err)
+	}
+
+	// The session already has more than the parent may grant
+	if _, _, err := sessionMgr.GrantAccess(audit.System, "alice", 90*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tb.approveRequest(parent, req, 15); err != errGrantLimit {
+		t.Fatalf("approveRequest() error = %v, want %v", err, errGrantLimit)
+	}
+
+	entries, err := auditLog.Range(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var approvals []audit.Entry
+	for _, entry := range entries {
+		if entry.Action == audit.ActionRequestApprove {
+			approvals = append(approvals, entry)
+		}
+	}
+	want := []struct {
+		outcome  string
+		extended int
+	}{
+		{"no active session for alice", 0},
+		{audit.OutcomeOK, 30},
+		{errGrantLimit.Error(), 0},
+	}
+	if len(approvals) != len(want) {
+		t.Fatalf("recorded %d approvals, want %d: %+v", len(approvals), len(want), approvals)
+	}
+	for i, w := range want {
+		entry := approvals[i]
+		if entry.UserID != parent.UserID || entry.Child != "alice" || entry.Outcome != w.outcome {
+			t.Errorf("approval %d = %+v, want outcome %q", i, entry, w.outcome)
+		}
+		if got, _ := entry.Params["extended_minutes"].(float64); int(got) != w.extended {
+			t.Errorf("approval %d extended_minutes = %v, want %d", i, entry.Params["extended_minutes"], w.extended)
+		}
+	}
+}
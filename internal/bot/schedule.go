@@ -0,0 +1,387 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+)
+
+func (tb *TelegramBot) handleSchedule(data string, chatID int64, messageID int, parent *config.Parent) error {
+	switch {
+	case data == "sched_menu":
+		return tb.showScheduleMenu(chatID, messageID, parent)
+	case data == "sched_holidays":
+		return tb.showHolidays(chatID, messageID)
+	case data == "sched_addhol":
+		tb.userStates[chatID] = "sched_holiday"
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, "⌨️ *Праздничный день*\n\nВведите дату в формате ГГГГ-ММ-ДД. В этот день действует расписание выходного.")
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+				{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "sched_holidays")},
+			},
+		}
+		_, err := tb.bot.Send(msg)
+		return err
+	case data == "sched_clearhol":
+		if err := tb.updateHolidays(parent.UserID, nil); err != nil {
+			msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось очистить праздники: %v", err))
+			tb.bot.Send(msg)
+			return err
+		}
+		return tb.showHolidays(chatID, messageID)
+	case strings.HasPrefix(data, "sched_user_"):
+		return tb.showChildSchedule(chatID, messageID, strings.TrimPrefix(data, "sched_user_"))
+	case strings.HasPrefix(data, "sched_exc_"):
+		username := strings.TrimPrefix(data, "sched_exc_")
+		tb.userStates[chatID] = "sched_exception"
+		tb.userData[chatID] = map[string]interface{}{
+			"selected_user": username,
+		}
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⌨️ *Исключение в расписании*\n\nПользователь: *%s*\n\nВведите дату и разрешённые интервалы, например:\n`2026-12-31 10:00-23:00`\n`2026-12-31 10:00-12:00, 18:00-21:00`\n\nЧтобы запретить доступ на весь день: `2026-12-31 -`", username))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+				{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "sched_user_"+username)},
+			},
+		}
+		_, err := tb.bot.Send(msg)
+		return err
+	case strings.HasPrefix(data, "sched_clearexc_"):
+		username := strings.TrimPrefix(data, "sched_clearexc_")
+		schedule := tb.childSchedule(username)
+		if schedule != nil {
+			schedule.Exceptions = nil
+			if err := tb.updateSchedule(parent.UserID, username, schedule); err != nil {
+				msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось очистить исключения для %s: %v", username, err))
+				tb.bot.Send(msg)
+				return err
+			}
+		}
+		return tb.showChildSchedule(chatID, messageID, username)
+	default:
+		return nil
+	}
+}
+
+func (tb *TelegramBot) showScheduleMenu(chatID int64, messageID int, parent *config.Parent) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+
+	for _, account := range tb.children(parent) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(account.FullName, "sched_user_"+account.Username),
+		))
+	}
+
+	// Holidays apply to every child
+	if parent.ManagesAll() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🎌 Праздники", "sched_holidays")))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "🕒 *Расписание*\n\nВыберите аккаунт ребёнка:")
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &keyboard
+	_, err := tb.bot.Send(editMsg)
+	return err
+}
+
+func (tb *TelegramBot) showChildSchedule(chatID int64, messageID int, username string) error {
+	schedule := tb.childSchedule(username)
+
+	var msgText strings.Builder
+	msgText.WriteString(fmt.Sprintf("🕒 *Расписание*\n\nПользователь: *%s*\n\n", username))
+	if schedule == nil {
+		msgText.WriteString("Расписание не задано — доступ разрешён в любое время.\n")
+	} else {
+		if len(schedule.Weekly) == 0 {
+			msgText.WriteString("Еженедельных интервалов нет.\n")
+		}
+		for _, rule := range schedule.Weekly {
+			msgText.WriteString(fmt.Sprintf("• %s: %s–%s\n", strings.Join(rule.Days, ", "), rule.Start, rule.End))
+		}
+
+		today := time.Now().Format("2006-01-02")
+		var dates []string
+		for date := range schedule.Exceptions {
+			if date >= today {
+				dates = append(dates, date)
+			}
+		}
+		sort.Strings(dates)
+		if len(dates) > 0 {
+			msgText.WriteString("\nИсключения:\n")
+			for _, date := range dates {
+				msgText.WriteString(fmt.Sprintf("• %s: %s\n", date, formatWindows(schedule.Exceptions[date])))
+			}
+		}
+	}
+
+	if tb.sessionMgr != nil {
+		if until, allowed, limited := tb.sessionMgr.AllowedUntil(username); limited {
+			if allowed {
+				msgText.WriteString(fmt.Sprintf("\n🟢 Сейчас разрешено до %s", until.Format("15:04")))
+			} else {
+				msgText.WriteString("\n🔴 Сейчас доступ по расписанию запрещён")
+			}
+		}
+	}
+
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("➕ Исключение на дату", "sched_exc_"+username)},
+	}
+	if schedule != nil && len(schedule.Exceptions) > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Очистить исключения", "sched_clearexc_"+username),
+		))
+	}
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "sched_menu")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	if messageID > 0 {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+		editMsg.ParseMode = "Markdown"
+		editMsg.ReplyMarkup = &keyboard
+		_, err := tb.bot.Send(editMsg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, msgText.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+func (tb *TelegramBot) showHolidays(chatID int64, messageID int) error {
+	today := time.Now().Format("2006-01-02")
+	holidays := tb.currentConfig().Holidays
+	var upcoming []string
+	for _, date := range holidays {
+		if date >= today {
+			upcoming = append(upcoming, date)
+		}
+	}
+	sort.Strings(upcoming)
+
+	var msgText strings.Builder
+	msgText.WriteString("🎌 *Праздники*\n\nВ праздничные дни действует расписание выходного.\n\n")
+	if len(upcoming) == 0 {
+		msgText.WriteString("Предстоящих праздников нет.")
+	}
+	for _, date := range upcoming {
+		msgText.WriteString(fmt.Sprintf("• %s\n", date))
+	}
+
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("➕ Добавить", "sched_addhol")},
+	}
+	if len(holidays) > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Очистить", "sched_clearhol"),
+		))
+	}
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "sched_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	if messageID > 0 {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+		editMsg.ParseMode = "Markdown"
+		editMsg.ReplyMarkup = &keyboard
+		_, err := tb.bot.Send(editMsg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, msgText.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+func (tb *TelegramBot) addScheduleException(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	userData, ok := tb.userData[chatID]
+	username, _ := userData["selected_user"].(string)
+	if !ok || username == "" {
+		delete(tb.userStates, message.From.ID)
+		msg := tgbotapi.NewMessage(chatID, "Выберите ребёнка в меню «🕒 Расписание».")
+		_, err := tb.bot.Send(msg)
+		return err
+	}
+
+	date, windows, err := parseScheduleException(message.Text)
+	if err != nil {
+		log.Printf("Invalid schedule exception %q: %v", message.Text, err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Некорректная дата или интервал.\n\nПример: `2026-12-31 10:00-23:00` или `2026-12-31 -`")
+		msg.ParseMode = "Markdown"
+		tb.bot.Send(msg)
+		return nil
+	}
+
+	delete(tb.userStates, message.From.ID)
+	delete(tb.userData, chatID)
+
+	schedule := tb.childSchedule(username)
+	if schedule == nil {
+		// Без расписания остальные дни остаются открытыми круглосуточно
+		schedule = &config.Schedule{Weekly: []config.ScheduleRule{{
+			Days:       []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun", "holiday"},
+			TimeWindow: config.TimeWindow{Start: "00:00", End: "24:00"},
+		}}}
+	}
+	if schedule.Exceptions == nil {
+		schedule.Exceptions = make(map[string][]config.TimeWindow)
+	}
+	today := time.Now().Format("2006-01-02")
+	for d := range schedule.Exceptions {
+		if d < today {
+			delete(schedule.Exceptions, d)
+		}
+	}
+	schedule.Exceptions[date] = windows
+
+	if err := tb.updateSchedule(message.From.ID, username, schedule); err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось сохранить расписание для %s: %v", username, err))
+		tb.bot.Send(msg)
+		return err
+	}
+	return tb.showChildSchedule(chatID, 0, username)
+}
+
+func (tb *TelegramBot) addHoliday(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	date := strings.TrimSpace(message.Text)
+
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Некорректная дата. Введите дату в формате ГГГГ-ММ-ДД.")
+		tb.bot.Send(msg)
+		return nil
+	}
+
+	delete(tb.userStates, message.From.ID)
+
+	today := time.Now().Format("2006-01-02")
+	holidays := []string{date}
+	for _, d := range tb.currentConfig().Holidays {
+		if d >= today && d != date {
+			holidays = append(holidays, d)
+		}
+	}
+	sort.Strings(holidays)
+
+	if err := tb.updateHolidays(message.From.ID, holidays); err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось сохранить праздник: %v", err))
+		tb.bot.Send(msg)
+		return err
+	}
+	return tb.showHolidays(chatID, 0)
+}
+
+// childSchedule returns a copy of the configured schedule of username, or nil.
+func (tb *TelegramBot) childSchedule(username string) *config.Schedule {
+	for _, acc := range tb.currentConfig().ChildAccounts {
+		if acc.Username == username {
+			return acc.Schedule.Clone()
+		}
+	}
+	return nil
+}
+
+// updateSchedule stores the schedule in config.json and applies it to the session manager.
+// The change is recorded in the audit log on behalf of actor.
+func (tb *TelegramBot) updateSchedule(actor int64, username string, schedule *config.Schedule) (err error) {
+	defer func() {
+		tb.audit.Record(actor, username, audit.ActionSetSchedule, map[string]interface{}{"allowed_hours": schedule}, err)
+	}()
+
+	if err := tb.updateChild(username, func(account *config.ChildAccount) { account.Schedule = schedule }); err != nil {
+		return err
+	}
+
+	if tb.sessionMgr != nil {
+		return tb.sessionMgr.SetSchedule(username, schedule.Clone())
+	}
+	return nil
+}
+
+// updateHolidays stores the holiday list in config.json and applies it to the session manager.
+// The change is recorded in the audit log on behalf of actor.
+func (tb *TelegramBot) updateHolidays(actor int64, holidays []string) error {
+	err := tb.updateConfig(func(cfg *config.Config) error {
+		cfg.Holidays = holidays
+		return nil
+	})
+	tb.audit.Record(actor, "", audit.ActionSetHolidays, map[string]interface{}{"holidays": holidays}, err)
+	if err != nil {
+		return err
+	}
+	if tb.sessionMgr != nil {
+		tb.sessionMgr.SetHolidays(holidays)
+	}
+	if tb.sites != nil {
+		tb.sites.SetHolidays(holidays)
+		if err := tb.sites.Refresh(); err != nil {
+			log.Printf("Failed to update blocked sites: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseScheduleException parses "YYYY-MM-DD HH:MM-HH:MM[, HH:MM-HH:MM]" or "YYYY-MM-DD -".
+func parseScheduleException(text string) (string, []config.TimeWindow, error) {
+	fields := strings.Fields(strings.TrimSpace(text))
+	if len(fields) < 2 {
+		return "", nil, fmt.Errorf("missing date or windows")
+	}
+
+	date := fields[0]
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", nil, fmt.Errorf("invalid date %q", date)
+	}
+
+	rest := strings.Join(fields[1:], "")
+	if rest == "-" {
+		return date, []config.TimeWindow{}, nil
+	}
+
+	var windows []config.TimeWindow
+	for _, part := range strings.Split(rest, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) != 2 {
+			return "", nil, fmt.Errorf("invalid window %q", part)
+		}
+		w := config.TimeWindow{Start: bounds[0], End: bounds[1]}
+		if _, _, err := w.Minutes(); err != nil {
+			return "", nil, fmt.Errorf("invalid window %q: %v", part, err)
+		}
+		windows = append(windows, w)
+	}
+	return date, windows, nil
+}
+
+func formatWindows(windows []config.TimeWindow) string {
+	if len(windows) == 0 {
+		return "весь день закрыто"
+	}
+	parts := make([]string, len(windows))
+	for i, w := range windows {
+		parts[i] = w.Start + "–" + w.End
+	}
+	return strings.Join(parts, ", ")
+}
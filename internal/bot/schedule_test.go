@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+func TestParseScheduleException(t *testing.T) {
+	tests := []struct {
+		text    string
+		date    string
+		windows []config.TimeWindow
+		wantErr bool
+	}{
+		{text: "2026-12-31 10:00-23:00", date: "2026-12-31", windows: []config.TimeWindow{{Start: "10:00", End: "23:00"}}},
+		{text: "  2026-12-31   10:00-12:00, 15:00-24:00 ", date: "2026-12-31", windows: []config.TimeWindow{{Start: "10:00", End: "12:00"}, {Start: "15:00", End: "24:00"}}},
+		{text: "2026-12-31 -", date: "2026-12-31", windows: []config.TimeWindow{}},
+		{text: "2026-12-31", wantErr: true},
+		{text: "", wantErr: true},
+		{text: "31.12.2026 10:00-23:00", wantErr: true},
+		{text: "2026-02-30 10:00-23:00", wantErr: true},
+		{text: "2026-12-31 10:00", wantErr: true},
+		{text: "2026-12-31 10:00-11:00-12:00", wantErr: true},
+		{text: "2026-12-31 23:00-10:00", wantErr: true},
+		{text: "2026-12-31 25:00-26:00", wantErr: true},
+		{text: "2026-12-31 10:00-23:00, x", wantErr: true},
+	}
+	for _, tt := range tests {
+		date, windows, err := parseScheduleException(tt.text)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseScheduleException(%q) = %q, %v, want an error", tt.text, date, windows)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseScheduleException(%q) error = %v", tt.text, err)
+			continue
+		}
+		if date != tt.date || !reflect.DeepEqual(windows, tt.windows) {
+			t.Errorf("parseScheduleException(%q) = %q, %v, want %q, %v", tt.text, date, windows, tt.date, tt.windows)
+		}
+	}
+}
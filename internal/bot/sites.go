@@ -0,0 +1,294 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+)
+
+// weekDays are the day names of schedules in order, used to expand ranges like "sun-thu".
+var weekDays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+func (tb *TelegramBot) handleSites(data string, chatID int64, messageID int, parent *config.Parent) error {
+	switch {
+	case data == "sites_menu":
+		return tb.showSitesMenu(chatID, messageID, parent)
+	case strings.HasPrefix(data, "sites_user_"):
+		return tb.showChildSites(chatID, messageID, strings.TrimPrefix(data, "sites_user_"))
+	case strings.HasPrefix(data, "sites_clear_"):
+		username := strings.TrimPrefix(data, "sites_clear_")
+		if err := tb.updateSiteRules(parent.UserID, username, nil); err != nil {
+			msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("❌ Не удалось очистить список сайтов для %s: %v", username, err))
+			tb.bot.Send(msg)
+			return err
+		}
+		return tb.showChildSites(chatID, messageID, username)
+	case strings.HasPrefix(data, "sites_add_"), strings.HasPrefix(data, "sites_del_"):
+		state, username, _ := strings.Cut(strings.TrimPrefix(data, "sites_"), "_")
+		tb.userStates[chatID] = "sites_" + state
+		tb.userData[chatID] = map[string]interface{}{
+			"selected_user": username,
+		}
+
+		prompt := "Введите номер правила, которое нужно удалить."
+		if state == "add" {
+			prompt = "Введите сайты через запятую, например:\n`tiktok.com, youtube.com`\n\nЧтобы блокировать только в определённые часы, добавьте после `;` дни и время:\n`tiktok.com; sun-thu 20:00-24:00`\n\nДни: mon, tue, wed, thu, fri, sat, sun, holiday; диапазоны через `-`.\n\nБлокируется сам сайт и его адрес с `www.`; другие поддомены, например `m.youtube.com`, укажите отдельно."
+		}
+		msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⌨️ *Сайты*\n\nПользователь: *%s*\n\n%s", username, prompt))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+				{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "sites_user_"+username)},
+			},
+		}
+		_, err := tb.bot.Send(msg)
+		return err
+	default:
+		return nil
+	}
+}
+
+func (tb *TelegramBot) showSitesMenu(chatID int64, messageID int, parent *config.Parent) error {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+
+	for _, account := range tb.children(parent) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(account.FullName, "sites_user_"+account.Username),
+		))
+	}
+
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "🌐 *Сайты*\n\nВыберите аккаунт ребёнка:")
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &keyboard
+	_, err := tb.bot.Send(editMsg)
+	return err
+}
+
+func (tb *TelegramBot) showChildSites(chatID int64, messageID int, username string) error {
+	rules := tb.childSiteRules(username)
+
+	var msgText strings.Builder
+	msgText.WriteString(fmt.Sprintf("🌐 *Сайты*\n\nПользователь: *%s*\n\n", username))
+	if len(rules) == 0 {
+		msgText.WriteString("Заблокированных сайтов нет.\n")
+	}
+	for i, rule := range rules {
+		msgText.WriteString(fmt.Sprintf("%d. %s — %s\n", i+1, formatAppList(rule.Domains), formatSiteSchedule(rule.When)))
+	}
+	if tb.sites != nil && len(rules) > 0 {
+		if blocked := tb.sites.Blocked(username, time.Now()); len(blocked) > 0 {
+			msgText.WriteString("\n⛔ Сейчас заблокированы: " + formatAppList(blocked))
+		} else {
+			msgText.WriteString("\n🟢 Сейчас ничего не заблокировано")
+		}
+	}
+
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("➕ Заблокировать", "sites_add_"+username)},
+	}
+	if len(rules) > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➖ Удалить правило", "sites_del_"+username),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Очистить", "sites_clear_"+username),
+		))
+	}
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "sites_menu")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	if messageID > 0 {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+		editMsg.ParseMode = "Markdown"
+		editMsg.ReplyMarkup = &keyboard
+		_, err := tb.bot.Send(editMsg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, msgText.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	_, err := tb.bot.Send(msg)
+	return err
+}
+
+// editSiteRules applies the text entered by the parent in state to the blocked sites of the selected child.
+func (tb *TelegramBot) editSiteRules(message *tgbotapi.Message, state string) error {
+	chatID := message.Chat.ID
+
+	userData, ok := tb.userData[chatID]
+	username, _ := userData["selected_user"].(string)
+	if !ok || username == "" {
+		delete(tb.userStates, message.From.ID)
+		msg := tgbotapi.NewMessage(chatID, "Выберите ребёнка в меню «🌐 Сайты».")
+		_, err := tb.bot.Send(msg)
+		return err
+	}
+
+	rules := tb.childSiteRules(username)
+	text := strings.TrimSpace(message.Text)
+
+	switch state {
+	case "sites_add":
+		rule, err := parseSiteRule(text)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %v\n\nПример: `tiktok.com, youtube.com` или `tiktok.com; sun-thu 20:00-24:00`", err))
+			msg.ParseMode = "Markdown"
+			tb.bot.Send(msg)
+			return nil
+		}
+		rules = append(rules, rule)
+	case "sites_del":
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 || n > len(rules) {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Введите номер правила от 1 до %d.", len(rules))))
+			return nil
+		}
+		rules = append(rules[:n-1], rules[n:]...)
+	}
+
+	delete(tb.userStates, message.From.ID)
+	delete(tb.userData, chatID)
+
+	if err := tb.updateSiteRules(message.From.ID, username, rules); err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось сохранить список сайтов для %s: %v", username, err))
+		tb.bot.Send(msg)
+		return err
+	}
+	return tb.showChildSites(chatID, 0, username)
+}
+
+// childSiteRules returns a copy of the configured blocked sites of username.
+func (tb *TelegramBot) childSiteRules(username string) []config.SiteRule {
+	for _, acc := range tb.currentConfig().ChildAccounts {
+		if acc.Username == username {
+			return config.CloneSiteRules(acc.Sites)
+		}
+	}
+	return nil
+}
+
+// updateSiteRules stores the blocked sites in config.json and applies them to the hosts file.
+// The change is recorded in the audit log on behalf of actor.
+func (tb *TelegramBot) updateSiteRules(actor int64, username string, rules []config.SiteRule) (err error) {
+	defer func() {
+		tb.audit.Record(actor, username, audit.ActionSetSites, map[string]interface{}{"blocked_sites": rules}, err)
+	}()
+
+	if err := tb.updateChild(username, func(account *config.ChildAccount) { account.Sites = rules }); err != nil {
+		return err
+	}
+
+	if tb.sites != nil {
+		tb.sites.SetRules(username, rules)
+		// The rules are saved; the next periodic refresh retries the hosts file
+		if err := tb.sites.Refresh(); err != nil {
+			log.Printf("Failed to update blocked sites: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseSiteRule parses "domain[, domain...][; days HH:MM-HH:MM]".
+func parseSiteRule(text string) (config.SiteRule, error) {
+	domainsText, scheduleText, hasSchedule := strings.Cut(text, ";")
+
+	var rule config.SiteRule
+	for _, part := range strings.Split(domainsText, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		domain, err := config.NormalizeDomain(part)
+		if err != nil {
+			return config.SiteRule{}, fmt.Errorf("Некорректный адрес сайта %q", strings.TrimSpace(part))
+		}
+		rule.Domains = append(rule.Domains, domain)
+	}
+	if len(rule.Domains) == 0 {
+		return config.SiteRule{}, fmt.Errorf("Укажите хотя бы один сайт")
+	}
+	if !hasSchedule {
+		return rule, nil
+	}
+
+	fields := strings.Fields(scheduleText)
+	if len(fields) != 2 {
+		return config.SiteRule{}, fmt.Errorf("Укажите дни и время, например sun-thu 20:00-24:00")
+	}
+	days, err := parseDays(fields[0])
+	if err != nil {
+		return config.SiteRule{}, err
+	}
+	start, end, ok := strings.Cut(fields[1], "-")
+	window := config.TimeWindow{Start: start, End: end}
+	if _, _, err := window.Minutes(); !ok || err != nil {
+		return config.SiteRule{}, fmt.Errorf("Некорректный интервал %q", fields[1])
+	}
+
+	rule.When = &config.Schedule{Weekly: []config.ScheduleRule{{Days: days, TimeWindow: window}}}
+	return rule, nil
+}
+
+// parseDays parses a comma separated list of day names and ranges such as "sun-thu,sat".
+func parseDays(text string) ([]string, error) {
+	var days []string
+	for _, part := range strings.Split(strings.ToLower(text), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			if part != "holiday" && dayIndex(part) < 0 {
+				return nil, fmt.Errorf("Неизвестный день %q", part)
+			}
+			days = append(days, part)
+			continue
+		}
+		i, j := dayIndex(from), dayIndex(to)
+		if i < 0 || j < 0 {
+			return nil, fmt.Errorf("Некорректный диапазон дней %q", part)
+		}
+		// Ranges may wrap around the week, e.g. "sun-thu"
+		for k := i; ; k = (k + 1) % len(weekDays) {
+			days = append(days, weekDays[k])
+			if k == j {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func dayIndex(name string) int {
+	for i, d := range weekDays {
+		if d == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func formatSiteSchedule(when *config.Schedule) string {
+	if when == nil {
+		return "всегда"
+	}
+	var parts []string
+	for _, rule := range when.Weekly {
+		parts = append(parts, fmt.Sprintf("%s %s–%s", strings.Join(rule.Days, ","), rule.Start, rule.End))
+	}
+	if len(parts) == 0 {
+		return "никогда"
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/tracker"
+)
+
+// categoryTitles are the names of the built-in application categories shown to parents.
+var categoryTitles = map[string]string{
+	config.CategoryGames:     "🎮 Игры",
+	config.CategoryVideo:     "🎬 Видео",
+	config.CategorySocial:    "💬 Общение",
+	config.CategoryBrowser:   "🌐 Браузеры",
+	config.CategoryEducation: "📚 Учёба",
+	config.CategoryOffice:    "📝 Документы",
+	config.CategorySystem:    "⚙️ Система",
+	config.CategoryOther:     "❓ Другое",
+}
+
+// categoryTitle returns the name of category shown to parents; categories from
+// the config are shown as they are named there.
+func categoryTitle(category string) string {
+	if title, ok := categoryTitles[category]; ok {
+		return title
+	}
+	return "📁 " + category
+}
+
+// summarizeReport groups a tracker report (application -> seconds) over the last days
+// days by category, leaving idle time out. Recorded window titles of the same period
+// split the time of their applications.
+func (tb *TelegramBot) summarizeReport(report map[string]int64, days int, username string) []apps.CategoryUsage {
+	usage := make(map[string]time.Duration, len(report))
+	for app, seconds := range report {
+		if app == tracker.IdleApp {
+			continue
+		}
+		usage[app] = time.Duration(seconds) * time.Second
+	}
+
+	titles := make(map[string]map[string]time.Duration)
+	for app, appTitles := range tb.tracker.TitleReport(days, username) {
+		titles[app] = make(map[string]time.Duration, len(appTitles))
+		for title, seconds := range appTitles {
+			titles[app][title] = time.Duration(seconds) * time.Second
+		}
+	}
+	return tb.classifier.Summarize(usage, titles)
+}
+
+// writeCategoryReport writes one line per category with its longest used applications
+// and adds a button per category that opens the list of its applications.
+func (tb *TelegramBot) writeCategoryReport(text *strings.Builder, summary []apps.CategoryUsage, period, username string) [][]tgbotapi.InlineKeyboardButton {
+	const shownApps = 3
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, cu := range summary {
+		names := make([]string, 0, shownApps)
+		for i, app := range cu.Apps {
+			if i == shownApps {
+				names = append(names, "…")
+				break
+			}
+			names = append(names, tgbotapi.EscapeText(tgbotapi.ModeMarkdown, app.Name))
+		}
+		text.WriteString(fmt.Sprintf("%s: %d мин\n    %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, categoryTitle(cu.Category)), int(cu.Time/time.Minute), strings.Join(names, ", ")))
+
+		if i := tb.categoryIndex(cu.Category); i >= 0 {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s — %d мин", categoryTitle(cu.Category), int(cu.Time/time.Minute)),
+					statsCallback(fmt.Sprintf("stats_cat_%s_%d", period, i), username),
+				),
+			))
+		}
+	}
+	return buttons
+}
+
+// categoryIndex returns the position of category in the classifier's list, which
+// identifies it in callback data, or -1.
+func (tb *TelegramBot) categoryIndex(category string) int {
+	for i, c := range tb.classifier.Categories() {
+		if c == category {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleCategoryStats shows the applications of one category from a report. The
+// data is "stats_cat_<period>_<category index>[_<username>]".
+func (tb *TelegramBot) handleCategoryStats(data string, chatID int64, messageID int, parent *config.Parent) error {
+	// Telegram messages are limited to 4096 characters
+	const shownTitles = 5
+
+	period, rest, _ := strings.Cut(strings.TrimPrefix(data, "stats_cat_"), "_")
+	indexText, username, _ := strings.Cut(rest, "_")
+
+	index, err := strconv.Atoi(indexText)
+	categories := tb.classifier.Categories()
+	if err != nil || index < 0 || index >= len(categories) || (period != "today" && period != "week") {
+		return fmt.Errorf("invalid category stats callback %q", data)
+	}
+	// The report belongs to the same children as the report it was opened from
+	if !callbackAllowed(parent, statsCallback("stats_"+period, username)) {
+		tb.audit.Record(parent.UserID, username, audit.ActionForbidden, map[string]interface{}{"callback": data}, nil)
+		tb.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "⛔ Недостаточно прав для этого действия."))
+		return nil
+	}
+	category := categories[index]
+
+	var report map[string]int64
+	title, days := "Сегодня", 1
+	switch {
+	case period == "today" && username == "":
+		report = tb.tracker.GetTodayReport()
+	case period == "today":
+		report = tb.tracker.GetTodayReportForUser(username)
+	case username == "":
+		report, title, days = tb.tracker.GetWeekReport(), "За неделю", 7
+	default:
+		report, title, days = tb.tracker.GetWeekReportForUser(username), "За неделю", 7
+	}
+
+	var msgText strings.Builder
+	msgText.WriteString(fmt.Sprintf("📊 *%s: %s*\n%s\n\n", title, tb.statsSubject(username), tgbotapi.EscapeText(tgbotapi.ModeMarkdown, categoryTitle(category))))
+	found := false
+	for _, cu := range tb.summarizeReport(report, days, username) {
+		if cu.Category != category {
+			continue
+		}
+		found = true
+		for _, app := range cu.Apps {
+			msgText.WriteString(fmt.Sprintf("• %s: %d мин (`%s`)\n",
+				tgbotapi.EscapeText(tgbotapi.ModeMarkdown, app.Name), int(app.Time/time.Minute), strings.Join(app.Executables, "`, `")))
+			for i, title := range app.Titles {
+				if i == shownTitles {
+					msgText.WriteString(fmt.Sprintf("    … и ещё %d\n", len(app.Titles)-shownTitles))
+					break
+				}
+				msgText.WriteString(fmt.Sprintf("    ◦ %s: %d мин\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, title.Title), int(title.Time/time.Minute)))
+			}
+		}
+		msgText.WriteString(fmt.Sprintf("\n📈 Итого: %d мин", int(cu.Time/time.Minute)))
+	}
+	if !found {
+		msgText.WriteString("Данных об активности нет.")
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+			{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", statsCallback("stats_"+period, username))},
+			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
+		},
+	}
+	_, err = tb.bot.Send(editMsg)
+	return err
+}
+
+// timelineBlock is a stretch of time spent in one application, as shown in the timeline.
+type timelineBlock struct {
+	user       string
+	name       string
+	idle       bool
+	start, end time.Time
+}
+
+// timelineBlocks merges intervals into blocks of the same application, bridging
+// gaps left by the tracker's sampling, and drops blocks too short to matter.
+func (tb *TelegramBot) timelineBlocks(intervals []tracker.Interval) []timelineBlock {
+	const (
+		maxGap      = time.Minute
+		minDuration = time.Minute
+	)
+
+	var blocks []timelineBlock
+	for _, iv := range intervals {
+		name := "💤 Бездействие"
+		if !iv.Idle {
+			name = tb.classifier.Classify(iv.App, iv.Title).Name
+		}
+		if n := len(blocks); n > 0 {
+			last := &blocks[n-1]
+			if last.user == iv.User && last.name == name && last.idle == iv.Idle && iv.Start.Sub(last.end) <= maxGap {
+				if iv.End.After(last.end) {
+					last.end = iv.End
+				}
+				continue
+			}
+			if last.end.Sub(last.start) < minDuration {
+				blocks = blocks[:n-1]
+			}
+		}
+		blocks = append(blocks, timelineBlock{user: iv.User, name: name, idle: iv.Idle, start: iv.Start, end: iv.End})
+	}
+	if n := len(blocks); n > 0 && blocks[n-1].end.Sub(blocks[n-1].start) < minDuration {
+		blocks = blocks[:n-1]
+	}
+	return blocks
+}
+
+// handleTimeline shows one day of a child as a list of blocks of continuous use.
+// The data is "stats_tl_<YYYY-MM-DD>[_<username>]".
+func (tb *TelegramBot) handleTimeline(data string, chatID int64, messageID int, parent *config.Parent) error {
+	// Telegram messages are limited to 4096 characters
+	const maxTextLength = 3500
+
+	dateText, username, _ := strings.Cut(strings.TrimPrefix(data, "stats_tl_"), "_")
+	day, err := time.ParseInLocation("2006-01-02", dateText, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid timeline callback %q", data)
+	}
+	if !callbackAllowed(parent, "stats_child_"+username) {
+		tb.audit.Record(parent.UserID, username, audit.ActionForbidden, map[string]interface{}{"callback": data}, nil)
+		tb.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "⛔ Недостаточно прав для этого действия."))
+		return nil
+	}
+
+	intervals, err := tb.tracker.Timeline(username, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("failed to read timeline: %v", err)
+	}
+	blocks := tb.timelineBlocks(intervals)
+
+	var msgText strings.Builder
+	msgText.WriteString(fmt.Sprintf("🕒 *Хронология: %s*\n%s\n\n", tb.statsSubject(username), day.Format("02.01.2006")))
+	if len(blocks) == 0 {
+		msgText.WriteString("Данных об активности нет.")
+	}
+	for i, block := range blocks {
+		if msgText.Len() > maxTextLength {
+			msgText.WriteString(fmt.Sprintf("… и ещё %d\n", len(blocks)-i))
+			break
+		}
+		line := fmt.Sprintf("`%s–%s` %s — %d мин", block.start.Format("15:04"), block.end.Format("15:04"),
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdown, block.name), int(block.end.Sub(block.start)/time.Minute))
+		if username == "" {
+			line += fmt.Sprintf(" (%s)", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, block.user))
+		}
+		msgText.WriteString(line + "\n")
+	}
+
+	navigation := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("◀️ "+day.AddDate(0, 0, -1).Format("02.01"), statsCallback("stats_tl_"+day.AddDate(0, 0, -1).Format("2006-01-02"), username)),
+	)
+	if next := day.AddDate(0, 0, 1); !next.After(time.Now()) {
+		navigation = append(navigation,
+			tgbotapi.NewInlineKeyboardButtonData(next.Format("02.01")+" ▶️", statsCallback("stats_tl_"+next.Format("2006-01-02"), username)))
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText.String())
+	editMsg.ParseMode = "Markdown"
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+			navigation,
+			{tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "stats_child_"+username)},
+			{tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu")},
+		},
+	}
+	_, err = tb.bot.Send(editMsg)
+	return err
+}
@@ -0,0 +1,305 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of the rendered images in pixels.
+const (
+	width  = 900
+	height = 520
+)
+
+// Layout of the images in pixels.
+const (
+	margin      = 20
+	titleHeight = 40
+	legendWidth = 300
+	axisWidth   = 60 // room for the value labels left of the bars
+	labelHeight = 30 // room for the bar labels under the bars
+	swatchSize  = 14
+	lineHeight  = 22
+
+	// maxSliceLabel is how many characters of a slice label fit in the legend
+	// next to its time and share.
+	maxSliceLabel = 18
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	foreground = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	gridColor  = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+
+	// palette colours series and slices in order; it repeats when there are more.
+	palette = []color.RGBA{
+		{0x4e, 0x79, 0xa7, 0xff},
+		{0xf2, 0x8e, 0x2b, 0xff},
+		{0xe1, 0x57, 0x59, 0xff},
+		{0x76, 0xb7, 0xb2, 0xff},
+		{0x59, 0xa1, 0x4f, 0xff},
+		{0xed, 0xc9, 0x48, 0xff},
+		{0xb0, 0x7a, 0xa1, 0xff},
+		{0xff, 0x9d, 0xa7, 0xff},
+		{0x9c, 0x75, 0x5f, 0xff},
+		{0xba, 0xb0, 0xac, 0xff},
+	}
+)
+
+// Series is one part of every stacked bar, e.g. a category on each day.
+type Series struct {
+	Label  string
+	Values []time.Duration // one per bar
+}
+
+// Slice is one part of a pie chart, e.g. an application.
+type Slice struct {
+	Label string
+	Value time.Duration
+}
+
+// StackedBars renders a PNG with one bar per label, each stacked from the values
+// of series at its position, and a legend of the series.
+func StackedBars(title string, labels []string, series []Series) ([]byte, error) {
+	face, err := fontFace()
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	img := newImage()
+	drawText(img, face, title, margin, margin+16, foreground)
+
+	var longest time.Duration
+	for i := range labels {
+		var total time.Duration
+		for _, s := range series {
+			if i < len(s.Values) {
+				total += s.Values[i]
+			}
+		}
+		longest = max(longest, total)
+	}
+	step := gridStep(longest)
+	top := step * time.Duration(max(1, int(math.Ceil(float64(longest)/float64(step)))))
+
+	plot := image.Rect(margin+axisWidth, margin+titleHeight, width-margin-legendWidth, height-margin-labelHeight)
+	y := func(d time.Duration) int {
+		return plot.Max.Y - int(float64(plot.Dy())*float64(d)/float64(top))
+	}
+
+	// Grid lines with their values
+	for d := time.Duration(0); d <= top; d += step {
+		fillRect(img, image.Rect(plot.Min.X, y(d), plot.Max.X, y(d)+1), gridColor)
+		text := formatDuration(d)
+		drawText(img, face, text, plot.Min.X-8-textWidth(face, text), y(d)+5, foreground)
+	}
+
+	if len(labels) > 0 {
+		slot := plot.Dx() / len(labels)
+		barWidth := slot * 3 / 5
+		for i, label := range labels {
+			left := plot.Min.X + i*slot + (slot-barWidth)/2
+			var stacked time.Duration
+			for j, s := range series {
+				if i >= len(s.Values) || s.Values[i] <= 0 {
+					continue
+				}
+				fillRect(img, image.Rect(left, y(stacked+s.Values[i]), left+barWidth, y(stacked)), palette[j%len(palette)])
+				stacked += s.Values[i]
+			}
+			center := left + barWidth/2
+			drawText(img, face, label, center-textWidth(face, label)/2, plot.Max.Y+20, foreground)
+		}
+	}
+	fillRect(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+1), foreground)
+
+	legend := make([]string, len(series))
+	for i, s := range series {
+		legend[i] = s.Label
+	}
+	drawLegend(img, face, legend)
+	return encode(img)
+}
+
+// Pie renders a PNG with a pie chart of slices and a legend with their shares.
+func Pie(title string, slices []Slice) ([]byte, error) {
+	face, err := fontFace()
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	img := newImage()
+	drawText(img, face, title, margin, margin+16, foreground)
+
+	var total time.Duration
+	for _, s := range slices {
+		if s.Value > 0 {
+			total += s.Value
+		}
+	}
+
+	// Each slice ends at this fraction of the circle, clockwise from the top
+	ends := make([]float64, len(slices))
+	var sum time.Duration
+	for i, s := range slices {
+		if s.Value > 0 {
+			sum += s.Value
+		}
+		if total > 0 {
+			ends[i] = float64(sum) / float64(total)
+		}
+	}
+
+	area := image.Rect(margin, margin+titleHeight, width-margin-legendWidth, height-margin)
+	radius := min(area.Dx(), area.Dy()) / 2
+	cx, cy := area.Min.X+area.Dx()/2, area.Min.Y+area.Dy()/2
+	if total > 0 {
+		for py := cy - radius; py <= cy+radius; py++ {
+			for px := cx - radius; px <= cx+radius; px++ {
+				dx, dy := float64(px-cx), float64(py-cy)
+				if dx*dx+dy*dy > float64(radius*radius) {
+					continue
+				}
+				fraction := math.Atan2(dx, -dy) / (2 * math.Pi)
+				if fraction < 0 {
+					fraction++
+				}
+				for i, end := range ends {
+					if fraction < end {
+						img.Set(px, py, palette[i%len(palette)])
+						break
+					}
+				}
+			}
+		}
+	} else {
+		text := "Нет данных"
+		drawText(img, face, text, cx-textWidth(face, text)/2, cy, foreground)
+	}
+
+	legend := make([]string, len(slices))
+	for i, s := range slices {
+		share := 0.0
+		if total > 0 {
+			share = float64(s.Value) * 100 / float64(total)
+		}
+		label := s.Label
+		if runes := []rune(label); len(runes) > maxSliceLabel {
+			label = string(runes[:maxSliceLabel-1]) + "…"
+		}
+		legend[i] = fmt.Sprintf("%s — %s (%.0f%%)", label, formatDuration(s.Value), share)
+	}
+	drawLegend(img, face, legend)
+	return encode(img)
+}
+
+// gridStep returns the distance between grid lines for values up to longest,
+// so that there are at most six lines.
+func gridStep(longest time.Duration) time.Duration {
+	steps := []time.Duration{
+		5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+		time.Hour, 2 * time.Hour, 3 * time.Hour, 4 * time.Hour, 6 * time.Hour,
+	}
+	for _, step := range steps {
+		if longest <= 6*step {
+			return step
+		}
+	}
+	return 12 * time.Hour
+}
+
+// formatDuration renders d as hours and minutes, e.g. "1 ч 05 мин".
+func formatDuration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%d мин", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%d ч", minutes/60)
+	default:
+		return fmt.Sprintf("%d ч %02d мин", minutes/60, minutes%60)
+	}
+}
+
+func newImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	return img
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawLegend lists labels with the colours of their series right of the chart,
+// shortening those that do not fit.
+func drawLegend(img *image.RGBA, face font.Face, labels []string) {
+	left := width - legendWidth
+	room := legendWidth - margin - swatchSize - 8
+	for i, label := range labels {
+		top := margin + titleHeight + i*lineHeight
+		if top+lineHeight > height-margin {
+			break
+		}
+		for runes := []rune(label); textWidth(face, label) > room && len(runes) > 1; {
+			runes = runes[:len(runes)-1]
+			label = string(runes) + "…"
+		}
+		fillRect(img, image.Rect(left, top, left+swatchSize, top+swatchSize), palette[i%len(palette)])
+		drawText(img, face, label, left+swatchSize+8, top+swatchSize-2, foreground)
+	}
+}
+
+// drawText draws text with its baseline starting at x, y.
+func drawText(img *image.RGBA, face font.Face, text string, x, y int, c color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func textWidth(face font.Face, text string) int {
+	return font.MeasureString(face, text).Round()
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	fontOnce sync.Once
+	goFont   *opentype.Font
+	fontErr  error
+)
+
+// fontFace returns a face of the font of all text: Go Regular, which is compiled in
+// and covers Cyrillic. Faces are not safe for concurrent use, so every image gets
+// its own.
+func fontFace() (font.Face, error) {
+	fontOnce.Do(func() {
+		goFont, fontErr = opentype.Parse(goregular.TTF)
+	})
+	if fontErr != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", fontErr)
+	}
+	return opentype.NewFace(goFont, &opentype.FaceOptions{Size: 13, DPI: 72, Hinting: font.HintingFull})
+}
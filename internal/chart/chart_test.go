@@ -0,0 +1,160 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+var week = []string{"пн", "вт", "ср", "чт", "пт", "сб", "вс"}
+
+// decode decodes a rendered chart and checks its size and that something is drawn on it.
+func decode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		t.Fatalf("image is %dx%d, want %dx%d", b.Dx(), b.Dy(), width, height)
+	}
+	if count(img, background) == width*height {
+		t.Fatal("image is blank")
+	}
+	return img
+}
+
+// count returns how many pixels of img are exactly c.
+func count(img image.Image, c color.RGBA) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == c {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// swatchPixels is how many pixels of its colour a series or slice gets in the legend.
+const swatchPixels = swatchSize * swatchSize
+
+func TestStackedBars(t *testing.T) {
+	hours := func(values ...float64) []time.Duration {
+		d := make([]time.Duration, len(values))
+		for i, v := range values {
+			d[i] = time.Duration(v * float64(time.Hour))
+		}
+		return d
+	}
+	tests := []struct {
+		name   string
+		labels []string
+		series []Series
+		drawn  []bool // whether each series shows up in the bars, not just the legend
+	}{
+		{name: "no bars"},
+		{
+			name:   "one bar",
+			labels: []string{"пн"},
+			series: []Series{{Label: "Игры", Values: hours(1.5)}},
+			drawn:  []bool{true},
+		},
+		{
+			name:   "a week of stacked bars",
+			labels: week,
+			series: []Series{
+				{Label: "Игры", Values: hours(1, 2, 0, 0.5, 3, 4, 2)},
+				{Label: "Браузер", Values: hours(0.5, 0, 1, 1, 0, 0.25, 0)},
+				{Label: "Учёба", Values: hours(2, 2, 2, 2, 2)}, // no values for the weekend
+			},
+			drawn: []bool{true, true, true},
+		},
+		{
+			name:   "an all-zero week",
+			labels: week,
+			series: []Series{{Label: "Игры", Values: make([]time.Duration, 7)}, {Label: "Браузер"}},
+			drawn:  []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := StackedBars("Время за компьютером", tt.labels, tt.series)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img := decode(t, data)
+			for i := range tt.series {
+				n := count(img, palette[i%len(palette)])
+				if n < swatchPixels {
+					t.Errorf("series %d has %d pixels, want at least its legend swatch", i, n)
+				}
+				if drawn := n > swatchPixels; drawn != tt.drawn[i] {
+					t.Errorf("series %d has %d pixels, drawn as a bar = %v, want %v", i, n, drawn, tt.drawn[i])
+				}
+			}
+			if len(tt.series) < len(palette) && count(img, palette[len(tt.series)]) != 0 {
+				t.Errorf("colour of series %d used with %d series", len(tt.series), len(tt.series))
+			}
+		})
+	}
+}
+
+func TestPie(t *testing.T) {
+	var many []Slice
+	for i := range 12 {
+		many = append(many, Slice{Label: fmt.Sprintf("program-with-a-long-name-%d.exe", i), Value: time.Duration(i+1) * time.Minute})
+	}
+	tests := []struct {
+		name   string
+		slices []Slice
+		drawn  bool
+	}{
+		{name: "no slices"},
+		{name: "one slice", slices: []Slice{{Label: "chrome.exe", Value: time.Hour}}, drawn: true},
+		{name: "more slices than colours", slices: many, drawn: true},
+		{name: "all zero", slices: []Slice{{Label: "chrome.exe"}, {Label: "steam.exe"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Pie("Программы", tt.slices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img := decode(t, data)
+			coloured := 0
+			for _, c := range palette {
+				coloured += count(img, c)
+			}
+			swatches := len(tt.slices) * swatchPixels
+			if drawn := coloured > swatches; drawn != tt.drawn {
+				t.Errorf("%d coloured pixels, pie drawn = %v, want %v", coloured, drawn, tt.drawn)
+			}
+		})
+	}
+}
+
+func TestGridStep(t *testing.T) {
+	tests := []struct {
+		longest time.Duration
+		want    time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{30 * time.Minute, 5 * time.Minute},
+		{31 * time.Minute, 10 * time.Minute},
+		{5 * time.Hour, time.Hour},
+		{24 * time.Hour, 4 * time.Hour},
+		{100 * time.Hour, 12 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := gridStep(tt.longest); got != tt.want {
+			t.Errorf("gridStep(%v) = %v, want %v", tt.longest, got, tt.want)
+		}
+	}
+}
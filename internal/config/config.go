@@ -1,399 +1,914 @@
-//go:build windows
-
 package config
 
 import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"math/big"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"unsafe"
-
-	"golang.org/x/sys/windows"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+	"github.com/Hepri/parental/internal/platform"
 )
 
 type ChildAccount struct {
-	Username string `json:"username"`
-	FullName string `json:"full_name"`
-	Password string `json:"password"`
+	Username string      `json:"username"`
+	FullName string      `json:"full_name"`
+	Password string      `json:"password"`
+	Quota    *DailyQuota `json:"daily_quota,omitempty"`   // nil = без ограничения
+	Schedule *Schedule   `json:"allowed_hours,omitempty"` // nil = в любое время
+	Apps     *AppRules   `json:"apps,omitempty"`          // nil = любые программы
+	Sites    []SiteRule  `json:"blocked_sites,omitempty"` // Заблокированные сайты
+
+	CaptureTitles bool `json:"capture_titles,omitempty"` // Записывать заголовки окон (вкладки браузера) в статистику
 }
 
-type Config struct {
-	TelegramBotToken     string         `json:"telegram_bot_token"`
-	AuthorizedUserIDs    []int64        `json:"authorized_user_ids"`
-	ChildAccounts        []ChildAccount `json:"child_accounts"`
-	DataRetentionDays    int            `json:"data_retention_days"`
-	ReconnectInterval    int            `json:"reconnect_interval_seconds"` // Интервал переподключения в секундах
-	MaxReconnectAttempts int            `json:"max_reconnect_attempts"`     // Максимальное количество попыток переподключения (0 = бесконечно)
-}
-
-var (
-	netapi32 = windows.NewLazySystemDLL("netapi32.dll")
-	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
-
-	procNetUserGetInfo          = netapi32.NewProc("NetUserGetInfo")
-	procNetUserAdd              = netapi32.NewProc("NetUserAdd")
-	procNetUserSetInfo          = netapi32.NewProc("NetUserSetInfo")
-	procNetLocalGroupAddMembers = netapi32.NewProc("NetLocalGroupAddMembers")
+// Parent roles, from the most to the least powerful.
+const (
+	RoleOwner     = "owner"     // everything, including passwords and shutting down the computer
+	RoleParent    = "parent"    // sessions, quotas, schedules, password resets and statistics
+	RoleCaretaker = "caretaker" // grant, extend, pause and lock sessions; statistics
+	RoleViewer    = "viewer"    // statistics and computer status only
 )
 
+// Parent assigns a bot role to a Telegram user.
+type Parent struct {
+	UserID          int64    `json:"user_id"`
+	Name            string   `json:"name,omitempty"`
+	Role            string   `json:"role"`
+	MaxGrantMinutes int      `json:"max_grant_minutes,omitempty"` // Максимум минут за одну выдачу или продление (0 = без ограничения)
+	Children        []string `json:"children,omitempty"`          // Какими детьми управляет (пусто = всеми)
+	Digest          *Digest  `json:"digest,omitempty"`            // Свои настройки сводок (nil = общие настройки digest)
+}
+
+// Manages reports whether the parent may act on the child account username.
+func (p *Parent) Manages(username string) bool {
+	if len(p.Children) == 0 {
+		return true
+	}
+	for _, child := range p.Children {
+		if strings.EqualFold(child, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// ManagesAll reports whether the parent is not restricted to some of the children.
+func (p *Parent) ManagesAll() bool {
+	return len(p.Children) == 0
+}
+
+// DailyQuota limits how many minutes a child may use the computer per calendar day.
+type DailyQuota struct {
+	WeekdayMinutes int `json:"weekday_minutes"`
+	WeekendMinutes int `json:"weekend_minutes"`
+}
+
+// MinutesFor returns the allowance for the calendar day containing t.
+func (q *DailyQuota) MinutesFor(t time.Time) int {
+	switch t.Weekday() {
+	case time.Saturday, time.Sunday:
+		return q.WeekendMinutes
+	default:
+		return q.WeekdayMinutes
+	}
+}
+
+// What the service does with an application a child may not use.
 const (
-	USER_PRIV_USER        = 1
-	UF_SCRIPT             = 1
-	UF_NORMAL_ACCOUNT     = 512
-	UF_DONT_EXPIRE_PASSWD = 65536
-	UF_PASSWD_CANT_CHANGE = 64
+	AppActionClose    = "close"    // terminate the process
+	AppActionMinimize = "minimize" // minimize its window
+	AppActionLock     = "lock"     // lock the child's session
 )
 
-type UserInfo1 struct {
-	Name        *uint16
-	Password    *uint16
-	PasswordAge uint32
-	Priv        uint32
-	HomeDir     *uint16
-	Comment     *uint16
-	Flags       uint32
-	ScriptPath  *uint16
+// Built-in application categories. Reports group applications by category, and
+// category_limits may use them without listing applications in app_categories.
+const (
+	CategoryBrowser   = "browser"
+	CategoryGames     = "games"
+	CategoryVideo     = "video"
+	CategoryEducation = "education"
+	CategorySocial    = "social"
+	CategoryOffice    = "office"
+	CategorySystem    = "system"
+	CategoryOther     = "other" // applications no rule knows
+)
+
+// BuiltinCategories lists the built-in categories in the order reports show them.
+var BuiltinCategories = []string{
+	CategoryGames, CategoryVideo, CategorySocial, CategoryBrowser,
+	CategoryEducation, CategoryOffice, CategorySystem, CategoryOther,
 }
 
-// USER_INFO_1003 for NetUserSetInfo (set password)
-type UserInfo1003 struct {
-	Password *uint16
+// AppLabel names an application in reports and puts it in a category. Labels take
+// precedence over app_categories and the built-in rules.
+type AppLabel struct {
+	App      string `json:"app"`                // Программа, например "javaw.exe"
+	Title    string `json:"title,omitempty"`    // Только окна, в заголовке которых есть этот текст
+	Name     string `json:"name,omitempty"`     // Название в отчётах, например "Minecraft"
+	Category string `json:"category,omitempty"` // Категория, например "games"
 }
 
-func LoadConfig(configPath string) (*Config, error) {
-	// Ensure config file has proper permissions (admin only)
-	if err := protectConfigFile(configPath); err != nil {
-		return nil, fmt.Errorf("failed to protect config file: %v", err)
-	}
+// AppRules restricts the applications a child may use. Applications are matched by
+// executable name, case-insensitively and with or without ".exe".
+type AppRules struct {
+	Blocked        []string       `json:"blocked,omitempty"`         // Запрещённые программы, например "Roblox.exe"
+	Allowed        []string       `json:"allowed,omitempty"`         // Если не пусто, разрешены только эти программы
+	Limits         map[string]int `json:"limits,omitempty"`          // Программа -> минут в день
+	CategoryLimits map[string]int `json:"category_limits,omitempty"` // Категория (встроенная или из app_categories) -> минут в день
+	Action         string         `json:"action,omitempty"`          // "close" (по умолчанию), "minimize" или "lock" (заблокировать сеанс)
+}
 
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %v", err)
+// Empty reports whether the rules restrict nothing.
+func (r *AppRules) Empty() bool {
+	return r == nil || (len(r.Blocked) == 0 && len(r.Allowed) == 0 && len(r.Limits) == 0 && len(r.CategoryLimits) == 0)
+}
+
+// Clone returns a deep copy of the rules.
+func (r *AppRules) Clone() *AppRules {
+	if r == nil {
+		return nil
+	}
+	c := &AppRules{
+		Blocked: append([]string(nil), r.Blocked...),
+		Allowed: append([]string(nil), r.Allowed...),
+		Action:  r.Action,
 	}
+	if r.Limits != nil {
+		c.Limits = make(map[string]int, len(r.Limits))
+		for app, minutes := range r.Limits {
+			c.Limits[app] = minutes
+		}
+	}
+	if r.CategoryLimits != nil {
+		c.CategoryLimits = make(map[string]int, len(r.CategoryLimits))
+		for category, minutes := range r.CategoryLimits {
+			c.CategoryLimits[category] = minutes
+		}
+	}
+	return c
+}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %v", err)
+func validateAppRules(r *AppRules, categories map[string]bool) error {
+	switch r.Action {
+	case "", AppActionClose, AppActionMinimize, AppActionLock:
+	default:
+		return fmt.Errorf("invalid action %q: must be %q, %q or %q", r.Action, AppActionClose, AppActionMinimize, AppActionLock)
+	}
+	for _, app := range append(append([]string(nil), r.Blocked...), r.Allowed...) {
+		if strings.TrimSpace(app) == "" {
+			return fmt.Errorf("empty application name")
+		}
 	}
+	for app, minutes := range r.Limits {
+		if strings.TrimSpace(app) == "" {
+			return fmt.Errorf("empty application name")
+		}
+		if minutes <= 0 || minutes > 1440 {
+			return fmt.Errorf("invalid limit for %s: %d minutes, must be 1-1440", app, minutes)
+		}
+	}
+	for category, minutes := range r.CategoryLimits {
+		if !categories[category] {
+			return fmt.Errorf("unknown application category %q", category)
+		}
+		if minutes <= 0 || minutes > 1440 {
+			return fmt.Errorf("invalid limit for category %s: %d minutes, must be 1-1440", category, minutes)
+		}
+	}
+	return nil
+}
 
-	// Validate config
-	if config.TelegramBotToken == "" || config.TelegramBotToken == "YOUR_BOT_TOKEN_HERE" {
-		return nil, fmt.Errorf("telegram bot token not configured")
+// SiteRule blocks websites for a child, at all times or only at the hours in When.
+type SiteRule struct {
+	Domains []string  `json:"domains"`        // Домены, например "tiktok.com" (вместе с www.)
+	When    *Schedule `json:"when,omitempty"` // Когда блокировать, в формате allowed_hours (nil = всегда)
+}
+
+// ActiveAt reports whether the rule blocks its domains at t.
+func (r SiteRule) ActiveAt(t time.Time, holiday bool) bool {
+	return r.When == nil || r.When.Covers(t, holiday)
+}
+
+// CloneSiteRules returns a deep copy of rules.
+func CloneSiteRules(rules []SiteRule) []SiteRule {
+	if rules == nil {
+		return nil
 	}
+	c := make([]SiteRule, len(rules))
+	for i, r := range rules {
+		c[i] = SiteRule{Domains: append([]string(nil), r.Domains...), When: r.When.Clone()}
+	}
+	return c
+}
 
-	if len(config.AuthorizedUserIDs) == 0 {
-		return nil, fmt.Errorf("no authorized user IDs configured")
+// NormalizeDomain turns user input such as "https://www.TikTok.com/foo" into "tiktok.com".
+func NormalizeDomain(input string) (string, error) {
+	domain := strings.ToLower(strings.TrimSpace(input))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
 	}
+	if i := strings.IndexAny(domain, "/?#:"); i >= 0 {
+		domain = domain[:i]
+	}
+	domain = strings.TrimSuffix(strings.TrimPrefix(domain, "www."), ".")
 
-	if len(config.ChildAccounts) == 0 {
-		return nil, fmt.Errorf("no child accounts configured")
+	labels := strings.Split(domain, ".")
+	if len(domain) > 253 || len(labels) < 2 {
+		return "", fmt.Errorf("invalid domain %q", input)
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", fmt.Errorf("invalid domain %q", input)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return "", fmt.Errorf("invalid domain %q", input)
+			}
+		}
 	}
+	return domain, nil
+}
 
-	if config.DataRetentionDays <= 0 {
-		config.DataRetentionDays = 7 // Default to 7 days
+func validateSiteRules(rules []SiteRule) error {
+	for _, rule := range rules {
+		if len(rule.Domains) == 0 {
+			return fmt.Errorf("rule without domains")
+		}
+		for _, domain := range rule.Domains {
+			if _, err := NormalizeDomain(domain); err != nil {
+				return err
+			}
+		}
+		if rule.When != nil {
+			if err := validateSchedule(rule.When); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	// Set default reconnect settings
-	if config.ReconnectInterval <= 0 {
-		config.ReconnectInterval = 30 // Default to 30 seconds
+// TimeWindow is a daily interval in "HH:MM" form; End may be "24:00".
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ScheduleRule allows the window on the listed days ("mon".."sun" or "holiday").
+type ScheduleRule struct {
+	Days []string `json:"days"`
+	TimeWindow
+}
+
+// Schedule describes the hours a child may use the computer.
+// An entry in Exceptions replaces the weekly rules for that date; an empty list closes the day.
+type Schedule struct {
+	Weekly     []ScheduleRule          `json:"weekly"`
+	Exceptions map[string][]TimeWindow `json:"exceptions,omitempty"` // date (YYYY-MM-DD) -> windows
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "mon",
+	time.Tuesday:   "tue",
+	time.Wednesday: "wed",
+	time.Thursday:  "thu",
+	time.Friday:    "fri",
+	time.Saturday:  "sat",
+	time.Sunday:    "sun",
+}
+
+// Minutes returns the window bounds as minutes since midnight.
+func (w TimeWindow) Minutes() (start, end int, err error) {
+	if start, err = parseClock(w.Start); err != nil {
+		return 0, 0, err
 	}
-	if config.MaxReconnectAttempts < 0 {
-		config.MaxReconnectAttempts = 0 // Default to infinite attempts
+	if end, err = parseClock(w.End); err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("window %s-%s ends before it starts", w.Start, w.End)
 	}
+	return start, end, nil
+}
 
-	return &config, nil
+// ParseClock returns the minutes since midnight of s in "HH:MM" form.
+func ParseClock(s string) (int, error) {
+	return parseClock(s)
 }
 
-func protectConfigFile(configPath string) error {
-	// Simple file protection - set read-only for non-admin users
-	// In a production environment, you would use Windows ACLs
-	return nil
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return h*60 + m, nil
 }
 
-func getAdminSID() (*windows.SID, error) {
-	// Simplified - return nil for now
-	// In production, you would create proper SID
-	return nil, nil
+// WindowsFor returns the allowed windows for the calendar day containing day.
+// Holidays use the "holiday" rules if any exist, otherwise the Sunday rules.
+func (s *Schedule) WindowsFor(day time.Time, holiday bool) []TimeWindow {
+	if windows, ok := s.Exceptions[day.Format("2006-01-02")]; ok {
+		return windows
+	}
+
+	name := weekdayNames[day.Weekday()]
+	if holiday {
+		name = "sun"
+		for _, rule := range s.Weekly {
+			if containsDay(rule.Days, "holiday") {
+				name = "holiday"
+				break
+			}
+		}
+	}
+
+	var windows []TimeWindow
+	for _, rule := range s.Weekly {
+		if containsDay(rule.Days, name) {
+			windows = append(windows, rule.TimeWindow)
+		}
+	}
+	return windows
 }
 
-func createDACL(adminSID *windows.SID) ([]byte, error) {
-	// Simplified - return empty DACL for now
-	// In production, you would create proper DACL
-	return []byte{}, nil
+// Covers reports whether t falls into one of the windows of its calendar day.
+func (s *Schedule) Covers(t time.Time, holiday bool) bool {
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s.WindowsFor(t, holiday) {
+		start, end, err := w.Minutes()
+		if err == nil && minute >= start && minute < end {
+			return true
+		}
+	}
+	return false
 }
 
-func EnsureChildAccounts(config *Config) error {
-	for i, account := range config.ChildAccounts {
-		exists, err := userExists(account.Username)
-		if err != nil {
-			return fmt.Errorf("failed to check if user %s exists: %v", account.Username, err)
+// Clone returns a deep copy of the schedule.
+func (s *Schedule) Clone() *Schedule {
+	if s == nil {
+		return nil
+	}
+	c := &Schedule{Weekly: make([]ScheduleRule, len(s.Weekly))}
+	for i, rule := range s.Weekly {
+		c.Weekly[i] = ScheduleRule{Days: append([]string(nil), rule.Days...), TimeWindow: rule.TimeWindow}
+	}
+	if s.Exceptions != nil {
+		c.Exceptions = make(map[string][]TimeWindow, len(s.Exceptions))
+		for date, windows := range s.Exceptions {
+			c.Exceptions[date] = append([]TimeWindow{}, windows...)
 		}
+	}
+	return c
+}
 
-		if !exists {
-			// Generate random password if not set
-			if account.Password == "" || account.Password == "auto-generated-on-creation" {
-				password, err := generateRandomPassword()
-				if err != nil {
-					return fmt.Errorf("failed to generate password for %s: %v", account.Username, err)
-				}
-				config.ChildAccounts[i].Password = password
-			}
+func containsDay(days []string, name string) bool {
+	for _, d := range days {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
 
-			// Create user account
-			if err := createUserAccount(account); err != nil {
-				// Try alternative method if NetUserAdd fails
-				if err2 := createUserAccountAlternative(account); err2 != nil {
-					return fmt.Errorf("failed to create user account %s: %v (alternative method also failed: %v)", account.Username, err, err2)
-				}
-			}
+// Categories returns every known application category: the built-in ones and
+// those used in app_categories and app_labels.
+func (c *Config) Categories() map[string]bool {
+	categories := make(map[string]bool)
+	for _, category := range BuiltinCategories {
+		categories[category] = true
+	}
+	for category := range c.AppCategories {
+		categories[category] = true
+	}
+	for _, label := range c.AppLabels {
+		if label.Category != "" {
+			categories[label.Category] = true
+		}
+	}
+	return categories
+}
 
-			// Add to Users group (localized name)
-			usersGroup, err := getBuiltinUsersGroupName()
-			if err != nil {
-				return fmt.Errorf("failed to resolve Users group name: %v", err)
-			}
-			if err := addUserToGroup(account.Username, usersGroup); err != nil {
-				return fmt.Errorf("failed to add user %s to Users group: %v", account.Username, err)
-			}
-			fmt.Printf("✓ Created user account and added to group: %s\n", account.Username)
-		} else {
-			fmt.Printf("✓ User account already exists: %s\n", account.Username)
-			// Ensure password matches config (reset if needed)
-			if account.Password == "" || account.Password == "auto-generated-on-creation" {
-				pwd, err := generateRandomPassword()
-				if err != nil {
-					return fmt.Errorf("failed to generate password for %s: %v", account.Username, err)
-				}
-				config.ChildAccounts[i].Password = pwd
+// IsHoliday reports whether the calendar day containing day is listed in Holidays.
+func (c *Config) IsHoliday(day time.Time) bool {
+	date := day.Format("2006-01-02")
+	for _, h := range c.Holidays {
+		if h == date {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSchedule(s *Schedule) error {
+	for _, rule := range s.Weekly {
+		for _, d := range rule.Days {
+			valid := strings.EqualFold(d, "holiday")
+			for _, name := range weekdayNames {
+				valid = valid || strings.EqualFold(d, name)
 			}
-			if err := SetUserPassword(account.Username, config.ChildAccounts[i].Password); err != nil {
-				return fmt.Errorf("failed to set password for %s: %v", account.Username, err)
+			if !valid {
+				return fmt.Errorf("unknown day %q", d)
 			}
-			// Ensure in Users group
-			usersGroup, err := getBuiltinUsersGroupName()
-			if err == nil {
-				_ = addUserToGroup(account.Username, usersGroup)
+		}
+		if _, _, err := rule.Minutes(); err != nil {
+			return err
+		}
+	}
+	for date, windows := range s.Exceptions {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid exception date %q", date)
+		}
+		for _, w := range windows {
+			if _, _, err := w.Minutes(); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
+}
 
-	// Save updated config with generated passwords
-	return saveConfig(config)
+type Config struct {
+	TelegramBotToken     string              `json:"telegram_bot_token"`
+	AuthorizedUserIDs    []int64             `json:"authorized_user_ids"` // Владельцы (роль owner)
+	Parents              []Parent            `json:"parents,omitempty"`   // Остальные взрослые с ролями и ограничениями
+	ChildAccounts        []ChildAccount      `json:"child_accounts"`
+	DataRetentionDays    int                 `json:"data_retention_days"`
+	ReconnectInterval    int                 `json:"reconnect_interval_seconds"` // Интервал переподключения в секундах
+	MaxReconnectAttempts int                 `json:"max_reconnect_attempts"`     // Максимальное количество попыток переподключения (0 = бесконечно)
+	Holidays             []string            `json:"holidays,omitempty"`         // Праздничные дни (YYYY-MM-DD), расписание как в выходной
+	IdleThresholdSeconds int                 `json:"idle_threshold_seconds"`     // Бездействие дольше порога считается простоем (0 = 300 секунд)
+	ExcludeIdleTime      bool                `json:"exclude_idle_time"`          // Не списывать простой с дневного лимита и длительности сеанса
+	PinLength            int                 `json:"pin_length"`                 // Длина одноразового PIN для входа (0 = 6)
+	PinAlphabet          string              `json:"pin_alphabet,omitempty"`     // Символы PIN (пусто = только цифры)
+	PinRecipients        string              `json:"pin_recipients,omitempty"`   // Кому отправлять PIN: "requester" (по умолчанию) или "all"
+	WarningMinutes       []int               `json:"warning_minutes"`            // За сколько минут до окончания предупреждать ребёнка (нет поля = 10, 5, 1; [] = не предупреждать)
+	TimeRequests         TimeRequests        `json:"time_requests"`              // Запросы дополнительного времени от детей
+	AppCategories        map[string][]string `json:"app_categories,omitempty"`   // Категории программ для отчётов и лимитов, например "games": ["Minecraft.exe"]
+	AppLabels            []AppLabel          `json:"app_labels,omitempty"`       // Названия и категории программ в отчётах
+	WindowTitles         WindowTitles        `json:"window_titles"`              // Хранение заголовков окон детей с capture_titles
+	Digest               Digest              `json:"digest"`                     // Сводки по расписанию для всех, у кого нет своих настроек
 }
 
-func userExists(username string) (bool, error) {
-	// Try NetUserGetInfo first
-	userName, _ := windows.UTF16PtrFromString(username)
+// Sections of a digest for Digest.Sections.
+const (
+	DigestQuota     = "quota"      // screen time against the daily quota
+	DigestApps      = "apps"       // most used applications
+	DigestSessions  = "sessions"   // number of sessions granted
+	DigestLateNight = "late_night" // usage during the night hours
+	DigestCompare   = "compare"    // change against the previous period
+)
 
-	var buf *byte
-	var bufSize uint32
+// DigestSections lists every digest section in the order they are shown.
+var DigestSections = []string{DigestQuota, DigestApps, DigestSessions, DigestLateNight, DigestCompare}
 
-	ret, _, _ := procNetUserGetInfo.Call(
-		0, // NULL for local computer
-		uintptr(unsafe.Pointer(userName)),
-		1, // INFO_LEVEL
-		uintptr(unsafe.Pointer(&buf)),
-		uintptr(unsafe.Pointer(&bufSize)),
-	)
+// Digest configures the usage summaries a parent receives on a schedule.
+type Digest struct {
+	DailyAt   string     `json:"daily_at,omitempty"`   // Время ежедневной сводки "HH:MM" (пусто = не отправлять)
+	WeeklyAt  string     `json:"weekly_at,omitempty"`  // Время еженедельной сводки "HH:MM" (пусто = не отправлять)
+	WeeklyDay string     `json:"weekly_day,omitempty"` // День еженедельной сводки: mon..sun (пусто = sun)
+	Sections  []string   `json:"sections,omitempty"`   // Разделы сводки (пусто = все): quota, apps, sessions, late_night, compare
+	Night     TimeWindow `json:"night"`                // Поздние часы для late_night (пусто = 22:00–06:00)
+}
 
-	if ret == 0 {
-		// User exists
-		return true, nil
-	} else if ret == 2221 { // NERR_UserNotFound
-		return false, nil
-	}
+// Includes reports whether the digest shows section.
+func (d Digest) Includes(section string) bool {
+	return len(d.Sections) == 0 || slices.Contains(d.Sections, section)
+}
 
-	// If NetUserGetInfo fails, try alternative method
-	cmd := exec.Command("net", "user", username)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return false, fmt.Errorf("both NetUserGetInfo and net user command failed: NetUserGetInfo error %d, net user error: %v", ret, err)
-	}
+// WeeklyWeekday returns the day the weekly digest is sent on.
+func (d Digest) WeeklyWeekday() time.Weekday {
+	day, _ := weekdayByName(d.WeeklyDay)
+	return day
+}
 
-	// Check if output contains "User name" (user exists) or "The user name could not be found"
-	outputStr := string(output)
-	if contains(outputStr, "User name") && !contains(outputStr, "could not be found") {
-		return true, nil
+// weekdayByName returns the day named name ("mon".."sun"); unknown names give Sunday.
+func weekdayByName(name string) (time.Weekday, bool) {
+	for day, n := range weekdayNames {
+		if strings.EqualFold(n, name) {
+			return day, true
+		}
 	}
-
-	return false, nil
+	return time.Sunday, false
 }
 
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || (len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || containsSubstring(s, substr))))
+// DigestFor returns the digest settings of parent.
+func (c *Config) DigestFor(parent *Parent) Digest {
+	if parent.Digest != nil {
+		return *parent.Digest
+	}
+	return c.Digest
 }
 
-func containsSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+func validateDigest(d *Digest) error {
+	for _, at := range []string{d.DailyAt, d.WeeklyAt} {
+		if at == "" {
+			continue
+		}
+		if minutes, err := parseClock(at); err != nil || minutes >= 24*60 {
+			return fmt.Errorf("invalid time %q, expected HH:MM", at)
 		}
 	}
-	return false
+	if _, ok := weekdayByName(d.WeeklyDay); d.WeeklyDay != "" && !ok {
+		return fmt.Errorf("invalid weekly_day %q, expected mon..sun", d.WeeklyDay)
+	}
+	for _, section := range d.Sections {
+		if !slices.Contains(DigestSections, section) {
+			return fmt.Errorf("unknown section %q, expected one of %s", section, strings.Join(DigestSections, ", "))
+		}
+	}
+	if d.Night.Start == "" && d.Night.End == "" {
+		d.Night = TimeWindow{Start: "22:00", End: "06:00"}
+	}
+	// Unlike other windows, the night may end after midnight
+	start, err1 := parseClock(d.Night.Start)
+	end, err2 := parseClock(d.Night.End)
+	if err1 != nil || err2 != nil || start == end {
+		return fmt.Errorf("invalid night %s-%s", d.Night.Start, d.Night.End)
+	}
+	return nil
 }
 
-func createUserAccount(account ChildAccount) error {
-	userName, _ := windows.UTF16PtrFromString(account.Username)
-	password, _ := windows.UTF16PtrFromString(account.Password)
-	fullName, _ := windows.UTF16PtrFromString(account.FullName)
+// WindowTitles configures how the window titles of children with CaptureTitles are kept.
+type WindowTitles struct {
+	RetentionDays int      `json:"retention_days"`   // Сколько дней хранить заголовки (0 = 7), отдельно от data_retention_days
+	Redact        []string `json:"redact,omitempty"` // Регулярные выражения; совпадения в заголовках заменяются на «…» до записи
+}
 
-	userInfo := UserInfo1{
-		Name:     userName,
-		Password: password,
-		Priv:     USER_PRIV_USER,
-		Flags:    UF_NORMAL_ACCOUNT | UF_DONT_EXPIRE_PASSWD | UF_PASSWD_CANT_CHANGE,
-		Comment:  fullName,
+// RedactPatterns compiles the redaction patterns.
+func (w WindowTitles) RedactPatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(w.Redact))
+	for _, expr := range w.Redact {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %v", expr, err)
+		}
+		patterns = append(patterns, re)
 	}
+	return patterns, nil
+}
 
-	var parmErr uint32
-	ret, _, _ := procNetUserAdd.Call(
-		0, // NULL for local computer
-		1, // INFO_LEVEL
-		uintptr(unsafe.Pointer(&userInfo)),
-		uintptr(unsafe.Pointer(&parmErr)),
-	)
+// TimeRequests configures the "request more time" endpoint used by children.
+type TimeRequests struct {
+	Disabled        bool `json:"disabled"`
+	Port            int  `json:"port"`             // Порт на 127.0.0.1 (0 = 47321)
+	TimeoutMinutes  int  `json:"timeout_minutes"`  // Запрос без ответа истекает (0 = 15 минут)
+	CooldownMinutes int  `json:"cooldown_minutes"` // Минимальный интервал между запросами одного ребёнка (0 = 10 минут)
+	MaxMinutes      int  `json:"max_minutes"`      // Максимум минут в одном запросе (0 = 60)
+}
 
-	if ret != 0 {
-		if ret == 2224 { // NERR_UserExists
-			return nil
+// FindParent returns the role of the Telegram user userID, or nil when the user
+// is not allowed to use the bot. Users listed in AuthorizedUserIDs are owners.
+func (c *Config) FindParent(userID int64) *Parent {
+	for i := range c.Parents {
+		if c.Parents[i].UserID == userID {
+			return &c.Parents[i]
+		}
+	}
+	for _, id := range c.AuthorizedUserIDs {
+		if id == userID {
+			return &Parent{UserID: id, Role: RoleOwner}
 		}
-		errorMsg := getNetApiErrorMessage(ret)
-		return fmt.Errorf("NetUserAdd failed with code %d (parm error: %d): %s", ret, parmErr, errorMsg)
 	}
-
 	return nil
 }
 
-// SetUserPassword sets a local user's password using NetUserSetInfo level 1003
-func SetUserPassword(username, password string) error {
-	// Use NetUserSetInfo level 1003 to set password
-	userName, _ := windows.UTF16PtrFromString(username)
-	passPtr, _ := windows.UTF16PtrFromString(password)
-	ui := UserInfo1003{Password: passPtr}
-	var parmErr uint32
-	ret, _, _ := procNetUserSetInfo.Call(
-		0, // local computer
-		uintptr(unsafe.Pointer(userName)),
-		1003, // level
-		uintptr(unsafe.Pointer(&ui)),
-		uintptr(unsafe.Pointer(&parmErr)),
-	)
-	if ret != 0 {
-		return fmt.Errorf("NetUserSetInfo failed with code %d (parm %d)", ret, parmErr)
+// AllParents returns every user allowed to use the bot with their role.
+func (c *Config) AllParents() []Parent {
+	parents := make([]Parent, 0, len(c.AuthorizedUserIDs)+len(c.Parents))
+	for _, id := range c.AuthorizedUserIDs {
+		parents = append(parents, Parent{UserID: id, Role: RoleOwner})
 	}
-	return nil
+	return append(parents, c.Parents...)
 }
 
-func getNetApiErrorMessage(errorCode uintptr) string {
-	switch errorCode {
-	case 2221:
-		return "Invalid computer name or insufficient privileges"
-	case 2224:
-		return "User already exists"
-	case 2225:
-		return "User does not exist"
-	case 2226:
-		return "Password too short or does not meet complexity requirements"
-	case 2227:
-		return "Invalid password"
-	case 5:
-		return "Access denied - run as administrator"
-	case 87:
-		return "Invalid parameter"
-	case 1314:
-		return "A required privilege is not held by the client"
-	default:
-		return fmt.Sprintf("Unknown error code: %d", errorCode)
+func validateParents(config *Config) error {
+	seen := make(map[int64]bool)
+	for _, id := range config.AuthorizedUserIDs {
+		seen[id] = true
+	}
+	for _, p := range config.Parents {
+		if p.UserID == 0 {
+			return fmt.Errorf("parent %q has no user_id", p.Name)
+		}
+		if seen[p.UserID] {
+			return fmt.Errorf("user %d is listed more than once in authorized_user_ids and parents", p.UserID)
+		}
+		seen[p.UserID] = true
+
+		switch p.Role {
+		case RoleOwner, RoleParent, RoleCaretaker, RoleViewer:
+		default:
+			return fmt.Errorf("invalid role %q for user %d: must be %s, %s, %s or %s", p.Role, p.UserID, RoleOwner, RoleParent, RoleCaretaker, RoleViewer)
+		}
+		if p.MaxGrantMinutes < 0 {
+			return fmt.Errorf("invalid max_grant_minutes for user %d: cannot be negative", p.UserID)
+		}
+		for _, child := range p.Children {
+			found := false
+			for _, account := range config.ChildAccounts {
+				found = found || strings.EqualFold(account.Username, child)
+			}
+			if !found {
+				return fmt.Errorf("user %d manages unknown child account %q", p.UserID, child)
+			}
+		}
 	}
+	return nil
 }
 
-// getBuiltinUsersGroupName returns the localized name of the built-in Users group
-func getBuiltinUsersGroupName() (string, error) {
-	// BUILTIN Users well-known SID: S-1-5-32-545
-	sid, err := windows.StringToSid("S-1-5-32-545")
+// PIN delivery modes for Config.PinRecipients.
+const (
+	PinToRequester = "requester" // only the parent who granted access
+	PinToAll       = "all"       // every parent who may grant access to the child
+)
+
+// DefaultPinAlphabet is used when pin_alphabet is not configured.
+const DefaultPinAlphabet = "0123456789"
+
+func LoadConfig(configPath string) (*Config, error) {
+	// Ensure config file has proper permissions (admin only)
+	if err := protectConfigFile(configPath); err != nil {
+		return nil, fmt.Errorf("failed to protect config file: %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return "", fmt.Errorf("StringToSid failed: %v", err)
+		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
-	var nameLen uint32 = 0
-	var domainLen uint32 = 0
-	var use uint32
-	// First call to get required buffer sizes
-	_ = windows.LookupAccountSid(nil, sid, nil, &nameLen, nil, &domainLen, &use)
-	name := make([]uint16, nameLen)
-	domain := make([]uint16, domainLen)
-	if err := windows.LookupAccountSid(nil, sid, &name[0], &nameLen, &domain[0], &domainLen, &use); err != nil {
-		return "", fmt.Errorf("LookupAccountSid failed: %v", err)
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, err
 	}
-	return windows.UTF16ToString(name[:nameLen]), nil
-}
 
-func createUserAccountAlternative(account ChildAccount) error {
-	// Alternative method using net.exe command
-	cmd := exec.Command("net", "user", account.Username, account.Password, "/add", "/fullname:"+account.FullName, "/passwordchg:no", "/expires:never")
-	output, err := cmd.CombinedOutput()
+	// Secrets are stored encrypted; plaintext ones come from older versions or manual edits
+	plaintextSecrets, err := decryptSecrets(config)
 	if err != nil {
-		return fmt.Errorf("net user command failed: %v, output: %s", err, string(output))
+		return nil, err
 	}
 
-	// Add to Users group
-	cmd = exec.Command("net", "localgroup", "Users", account.Username, "/add")
-	output, err = cmd.CombinedOutput()
+	// Migrate: encrypt secrets found in plain text. The file is written back as it
+	// was read, without the defaults filled in by parseConfig.
+	if plaintextSecrets {
+		var onDisk Config
+		if err := json.Unmarshal(data, &onDisk); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %v", err)
+		}
+		if err := saveConfigTo(&onDisk, configPath); err != nil {
+			return nil, fmt.Errorf("failed to encrypt secrets in config file: %v", err)
+		}
+	}
+
+	return config, nil
+}
+
+// ReadConfig reads the configuration at configPath for tools that only look at it.
+// Unlike LoadConfig it changes nothing on disk: the file keeps its permissions and
+// is not migrated. The bot token and child passwords are left empty, since only
+// the service may decrypt them.
+func ReadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	config, err := parseConfig(data)
 	if err != nil {
-		return fmt.Errorf("net localgroup command failed: %v, output: %s", err, string(output))
+		return nil, err
 	}
 
-	return nil
+	config.TelegramBotToken = ""
+	for i := range config.ChildAccounts {
+		config.ChildAccounts[i].Password = ""
+	}
+	return config, nil
 }
 
-func addUserToGroup(username, groupName string) error {
-	groupNamePtr, _ := windows.UTF16PtrFromString(groupName)
-	// Use COMPUTERNAME\username for LOCALGROUP_MEMBERS_INFO_3
-	var compName [windows.MAX_COMPUTERNAME_LENGTH + 1]uint16
-	var size uint32 = windows.MAX_COMPUTERNAME_LENGTH + 1
-	if err := windows.GetComputerName(&compName[0], &size); err != nil {
-		return fmt.Errorf("GetComputerName failed: %v", err)
+// parseConfig decodes and validates the content of config.json and fills in the
+// defaults. Secrets are left as they are stored.
+func parseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
-	qualified := windows.UTF16ToString(compName[:size]) + "\\" + username
-	userNamePtr, _ := windows.UTF16PtrFromString(qualified)
 
-	// Create LOCALGROUP_MEMBERS_INFO_3 structure
-	memberInfo := struct {
-		lgrmi3_domainandname *uint16
-	}{
-		lgrmi3_domainandname: userNamePtr,
+	// Validate config
+	if config.TelegramBotToken == "" || config.TelegramBotToken == "YOUR_BOT_TOKEN_HERE" {
+		return nil, fmt.Errorf("telegram bot token not configured")
+	}
+
+	if len(config.AuthorizedUserIDs) == 0 && len(config.Parents) == 0 {
+		return nil, fmt.Errorf("no authorized user IDs configured")
+	}
+
+	if len(config.ChildAccounts) == 0 {
+		return nil, fmt.Errorf("no child accounts configured")
 	}
 
-	ret, _, _ := procNetLocalGroupAddMembers.Call(
-		0, // NULL for local computer
-		uintptr(unsafe.Pointer(groupNamePtr)),
-		3, // INFO_LEVEL
-		uintptr(unsafe.Pointer(&memberInfo)),
-		1, // TOTAL_ENTRIES
-	)
+	if err := validateParents(&config); err != nil {
+		return nil, err
+	}
 
-	if ret != 0 {
-		// Fallback to net.exe when API fails (e.g., name format issues)
-		// Use localized group name if possible
-		usersGroup, err := getBuiltinUsersGroupName()
-		if err == nil {
-			groupName = usersGroup
+	if config.DataRetentionDays <= 0 {
+		config.DataRetentionDays = 7 // Default to 7 days
+	}
+	if config.WindowTitles.RetentionDays <= 0 {
+		config.WindowTitles.RetentionDays = 7
+	}
+	if _, err := config.WindowTitles.RedactPatterns(); err != nil {
+		return nil, fmt.Errorf("invalid window_titles: %v", err)
+	}
+	if err := validateDigest(&config.Digest); err != nil {
+		return nil, fmt.Errorf("invalid digest: %v", err)
+	}
+	for _, p := range config.Parents {
+		if p.Digest == nil {
+			continue
 		}
-		cmd := exec.Command("net", "localgroup", groupName, username, "/add")
-		if out, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("NetLocalGroupAddMembers failed with code %d; fallback failed: %v; output: %s", ret, err, string(out))
+		if err := validateDigest(p.Digest); err != nil {
+			return nil, fmt.Errorf("invalid digest for user %d: %v", p.UserID, err)
 		}
 	}
 
-	return nil
+	// Set default reconnect settings
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = 30 // Default to 30 seconds
+	}
+	if config.MaxReconnectAttempts < 0 {
+		config.MaxReconnectAttempts = 0 // Default to infinite attempts
+	}
+
+	if config.IdleThresholdSeconds <= 0 {
+		config.IdleThresholdSeconds = 300 // Default to 5 minutes
+	}
+
+	if config.PinLength <= 0 {
+		config.PinLength = 6
+	}
+	if config.PinAlphabet == "" {
+		config.PinAlphabet = DefaultPinAlphabet
+	}
+	if config.PinLength < 4 || len(config.PinAlphabet) < 2 {
+		return nil, fmt.Errorf("PIN must have at least 4 characters from an alphabet of at least 2")
+	}
+	for _, r := range config.PinAlphabet {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) || r == ' ' || r == '`' {
+			return nil, fmt.Errorf("invalid pin_alphabet: only printable ASCII characters except space and backtick are allowed")
+		}
+	}
+	if config.WarningMinutes == nil {
+		config.WarningMinutes = []int{10, 5, 1}
+	}
+	for _, minutes := range config.WarningMinutes {
+		if minutes <= 0 {
+			return nil, fmt.Errorf("invalid warning_minutes: %d, must be positive", minutes)
+		}
+	}
+
+	if config.TimeRequests.Port <= 0 {
+		config.TimeRequests.Port = 47321
+	}
+	if config.TimeRequests.TimeoutMinutes <= 0 {
+		config.TimeRequests.TimeoutMinutes = 15
+	}
+	if config.TimeRequests.CooldownMinutes <= 0 {
+		config.TimeRequests.CooldownMinutes = 10
+	}
+	if config.TimeRequests.MaxMinutes <= 0 {
+		config.TimeRequests.MaxMinutes = 60
+	}
+
+	if config.PinRecipients == "" {
+		config.PinRecipients = PinToRequester
+	}
+	if config.PinRecipients != PinToRequester && config.PinRecipients != PinToAll {
+		return nil, fmt.Errorf("invalid pin_recipients %q: must be %q or %q", config.PinRecipients, PinToRequester, PinToAll)
+	}
+
+	for _, account := range config.ChildAccounts {
+		if q := account.Quota; q != nil && (q.WeekdayMinutes < 0 || q.WeekendMinutes < 0) {
+			return nil, fmt.Errorf("invalid daily quota for %s: minutes cannot be negative", account.Username)
+		}
+		if account.Schedule != nil {
+			if err := validateSchedule(account.Schedule); err != nil {
+				return nil, fmt.Errorf("invalid allowed hours for %s: %v", account.Username, err)
+			}
+		}
+		if account.Apps != nil {
+			if err := validateAppRules(account.Apps, config.Categories()); err != nil {
+				return nil, fmt.Errorf("invalid application rules for %s: %v", account.Username, err)
+			}
+		}
+		if err := validateSiteRules(account.Sites); err != nil {
+			return nil, fmt.Errorf("invalid blocked sites for %s: %v", account.Username, err)
+		}
+	}
+	for _, date := range config.Holidays {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q", date)
+		}
+	}
+	// An application belongs to one category, or which one it counts for would be arbitrary
+	categoryOf := make(map[string]string) // lower-case name without .exe -> category
+	for _, category := range slices.Sorted(maps.Keys(config.AppCategories)) {
+		if strings.TrimSpace(category) == "" {
+			return nil, fmt.Errorf("application category without a name")
+		}
+		for _, app := range config.AppCategories[category] {
+			if strings.TrimSpace(app) == "" {
+				return nil, fmt.Errorf("empty application name in category %s", category)
+			}
+			key := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(app)), ".exe")
+			if other, ok := categoryOf[key]; ok && other != category {
+				return nil, fmt.Errorf("application %s is in both categories %s and %s", app, other, category)
+			}
+			categoryOf[key] = category
+		}
+	}
+	for i, label := range config.AppLabels {
+		if strings.TrimSpace(label.App) == "" {
+			return nil, fmt.Errorf("application label %d without an application", i+1)
+		}
+		if label.Name == "" && label.Category == "" {
+			return nil, fmt.Errorf("application label for %s sets neither a name nor a category", label.App)
+		}
+	}
+
+	return &config, nil
+}
+
+// EnsureChildAccounts creates missing child accounts, resets existing ones to the
+// configured password and saves any generated passwords back to config.json.
+func EnsureChildAccounts(config *Config, accounts platform.Accounts) error {
+	return ensureChildAccounts(config, accounts, filepath.Join(filepath.Dir(os.Args[0]), "config.json"))
+}
+
+// ensureChildAccounts is EnsureChildAccounts with the configuration saved to configPath.
+// The file is left as it is unless a password was generated.
+func ensureChildAccounts(config *Config, accounts platform.Accounts, configPath string) error {
+	generated := false
+	for i := range config.ChildAccounts {
+		account := &config.ChildAccounts[i]
+
+		exists, err := accounts.UserExists(account.Username)
+		if err != nil {
+			return fmt.Errorf("failed to check if user %s exists: %v", account.Username, err)
+		}
+
+		// Generate random password if not set
+		if account.Password == "" || account.Password == "auto-generated-on-creation" {
+			password, err := generateRandomPassword()
+			if err != nil {
+				return fmt.Errorf("failed to generate password for %s: %v", account.Username, err)
+			}
+			account.Password = password
+			generated = true
+		}
+
+		if !exists {
+			// Create user account
+			if err := accounts.CreateUser(account.Username, account.FullName, account.Password); err != nil {
+				return fmt.Errorf("failed to create user account %s: %v", account.Username, err)
+			}
+
+			if err := accounts.AddToUsersGroup(account.Username); err != nil {
+				return fmt.Errorf("failed to add user %s to Users group: %v", account.Username, err)
+			}
+			fmt.Printf("✓ Created user account and added to group: %s\n", account.Username)
+		} else {
+			fmt.Printf("✓ User account already exists: %s\n", account.Username)
+			// Ensure password matches config (reset if needed)
+			if err := accounts.SetPassword(account.Username, account.Password); err != nil {
+				return fmt.Errorf("failed to set password for %s: %v", account.Username, err)
+			}
+			// Ensure in Users group
+			_ = accounts.AddToUsersGroup(account.Username)
+		}
+	}
+
+	if !generated {
+		return nil
+	}
+	// Save updated config with generated passwords
+	return saveConfigTo(config, configPath)
 }
 
 func generateRandomPassword() (string, error) {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*"
-	password := make([]byte, 16)
+	return GenerateRandomString(16, charset)
+}
+
+// GenerateRandomString returns length characters picked uniformly from charset
+// with a cryptographically secure generator.
+func GenerateRandomString(length int, charset string) (string, error) {
+	password := make([]byte, length)
 
 	for i := range password {
 		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
@@ -406,15 +921,25 @@ func generateRandomPassword() (string, error) {
 	return string(password), nil
 }
 
-func saveConfig(config *Config) error {
-	configPath := filepath.Join(filepath.Dir(os.Args[0]), "config.json")
+// SaveConfig writes the configuration back to config.json next to the executable.
+// The bot token and child passwords are encrypted on the way out.
+func SaveConfig(config *Config) error {
+	return saveConfigTo(config, filepath.Join(filepath.Dir(os.Args[0]), "config.json"))
+}
+
+func saveConfigTo(config *Config, configPath string) error {
+	onDisk, err := encryptedCopy(config)
+	if err != nil {
+		return err
+	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := json.MarshalIndent(onDisk, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
+	// Replaced atomically: the file holds the only copy of the secrets
+	if err := atomicfile.WriteFile(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hepri/parental/internal/platform"
+)
+
+func TestReadConfigChangesNothing(t *testing.T) {
+	const plaintext = `{
+  "telegram_bot_token": "123456:plain-token",
+  "authorized_user_ids": [42],
+  "child_accounts": [{"username": "alice", "full_name": "Alice", "password": "alice-password"}],
+  "app_categories": {"games": ["Minecraft.exe"]}
+}`
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(plaintext), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ChildAccounts[0].FullName != "Alice" || len(config.AppCategories["games"]) != 1 {
+		t.Errorf("read accounts %+v and categories %v, want those of the file", config.ChildAccounts, config.AppCategories)
+	}
+	if config.TelegramBotToken != "" || config.ChildAccounts[0].Password != "" {
+		t.Errorf("read secrets %q, %q, want them left out", config.TelegramBotToken, config.ChildAccounts[0].Password)
+	}
+	if config.PinLength != 6 || config.DataRetentionDays != 7 {
+		t.Errorf("read config without defaults: pin_length %d, data_retention_days %d", config.PinLength, config.DataRetentionDays)
+	}
+
+	// Neither migrated nor protected
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Mode() != before.Mode() || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("config file changed from %v, %v to %v, %v", before.Mode(), before.ModTime(), after.Mode(), after.ModTime())
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, []byte(plaintext)) {
+		t.Errorf("config file rewritten:\n%s", data)
+	}
+
+	if _, err := ReadConfig(filepath.Join(t.TempDir(), "config.json")); err == nil {
+		t.Error("ReadConfig of a missing file succeeded")
+	}
+}
+
+func TestEnsureChildAccountsSavesOnlyGeneratedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	const original = `{"child_accounts": [{"username": "alice", "password": "secret"}]}`
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Configured passwords: accounts are set up, the file is not rewritten
+	fake := platform.NewFake()
+	config := &Config{ChildAccounts: []ChildAccount{{Username: "alice", FullName: "Alice", Password: "secret"}}, PinLength: 6}
+	if err := ensureChildAccounts(config, fake, path); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Password("alice") != "secret" {
+		t.Errorf("alice's password = %q, want the configured one", fake.Password("alice"))
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != original {
+		t.Errorf("config file rewritten without generated passwords:\n%s", data)
+	}
+
+	// A generated password is saved, encrypted
+	config.ChildAccounts = append(config.ChildAccounts, ChildAccount{Username: "bob", Password: "auto-generated-on-creation"})
+	if err := ensureChildAccounts(config, fake, path); err != nil {
+		t.Fatal(err)
+	}
+	generated := config.ChildAccounts[1].Password
+	if generated == "auto-generated-on-creation" || fake.Password("bob") != generated {
+		t.Fatalf("bob's password = %q, set to %q, want a generated one", generated, fake.Password("bob"))
+	}
+	var onDisk Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if len(onDisk.ChildAccounts) != 2 || !isEncrypted(onDisk.ChildAccounts[1].Password) {
+		t.Errorf("saved accounts %+v, want bob's password encrypted", onDisk.ChildAccounts)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestAppInTwoCategoriesRejected(t *testing.T) {
+	const base = `{"telegram_bot_token": "t", "authorized_user_ids": [42], "child_accounts": [{"username": "alice"}], "app_categories": %s}`
+	tests := []struct {
+		categories string
+		valid      bool
+	}{
+		{`{"fun": ["vlc.exe", "Minecraft.exe"], "school": ["Teams.exe"]}`, true},
+		{`{"fun": ["vlc.exe", "VLC"]}`, true},
+		{`{"fun": ["vlc.exe"], "school": ["VLC"]}`, false},
+		{`{"fun": ["Minecraft.exe"], "school": ["minecraft.EXE"]}`, false},
+	}
+	for _, tt := range tests {
+		_, err := parseConfig([]byte(fmt.Sprintf(base, tt.categories)))
+		if (err == nil) != tt.valid {
+			t.Errorf("parseConfig() with app_categories %s error = %v, want valid %v", tt.categories, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ProtectedPaths returns the configuration and data files kept in dataDir that only
+// administrators may access: a child able to edit them could change their own quota.
+func ProtectedPaths(dataDir string) []string {
+	return []string{
+		filepath.Join(dataDir, "config.json"),
+		filepath.Join(dataDir, "time_tracking.json"),
+		filepath.Join(dataDir, "window_titles.json"),
+		filepath.Join(dataDir, "time_tracking.journal"),
+		filepath.Join(dataDir, "timeline"),
+		filepath.Join(dataDir, "quota_usage.json"),
+		filepath.Join(dataDir, "sessions_state.json"),
+		filepath.Join(dataDir, "audit.jsonl"),
+		filepath.Join(dataDir, "digest_state.json"),
+		filepath.Join(dataDir, "secret.key"),
+		filepath.Join(dataDir, "logs"),
+	}
+}
+
+// CheckDataDir reports whether files created in dataDir later, such as the state
+// files replaced on every save, would be accessible to other users, and whether
+// other users can add, replace or remove files there.
+func CheckDataDir(dataDir string) []PermissionProblem {
+	reasons, err := checkDataDir(dataDir)
+	if err != nil {
+		return []PermissionProblem{{Path: dataDir, Reason: fmt.Sprintf("cannot read permissions: %v", err)}}
+	}
+	var problems []PermissionProblem
+	for _, reason := range reasons {
+		problems = append(problems, PermissionProblem{Path: dataDir, Reason: reason})
+	}
+	return problems
+}
+
+// ProtectDataDir makes everything created in dataDir accessible to administrators
+// only and lets no one else add or remove files there. Other users can still list
+// the directory and run the executables in it, which children need to request more time.
+func ProtectDataDir(dataDir string) error {
+	if err := protectDataDir(dataDir); err != nil {
+		return fmt.Errorf("failed to protect %s: %v", dataDir, err)
+	}
+	return nil
+}
+
+// PermissionProblem describes a protected path other users can access.
+type PermissionProblem struct {
+	Path   string
+	Reason string
+}
+
+func (p PermissionProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Reason)
+}
+
+// CheckPermissions reports every existing path in paths that is not restricted
+// to administrators. Missing paths are skipped; they are created later with the
+// permissions of their directory and checked on the next start.
+func CheckPermissions(paths []string) []PermissionProblem {
+	var problems []PermissionProblem
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		reasons, err := checkPath(path)
+		if err != nil {
+			problems = append(problems, PermissionProblem{Path: path, Reason: fmt.Sprintf("cannot read permissions: %v", err)})
+			continue
+		}
+		for _, reason := range reasons {
+			problems = append(problems, PermissionProblem{Path: path, Reason: reason})
+		}
+	}
+	return problems
+}
+
+// ProtectPaths restricts every existing path in paths to administrators.
+func ProtectPaths(paths []string) error {
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := protectPath(path); err != nil {
+			return fmt.Errorf("failed to protect %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func protectConfigFile(configPath string) error {
+	return protectPath(configPath)
+}
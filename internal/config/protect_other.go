@@ -0,0 +1,89 @@
+//go:build !windows
+
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// protectPath makes path (and everything inside it, for directories) owned by the
+// service user, normally root, and inaccessible to group and others.
+func protectPath(path string) error {
+	uid, gid := os.Geteuid(), os.Getegid()
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0600)
+		if d.IsDir() {
+			mode = 0700
+		}
+		if err := os.Lchown(p, uid, gid); err != nil {
+			return err
+		}
+		return os.Chmod(p, mode)
+	})
+}
+
+// checkPath reports whether path, or anything inside it, is owned by another user
+// or accessible to group or others.
+func checkPath(path string) ([]string, error) {
+	var reasons []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		prefix := ""
+		if p != path {
+			prefix = filepath.Base(p) + ": "
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+			reasons = append(reasons, fmt.Sprintf("%sowned by uid %d", prefix, st.Uid))
+		}
+		if perm := info.Mode().Perm(); perm&0022 != 0 {
+			reasons = append(reasons, fmt.Sprintf("%swritable by group or others (mode %v)", prefix, perm))
+		} else if perm&0044 != 0 {
+			reasons = append(reasons, fmt.Sprintf("%sreadable by group or others (mode %v)", prefix, perm))
+		}
+		return nil
+	})
+	return reasons, err
+}
+
+// checkDataDir reports whether dir is owned by another user or writable by group
+// or others. Files are always created owner-only, so their permissions do not
+// depend on the directory.
+func checkDataDir(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	var reasons []string
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		reasons = append(reasons, fmt.Sprintf("directory owned by uid %d", st.Uid))
+	}
+	if perm := info.Mode().Perm(); perm&0022 != 0 {
+		reasons = append(reasons, fmt.Sprintf("group or others can add or remove files (mode %v)", perm))
+	}
+	return reasons, nil
+}
+
+// protectDataDir makes dir owned by the service user and writable by it only.
+func protectDataDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.Lchown(dir, os.Geteuid(), os.Getegid()); err != nil {
+		return err
+	}
+	return os.Chmod(dir, info.Mode().Perm()&^0022)
+}
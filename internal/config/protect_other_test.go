@@ -0,0 +1,32 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestProtectDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if problems := CheckDataDir(dir); len(problems) == 0 {
+		t.Fatalf("CheckDataDir found no problems with a world-writable directory")
+	}
+
+	if err := ProtectDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if problems := CheckDataDir(dir); len(problems) > 0 {
+		t.Errorf("CheckDataDir after ProtectDataDir = %v", problems)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0755 {
+		t.Errorf("directory mode = %v, want it still listable", perm)
+	}
+}
@@ -0,0 +1,261 @@
+//go:build windows
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32   = windows.NewLazySystemDLL("advapi32.dll")
+	procGetAce = advapi32.NewProc("GetAce")
+)
+
+const (
+	ACCESS_ALLOWED_ACE_TYPE = 0x0
+	INHERIT_ONLY_ACE        = 0x8
+	FILE_DELETE_CHILD       = 0x40
+
+	// Rights that let a user modify a file or directory, or its permissions; on a
+	// directory FILE_WRITE_DATA and FILE_APPEND_DATA add files and subdirectories
+	writeRights = windows.GENERIC_WRITE | windows.GENERIC_ALL | windows.FILE_WRITE_DATA | windows.FILE_APPEND_DATA |
+		windows.FILE_WRITE_EA | windows.FILE_WRITE_ATTRIBUTES | windows.DELETE | windows.WRITE_DAC | windows.WRITE_OWNER |
+		FILE_DELETE_CHILD
+
+	// Rights of other users on the data directory and the executables in it
+	readExecuteRights = windows.FILE_GENERIC_READ | windows.FILE_GENERIC_EXECUTE
+)
+
+// aclHeader mirrors the ACL structure, whose fields windows.ACL does not export.
+type aclHeader struct {
+	AclRevision byte
+	Sbz1        byte
+	AclSize     uint16
+	AceCount    uint16
+	Sbz2        uint16
+}
+
+type accessAllowedAce struct {
+	AceType  byte
+	AceFlags byte
+	AceSize  uint16
+	Mask     uint32
+	SidStart uint32
+}
+
+// protectPath replaces the DACL of path with one granting full control to SYSTEM and
+// Administrators only. Inheritance from the parent directory is turned off; for
+// directories the new entries are inherited by everything inside.
+func protectPath(path string) error {
+	adminSID, err := getAdminSID()
+	if err != nil {
+		return err
+	}
+	dacl, err := createDACL(adminSID)
+	if err != nil {
+		return err
+	}
+	return setDACL(path, dacl)
+}
+
+// protectDataDir gives SYSTEM and Administrators full control of dir and of
+// everything created in it, and other users read and execute access to dir itself
+// and to the executables in it only. Files created later, such as state files
+// replaced through a temporary file, inherit the administrators-only entries.
+func protectDataDir(dir string) error {
+	adminSID, err := getAdminSID()
+	if err != nil {
+		return err
+	}
+	usersSID, err := windows.CreateWellKnownSid(windows.WinBuiltinUsersSid)
+	if err != nil {
+		return fmt.Errorf("failed to create Users SID: %v", err)
+	}
+
+	dirDACL, err := createDACL(adminSID, windows.EXPLICIT_ACCESS{
+		AccessPermissions: readExecuteRights,
+		AccessMode:        windows.GRANT_ACCESS,
+		Inheritance:       windows.NO_INHERITANCE,
+		Trustee:           groupTrustee(usersSID),
+	})
+	if err != nil {
+		return err
+	}
+	if err := setDACL(dir, dirDACL); err != nil {
+		return err
+	}
+
+	// Executables stay readable: children run them to request more time
+	executables, err := filepath.Glob(filepath.Join(dir, "*.exe"))
+	if err != nil {
+		return err
+	}
+	for _, exe := range executables {
+		exeDACL, err := createDACL(adminSID, windows.EXPLICIT_ACCESS{
+			AccessPermissions: readExecuteRights,
+			AccessMode:        windows.GRANT_ACCESS,
+			Inheritance:       windows.NO_INHERITANCE,
+			Trustee:           groupTrustee(usersSID),
+		})
+		if err != nil {
+			return err
+		}
+		if err := setDACL(exe, exeDACL); err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(exe), err)
+		}
+	}
+	return nil
+}
+
+// checkDataDir lists the accounts other than SYSTEM and Administrators that pass
+// their access to dir on to new files, or that can add or remove files in it.
+func checkDataDir(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	aces, err := foreignACEs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var reasons []string
+	for _, ace := range aces {
+		if ace.flags&(windows.OBJECT_INHERIT_ACE|windows.CONTAINER_INHERIT_ACE) != 0 {
+			reasons = append(reasons, fmt.Sprintf("files created here are accessible to %s", ace.account))
+		}
+		if ace.flags&INHERIT_ONLY_ACE == 0 && ace.mask&writeRights != 0 {
+			reasons = append(reasons, fmt.Sprintf("%s can add or remove files", ace.account))
+		}
+	}
+	return reasons, nil
+}
+
+// setDACL replaces the DACL of path with dacl, turning off inheritance from its parent.
+func setDACL(path string, dacl *windows.ACL) error {
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil, nil, dacl, nil)
+}
+
+func groupTrustee(sid *windows.SID) windows.TRUSTEE {
+	return windows.TRUSTEE{
+		TrusteeForm:  windows.TRUSTEE_IS_SID,
+		TrusteeType:  windows.TRUSTEE_IS_WELL_KNOWN_GROUP,
+		TrusteeValue: windows.TrusteeValueFromSID(sid),
+	}
+}
+
+func getAdminSID() (*windows.SID, error) {
+	sid, err := windows.CreateWellKnownSid(windows.WinBuiltinAdministratorsSid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Administrators SID: %v", err)
+	}
+	return sid, nil
+}
+
+// createDACL returns a DACL granting full control to SYSTEM and Administrators,
+// inherited by everything inside directories, followed by extra.
+func createDACL(adminSID *windows.SID, extra ...windows.EXPLICIT_ACCESS) (*windows.ACL, error) {
+	systemSID, err := windows.CreateWellKnownSid(windows.WinLocalSystemSid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SYSTEM SID: %v", err)
+	}
+
+	entries := make([]windows.EXPLICIT_ACCESS, 0, 2+len(extra))
+	for _, sid := range []*windows.SID{systemSID, adminSID} {
+		entries = append(entries, windows.EXPLICIT_ACCESS{
+			AccessPermissions: windows.GENERIC_ALL,
+			AccessMode:        windows.GRANT_ACCESS,
+			Inheritance:       windows.SUB_CONTAINERS_AND_OBJECTS_INHERIT,
+			Trustee:           groupTrustee(sid),
+		})
+	}
+	entries = append(entries, extra...)
+
+	dacl, err := windows.ACLFromEntries(entries, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DACL: %v", err)
+	}
+	return dacl, nil
+}
+
+// checkPath lists the accounts other than SYSTEM and Administrators that have access to path.
+func checkPath(path string) ([]string, error) {
+	aces, err := foreignACEs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	for _, ace := range aces {
+		if ace.flags&INHERIT_ONLY_ACE != 0 {
+			continue
+		}
+		access := "can read"
+		if ace.mask&writeRights != 0 {
+			access = "can write"
+		}
+		reasons = append(reasons, fmt.Sprintf("%s %s", ace.account, access))
+	}
+	return reasons, nil
+}
+
+// foreignACE is an access allowed entry for an account other than SYSTEM and Administrators.
+type foreignACE struct {
+	account string
+	flags   byte
+	mask    uint32
+}
+
+// foreignACEs returns the access allowed entries of the DACL of path for accounts
+// other than SYSTEM and Administrators. A missing DACL is reported as everyone
+// having full access.
+func foreignACEs(path string) ([]foreignACE, error) {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return nil, err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return nil, err
+	}
+	if dacl == nil {
+		everyone := windows.OBJECT_INHERIT_ACE | windows.CONTAINER_INHERIT_ACE
+		return []foreignACE{{account: "everyone (no DACL)", flags: byte(everyone), mask: windows.GENERIC_ALL}}, nil
+	}
+
+	var aces []foreignACE
+	header := (*aclHeader)(unsafe.Pointer(dacl))
+	for i := uint32(0); i < uint32(header.AceCount); i++ {
+		var ace *accessAllowedAce
+		ret, _, _ := procGetAce.Call(uintptr(unsafe.Pointer(dacl)), uintptr(i), uintptr(unsafe.Pointer(&ace)))
+		if ret == 0 {
+			return nil, fmt.Errorf("GetAce failed")
+		}
+		if ace.AceType != ACCESS_ALLOWED_ACE_TYPE {
+			continue
+		}
+		sid := (*windows.SID)(unsafe.Pointer(&ace.SidStart))
+		if sid.IsWellKnown(windows.WinLocalSystemSid) || sid.IsWellKnown(windows.WinBuiltinAdministratorsSid) {
+			continue
+		}
+		aces = append(aces, foreignACE{account: accountName(sid), flags: ace.AceFlags, mask: ace.Mask})
+	}
+	return aces, nil
+}
+
+// accountName returns DOMAIN\name for sid, or the SID string if it cannot be resolved.
+func accountName(sid *windows.SID) string {
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil {
+		return sid.String()
+	}
+	if domain != "" {
+		return domain + `\` + account
+	}
+	return account
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// secretPrefix marks values in config.json that were encrypted by encryptSecret.
+// The payload is protected with an OS-specific key, see secrets_windows.go and secrets_other.go.
+const secretPrefix = "enc:v1:"
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// encryptSecret returns the on-disk form of a secret. Empty values stay empty.
+func encryptSecret(plain string) (string, error) {
+	if plain == "" || isEncrypted(plain) {
+		return plain, nil
+	}
+	data, err := protectSecret([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decryptSecret reverses encryptSecret. Values without the prefix are returned as is,
+// which is how plaintext configs written by older versions are read.
+func decryptSecret(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %v", err)
+	}
+	plain, err := unprotectSecret(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// decryptSecrets decrypts the bot token and child passwords in place. It reports
+// whether any of them was stored in plain text and should be migrated.
+func decryptSecrets(config *Config) (bool, error) {
+	plaintext := config.TelegramBotToken != "" && !isEncrypted(config.TelegramBotToken)
+
+	token, err := decryptSecret(config.TelegramBotToken)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt telegram bot token: %v", err)
+	}
+	config.TelegramBotToken = token
+
+	for i := range config.ChildAccounts {
+		account := &config.ChildAccounts[i]
+		if account.Password != "" && !isEncrypted(account.Password) {
+			plaintext = true
+		}
+		password, err := decryptSecret(account.Password)
+		if err != nil {
+			return false, fmt.Errorf("failed to decrypt password for %s: %v", account.Username, err)
+		}
+		account.Password = password
+	}
+
+	return plaintext, nil
+}
+
+// encryptedCopy returns a copy of config with the secrets encrypted, ready to be written to disk.
+func encryptedCopy(config *Config) (*Config, error) {
+	out := *config
+	out.ChildAccounts = append([]ChildAccount(nil), config.ChildAccounts...)
+
+	token, err := encryptSecret(config.TelegramBotToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt telegram bot token: %v", err)
+	}
+	out.TelegramBotToken = token
+
+	for i := range out.ChildAccounts {
+		password, err := encryptSecret(out.ChildAccounts[i].Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt password for %s: %v", out.ChildAccounts[i].Username, err)
+		}
+		out.ChildAccounts[i].Password = password
+	}
+
+	return &out, nil
+}
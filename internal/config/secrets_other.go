@@ -0,0 +1,96 @@
+//go:build !windows
+
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// secretKeyFile holds the AES-256 key the secrets are encrypted with. It lives next
+// to the executable and must be readable by its owner (root) only.
+const secretKeyFile = "secret.key"
+
+func protectSecret(data []byte) ([]byte, error) {
+	aead, err := secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func unprotectSecret(data []byte) ([]byte, error) {
+	aead, err := secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong %s?): %v", secretKeyFile, err)
+	}
+	return plain, nil
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	key, err := loadSecretKey(filepath.Join(filepath.Dir(os.Args[0]), secretKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadSecretKey reads the key file, creating it with a random key on first use.
+func loadSecretKey(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		// O_EXCL: never overwrite a key that appeared in the meantime
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", path, err)
+		}
+		if _, err := f.Write(key); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%s must be accessible by its owner only (mode %v), run: chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must contain a 32-byte key", path)
+	}
+	return key, nil
+}
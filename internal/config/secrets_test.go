@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretRoundTrip(t *testing.T) {
+	for _, plain := range []string{"hunter2", "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11", "пароль с пробелами"} {
+		encrypted, err := encryptSecret(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isEncrypted(encrypted) || strings.Contains(encrypted, plain) {
+			t.Errorf("encryptSecret(%q) = %q, want an encrypted value", plain, encrypted)
+		}
+		// Encrypting twice must not wrap the value again
+		if again, err := encryptSecret(encrypted); err != nil || again != encrypted {
+			t.Errorf("encryptSecret(encrypted) = %q, %v, want it unchanged", again, err)
+		}
+
+		decrypted, err := decryptSecret(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != plain {
+			t.Errorf("decryptSecret(encryptSecret(%q)) = %q", plain, decrypted)
+		}
+	}
+
+	if encrypted, err := encryptSecret(""); err != nil || encrypted != "" {
+		t.Errorf("encryptSecret(\"\") = %q, %v, want it empty", encrypted, err)
+	}
+	if plain, err := decryptSecret("plain text"); err != nil || plain != "plain text" {
+		t.Errorf("decryptSecret of a plaintext value = %q, %v, want it unchanged", plain, err)
+	}
+	for _, malformed := range []string{secretPrefix + "not base64!", secretPrefix + "AAAA"} {
+		if _, err := decryptSecret(malformed); err == nil {
+			t.Errorf("decryptSecret(%q) succeeded", malformed)
+		}
+	}
+}
+
+func TestMigratePlaintextSecrets(t *testing.T) {
+	const plaintext = `{
+  "telegram_bot_token": "123456:plain-token",
+  "authorized_user_ids": [42],
+  "child_accounts": [
+    {"username": "alice", "full_name": "Alice", "password": "alice-password"},
+    {"username": "bob", "full_name": "Bob", "password": ""}
+  ]
+}`
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(plaintext), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TelegramBotToken != "123456:plain-token" || config.ChildAccounts[0].Password != "alice-password" {
+		t.Errorf("loaded secrets %q, %q, want them decrypted", config.TelegramBotToken, config.ChildAccounts[0].Password)
+	}
+	if config.PinLength != 6 || config.TimeRequests.Port != 47321 {
+		t.Errorf("loaded config without defaults: pin_length %d, port %d", config.PinLength, config.TimeRequests.Port)
+	}
+
+	migrated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(migrated, []byte("plain-token")) || bytes.Contains(migrated, []byte("alice-password")) {
+		t.Fatalf("secrets left in plain text:\n%s", migrated)
+	}
+	var onDisk Config
+	if err := json.Unmarshal(migrated, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(onDisk.TelegramBotToken) || !isEncrypted(onDisk.ChildAccounts[0].Password) {
+		t.Errorf("secrets not encrypted: %q, %q", onDisk.TelegramBotToken, onDisk.ChildAccounts[0].Password)
+	}
+	if onDisk.ChildAccounts[1].Password != "" {
+		t.Errorf("empty password written as %q", onDisk.ChildAccounts[1].Password)
+	}
+	// Defaults stay defaults instead of being written into the file
+	if onDisk.PinLength != 0 || onDisk.WarningMinutes != nil || onDisk.TimeRequests.Port != 0 ||
+		onDisk.DataRetentionDays != 0 || onDisk.Digest.Night != (TimeWindow{}) {
+		t.Errorf("defaults written to the config file:\n%s", migrated)
+	}
+
+	// The migrated file loads to the same secrets and is not written again
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TelegramBotToken != config.TelegramBotToken || reloaded.ChildAccounts[0].Password != config.ChildAccounts[0].Password {
+		t.Errorf("reloaded secrets %q, %q differ", reloaded.TelegramBotToken, reloaded.ChildAccounts[0].Password)
+	}
+	if again, err := os.ReadFile(path); err != nil || !bytes.Equal(again, migrated) {
+		t.Errorf("config file rewritten on the second load")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package config
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// secretEntropy ties the DPAPI blobs to this application.
+var secretEntropy = []byte("ParentalControlBot config secrets")
+
+// protectSecret encrypts data with DPAPI using the machine key, so that both the
+// service (LocalSystem) and an administrator running -test can decrypt it.
+// The file ACL is what keeps other local users away from the blobs.
+func protectSecret(data []byte) ([]byte, error) {
+	var out windows.DataBlob
+	err := windows.CryptProtectData(newBlob(data), nil, newBlob(secretEntropy), 0, nil,
+		windows.CRYPTPROTECT_LOCAL_MACHINE|windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return nil, fmt.Errorf("CryptProtectData failed: %v", err)
+	}
+	return takeBlob(&out), nil
+}
+
+func unprotectSecret(data []byte) ([]byte, error) {
+	var out windows.DataBlob
+	err := windows.CryptUnprotectData(newBlob(data), nil, newBlob(secretEntropy), 0, nil,
+		windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return nil, fmt.Errorf("CryptUnprotectData failed: %v", err)
+	}
+	return takeBlob(&out), nil
+}
+
+func newBlob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// takeBlob copies a blob allocated by DPAPI into Go memory and frees it.
+func takeBlob(blob *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	return append([]byte(nil), unsafe.Slice(blob.Data, blob.Size)...)
+}
@@ -1,5 +1,3 @@
-//go:build windows
-
 package logger
 
 import (
@@ -27,7 +25,7 @@ func InitLogger(debug bool) error {
 
 	// Создаем папку для логов
 	logsDir := filepath.Join(exeDir, "logs")
-	if err := os.MkdirAll(logsDir, 0755); err != nil {
+	if err := os.MkdirAll(logsDir, 0700); err != nil {
 		return fmt.Errorf("failed to create logs directory: %v", err)
 	}
 
@@ -36,7 +34,7 @@ func InitLogger(debug bool) error {
 	logFilePath := filepath.Join(logsDir, logFileName)
 
 	// Открываем файл лога (создаем если не существует, дописываем если существует)
-	logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %v", err)
 	}
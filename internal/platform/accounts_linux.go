@@ -0,0 +1,48 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"os/user"
+)
+
+// usersGroup is the group standard desktop users belong to on most distributions.
+const usersGroup = "users"
+
+func (linuxPlatform) UserExists(username string) (bool, error) {
+	_, err := user.Lookup(username)
+	if err == nil {
+		return true, nil
+	}
+	var unknown user.UnknownUserError
+	if errors.As(err, &unknown) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (p linuxPlatform) CreateUser(username, fullName, password string) error {
+	// -m creates the home directory, -U a personal group like desktop installers do
+	if _, err := run("", "useradd", "-m", "-U", "-s", "/bin/bash", "-c", fullName, username); err != nil {
+		return err
+	}
+	if err := p.SetPassword(username, password); err != nil {
+		return err
+	}
+	// Children must not be able to change the password themselves; same as UF_PASSWD_CANT_CHANGE on Windows
+	// (-m: minimum days between changes, -M -1: never expires)
+	_, err := run("", "chage", "-m", "99999", "-M", "-1", username)
+	return err
+}
+
+func (linuxPlatform) SetPassword(username, password string) error {
+	// chpasswd reads "user:password" from stdin so the password never shows up in the process list
+	_, err := run(username+":"+password+"\n", "chpasswd")
+	return err
+}
+
+func (linuxPlatform) AddToUsersGroup(username string) error {
+	_, err := run("", "usermod", "-a", "-G", usersGroup, username)
+	return err
+}
@@ -0,0 +1,246 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	netapi32 = windows.NewLazySystemDLL("netapi32.dll")
+
+	procNetUserGetInfo          = netapi32.NewProc("NetUserGetInfo")
+	procNetUserAdd              = netapi32.NewProc("NetUserAdd")
+	procNetUserSetInfo          = netapi32.NewProc("NetUserSetInfo")
+	procNetLocalGroupAddMembers = netapi32.NewProc("NetLocalGroupAddMembers")
+	procNetApiBufferFree        = netapi32.NewProc("NetApiBufferFree")
+)
+
+const (
+	USER_PRIV_USER        = 1
+	UF_SCRIPT             = 1
+	UF_NORMAL_ACCOUNT     = 512
+	UF_DONT_EXPIRE_PASSWD = 65536
+	UF_PASSWD_CANT_CHANGE = 64
+)
+
+type UserInfo1 struct {
+	Name        *uint16
+	Password    *uint16
+	PasswordAge uint32
+	Priv        uint32
+	HomeDir     *uint16
+	Comment     *uint16
+	Flags       uint32
+	ScriptPath  *uint16
+}
+
+// USER_INFO_1003 for NetUserSetInfo (set password)
+type UserInfo1003 struct {
+	Password *uint16
+}
+
+func (windowsPlatform) UserExists(username string) (bool, error) {
+	// Try NetUserGetInfo first
+	userName, _ := windows.UTF16PtrFromString(username)
+
+	var buf *byte
+
+	ret, _, _ := procNetUserGetInfo.Call(
+		0, // NULL for local computer
+		uintptr(unsafe.Pointer(userName)),
+		1, // INFO_LEVEL
+		uintptr(unsafe.Pointer(&buf)),
+	)
+
+	if ret == 0 {
+		// User exists
+		procNetApiBufferFree.Call(uintptr(unsafe.Pointer(buf)))
+		return true, nil
+	} else if ret == 2221 { // NERR_UserNotFound
+		return false, nil
+	}
+
+	// If NetUserGetInfo fails, try alternative method
+	cmd := exec.Command("net", "user", username)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("both NetUserGetInfo and net user command failed: NetUserGetInfo error %d, net user error: %v", ret, err)
+	}
+
+	// Check if output contains "User name" (user exists) or "The user name could not be found"
+	outputStr := string(output)
+	if strings.Contains(outputStr, "User name") && !strings.Contains(outputStr, "could not be found") {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (windowsPlatform) CreateUser(username, fullName, password string) error {
+	userName, _ := windows.UTF16PtrFromString(username)
+	passwordPtr, _ := windows.UTF16PtrFromString(password)
+	fullNamePtr, _ := windows.UTF16PtrFromString(fullName)
+
+	userInfo := UserInfo1{
+		Name:     userName,
+		Password: passwordPtr,
+		Priv:     USER_PRIV_USER,
+		Flags:    UF_NORMAL_ACCOUNT | UF_DONT_EXPIRE_PASSWD | UF_PASSWD_CANT_CHANGE,
+		Comment:  fullNamePtr,
+	}
+
+	var parmErr uint32
+	ret, _, _ := procNetUserAdd.Call(
+		0, // NULL for local computer
+		1, // INFO_LEVEL
+		uintptr(unsafe.Pointer(&userInfo)),
+		uintptr(unsafe.Pointer(&parmErr)),
+	)
+
+	if ret != 0 {
+		if ret == 2224 { // NERR_UserExists
+			return nil
+		}
+		errorMsg := getNetApiErrorMessage(ret)
+		apiErr := fmt.Errorf("NetUserAdd failed with code %d (parm error: %d): %s", ret, parmErr, errorMsg)
+
+		// Try alternative method if NetUserAdd fails
+		if err := createUserAccountAlternative(username, fullName, password); err != nil {
+			return fmt.Errorf("%v (alternative method also failed: %v)", apiErr, err)
+		}
+	}
+
+	return nil
+}
+
+// SetPassword sets a local user's password using NetUserSetInfo level 1003
+func (windowsPlatform) SetPassword(username, password string) error {
+	userName, _ := windows.UTF16PtrFromString(username)
+	passPtr, _ := windows.UTF16PtrFromString(password)
+	ui := UserInfo1003{Password: passPtr}
+	var parmErr uint32
+	ret, _, _ := procNetUserSetInfo.Call(
+		0, // local computer
+		uintptr(unsafe.Pointer(userName)),
+		1003, // level
+		uintptr(unsafe.Pointer(&ui)),
+		uintptr(unsafe.Pointer(&parmErr)),
+	)
+	if ret != 0 {
+		return fmt.Errorf("NetUserSetInfo failed with code %d (parm %d)", ret, parmErr)
+	}
+	return nil
+}
+
+func (windowsPlatform) AddToUsersGroup(username string) error {
+	// Add to Users group (localized name)
+	usersGroup, err := getBuiltinUsersGroupName()
+	if err != nil {
+		return fmt.Errorf("failed to resolve Users group name: %v", err)
+	}
+	return addUserToGroup(username, usersGroup)
+}
+
+func getNetApiErrorMessage(errorCode uintptr) string {
+	switch errorCode {
+	case 2221:
+		return "Invalid computer name or insufficient privileges"
+	case 2224:
+		return "User already exists"
+	case 2225:
+		return "User does not exist"
+	case 2226:
+		return "Password too short or does not meet complexity requirements"
+	case 2227:
+		return "Invalid password"
+	case 5:
+		return "Access denied - run as administrator"
+	case 87:
+		return "Invalid parameter"
+	case 1314:
+		return "A required privilege is not held by the client"
+	default:
+		return fmt.Sprintf("Unknown error code: %d", errorCode)
+	}
+}
+
+// getBuiltinUsersGroupName returns the localized name of the built-in Users group
+func getBuiltinUsersGroupName() (string, error) {
+	// BUILTIN Users well-known SID: S-1-5-32-545
+	sid, err := windows.StringToSid("S-1-5-32-545")
+	if err != nil {
+		return "", fmt.Errorf("StringToSid failed: %v", err)
+	}
+	var nameLen uint32 = 0
+	var domainLen uint32 = 0
+	var use uint32
+	// First call to get required buffer sizes
+	_ = windows.LookupAccountSid(nil, sid, nil, &nameLen, nil, &domainLen, &use)
+	name := make([]uint16, nameLen)
+	domain := make([]uint16, domainLen)
+	if err := windows.LookupAccountSid(nil, sid, &name[0], &nameLen, &domain[0], &domainLen, &use); err != nil {
+		return "", fmt.Errorf("LookupAccountSid failed: %v", err)
+	}
+	return windows.UTF16ToString(name[:nameLen]), nil
+}
+
+func createUserAccountAlternative(username, fullName, password string) error {
+	// Alternative method using net.exe command
+	cmd := exec.Command("net", "user", username, password, "/add", "/fullname:"+fullName, "/passwordchg:no", "/expires:never")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("net user command failed: %v, output: %s", err, string(output))
+	}
+
+	// Add to Users group
+	cmd = exec.Command("net", "localgroup", "Users", username, "/add")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("net localgroup command failed: %v, output: %s", err, string(output))
+	}
+
+	return nil
+}
+
+func addUserToGroup(username, groupName string) error {
+	groupNamePtr, _ := windows.UTF16PtrFromString(groupName)
+	// Use COMPUTERNAME\username for LOCALGROUP_MEMBERS_INFO_3
+	var compName [windows.MAX_COMPUTERNAME_LENGTH + 1]uint16
+	var size uint32 = windows.MAX_COMPUTERNAME_LENGTH + 1
+	if err := windows.GetComputerName(&compName[0], &size); err != nil {
+		return fmt.Errorf("GetComputerName failed: %v", err)
+	}
+	qualified := windows.UTF16ToString(compName[:size]) + "\\" + username
+	userNamePtr, _ := windows.UTF16PtrFromString(qualified)
+
+	// Create LOCALGROUP_MEMBERS_INFO_3 structure
+	memberInfo := struct {
+		lgrmi3_domainandname *uint16
+	}{
+		lgrmi3_domainandname: userNamePtr,
+	}
+
+	ret, _, _ := procNetLocalGroupAddMembers.Call(
+		0, // NULL for local computer
+		uintptr(unsafe.Pointer(groupNamePtr)),
+		3, // INFO_LEVEL
+		uintptr(unsafe.Pointer(&memberInfo)),
+		1, // TOTAL_ENTRIES
+	)
+
+	if ret != 0 {
+		// Fallback to net.exe when API fails (e.g., name format issues)
+		cmd := exec.Command("net", "localgroup", groupName, username, "/add")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("NetLocalGroupAddMembers failed with code %d; fallback failed: %v; output: %s", ret, err, string(out))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package platform
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+var _ Platform = (*Fake)(nil)
+
+// Fake is an in-memory Platform. It lets the session manager, tracker and bot run
+// on any OS and lets tests inspect what the service asked the OS to do.
+type Fake struct {
+	mutex     sync.Mutex
+	users     map[string]fakeUser
+	sessions  []Session
+	nextID    uint32
+	process   Process
+	procErr   error
+	idle      time.Duration
+	hostsFile string
+	connOwner string
+	sysApps   []string
+	shutdown  *time.Duration // pending shutdown delay, nil if none
+	messages  []FakeMessage
+	calls     []string
+	FailCalls map[string]error // operation name -> error to return, e.g. "SetPassword"
+}
+
+// FakeMessage is a message shown by Fake.ShowMessage.
+type FakeMessage struct {
+	SessionID uint32
+	Title     string
+	Text      string
+	At        time.Time
+}
+
+type fakeUser struct {
+	fullName   string
+	password   string
+	usersGroup bool
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		users:     make(map[string]fakeUser),
+		nextID:    1,
+		procErr:   fmt.Errorf("no foreground window"),
+		FailCalls: make(map[string]error),
+	}
+}
+
+// record logs an operation and returns the configured failure for it, if any.
+func (f *Fake) record(op string, args ...interface{}) error {
+	f.calls = append(f.calls, fmt.Sprintf("%s %v", op, args))
+	return f.FailCalls[op]
+}
+
+// Calls returns the log of operations performed so far, e.g. "SetPassword [child1 ...]".
+func (f *Fake) Calls() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *Fake) UserExists(username string) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("UserExists", username); err != nil {
+		return false, err
+	}
+	_, ok := f.users[username]
+	return ok, nil
+}
+
+func (f *Fake) CreateUser(username, fullName, password string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("CreateUser", username, fullName); err != nil {
+		return err
+	}
+	if _, ok := f.users[username]; !ok {
+		f.users[username] = fakeUser{fullName: fullName, password: password}
+	}
+	return nil
+}
+
+func (f *Fake) SetPassword(username, password string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("SetPassword", username); err != nil {
+		return err
+	}
+	u, ok := f.users[username]
+	if !ok {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	u.password = password
+	f.users[username] = u
+	return nil
+}
+
+func (f *Fake) AddToUsersGroup(username string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("AddToUsersGroup", username); err != nil {
+		return err
+	}
+	u, ok := f.users[username]
+	if !ok {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	u.usersGroup = true
+	f.users[username] = u
+	return nil
+}
+
+// Password returns the current password of username.
+func (f *Fake) Password(username string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.users[username].password
+}
+
+// Login simulates username logging on at the console and returns the new session ID.
+func (f *Fake) Login(username string) uint32 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	id := f.nextID
+	f.nextID++
+	f.sessions = append(f.sessions, Session{ID: id, Username: username, Active: true})
+	return id
+}
+
+func (f *Fake) ListSessions() ([]Session, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ListSessions"); err != nil {
+		return nil, err
+	}
+	return append([]Session(nil), f.sessions...), nil
+}
+
+func (f *Fake) DisconnectSession(id uint32) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DisconnectSession", id); err != nil {
+		return err
+	}
+	for i := range f.sessions {
+		if f.sessions[i].ID == id {
+			f.sessions[i].Active = false
+			return nil
+		}
+	}
+	return fmt.Errorf("session %d not found", id)
+}
+
+func (f *Fake) LogoffSession(id uint32) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("LogoffSession", id); err != nil {
+		return err
+	}
+	for i := range f.sessions {
+		if f.sessions[i].ID == id {
+			f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("session %d not found", id)
+}
+
+// SetForeground makes name, run by username, the foreground process.
+func (f *Fake) SetForeground(name, username string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.process = Process{PID: 1000, Name: name, Username: username}
+	f.procErr = nil
+}
+
+// SetForegroundTitle sets the title of the foreground window.
+func (f *Fake) SetForegroundTitle(title string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.process.Title = title
+}
+
+func (f *Fake) ForegroundProcess() (Process, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.process, f.procErr
+}
+
+func (f *Fake) TerminateProcess(pid uint32) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("TerminateProcess", pid); err != nil {
+		return err
+	}
+	if f.procErr == nil && f.process.PID == pid {
+		f.process = Process{}
+		f.procErr = fmt.Errorf("no foreground window")
+	}
+	return nil
+}
+
+func (f *Fake) MinimizeProcess(pid uint32) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.record("MinimizeProcess", pid)
+}
+
+// SetSystemApps sets the executables returned by SystemApps.
+func (f *Fake) SetSystemApps(names ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sysApps = append([]string(nil), names...)
+}
+
+func (f *Fake) SystemApps() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.sysApps...)
+}
+
+// SetHostsFile sets the path returned by HostsFile, e.g. a file in a test's temporary directory.
+func (f *Fake) SetHostsFile(path string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.hostsFile = path
+}
+
+func (f *Fake) HostsFile() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.hostsFile
+}
+
+func (f *Fake) FlushDNSCache() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.record("FlushDNSCache")
+}
+
+// SetConnectionOwner sets the user ConnectionOwner reports for every connection;
+// "" makes it fail as if the owner could not be found.
+func (f *Fake) SetConnectionOwner(username string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.connOwner = username
+}
+
+func (f *Fake) ConnectionOwner(client, server netip.AddrPort) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ConnectionOwner", client, server); err != nil {
+		return "", err
+	}
+	if f.connOwner == "" {
+		return "", fmt.Errorf("no connection from %v to %v", client, server)
+	}
+	return f.connOwner, nil
+}
+
+// SetIdle sets the time since the last simulated input.
+func (f *Fake) SetIdle(idle time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.idle = idle
+}
+
+func (f *Fake) IdleTime() (time.Duration, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.idle, nil
+}
+
+func (f *Fake) ShowMessage(sessionID uint32, title, text string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ShowMessage", sessionID); err != nil {
+		return err
+	}
+	f.messages = append(f.messages, FakeMessage{SessionID: sessionID, Title: title, Text: text, At: time.Now()})
+	return nil
+}
+
+// Messages returns the messages shown so far, oldest first.
+func (f *Fake) Messages() []FakeMessage {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]FakeMessage(nil), f.messages...)
+}
+
+func (f *Fake) ScheduleShutdown(delay time.Duration, message string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ScheduleShutdown", delay); err != nil {
+		return err
+	}
+	f.shutdown = &delay
+	return nil
+}
+
+func (f *Fake) CancelShutdown() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("CancelShutdown"); err != nil {
+		return err
+	}
+	if f.shutdown == nil {
+		return fmt.Errorf("no shutdown scheduled")
+	}
+	f.shutdown = nil
+	return nil
+}
+
+// PendingShutdown returns the delay of the scheduled shutdown, if any.
+func (f *Fake) PendingShutdown() (time.Duration, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.shutdown == nil {
+		return 0, false
+	}
+	return *f.shutdown, true
+}
@@ -0,0 +1,111 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unknownApp is reported for sessions where the foreground window cannot be queried,
+// e.g. Wayland compositors, so that the time is still attributed to the right user.
+const unknownApp = "unknown"
+
+func (linuxPlatform) ForegroundProcess() (Process, error) {
+	session, err := activeGraphicalSession()
+	if err != nil {
+		return Process{}, err
+	}
+	username := session.props["Name"]
+
+	if session.props["Type"] != "x11" {
+		return Process{Name: unknownApp, Username: username}, nil
+	}
+
+	// Chained commands print the PID and then the title of the same window
+	out, err := runAsUser(username, session.props["Display"], "xdotool", "getactivewindow", "getwindowpid", "getwindowname")
+	if err != nil {
+		return Process{}, err
+	}
+	pidText, title, _ := strings.Cut(out, "\n")
+	pid, err := strconv.ParseUint(pidText, 10, 32)
+	if err != nil {
+		return Process{}, fmt.Errorf("unexpected xdotool output %q", out)
+	}
+
+	comm, err := os.ReadFile(filepath.Join("/proc", pidText, "comm"))
+	if err != nil {
+		return Process{}, fmt.Errorf("failed to get process name: %v", err)
+	}
+
+	return Process{
+		PID:      uint32(pid),
+		Name:     strings.TrimSpace(string(comm)),
+		Username: username,
+		Title:    strings.TrimSpace(title),
+	}, nil
+}
+
+func (linuxPlatform) IdleTime() (time.Duration, error) {
+	session, err := activeGraphicalSession()
+	if err != nil {
+		return 0, err
+	}
+
+	// xprintidle is exact on X11; other sessions rely on the idle hint the desktop reports to logind
+	if session.props["Type"] == "x11" {
+		if out, err := runAsUser(session.props["Name"], session.props["Display"], "xprintidle"); err == nil {
+			if millis, err := strconv.ParseInt(out, 10, 64); err == nil {
+				return time.Duration(millis) * time.Millisecond, nil
+			}
+		}
+	}
+
+	if session.props["IdleHint"] != "yes" {
+		return 0, nil
+	}
+	micros, err := strconv.ParseInt(session.props["IdleSinceHint"], 10, 64)
+	if err != nil || micros == 0 {
+		return 0, fmt.Errorf("no idle information for session %d", session.id)
+	}
+	return time.Since(time.UnixMicro(micros)), nil
+}
+
+// runAsUser runs an X11 helper inside the desktop session of username.
+func runAsUser(username, display, name string, args ...string) (string, error) {
+	if display == "" {
+		display = ":0"
+	}
+	env := []string{"DISPLAY=" + display}
+	if xauth := xauthorityFor(username); xauth != "" {
+		env = append(env, "XAUTHORITY="+xauth)
+	}
+
+	cmdArgs := append([]string{"-u", username, "--", "env"}, env...)
+	cmdArgs = append(cmdArgs, name)
+	cmdArgs = append(cmdArgs, args...)
+	return run("", "runuser", cmdArgs...)
+}
+
+// xauthorityFor returns the X authority file of username's session, "" if none was found.
+func xauthorityFor(username string) string {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return ""
+	}
+	// GDM keeps it under the runtime directory, startx and most other display managers in the home directory
+	for _, path := range []string{
+		filepath.Join("/run/user", u.Uid, "gdm", "Xauthority"),
+		filepath.Join(u.HomeDir, ".Xauthority"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32 = windows.NewLazySystemDLL("kernel32.dll")
+
+	procGetForegroundWindow       = user32.NewProc("GetForegroundWindow")
+	procGetWindowThreadProcessId  = user32.NewProc("GetWindowThreadProcessId")
+	procGetWindowTextW            = user32.NewProc("GetWindowTextW")
+	procGetWindowTextLengthW      = user32.NewProc("GetWindowTextLengthW")
+	procGetLastInputInfo          = user32.NewProc("GetLastInputInfo")
+	procOpenProcess               = kernel32.NewProc("OpenProcess")
+	procQueryFullProcessImageName = kernel32.NewProc("QueryFullProcessImageNameW")
+	procGetTickCount              = kernel32.NewProc("GetTickCount")
+)
+
+const (
+	PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+	MAX_PATH                          = 260
+)
+
+func (windowsPlatform) ForegroundProcess() (Process, error) {
+	// Get foreground window
+	hWnd, _, _ := procGetForegroundWindow.Call()
+	if hWnd == 0 {
+		return Process{}, fmt.Errorf("no foreground window")
+	}
+
+	// Get process ID
+	var processID uint32
+	procGetWindowThreadProcessId.Call(hWnd, uintptr(unsafe.Pointer(&processID)))
+	if processID == 0 {
+		return Process{}, fmt.Errorf("failed to get process ID")
+	}
+
+	// Open process
+	hProcess, _, _ := procOpenProcess.Call(
+		PROCESS_QUERY_LIMITED_INFORMATION,
+		0,
+		uintptr(processID),
+	)
+	if hProcess == 0 {
+		return Process{}, fmt.Errorf("failed to open process")
+	}
+	defer windows.CloseHandle(windows.Handle(hProcess))
+
+	// Get process image name
+	var size uint32 = MAX_PATH
+	buf := make([]uint16, MAX_PATH)
+	ret, _, _ := procQueryFullProcessImageName.Call(
+		hProcess,
+		0, // Win32 path format
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(unsafe.Pointer(&size)),
+	)
+
+	if ret == 0 {
+		return Process{}, fmt.Errorf("failed to get process image name")
+	}
+
+	// Convert to string and extract filename
+	imagePath := windows.UTF16ToString(buf)
+
+	return Process{
+		PID:      processID,
+		Name:     filepath.Base(imagePath),
+		Username: processUsername(processID),
+		Title:    windowTitle(hWnd),
+	}, nil
+}
+
+// windowTitle returns the title of the window, or "" if it has none.
+func windowTitle(hWnd uintptr) string {
+	length, _, _ := procGetWindowTextLengthW.Call(hWnd)
+	if length == 0 {
+		return ""
+	}
+	buf := make([]uint16, length+1)
+	n, _, _ := procGetWindowTextW.Call(hWnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return windows.UTF16ToString(buf[:n])
+}
+
+// processUsername returns the user name of the session the process runs in, or "".
+func processUsername(processID uint32) string {
+	var sessionID uint32
+	if err := windows.ProcessIdToSessionId(processID, &sessionID); err != nil {
+		return ""
+	}
+	username, err := sessionUsername(sessionID)
+	if err != nil {
+		return ""
+	}
+	return username
+}
+
+type lastInputInfo struct {
+	Size uint32
+	Time uint32
+}
+
+func (windowsPlatform) IdleTime() (time.Duration, error) {
+	info := lastInputInfo{Size: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	ret, _, _ := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info)))
+	if ret == 0 {
+		return 0, fmt.Errorf("GetLastInputInfo failed")
+	}
+
+	tick, _, _ := procGetTickCount.Call()
+	// uint32 arithmetic handles the tick counter wrapping every ~49 days
+	idleMillis := uint32(tick) - info.Time
+	return time.Duration(idleMillis) * time.Millisecond, nil
+}
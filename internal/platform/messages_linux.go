@@ -0,0 +1,25 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"os/user"
+)
+
+func (linuxPlatform) ShowMessage(sessionID uint32, title, text string) error {
+	props, err := showSession(fmt.Sprint(sessionID))
+	if err != nil {
+		return err
+	}
+	u, err := user.Lookup(props["Name"])
+	if err != nil {
+		return err
+	}
+
+	// notify-send talks to the notification daemon over the user's session bus
+	bus := "DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/" + u.Uid + "/bus"
+	_, err = run("", "runuser", "-u", u.Username, "--", "env", bus,
+		"notify-send", "--urgency=critical", "--app-name=Parental Control", title, text)
+	return err
+}
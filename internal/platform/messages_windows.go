@@ -0,0 +1,49 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procWTSSendMessage = wtsapi32.NewProc("WTSSendMessageW")
+
+const (
+	MB_OK            = 0x00000000
+	MB_ICONWARNING   = 0x00000030
+	MB_SETFOREGROUND = 0x00010000
+	MB_TOPMOST       = 0x00040000
+)
+
+func (windowsPlatform) ShowMessage(sessionID uint32, title, text string) error {
+	titleUTF16, err := windows.UTF16FromString(title)
+	if err != nil {
+		return err
+	}
+	textUTF16, err := windows.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+
+	var response uint32
+	// Lengths are in bytes without the terminating NUL; bWait=FALSE returns immediately
+	ret, _, _ := procWTSSendMessage.Call(
+		WTS_CURRENT_SERVER_HANDLE,
+		uintptr(sessionID),
+		uintptr(unsafe.Pointer(&titleUTF16[0])),
+		uintptr((len(titleUTF16)-1)*2),
+		uintptr(unsafe.Pointer(&textUTF16[0])),
+		uintptr((len(textUTF16)-1)*2),
+		MB_OK|MB_ICONWARNING|MB_SETFOREGROUND|MB_TOPMOST,
+		0, // No timeout
+		uintptr(unsafe.Pointer(&response)),
+		0,
+	)
+	if ret == 0 {
+		return fmt.Errorf("WTSSendMessage failed")
+	}
+	return nil
+}
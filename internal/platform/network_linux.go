@@ -0,0 +1,78 @@
+//go:build linux
+
+package platform
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+func (linuxPlatform) HostsFile() string {
+	return "/etc/hosts"
+}
+
+func (linuxPlatform) FlushDNSCache() error {
+	// Without systemd-resolved nothing caches lookups system-wide
+	if _, err := exec.LookPath("resolvectl"); err != nil {
+		return nil
+	}
+	_, err := run("", "resolvectl", "flush-caches")
+	return err
+}
+
+// ConnectionOwner looks the connection up in /proc/net/tcp, which lists the uid
+// owning every IPv4 socket.
+func (linuxPlatform) ConnectionOwner(client, server netip.AddrPort) (string, error) {
+	data, err := os.ReadFile("/proc/net/tcp")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[1:] {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid ...
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		local, err := parseProcAddr(fields[1])
+		if err != nil || local != client {
+			continue
+		}
+		remote, err := parseProcAddr(fields[2])
+		if err != nil || remote != server {
+			continue
+		}
+		u, err := user.LookupId(fields[7])
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	}
+	return "", fmt.Errorf("no connection from %v to %v", client, server)
+}
+
+// parseProcAddr parses an IPv4 address of /proc/net/tcp such as "0100007F:B9A2":
+// the address in host byte order and the port, both in hex.
+func parseProcAddr(s string) (netip.AddrPort, error) {
+	addrHex, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.AddrPort{}, fmt.Errorf("invalid address %q", s)
+	}
+	addr, err := strconv.ParseUint(addrHex, 16, 32)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	var ip [4]byte
+	binary.NativeEndian.PutUint32(ip[:], uint32(addr))
+	return netip.AddrPortFrom(netip.AddrFrom4(ip), uint16(port)), nil
+}
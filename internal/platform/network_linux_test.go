@@ -0,0 +1,44 @@
+//go:build linux
+
+package platform
+
+import (
+	"net"
+	"net/netip"
+	"os/user"
+	"testing"
+)
+
+func TestConnectionOwner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := conn.LocalAddr().(*net.TCPAddr).AddrPort()
+	server := conn.RemoteAddr().(*net.TCPAddr).AddrPort()
+	owner, err := linuxPlatform{}.ConnectionOwner(client, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != current.Username {
+		t.Errorf("ConnectionOwner() = %q, want %q", owner, current.Username)
+	}
+
+	// A port nobody connected from
+	other := netip.AddrPortFrom(client.Addr(), client.Port()+1)
+	if _, err := (linuxPlatform{}).ConnectionOwner(other, server); err == nil {
+		t.Error("ConnectionOwner() found a connection that does not exist")
+	}
+}
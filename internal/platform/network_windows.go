@@ -0,0 +1,100 @@
+//go:build windows
+
+package platform
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	dnsapi   = windows.NewLazySystemDLL("dnsapi.dll")
+	iphlpapi = windows.NewLazySystemDLL("iphlpapi.dll")
+
+	procDnsFlushResolverCache = dnsapi.NewProc("DnsFlushResolverCache")
+	procGetExtendedTcpTable   = iphlpapi.NewProc("GetExtendedTcpTable")
+)
+
+const (
+	TCP_TABLE_OWNER_PID_ALL   = 5
+	ERROR_INSUFFICIENT_BUFFER = 122
+)
+
+// mibTCPRowOwnerPID is MIB_TCPROW_OWNER_PID. Addresses and ports are in network
+// byte order, ports in the low 16 bits.
+type mibTCPRowOwnerPID struct {
+	State      uint32
+	LocalAddr  uint32
+	LocalPort  uint32
+	RemoteAddr uint32
+	RemotePort uint32
+	OwningPID  uint32
+}
+
+func (windowsPlatform) HostsFile() string {
+	root := os.Getenv("SystemRoot")
+	if root == "" {
+		root = `C:\Windows`
+	}
+	return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+}
+
+func (windowsPlatform) FlushDNSCache() error {
+	// Same as "ipconfig /flushdns"
+	ret, _, _ := procDnsFlushResolverCache.Call()
+	if ret == 0 {
+		return fmt.Errorf("DnsFlushResolverCache failed")
+	}
+	return nil
+}
+
+// ConnectionOwner finds the process owning the connection in the IPv4 TCP table
+// and returns the user of the session it runs in.
+func (windowsPlatform) ConnectionOwner(client, server netip.AddrPort) (string, error) {
+	var size uint32
+	var buf []byte
+	for {
+		var table uintptr
+		if len(buf) > 0 {
+			table = uintptr(unsafe.Pointer(&buf[0]))
+		}
+		ret, _, _ := procGetExtendedTcpTable.Call(table, uintptr(unsafe.Pointer(&size)), 0,
+			windows.AF_INET, TCP_TABLE_OWNER_PID_ALL, 0)
+		if ret == 0 {
+			break
+		}
+		if ret != ERROR_INSUFFICIENT_BUFFER {
+			return "", fmt.Errorf("GetExtendedTcpTable failed: %v", windows.Errno(ret))
+		}
+		buf = make([]byte, size)
+	}
+	if len(buf) < 4 {
+		return "", fmt.Errorf("no connection from %v to %v", client, server)
+	}
+
+	count := binary.LittleEndian.Uint32(buf)
+	rows := unsafe.Slice((*mibTCPRowOwnerPID)(unsafe.Pointer(&buf[4])), count)
+	for _, row := range rows {
+		if tcpEndpoint(row.LocalAddr, row.LocalPort) != client || tcpEndpoint(row.RemoteAddr, row.RemotePort) != server {
+			continue
+		}
+		if username := processUsername(row.OwningPID); username != "" {
+			return username, nil
+		}
+		return "", fmt.Errorf("owner of process %d is unknown", row.OwningPID)
+	}
+	return "", fmt.Errorf("no connection from %v to %v", client, server)
+}
+
+// tcpEndpoint converts an address and port of a TCP table row.
+func tcpEndpoint(addr, port uint32) netip.AddrPort {
+	var ip [4]byte
+	binary.LittleEndian.PutUint32(ip[:], addr)
+	return netip.AddrPortFrom(netip.AddrFrom4(ip), uint16(port&0xff)<<8|uint16(port>>8&0xff))
+}
@@ -0,0 +1,97 @@
+// Package platform hides the operating system behind small interfaces so that
+// session management, time tracking and the bot can run against a fake on any OS.
+package platform
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Session is an interactive logon session on the machine.
+type Session struct {
+	ID       uint32
+	Username string
+	Active   bool // a user is attached to the session (not disconnected)
+}
+
+// Process describes the process owning the foreground window.
+type Process struct {
+	PID      uint32
+	Name     string // executable file name, e.g. "chrome.exe"
+	Username string // owner of the session the process runs in, "" if unknown
+	Title    string // title of the foreground window, "" if unknown
+}
+
+// Accounts manages local user accounts.
+type Accounts interface {
+	UserExists(username string) (bool, error)
+	CreateUser(username, fullName, password string) error
+	SetPassword(username, password string) error
+	// AddToUsersGroup makes sure the account is a member of the built-in standard users group.
+	AddToUsersGroup(username string) error
+}
+
+// Sessions enumerates and terminates interactive sessions.
+type Sessions interface {
+	ListSessions() ([]Session, error)
+	// DisconnectSession locks the session while keeping its applications running.
+	DisconnectSession(id uint32) error
+	LogoffSession(id uint32) error
+}
+
+// Foreground identifies the application the user is currently working with.
+type Foreground interface {
+	ForegroundProcess() (Process, error)
+}
+
+// Processes acts on applications running in users' sessions.
+type Processes interface {
+	// TerminateProcess ends the process without giving it a chance to refuse.
+	TerminateProcess(pid uint32) error
+	// MinimizeProcess minimizes the windows of the process.
+	MinimizeProcess(pid uint32) error
+	// SystemApps returns the executables the desktop needs to stay usable, such as
+	// the shell and the lock screen, as named in Process.Name.
+	SystemApps() []string
+}
+
+// Network controls name resolution for the whole machine.
+type Network interface {
+	// HostsFile returns the path of the system hosts file.
+	HostsFile() string
+	// FlushDNSCache drops cached lookups so that hosts file changes apply immediately.
+	FlushDNSCache() error
+	// ConnectionOwner returns the user owning the process at the local end of the
+	// IPv4 TCP connection from client to server, both on this machine.
+	ConnectionOwner(client, server netip.AddrPort) (string, error)
+}
+
+// Input reports user input activity.
+type Input interface {
+	// IdleTime returns how long ago the last keyboard or mouse input happened.
+	IdleTime() (time.Duration, error)
+}
+
+// Messages shows notifications inside a user's interactive session.
+type Messages interface {
+	// ShowMessage pops up a message in the session without waiting for the user to close it.
+	ShowMessage(sessionID uint32, title, text string) error
+}
+
+// Power schedules and cancels a system shutdown.
+type Power interface {
+	ScheduleShutdown(delay time.Duration, message string) error
+	CancelShutdown() error
+}
+
+// Platform bundles every OS operation the service relies on.
+type Platform interface {
+	Accounts
+	Sessions
+	Foreground
+	Processes
+	Network
+	Input
+	Messages
+	Power
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// linuxPlatform implements Platform with logind (loginctl), the shadow utilities
+// and X11 helpers. The service must run as root.
+type linuxPlatform struct{}
+
+// New returns the Platform implementation for the running OS.
+func New() Platform {
+	return linuxPlatform{}
+}
+
+// run executes an external command and returns its trimmed standard output.
+// stdin, if not empty, is written to the command's standard input.
+func run(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, msg)
+		}
+		return "", fmt.Errorf("%s %s failed: %v", name, strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package platform
+
+// windowsPlatform implements Platform with Win32 API calls.
+type windowsPlatform struct{}
+
+// New returns the Platform implementation for the running OS.
+func New() Platform {
+	return windowsPlatform{}
+}
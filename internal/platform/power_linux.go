@@ -0,0 +1,23 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"time"
+)
+
+func (linuxPlatform) ScheduleShutdown(delay time.Duration, message string) error {
+	// shutdown(8) schedules with minute precision; round up so the warning is never shorter
+	when := "now"
+	if delay > 0 {
+		when = fmt.Sprintf("+%d", int((delay+time.Minute-1)/time.Minute))
+	}
+	_, err := run("", "shutdown", "--poweroff", when, message)
+	return err
+}
+
+func (linuxPlatform) CancelShutdown() error {
+	_, err := run("", "shutdown", "-c")
+	return err
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+
+	procInitiateSystemShutdownEx = advapi32.NewProc("InitiateSystemShutdownExW")
+	procAbortSystemShutdown      = advapi32.NewProc("AbortSystemShutdownW")
+)
+
+const (
+	SHUTDOWN_FORCE_OTHERS        = 0x00000001
+	SHUTDOWN_FORCE_SELF          = 0x00000002
+	SHUTDOWN_RESTART             = 0x00000004
+	SHUTDOWN_POWEROFF            = 0x00000008
+	SHUTDOWN_NOREBOOT            = 0x00000010
+	SHUTDOWN_GRACE_OVERRIDE      = 0x00000020
+	SHUTDOWN_INSTALL_UPDATES     = 0x00000040
+	SHUTDOWN_RESTARTAPPS         = 0x00000080
+	SHUTDOWN_SKIP_SVC_POPUP      = 0x00000100
+	SHUTDOWN_HYBRID              = 0x00000200
+	SHUTDOWN_RESTART_BOOTOPTIONS = 0x00000400
+)
+
+func (windowsPlatform) ScheduleShutdown(delay time.Duration, message string) error {
+	messagePtr, err := windows.UTF16PtrFromString(message)
+	if err != nil {
+		return fmt.Errorf("failed to convert message to UTF16: %v", err)
+	}
+
+	ret, _, _ := procInitiateSystemShutdownEx.Call(
+		0, // Local computer
+		uintptr(unsafe.Pointer(messagePtr)),
+		uintptr(uint32(delay/time.Second)),
+		SHUTDOWN_FORCE_OTHERS|SHUTDOWN_GRACE_OVERRIDE, // Force close applications
+		0, // Don't restart
+	)
+
+	if ret == 0 {
+		return fmt.Errorf("InitiateSystemShutdownEx failed")
+	}
+	return nil
+}
+
+func (windowsPlatform) CancelShutdown() error {
+	ret, _, _ := procAbortSystemShutdown.Call(0) // Local computer
+	if ret == 0 {
+		return fmt.Errorf("AbortSystemShutdown failed")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+)
+
+// SystemApps lists the display servers, shells, window managers and screen lockers
+// of common desktops. Names are as in /proc/<pid>/comm, cut to 15 characters.
+func (linuxPlatform) SystemApps() []string {
+	return []string{
+		unknownApp,
+		"Xorg",
+		"Xwayland",
+		"gnome-shell",
+		"gnome-screensav",
+		"nautilus",
+		"plasmashell",
+		"kwin_x11",
+		"kwin_wayland",
+		"kscreenlocker_g",
+		"dolphin",
+		"cinnamon",
+		"cinnamon-screen",
+		"nemo",
+		"xfce4-panel",
+		"xfdesktop",
+		"xfwm4",
+		"xfce4-screensav",
+		"thunar",
+		"mate-panel",
+		"marco",
+		"caja",
+		"mate-screensave",
+		"lxpanel",
+		"openbox",
+		"pcmanfm",
+		"light-locker",
+		"xscreensaver",
+	}
+}
+
+func (linuxPlatform) TerminateProcess(pid uint32) error {
+	if err := syscall.Kill(int(pid), syscall.SIGKILL); err != nil {
+		return fmt.Errorf("failed to terminate process %d: %v", pid, err)
+	}
+	return nil
+}
+
+func (linuxPlatform) MinimizeProcess(pid uint32) error {
+	session, err := activeGraphicalSession()
+	if err != nil {
+		return err
+	}
+	if session.props["Type"] != "x11" {
+		return fmt.Errorf("minimizing windows is not supported in %s sessions", session.props["Type"])
+	}
+
+	_, err = runAsUser(session.props["Name"], session.props["Display"],
+		"xdotool", "search", "--pid", strconv.FormatUint(uint64(pid), 10), "windowminimize", "%@")
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package platform
+
+import "testing"
+
+func TestSystemAppsMatchComm(t *testing.T) {
+	// The kernel cuts process names to 15 characters; longer names never match
+	for _, name := range (linuxPlatform{}).SystemApps() {
+		if len(name) > 15 {
+			t.Errorf("system app %q is longer than a process name can be", name)
+		}
+	}
+}
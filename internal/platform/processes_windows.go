@@ -0,0 +1,63 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procShowWindowAsync = user32.NewProc("ShowWindowAsync")
+
+const SW_MINIMIZE = 6
+
+func (windowsPlatform) SystemApps() []string {
+	return []string{
+		"explorer.exe",
+		"dwm.exe",
+		"LogonUI.exe",
+		"LockApp.exe",
+		"ShellExperienceHost.exe",
+		"StartMenuExperienceHost.exe",
+		"SearchHost.exe",
+		"SearchApp.exe",
+		"TextInputHost.exe",
+		"ApplicationFrameHost.exe",
+	}
+}
+
+func (windowsPlatform) TerminateProcess(pid uint32) error {
+	hProcess, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, pid)
+	if err != nil {
+		return fmt.Errorf("failed to open process %d: %v", pid, err)
+	}
+	defer windows.CloseHandle(hProcess)
+
+	if err := windows.TerminateProcess(hProcess, 1); err != nil {
+		return fmt.Errorf("failed to terminate process %d: %v", pid, err)
+	}
+	return nil
+}
+
+func (windowsPlatform) MinimizeProcess(pid uint32) error {
+	// Only the foreground window is minimized: it is the one the child is using
+	hWnd, _, _ := procGetForegroundWindow.Call()
+	if hWnd == 0 {
+		return fmt.Errorf("no foreground window")
+	}
+
+	var processID uint32
+	procGetWindowThreadProcessId.Call(hWnd, uintptr(unsafe.Pointer(&processID)))
+	if processID != pid {
+		return fmt.Errorf("process %d is no longer in the foreground", pid)
+	}
+
+	// Async: a hung application must not block the service
+	ret, _, _ := procShowWindowAsync.Call(hWnd, SW_MINIMIZE)
+	if ret == 0 {
+		return fmt.Errorf("ShowWindowAsync failed")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sessionProperties lists the logind properties used to describe a session.
+var sessionProperties = []string{"Name", "Active", "Class", "Type", "Display", "State", "IdleHint", "IdleSinceHint"}
+
+func (linuxPlatform) ListSessions() ([]Session, error) {
+	infos, err := listUserSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(infos))
+	for _, info := range infos {
+		sessions = append(sessions, Session{
+			ID:       info.id,
+			Username: info.props["Name"],
+			Active:   info.props["Active"] == "yes",
+		})
+	}
+	return sessions, nil
+}
+
+func (linuxPlatform) DisconnectSession(id uint32) error {
+	// Asks the desktop environment to show its lock screen; applications keep running
+	_, err := run("", "loginctl", "lock-session", strconv.FormatUint(uint64(id), 10))
+	return err
+}
+
+func (linuxPlatform) LogoffSession(id uint32) error {
+	_, err := run("", "loginctl", "terminate-session", strconv.FormatUint(uint64(id), 10))
+	return err
+}
+
+// logindSession is a user session as reported by loginctl.
+type logindSession struct {
+	id    uint32
+	props map[string]string
+}
+
+// listUserSessions returns the sessions of class "user", skipping greeters and
+// sessions whose ID is not numeric (logind uses "c1", ... for some of those).
+func listUserSessions() ([]logindSession, error) {
+	out, err := run("", "loginctl", "list-sessions", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []logindSession
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		props, err := showSession(fields[0])
+		if err != nil {
+			// The session may have ended between the two calls
+			continue
+		}
+		if props["Class"] != "user" || props["Name"] == "" {
+			continue
+		}
+		sessions = append(sessions, logindSession{id: uint32(id), props: props})
+	}
+	return sessions, nil
+}
+
+// showSession returns the properties of a logind session.
+func showSession(id string) (map[string]string, error) {
+	args := []string{"show-session", id, "--no-pager"}
+	for _, p := range sessionProperties {
+		args = append(args, "--property="+p)
+	}
+	out, err := run("", "loginctl", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if ok {
+			props[key] = value
+		}
+	}
+	return props, nil
+}
+
+// activeGraphicalSession returns the user session currently shown on a seat.
+func activeGraphicalSession() (logindSession, error) {
+	sessions, err := listUserSessions()
+	if err != nil {
+		return logindSession{}, err
+	}
+	for _, s := range sessions {
+		if s.props["Active"] != "yes" {
+			continue
+		}
+		if t := s.props["Type"]; t == "x11" || t == "wayland" {
+			return s, nil
+		}
+	}
+	return logindSession{}, fmt.Errorf("no active graphical session")
+}
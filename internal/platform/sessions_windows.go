@@ -0,0 +1,120 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32   = windows.NewLazySystemDLL("user32.dll")
+	wtsapi32 = windows.NewLazySystemDLL("wtsapi32.dll")
+
+	procLockWorkStation            = user32.NewProc("LockWorkStation")
+	procWTSEnumerateSessions       = wtsapi32.NewProc("WTSEnumerateSessionsW")
+	procWTSQuerySessionInformation = wtsapi32.NewProc("WTSQuerySessionInformationW")
+	procWTSFreeMemory              = wtsapi32.NewProc("WTSFreeMemory")
+	procWTSLogoffSession           = wtsapi32.NewProc("WTSLogoffSession")
+	procWTSDisconnectSession       = wtsapi32.NewProc("WTSDisconnectSession")
+)
+
+const (
+	WTS_CURRENT_SERVER_HANDLE = 0
+	WTSActive                 = 0
+	WTSDisconnected           = 1
+	WTSConnected              = 2
+	WTSConnectState           = 8
+	WTSUserName               = 5
+	WTSDomainName             = 7
+)
+
+type WTS_SESSION_INFO struct {
+	SessionID      uint32
+	WinStationName *uint16
+	State          uint32
+}
+
+func (windowsPlatform) ListSessions() ([]Session, error) {
+	var sessionInfo *WTS_SESSION_INFO
+	var count uint32
+
+	ret, _, _ := procWTSEnumerateSessions.Call(
+		WTS_CURRENT_SERVER_HANDLE,
+		0, // Reserved
+		1, // Version
+		uintptr(unsafe.Pointer(&sessionInfo)),
+		uintptr(unsafe.Pointer(&count)),
+	)
+
+	if ret == 0 {
+		return nil, fmt.Errorf("WTSEnumerateSessions failed")
+	}
+
+	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(sessionInfo)))
+
+	sessions := make([]Session, 0, count)
+	for i := uint32(0); i < count; i++ {
+		info := *(*WTS_SESSION_INFO)(unsafe.Pointer(uintptr(unsafe.Pointer(sessionInfo)) + uintptr(i)*unsafe.Sizeof(*sessionInfo)))
+		username, err := sessionUsername(info.SessionID)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, Session{
+			ID:       info.SessionID,
+			Username: username,
+			Active:   info.State == WTSActive,
+		})
+	}
+
+	return sessions, nil
+}
+
+func (windowsPlatform) DisconnectSession(id uint32) error {
+	r, _, _ := procWTSDisconnectSession.Call(
+		WTS_CURRENT_SERVER_HANDLE,
+		uintptr(id),
+		0,
+	)
+	if r == 0 {
+		// Best-effort fallback to local lock
+		_, _, _ = procLockWorkStation.Call()
+	}
+	return nil
+}
+
+func (windowsPlatform) LogoffSession(id uint32) error {
+	r, _, _ := procWTSLogoffSession.Call(
+		WTS_CURRENT_SERVER_HANDLE,
+		uintptr(id),
+		0,
+	)
+	if r == 0 {
+		return fmt.Errorf("WTSLogoffSession failed")
+	}
+	return nil
+}
+
+// sessionUsername returns the user logged on to the session, "" for sessions without one.
+func sessionUsername(sessionID uint32) (string, error) {
+	var buffer *uint16
+	var bytesReturned uint32
+
+	ret, _, _ := procWTSQuerySessionInformation.Call(
+		WTS_CURRENT_SERVER_HANDLE,
+		uintptr(sessionID),
+		WTSUserName,
+		uintptr(unsafe.Pointer(&buffer)),
+		uintptr(unsafe.Pointer(&bytesReturned)),
+	)
+
+	if ret == 0 {
+		return "", fmt.Errorf("WTSQuerySessionInformation failed")
+	}
+
+	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(buffer)))
+
+	return windows.UTF16PtrToString(buffer), nil
+}
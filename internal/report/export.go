@@ -0,0 +1,173 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+// Format is a file format reports are exported to.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+	FormatHTML Format = "html"
+)
+
+// Formats lists the export formats in the order they are offered.
+var Formats = []Format{FormatCSV, FormatJSON, FormatHTML}
+
+// ParseFormat returns the format named s, ignoring case.
+func ParseFormat(s string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(s, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown report format %q, use csv, json or html", s)
+}
+
+// FileName returns the name for the report exported in format,
+// e.g. "usage_alice_2025-10-01_2025-10-07.csv".
+func (r *Report) FileName(format Format) string {
+	user := r.User
+	if user == "" {
+		user = "all"
+	}
+	return fmt.Sprintf("usage_%s_%s_%s.%s", user, r.From, r.To, format)
+}
+
+// Write exports the report to w in format.
+func (r *Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatCSV:
+		return r.WriteCSV(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatHTML:
+		return r.WriteHTML(w)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// WriteCSV writes one line per day, user and application.
+func (r *Report) WriteCSV(w io.Writer) error {
+	// The byte order mark makes Excel read the file as UTF-8
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"date", "user", "app", "name", "category", "seconds"}); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		record := []string{row.Date, row.User, row.App, row.Name, row.Category, strconv.FormatInt(row.Seconds, 10)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteHTML writes a self-contained page with the totals per day and per application
+// and the usage of every day.
+func (r *Report) WriteHTML(w io.Writer) error {
+	active, idle := r.Total()
+	return htmlTemplate.Execute(w, map[string]interface{}{
+		"Report": r,
+		"Active": active,
+		"Idle":   idle,
+		"Days":   r.Days(),
+		"Apps":   r.Apps(),
+	})
+}
+
+// categoryNames are the names of the built-in categories in reports.
+var categoryNames = map[string]string{
+	config.CategoryGames:     "Игры",
+	config.CategoryVideo:     "Видео",
+	config.CategorySocial:    "Общение",
+	config.CategoryBrowser:   "Браузеры",
+	config.CategoryEducation: "Учёба",
+	config.CategoryOffice:    "Документы",
+	config.CategorySystem:    "Система",
+	config.CategoryOther:     "Другое",
+	CategoryIdle:             "Бездействие",
+}
+
+// CategoryName returns the plain name of category; categories from the config
+// are named as they are there.
+func CategoryName(category string) string {
+	if name, ok := categoryNames[category]; ok {
+		return name
+	}
+	return category
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": FormatDuration,
+	"category": CategoryName,
+	"percent": func(part, whole int64) string {
+		if whole == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.0f%%", float64(part)*100/float64(whole))
+	},
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Отчёт: {{.Report.Subject}}, {{.Report.From}} — {{.Report.To}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; margin-bottom: 0.2em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+.meta { color: #666; }
+table { border-collapse: collapse; min-width: 50%; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.35em 0.8em; text-align: left; }
+th { background: #f4f4f4; }
+td.num { text-align: right; white-space: nowrap; }
+code { color: #555; }
+</style>
+</head>
+<body>
+<h1>Отчёт об использовании: {{.Report.Subject}}</h1>
+<p class="meta">{{.Report.From}} — {{.Report.To}} · создан {{.Report.Generated.Format "02.01.2006 15:04"}}</p>
+<p>Активно: <b>{{duration .Active}}</b> · Бездействие: {{duration .Idle}}</p>
+
+<h2>По дням</h2>
+<table>
+<tr><th>Дата</th><th>Активно</th><th>Бездействие</th></tr>
+{{range .Days}}<tr><td>{{.Date}}</td><td class="num">{{duration .Active}}</td><td class="num">{{duration .Idle}}</td></tr>
+{{end}}</table>
+
+<h2>По программам</h2>
+{{if .Apps}}<table>
+<tr><th>Программа</th><th>Категория</th><th>Файлы</th><th>Время</th><th>Доля</th></tr>
+{{range .Apps}}<tr><td>{{.Name}}</td><td>{{category .Category}}</td><td><code>{{join .Executables ", "}}</code></td><td class="num">{{duration .Seconds}}</td><td class="num">{{percent .Seconds $.Active}}</td></tr>
+{{end}}</table>{{else}}<p>Данных об активности нет.</p>{{end}}
+
+<h2>Подробно</h2>
+{{if .Report.Rows}}<table>
+<tr><th>Дата</th>{{if not .Report.User}}<th>Пользователь</th>{{end}}<th>Программа</th><th>Категория</th><th>Время</th></tr>
+{{range .Report.Rows}}<tr><td>{{.Date}}</td>{{if not $.Report.User}}<td>{{.User}}</td>{{end}}<td>{{.Name}}</td><td>{{category .Category}}</td><td class="num">{{duration .Seconds}}</td></tr>
+{{end}}</table>{{else}}<p>Данных об активности нет.</p>{{end}}
+</body>
+</html>
+`))
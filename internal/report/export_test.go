@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected exports in testdata")
+
+// goldenReport is a report with names that need escaping in HTML and quoting in CSV.
+func goldenReport() *Report {
+	classifier := apps.NewClassifier([]config.AppLabel{
+		{App: "tom.exe", Name: `Tom & "Jerry" <script>alert(1)</script>`, Category: "<b>cartoons</b>"},
+	}, nil)
+	days := map[string]map[string]map[string]int64{
+		"2026-10-11": {"alice": {"chrome.exe": 600}},
+		"2026-10-12": {"alice": {"chrome.exe": 3600, "tom.exe": 1800, "<img src=x>.exe": 65}, "bob": {"steam.exe": 1200}},
+		"2026-10-13": {"alice": {"javaw.exe": 900}},
+		"2026-10-14": {"alice": {"chrome.exe": 60}},
+	}
+	r := Build(days, classifier, "alice", day("2026-10-12"), day("2026-10-13"))
+	r.Name = "Алиса <младшая>"
+	r.Generated = time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	return r
+}
+
+func TestWriteGolden(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := goldenReport().Write(&out, format); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", "report."+string(format))
+			if *update {
+				if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("%s export differs from %s (go test -update rewrites it):\n%s", format, path, out.String())
+			}
+		})
+	}
+}
+
+func TestWriteHTMLEscapes(t *testing.T) {
+	var out bytes.Buffer
+	if err := goldenReport().WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, raw := range []string{"<script>", "<img src=x>", "<b>cartoons</b>", "<младшая>"} {
+		if strings.Contains(html, raw) {
+			t.Errorf("HTML contains %q unescaped", raw)
+		}
+	}
+	for _, escaped := range []string{"&lt;script&gt;", "&lt;img src=x&gt;.exe", "&lt;b&gt;cartoons&lt;/b&gt;", "Алиса &lt;младшая&gt;"} {
+		if !strings.Contains(html, escaped) {
+			t.Errorf("HTML does not contain %q", escaped)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"csv", "JSON", "Html"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("ParseFormat(%q) error = %v", s, err)
+		}
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Error("ParseFormat(\"xlsx\") succeeded")
+	}
+	if name := goldenReport().FileName(FormatCSV); name != "usage_alice_2026-10-12_2026-10-13.csv" {
+		t.Errorf("FileName() = %q", name)
+	}
+}
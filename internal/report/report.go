@@ -0,0 +1,211 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/tracker"
+)
+
+// CategoryIdle is the category of time without input.
+const CategoryIdle = "idle"
+
+// Row is the time one user spent in one application on one day.
+type Row struct {
+	Date     string `json:"date"`
+	User     string `json:"user"`
+	App      string `json:"app"`
+	Name     string `json:"name"`
+	Category string `json:"category"` // CategoryIdle for time without input
+	Seconds  int64  `json:"seconds"`
+}
+
+// Report is the usage of one user, or of all users, over a range of days.
+type Report struct {
+	From      string    `json:"from"`           // first day, YYYY-MM-DD
+	To        string    `json:"to"`             // last day, inclusive
+	User      string    `json:"user,omitempty"` // empty for all users
+	Name      string    `json:"name,omitempty"` // full name of User, shown in HTML
+	Generated time.Time `json:"generated"`
+	Rows      []Row     `json:"rows"` // by date and user, longest first
+}
+
+// Build collects the usage in days (date -> user -> app -> seconds) of username
+// from the day of from to the day of to, inclusive. An empty username means all
+// users, a zero from the first recorded day and a zero to today.
+func Build(days map[string]map[string]map[string]int64, classifier *apps.Classifier, username string, from, to time.Time) *Report {
+	r := &Report{
+		User:      username,
+		Generated: time.Now(),
+		Rows:      []Row{},
+	}
+	if to.IsZero() {
+		to = r.Generated
+	}
+	r.To = to.Format("2006-01-02")
+	r.From = from.Format("2006-01-02")
+	if from.IsZero() {
+		r.From = r.To
+		for date := range days {
+			if date < r.From {
+				r.From = date
+			}
+		}
+	}
+
+	for date, users := range days {
+		if date < r.From || date > r.To {
+			continue
+		}
+		for user, usage := range users {
+			if username != "" && !strings.EqualFold(user, username) {
+				continue
+			}
+			for app, seconds := range usage {
+				row := Row{Date: date, User: user, App: app, Seconds: seconds}
+				if app == tracker.IdleApp {
+					row.Name, row.Category = "Бездействие", CategoryIdle
+				} else {
+					label := classifier.Classify(app, "")
+					row.Name, row.Category = label.Name, label.Category
+				}
+				r.Rows = append(r.Rows, row)
+			}
+		}
+	}
+
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.Seconds != b.Seconds {
+			return a.Seconds > b.Seconds
+		}
+		return a.App < b.App
+	})
+	return r
+}
+
+// DayTotal is the time of one day of a report.
+type DayTotal struct {
+	Date   string
+	Active int64 // seconds
+	Idle   int64 // seconds
+}
+
+// Days returns the totals of every day of the report, including days without usage.
+func (r *Report) Days() []DayTotal {
+	totals := make(map[string]*DayTotal)
+	for _, row := range r.Rows {
+		total := totals[row.Date]
+		if total == nil {
+			total = &DayTotal{Date: row.Date}
+			totals[row.Date] = total
+		}
+		if row.Category == CategoryIdle {
+			total.Idle += row.Seconds
+		} else {
+			total.Active += row.Seconds
+		}
+	}
+
+	var days []DayTotal
+	first, err1 := time.Parse("2006-01-02", r.From)
+	last, err2 := time.Parse("2006-01-02", r.To)
+	if err1 != nil || err2 != nil {
+		return days
+	}
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if total := totals[date]; total != nil {
+			days = append(days, *total)
+		} else {
+			days = append(days, DayTotal{Date: date})
+		}
+	}
+	return days
+}
+
+// AppTotal is the time of one application over the whole report.
+type AppTotal struct {
+	Name        string
+	Category    string
+	Executables []string
+	Seconds     int64
+}
+
+// Apps returns the applications of the report merged by name, longest used first.
+// Idle time is left out.
+func (r *Report) Apps() []AppTotal {
+	var totals []AppTotal
+	index := make(map[string]int)
+	for _, row := range r.Rows {
+		if row.Category == CategoryIdle {
+			continue
+		}
+		i, ok := index[row.Name]
+		if !ok {
+			i = len(totals)
+			index[row.Name] = i
+			totals = append(totals, AppTotal{Name: row.Name, Category: row.Category})
+		}
+		totals[i].Seconds += row.Seconds
+		if !containsFold(totals[i].Executables, row.App) {
+			totals[i].Executables = append(totals[i].Executables, row.App)
+		}
+	}
+
+	sort.SliceStable(totals, func(i, j int) bool {
+		return totals[i].Seconds > totals[j].Seconds
+	})
+	return totals
+}
+
+// Total returns the active and idle time of the whole report in seconds.
+func (r *Report) Total() (active, idle int64) {
+	for _, row := range r.Rows {
+		if row.Category == CategoryIdle {
+			idle += row.Seconds
+		} else {
+			active += row.Seconds
+		}
+	}
+	return active, idle
+}
+
+// Subject returns who the report is about: the name, the user or all users.
+func (r *Report) Subject() string {
+	switch {
+	case r.Name != "":
+		return r.Name
+	case r.User != "":
+		return r.User
+	default:
+		return "все пользователи"
+	}
+}
+
+// FormatDuration renders seconds as hours and minutes, e.g. "1 ч 05 мин".
+func FormatDuration(seconds int64) string {
+	minutes := seconds / 60
+	if minutes < 60 {
+		return fmt.Sprintf("%d мин", minutes)
+	}
+	return fmt.Sprintf("%d ч %02d мин", minutes/60, minutes%60)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
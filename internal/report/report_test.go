@@ -0,0 +1,166 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/tracker"
+)
+
+// testDays is the usage the tests build reports from: date -> user -> app -> seconds.
+var testDays = map[string]map[string]map[string]int64{
+	"2026-10-11": {"alice": {"chrome.exe": 600}},
+	"2026-10-12": {
+		"alice": {"chrome.exe": 3600, "javaw.exe": 1800, tracker.IdleApp: 300},
+		"bob":   {"steam.exe": 1200},
+	},
+	"2026-10-13": {"alice": {"javaw.exe": 900, "steamwebhelper.exe": 60}},
+	"2026-10-15": {"ALICE": {"tom.exe": 120}},
+	"2026-10-16": {"alice": {"chrome.exe": 60}},
+}
+
+func day(date string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBuildRange(t *testing.T) {
+	classifier := apps.NewClassifier(nil, nil)
+	tests := []struct {
+		name       string
+		user       string
+		from, to   time.Time
+		wantFrom   string
+		wantTo     string
+		wantDates  []string // of the rows, in order
+		wantActive int64
+	}{
+		{
+			name: "both bounds inclusive", user: "alice",
+			from: day("2026-10-12"), to: day("2026-10-13"),
+			wantFrom: "2026-10-12", wantTo: "2026-10-13",
+			wantDates:  []string{"2026-10-12", "2026-10-12", "2026-10-12", "2026-10-13", "2026-10-13"},
+			wantActive: 3600 + 1800 + 900 + 60,
+		},
+		{
+			name: "one day, all users",
+			from: day("2026-10-12"), to: day("2026-10-12"),
+			wantFrom: "2026-10-12", wantTo: "2026-10-12",
+			wantDates:  []string{"2026-10-12", "2026-10-12", "2026-10-12", "2026-10-12"},
+			wantActive: 3600 + 1800 + 1200,
+		},
+		{
+			name: "users matched ignoring case", user: "Alice",
+			from: day("2026-10-14"), to: day("2026-10-15"),
+			wantFrom: "2026-10-14", wantTo: "2026-10-15",
+			wantDates:  []string{"2026-10-15"},
+			wantActive: 120,
+		},
+		{
+			name: "open start", user: "bob",
+			to:       day("2026-10-12"),
+			wantFrom: "2026-10-11", wantTo: "2026-10-12",
+			wantDates:  []string{"2026-10-12"},
+			wantActive: 1200,
+		},
+		{
+			name: "nothing in range", user: "alice",
+			from: day("2026-10-14"), to: day("2026-10-14"),
+			wantFrom: "2026-10-14", wantTo: "2026-10-14",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Build(testDays, classifier, tt.user, tt.from, tt.to)
+			if r.From != tt.wantFrom || r.To != tt.wantTo {
+				t.Errorf("range %s - %s, want %s - %s", r.From, r.To, tt.wantFrom, tt.wantTo)
+			}
+			var dates []string
+			for _, row := range r.Rows {
+				dates = append(dates, row.Date)
+			}
+			if len(dates) != len(tt.wantDates) {
+				t.Fatalf("rows of %v, want %v", dates, tt.wantDates)
+			}
+			for i := range dates {
+				if dates[i] != tt.wantDates[i] {
+					t.Fatalf("rows of %v, want %v", dates, tt.wantDates)
+				}
+			}
+			if active, _ := r.Total(); active != tt.wantActive {
+				t.Errorf("active = %ds, want %ds", active, tt.wantActive)
+			}
+			if days := r.Days(); len(days) == 0 || days[0].Date != tt.wantFrom || days[len(days)-1].Date != tt.wantTo {
+				t.Errorf("Days() = %+v, want every day from %s to %s", days, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+
+	// An open end is today
+	r := Build(testDays, classifier, "alice", day("2026-10-16"), time.Time{})
+	if today := time.Now().Format("2006-01-02"); r.To != today {
+		t.Errorf("open range ends %s, want today %s", r.To, today)
+	}
+}
+
+func TestReportTotals(t *testing.T) {
+	r := Build(testDays, apps.NewClassifier(nil, nil), "alice", day("2026-10-12"), day("2026-10-14"))
+
+	// Rows by date, longest first; idle time is its own category
+	want := []Row{
+		{"2026-10-12", "alice", "chrome.exe", "Google Chrome", config.CategoryBrowser, 3600},
+		{"2026-10-12", "alice", "javaw.exe", "Minecraft (Java)", config.CategoryGames, 1800},
+		{"2026-10-12", "alice", tracker.IdleApp, "Бездействие", CategoryIdle, 300},
+		{"2026-10-13", "alice", "javaw.exe", "Minecraft (Java)", config.CategoryGames, 900},
+		{"2026-10-13", "alice", "steamwebhelper.exe", "Steam", config.CategoryGames, 60},
+	}
+	if len(r.Rows) != len(want) {
+		t.Fatalf("rows %+v, want %d", r.Rows, len(want))
+	}
+	for i := range want {
+		if r.Rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, r.Rows[i], want[i])
+		}
+	}
+
+	days := r.Days()
+	wantDays := []DayTotal{{"2026-10-12", 5400, 300}, {"2026-10-13", 960, 0}, {"2026-10-14", 0, 0}}
+	if len(days) != len(wantDays) {
+		t.Fatalf("Days() = %+v, want %+v", days, wantDays)
+	}
+	for i := range wantDays {
+		if days[i] != wantDays[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], wantDays[i])
+		}
+	}
+
+	totals := r.Apps()
+	if len(totals) != 3 || totals[0].Name != "Google Chrome" || totals[1].Name != "Minecraft (Java)" || totals[1].Seconds != 2700 || totals[2].Name != "Steam" {
+		t.Errorf("Apps() = %+v, want Chrome, Minecraft with 2700s and Steam, without idle time", totals)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		seconds int64
+		want    string
+	}{
+		{0, "0 мин"},
+		{59, "0 мин"},
+		{60, "1 мин"},
+		{3599, "59 мин"},
+		{3600, "1 ч 00 мин"},
+		{3900, "1 ч 05 мин"},
+		{36000 + 59*60, "10 ч 59 мин"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.seconds); got != tt.want {
+			t.Errorf("FormatDuration(%d) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
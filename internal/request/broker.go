@@ -0,0 +1,208 @@
+// Package request lets a child ask the parents for more time: a helper running in
+// the child's session submits a request to the local endpoint, the bot forwards it
+// to the parents and the first answer wins.
+package request
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRateLimited is returned when a child asks again too soon or already has a pending request.
+	ErrRateLimited = errors.New("too many requests")
+	// ErrNotPending is returned when a request was already answered or has expired.
+	ErrNotPending = errors.New("request already answered or expired")
+	// ErrNotDelivered is returned when the parents could not be told about a request.
+	ErrNotDelivered = errors.New("parents cannot be reached, try again later")
+)
+
+// TimeRequest is a child's request for extra minutes.
+type TimeRequest struct {
+	ID        string
+	Username  string
+	Minutes   int
+	Reason    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Handler receives request events. Calls are made without locks held.
+type Handler interface {
+	// RequestCreated is called for every accepted request. An error withdraws the
+	// request, as nobody could be asked to answer it.
+	RequestCreated(req TimeRequest) error
+	// RequestExpired is called when nobody answered before ExpiresAt.
+	RequestExpired(req TimeRequest)
+}
+
+// Broker keeps pending requests, expires them and enforces the per-child rate limit.
+type Broker struct {
+	timeout    time.Duration
+	cooldown   time.Duration
+	maxMinutes int
+	handler    Handler
+
+	pending     map[string]*TimeRequest // id -> request
+	timers      map[string]*time.Timer  // id -> expiry timer
+	lastRequest map[string]time.Time    // username -> time of the last accepted request
+	mutex       sync.Mutex
+}
+
+// NewBroker creates a broker whose requests expire after timeout. A child may submit
+// a new request only cooldown after the previous one and may ask for at most maxMinutes.
+func NewBroker(timeout, cooldown time.Duration, maxMinutes int) *Broker {
+	return &Broker{
+		timeout:     timeout,
+		cooldown:    cooldown,
+		maxMinutes:  maxMinutes,
+		pending:     make(map[string]*TimeRequest),
+		timers:      make(map[string]*time.Timer),
+		lastRequest: make(map[string]time.Time),
+	}
+}
+
+// SetHandler registers the receiver of request events.
+func (b *Broker) SetHandler(handler Handler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handler = handler
+}
+
+// MaxMinutes returns the largest number of minutes a child may ask for.
+func (b *Broker) MaxMinutes() int {
+	return b.maxMinutes
+}
+
+// Submit records a request from username and notifies the handler. If the handler
+// fails, the request is withdrawn and the child may ask again right away.
+func (b *Broker) Submit(username string, minutes int, reason string) (TimeRequest, error) {
+	if minutes <= 0 || minutes > b.maxMinutes {
+		return TimeRequest{}, fmt.Errorf("minutes must be between 1 and %d", b.maxMinutes)
+	}
+	if len([]rune(reason)) > 200 {
+		reason = string([]rune(reason)[:200])
+	}
+
+	b.mutex.Lock()
+	now := time.Now()
+	for _, req := range b.pending {
+		if req.Username == username {
+			b.mutex.Unlock()
+			return TimeRequest{}, fmt.Errorf("%w: a request is already waiting for an answer", ErrRateLimited)
+		}
+	}
+	if last, ok := b.lastRequest[username]; ok && now.Sub(last) < b.cooldown {
+		b.mutex.Unlock()
+		return TimeRequest{}, fmt.Errorf("%w: try again in %d min", ErrRateLimited, int((b.cooldown-now.Sub(last)+time.Minute-1)/time.Minute))
+	}
+
+	id, err := newID()
+	if err != nil {
+		b.mutex.Unlock()
+		return TimeRequest{}, err
+	}
+	req := &TimeRequest{
+		ID:        id,
+		Username:  username,
+		Minutes:   minutes,
+		Reason:    reason,
+		CreatedAt: now,
+		ExpiresAt: now.Add(b.timeout),
+	}
+	previous, hadPrevious := b.lastRequest[username]
+	b.pending[id] = req
+	b.lastRequest[username] = now
+	b.timers[id] = time.AfterFunc(b.timeout, func() { b.expire(id) })
+	handler := b.handler
+	b.mutex.Unlock()
+
+	log.Printf("Time request %s from %s: %d min (%s)", id, username, minutes, reason)
+	if handler != nil {
+		if err := handler.RequestCreated(*req); err != nil {
+			log.Printf("Withdrawing time request %s: %v", id, err)
+			b.mutex.Lock()
+			if _, ok := b.pending[id]; ok {
+				delete(b.pending, id)
+				b.timers[id].Stop()
+				delete(b.timers, id)
+				if hadPrevious {
+					b.lastRequest[username] = previous
+				} else {
+					delete(b.lastRequest, username)
+				}
+			}
+			b.mutex.Unlock()
+			return TimeRequest{}, fmt.Errorf("%w: %v", ErrNotDelivered, err)
+		}
+	}
+	return *req, nil
+}
+
+// Get returns the pending request id without answering it.
+func (b *Broker) Get(id string) (TimeRequest, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	req, ok := b.pending[id]
+	if !ok {
+		return TimeRequest{}, ErrNotPending
+	}
+	return *req, nil
+}
+
+// Take removes the pending request id so that it can be answered. Only the first
+// caller succeeds; later ones get ErrNotPending.
+func (b *Broker) Take(id string) (TimeRequest, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	req, ok := b.pending[id]
+	if !ok {
+		return TimeRequest{}, ErrNotPending
+	}
+	delete(b.pending, id)
+	if t, ok := b.timers[id]; ok {
+		t.Stop()
+		delete(b.timers, id)
+	}
+	return *req, nil
+}
+
+func (b *Broker) expire(id string) {
+	req, err := b.Take(id)
+	if err != nil {
+		return
+	}
+	log.Printf("Time request %s from %s expired unanswered", id, req.Username)
+
+	b.mutex.Lock()
+	handler := b.handler
+	b.mutex.Unlock()
+	if handler != nil {
+		handler.RequestExpired(req)
+	}
+}
+
+// Stop cancels all expiry timers.
+func (b *Broker) Stop() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for id, t := range b.timers {
+		t.Stop()
+		delete(b.timers, id)
+	}
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,73 @@
+package request
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// handlerFunc delivers requests with a function and ignores expiry.
+type handlerFunc func(req TimeRequest) error
+
+func (f handlerFunc) RequestCreated(req TimeRequest) error { return f(req) }
+func (f handlerFunc) RequestExpired(req TimeRequest)       {}
+
+func TestSubmitNotDelivered(t *testing.T) {
+	broker := NewBroker(time.Minute, 10*time.Minute, 60)
+	t.Cleanup(broker.Stop)
+
+	offline := errors.New("bot not connected")
+	var delivered []TimeRequest
+	broker.SetHandler(handlerFunc(func(req TimeRequest) error {
+		if offline != nil {
+			return offline
+		}
+		delivered = append(delivered, req)
+		return nil
+	}))
+
+	_, err := broker.Submit("alice", 30, "")
+	if !errors.Is(err, ErrNotDelivered) {
+		t.Fatalf("Submit() error = %v, want %v", err, ErrNotDelivered)
+	}
+
+	// The undelivered request neither waits for an answer nor counts against the cooldown
+	offline = nil
+	req, err := broker.Submit("alice", 30, "")
+	if err != nil {
+		t.Fatalf("Submit() after the bot reconnected: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0].ID != req.ID {
+		t.Errorf("delivered %+v, want only %s", delivered, req.ID)
+	}
+	if _, err := broker.Submit("alice", 30, ""); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Submit() with a request pending: error = %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestSubmitNotDeliveredKeepsCooldown(t *testing.T) {
+	broker := NewBroker(time.Minute, 10*time.Minute, 60)
+	t.Cleanup(broker.Stop)
+
+	fail := false
+	broker.SetHandler(handlerFunc(func(req TimeRequest) error {
+		if fail {
+			return errors.New("no parent received the request")
+		}
+		return nil
+	}))
+
+	req, err := broker.Submit("alice", 30, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.Take(req.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed attempt does not reset the cooldown of the answered request
+	fail = true
+	if _, err := broker.Submit("alice", 30, ""); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Submit() within the cooldown: error = %v, want %v", err, ErrRateLimited)
+	}
+}
@@ -0,0 +1,157 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// DefaultPort is the loopback port of the request endpoint unless configured otherwise.
+const DefaultPort = 47321
+
+// submission is the body of POST /request. The child is whoever owns the
+// connection, not a name in the body.
+type submission struct {
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason"`
+}
+
+// reply is the response body of POST /request.
+type reply struct {
+	ID        string    `json:"id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Server is the local HTTP endpoint children's helpers submit requests to. It listens
+// on the loopback interface only.
+type Server struct {
+	broker   *Broker
+	identify func(client, server netip.AddrPort) (string, error)
+	server   *http.Server
+}
+
+// NewServer creates a server on 127.0.0.1:port. identify returns the child owning
+// the connection from client to server as configured, and rejects connections
+// from anyone who is not a child logged on to this computer.
+func NewServer(port int, broker *Broker, identify func(client, server netip.AddrPort) (string, error)) *Server {
+	s := &Server{broker: broker, identify: identify}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/request", s.handleRequest)
+	s.server = &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start serves requests until ctx is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("Time request endpoint listening on %s", s.server.Addr)
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeReply(w, http.StatusMethodNotAllowed, reply{Error: "use POST"})
+		return
+	}
+
+	var sub submission
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&sub); err != nil {
+		writeReply(w, http.StatusBadRequest, reply{Error: "invalid request body"})
+		return
+	}
+	username, err := s.caller(r)
+	if err != nil {
+		writeReply(w, http.StatusForbidden, reply{Error: err.Error()})
+		return
+	}
+
+	req, err := s.broker.Submit(username, sub.Minutes, sub.Reason)
+	if errors.Is(err, ErrRateLimited) {
+		writeReply(w, http.StatusTooManyRequests, reply{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, ErrNotDelivered) {
+		writeReply(w, http.StatusServiceUnavailable, reply{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeReply(w, http.StatusBadRequest, reply{Error: err.Error()})
+		return
+	}
+	writeReply(w, http.StatusAccepted, reply{ID: req.ID, ExpiresAt: req.ExpiresAt})
+}
+
+// caller returns the child who sent r, identified by the connection it came over.
+func (s *Server) caller(r *http.Request) (string, error) {
+	client, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return "", fmt.Errorf("unknown client address %q", r.RemoteAddr)
+	}
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return "", errors.New("unknown server address")
+	}
+	server, err := netip.ParseAddrPort(local.String())
+	if err != nil {
+		return "", fmt.Errorf("unknown server address %q", local)
+	}
+	return s.identify(client, server)
+}
+
+func writeReply(w http.ResponseWriter, status int, body reply) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Send submits a request to the service listening on 127.0.0.1:port and returns
+// the accepted request. It is used by the -request helper in the child's session;
+// the service tells who is asking from the connection.
+func Send(port int, minutes int, reason string) (TimeRequest, error) {
+	body, err := json.Marshal(submission{Minutes: minutes, Reason: reason})
+	if err != nil {
+		return TimeRequest{}, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(fmt.Sprintf("http://127.0.0.1:%d/request", port), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return TimeRequest{}, fmt.Errorf("service is not reachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var rep reply
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		return TimeRequest{}, fmt.Errorf("unexpected response: %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return TimeRequest{}, errors.New(rep.Error)
+	}
+	return TimeRequest{ID: rep.ID, Minutes: minutes, Reason: reason, ExpiresAt: rep.ExpiresAt}, nil
+}
@@ -0,0 +1,96 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// startServer serves broker on a free loopback port with identify and returns the port.
+func startServer(t *testing.T, broker *Broker, identify func(client, server netip.AddrPort) (string, error)) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := NewServer(port, broker, identify).Start(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			conn.Close()
+			return port
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServerIdentifiesCaller(t *testing.T) {
+	broker := NewBroker(time.Minute, time.Minute, 60)
+	t.Cleanup(broker.Stop)
+
+	var gotClient, gotServer netip.AddrPort
+	port := startServer(t, broker, func(client, server netip.AddrPort) (string, error) {
+		gotClient, gotServer = client, server
+		return "alice", nil
+	})
+
+	req, err := Send(port, 30, "homework")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := broker.Get(req.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Username != "alice" || pending.Minutes != 30 || pending.Reason != "homework" {
+		t.Errorf("pending request %+v, want 30 min for alice", pending)
+	}
+	if !gotClient.Addr().IsLoopback() || gotServer != netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(port)) {
+		t.Errorf("identified connection %v -> %v, want one from loopback to port %d", gotClient, gotServer, port)
+	}
+}
+
+func TestServerRejectsUnknownCaller(t *testing.T) {
+	broker := NewBroker(time.Minute, time.Minute, 60)
+	t.Cleanup(broker.Stop)
+	port := startServer(t, broker, func(client, server netip.AddrPort) (string, error) {
+		return "", errors.New("root is not a child account")
+	})
+
+	if _, err := Send(port, 30, ""); err == nil || err.Error() != "root is not a child account" {
+		t.Errorf("Send() error = %v, want the identification error", err)
+	}
+}
+
+func TestServerReportsUndelivered(t *testing.T) {
+	broker := NewBroker(time.Minute, time.Minute, 60)
+	t.Cleanup(broker.Stop)
+	broker.SetHandler(handlerFunc(func(req TimeRequest) error { return errors.New("bot not connected") }))
+	port := startServer(t, broker, func(client, server netip.AddrPort) (string, error) { return "alice", nil })
+
+	if _, err := Send(port, 30, ""); err == nil || err.Error() != ErrNotDelivered.Error()+": bot not connected" {
+		t.Errorf("Send() error = %v, want the request reported as not delivered", err)
+	}
+}
@@ -1,99 +1,54 @@
-//go:build windows
-
 package service
 
 import (
 	"context"
 	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/eventlog"
-
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/audit"
 	"github.com/Hepri/parental/internal/bot"
 	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+	"github.com/Hepri/parental/internal/request"
 	"github.com/Hepri/parental/internal/session"
 	"github.com/Hepri/parental/internal/shutdown"
+	"github.com/Hepri/parental/internal/sites"
 	"github.com/Hepri/parental/internal/tracker"
 )
 
 type ParentalControlService struct {
 	config      *config.Config
+	system      platform.Platform
 	bot         *bot.TelegramBot
 	tracker     *tracker.TimeTracker
 	sessionMgr  *session.Manager
 	shutdownMgr *shutdown.ShutdownManager
+	requests    *request.Broker
+	enforcer    *apps.Enforcer
+	sites       *sites.Filter
+	audit       *audit.Log
 	ctx         context.Context
 	cancel      context.CancelFunc
 }
 
-func (s *ParentalControlService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
-	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
-
-	log.Println("=== Parental Control Service Execute started ===")
-	changes <- svc.Status{State: svc.StartPending}
-
-	// Initialize service
-	log.Println("Initializing service...")
-	if err := s.initialize(); err != nil {
-		log.Printf("Failed to initialize service: %v", err)
-		changes <- svc.Status{State: svc.Stopped}
-		return false, 1
-	}
-
-	log.Println("Service initialized successfully, changing status to Running")
-	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
-
-	// Start background goroutines
-	log.Println("Starting background goroutines...")
-	go s.runBot()
-	go s.runTimeTracker()
-	go s.runSessionMonitor()
-	log.Println("All background goroutines started")
-
-	// Handle service control requests
-	log.Println("Entering main service loop...")
-	for {
-		select {
-		case c := <-r:
-			switch c.Cmd {
-			case svc.Interrogate:
-				log.Println("Service interrogate request received")
-				changes <- c.CurrentStatus
-			case svc.Stop, svc.Shutdown:
-				log.Println("Service stop/shutdown request received")
-				s.cleanup()
-				changes <- svc.Status{State: svc.StopPending}
-				log.Println("Service stopped")
-				return false, 0
-			case svc.Pause:
-				log.Println("Service pause request received")
-				changes <- svc.Status{State: svc.Paused, Accepts: cmdsAccepted}
-			case svc.Continue:
-				log.Println("Service continue request received")
-				changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
-			default:
-				log.Printf("Unexpected control request #%d", c)
-			}
-		case <-s.ctx.Done():
-			log.Println("Service context cancelled")
-			s.cleanup()
-			changes <- svc.Status{State: svc.StopPending}
-			log.Println("Service stopped after context cancellation")
-			return false, 0
-		}
-	}
-}
-
 func (s *ParentalControlService) initialize() error {
 	// Create context for graceful shutdown
 	log.Println("Creating service context...")
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	// Load configuration
+	// Make sure children cannot edit the configuration or the collected data
+	log.Println("Checking file permissions...")
+	if err := ensureFilePermissions(filepath.Dir(os.Args[0])); err != nil {
+		return err
+	}
+
 	configPath := filepath.Join(filepath.Dir(os.Args[0]), "config.json")
 	log.Printf("Loading configuration from: %s", configPath)
 	cfg, err := config.LoadConfig(configPath)
@@ -102,58 +57,141 @@ func (s *ParentalControlService) initialize() error {
 	}
 	s.config = cfg
 	log.Printf("Configuration loaded successfully. Authorized users: %d, Child accounts: %d",
-		len(cfg.AuthorizedUserIDs), len(cfg.ChildAccounts))
+		len(cfg.AllParents()), len(cfg.ChildAccounts))
+
+	s.system = platform.New()
+	s.audit = audit.New(filepath.Join(filepath.Dir(os.Args[0]), audit.FileName))
 
 	// Ensure child accounts exist
 	log.Println("Ensuring child accounts exist...")
-	if err := config.EnsureChildAccounts(s.config); err != nil {
+	if err := config.EnsureChildAccounts(s.config, s.system); err != nil {
 		return fmt.Errorf("failed to ensure child accounts: %v", err)
 	}
 	log.Println("Child accounts verified/created successfully")
 
 	// Initialize session manager
 	log.Println("Initializing session manager...")
-	s.sessionMgr, err = session.NewManager(s.config.ChildAccounts)
+	s.sessionMgr, err = session.NewManager(filepath.Dir(os.Args[0]), s.config.ChildAccounts, s.system, s.audit)
 	if err != nil {
 		return fmt.Errorf("failed to initialize session manager: %v", err)
 	}
+	s.sessionMgr.SetHolidays(s.config.Holidays)
+	s.sessionMgr.SetPINPolicy(s.config.PinLength, s.config.PinAlphabet)
+	warnings := make([]time.Duration, len(s.config.WarningMinutes))
+	for i, minutes := range s.config.WarningMinutes {
+		warnings[i] = time.Duration(minutes) * time.Minute
+	}
+	s.sessionMgr.SetWarnings(warnings)
 	log.Println("Session manager initialized")
 
 	// Initialize time tracker
 	log.Println("Initializing time tracker...")
-	s.tracker, err = tracker.NewTracker()
+	s.tracker, err = tracker.NewTracker(s.system, tracker.NewFileStorage(filepath.Dir(os.Args[0])))
 	if err != nil {
 		return fmt.Errorf("failed to initialize time tracker: %v", err)
 	}
+	s.tracker.SetIdleDetector(s.system)
+	s.tracker.SetRetentionDays(s.config.DataRetentionDays)
+	s.tracker.SetIdleThreshold(time.Duration(s.config.IdleThresholdSeconds) * time.Second)
+	s.tracker.SetTitleRetentionDays(s.config.WindowTitles.RetentionDays)
+	var titleChildren []string
+	for _, account := range s.config.ChildAccounts {
+		if account.CaptureTitles {
+			titleChildren = append(titleChildren, account.Username)
+		}
+	}
+	var titleFilter *apps.TitleFilter
+	if len(titleChildren) > 0 {
+		redact, err := s.config.WindowTitles.RedactPatterns()
+		if err != nil {
+			return err
+		}
+		titleFilter = apps.NewTitleFilter(titleChildren, redact)
+		s.tracker.SetTitleFunc(titleFilter.Title)
+		log.Printf("Window titles are recorded for %s", strings.Join(titleChildren, ", "))
+	}
+	if s.config.ExcludeIdleTime {
+		s.tracker.SetIdleHandler(s.sessionMgr.AddIdleTime)
+		log.Println("Idle time is excluded from quotas and session durations")
+	}
 	log.Println("Time tracker initialized")
 
+	// Enforce the application rules on the foreground process the tracker sees
+	s.enforcer = apps.NewEnforcer(s.system, s.tracker.TodayUsage, s.sessionMgr, s.audit)
+	if titleFilter != nil {
+		s.enforcer.SetTitleUsage(titleFilter.Title, s.tracker.TodayTitleUsage)
+	}
+	s.enforcer.SetClassifier(apps.NewClassifier(s.config.AppLabels, s.config.AppCategories))
+	for _, account := range s.config.ChildAccounts {
+		s.enforcer.SetRules(account.Username, account.Apps)
+	}
+	s.tracker.SetForegroundHandler(s.enforcer.Check)
+
+	// Block websites through the hosts file while children are logged on
+	s.sites = sites.NewFilter(s.system)
+	s.sites.SetHolidays(s.config.Holidays)
+	for _, account := range s.config.ChildAccounts {
+		s.sites.SetRules(account.Username, account.Sites)
+	}
+
 	// Initialize shutdown manager
 	log.Println("Initializing shutdown manager...")
-	s.shutdownMgr = shutdown.NewShutdownManager()
+	s.shutdownMgr = shutdown.NewShutdownManager(s.system, s.audit)
 	log.Println("Shutdown manager initialized")
 
+	// Initialize time requests from children
+	if !s.config.TimeRequests.Disabled {
+		tr := s.config.TimeRequests
+		s.requests = request.NewBroker(
+			time.Duration(tr.TimeoutMinutes)*time.Minute,
+			time.Duration(tr.CooldownMinutes)*time.Minute,
+			tr.MaxMinutes)
+	}
+
 	// Initialize Telegram bot
 	log.Println("Initializing Telegram bot...")
-	s.bot, err = bot.NewBot(s.config, s.sessionMgr, s.tracker, s.shutdownMgr)
+	s.bot, err = bot.NewBot(s.config, s.sessionMgr, s.tracker, s.shutdownMgr, s.requests, s.enforcer, s.sites, s.audit)
 	if err != nil {
 		return fmt.Errorf("failed to initialize Telegram bot: %v", err)
 	}
 	log.Println("Telegram bot initialized successfully")
 
 	// Setup event logging
-	elog, err := eventlog.Open("ParentalControlBot")
-	if err != nil {
-		log.Printf("Warning: Failed to open event log: %v", err)
-	} else {
-		elog.Info(1, "Parental Control Bot Service started")
-		elog.Close()
-		log.Println("Event log entry created")
-	}
+	writeEventLog("Parental Control Bot Service started")
 
 	log.Println("=== Service initialization completed successfully ===")
 	return nil
 }
 
+// ensureFilePermissions repairs the permissions of dataDir and of the protected files
+// in it and refuses to continue if other users can still access them afterwards.
+func ensureFilePermissions(dataDir string) error {
+	paths := config.ProtectedPaths(dataDir)
+	problems := append(config.CheckDataDir(dataDir), config.CheckPermissions(paths)...)
+	if len(problems) == 0 {
+		log.Println("File permissions are correct")
+		return nil
+	}
+
+	for _, problem := range problems {
+		log.Printf("Insecure permissions: %s", problem)
+	}
+	log.Println("Repairing file permissions...")
+	if err := config.ProtectDataDir(dataDir); err != nil {
+		return fmt.Errorf("failed to repair file permissions: %v", err)
+	}
+	if err := config.ProtectPaths(paths); err != nil {
+		return fmt.Errorf("failed to repair file permissions: %v", err)
+	}
+
+	problems = append(config.CheckDataDir(dataDir), config.CheckPermissions(paths)...)
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to start, insecure permissions remain: %s", problems[0])
+	}
+	log.Println("File permissions repaired")
+	return nil
+}
+
 func (s *ParentalControlService) runBot() {
 	log.Println("Starting Telegram bot...")
 	if err := s.bot.Start(s.ctx); err != nil {
@@ -192,7 +230,7 @@ func (s *ParentalControlService) runSessionMonitor() {
 				log.Printf("Found %d expired session(s)", len(expiredSessions))
 				for _, session := range expiredSessions {
 					log.Printf("Session expired for user: %s", session.Username)
-					if err := s.sessionMgr.LockSession(session.Username); err != nil {
+					if err := s.sessionMgr.EnforceLock(session.Username, audit.ActionExpired); err != nil {
 						log.Printf("ERROR: Failed to lock expired session for %s: %v", session.Username, err)
 					} else {
 						log.Printf("Successfully locked session for user: %s", session.Username)
@@ -203,8 +241,74 @@ func (s *ParentalControlService) runSessionMonitor() {
 			} else {
 				log.Printf("No expired sessions found")
 			}
+
+			// Lock sessions that ran past the end of the allowed hours
+			for _, session := range s.sessionMgr.GetOutOfScheduleSessions() {
+				log.Printf("Session of %s is outside allowed hours", session.Username)
+				if err := s.sessionMgr.EnforceLock(session.Username, audit.ActionOutsideSchedule); err != nil {
+					log.Printf("ERROR: Failed to lock session outside allowed hours for %s: %v", session.Username, err)
+				} else {
+					s.bot.NotifyOutsideSchedule(session.Username)
+				}
+			}
+		}
+	}
+}
+
+func (s *ParentalControlService) runSiteFilter() {
+	log.Println("Starting website filter...")
+	if err := s.sites.Start(s.ctx); err != nil {
+		log.Printf("Website filter error: %v", err)
+	}
+}
+
+func (s *ParentalControlService) runDigests() {
+	log.Println("Starting scheduled digests...")
+	s.bot.RunDigests(s.ctx)
+}
+
+func (s *ParentalControlService) runRequestServer() {
+	if s.requests == nil {
+		log.Println("Time requests are disabled")
+		return
+	}
+	server := request.NewServer(s.config.TimeRequests.Port, s.requests, s.identifyChild)
+	if err := server.Start(s.ctx); err != nil {
+		// The rest of the service keeps working without the endpoint
+		log.Printf("Time request endpoint stopped with error: %v", err)
+	}
+}
+
+// identifyChild accepts time requests only over connections opened by configured
+// children who are logged on to this computer. It returns the username as configured.
+func (s *ParentalControlService) identifyChild(client, server netip.AddrPort) (string, error) {
+	username, err := s.system.ConnectionOwner(client, server)
+	if err != nil {
+		log.Printf("Failed to identify time request from %v: %v", client, err)
+		return "", fmt.Errorf("cannot tell who is asking")
+	}
+
+	child := ""
+	for _, account := range s.config.ChildAccounts {
+		if strings.EqualFold(account.Username, username) {
+			child = account.Username
+			break
+		}
+	}
+	if child == "" {
+		return "", fmt.Errorf("%s is not a child account", username)
+	}
+
+	sessions, err := s.system.ListSessions()
+	if err != nil {
+		return "", fmt.Errorf("failed to check sessions: %v", err)
+	}
+	for _, session := range sessions {
+		if strings.EqualFold(session.Username, child) {
+			return child, nil
 		}
 	}
+	return "", fmt.Errorf("%s is not logged on", child)
 }
 
 func (s *ParentalControlService) cleanup() {
@@ -215,6 +319,10 @@ func (s *ParentalControlService) cleanup() {
 		s.cancel()
 	}
 
+	if s.requests != nil {
+		s.requests.Stop()
+	}
+
 	if s.bot != nil {
 		log.Println("Stopping Telegram bot...")
 		s.bot.Stop()
@@ -227,6 +335,11 @@ func (s *ParentalControlService) cleanup() {
 		log.Println("Time tracker stopped")
 	}
 
+	if s.sites != nil {
+		log.Println("Removing blocked sites from the hosts file...")
+		s.sites.Stop()
+	}
+
 	if s.sessionMgr != nil {
 		log.Println("Cleaning up session manager...")
 		s.sessionMgr.Cleanup()
@@ -235,18 +348,37 @@ func (s *ParentalControlService) cleanup() {
 
 	// Log service stop
 	log.Println("Writing to event log...")
-	elog, err := eventlog.Open("ParentalControlBot")
-	if err == nil {
-		elog.Info(1, "Parental Control Bot Service stopped")
-		elog.Close()
-		log.Println("Event log entry created")
-	} else {
-		log.Printf("Warning: Failed to write to event log: %v", err)
-	}
+	writeEventLog("Parental Control Bot Service stopped")
 
 	log.Println("=== Service cleanup completed ===")
 }
 
+// Run runs the service until ctx is cancelled. It is used where the service manager
+// simply starts the executable, such as systemd.
+func (s *ParentalControlService) Run(ctx context.Context) error {
+	if err := s.initialize(); err != nil {
+		return fmt.Errorf("failed to initialize service: %v", err)
+	}
+
+	go s.runBot()
+	go s.runTimeTracker()
+	go s.runSessionMonitor()
+	go s.runRequestServer()
+	go s.runSiteFilter()
+	go s.runDigests()
+
+	select {
+	case <-ctx.Done():
+		log.Println("Service stop requested")
+	case <-s.ctx.Done():
+		log.Println("Service context cancelled")
+	}
+
+	s.cleanup()
+	log.Println("Service stopped")
+	return nil
+}
+
 // RunDebug runs the service in debug mode (not as Windows service)
 func (s *ParentalControlService) RunDebug(ctx context.Context) error {
 	fmt.Println("Initializing Parental Control Bot in debug mode...")
@@ -268,6 +400,9 @@ func (s *ParentalControlService) RunDebug(ctx context.Context) error {
 	go s.runBot()
 	go s.runTimeTracker()
 	go s.runSessionMonitor()
+	go s.runRequestServer()
+	go s.runSiteFilter()
+	go s.runDigests()
 
 	// Wait for context cancellation
 	<-ctx.Done()
@@ -287,7 +422,7 @@ func LoadConfigForTest(configPath string) (*config.Config, error) {
 // TestBotConnection tests the Telegram bot connection
 func TestBotConnection(cfg *config.Config) (*bot.TelegramBot, error) {
 	// Create a minimal bot instance for testing
-	bot, err := bot.NewBot(cfg, nil, nil, nil)
+	bot, err := bot.NewBot(cfg, nil, nil, nil, nil, nil, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package service
+
+import "log"
+
+// writeEventLog has no system event log to write to; the message goes to the
+// regular log, which the service manager (e.g. journald) also collects.
+func writeEventLog(message string) {
+	log.Println(message)
+}
@@ -0,0 +1,83 @@
+//go:build windows
+
+package service
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func (s *ParentalControlService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
+	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
+
+	log.Println("=== Parental Control Service Execute started ===")
+	changes <- svc.Status{State: svc.StartPending}
+
+	// Initialize service
+	log.Println("Initializing service...")
+	if err := s.initialize(); err != nil {
+		log.Printf("Failed to initialize service: %v", err)
+		changes <- svc.Status{State: svc.Stopped}
+		return false, 1
+	}
+
+	log.Println("Service initialized successfully, changing status to Running")
+	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
+
+	// Start background goroutines
+	log.Println("Starting background goroutines...")
+	go s.runBot()
+	go s.runTimeTracker()
+	go s.runSessionMonitor()
+	go s.runRequestServer()
+	go s.runSiteFilter()
+	go s.runDigests()
+	log.Println("All background goroutines started")
+
+	// Handle service control requests
+	log.Println("Entering main service loop...")
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				log.Println("Service interrogate request received")
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Println("Service stop/shutdown request received")
+				s.cleanup()
+				changes <- svc.Status{State: svc.StopPending}
+				log.Println("Service stopped")
+				return false, 0
+			case svc.Pause:
+				log.Println("Service pause request received")
+				changes <- svc.Status{State: svc.Paused, Accepts: cmdsAccepted}
+			case svc.Continue:
+				log.Println("Service continue request received")
+				changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
+			default:
+				log.Printf("Unexpected control request #%d", c)
+			}
+		case <-s.ctx.Done():
+			log.Println("Service context cancelled")
+			s.cleanup()
+			changes <- svc.Status{State: svc.StopPending}
+			log.Println("Service stopped after context cancellation")
+			return false, 0
+		}
+	}
+}
+
+// writeEventLog records message in the Windows event log.
+func writeEventLog(message string) {
+	elog, err := eventlog.Open("ParentalControlBot")
+	if err != nil {
+		log.Printf("Warning: Failed to open event log: %v", err)
+		return
+	}
+	defer elog.Close()
+	elog.Info(1, message)
+	log.Println("Event log entry created")
+}
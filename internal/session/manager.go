@@ -1,17 +1,15 @@
-//go:build windows
-
 package session
 
 import (
 	"fmt"
 	"log"
+	"path/filepath"
 	"sync"
 	"time"
-	"unsafe"
-
-	"golang.org/x/sys/windows"
 
+	"github.com/Hepri/parental/internal/audit"
 	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
 )
 
 type ActiveSession struct {
@@ -19,216 +17,432 @@ type ActiveSession struct {
 	StartTime time.Time
 	Duration  time.Duration
 	IsActive  bool
+
+	// accountedUntil is the point up to which usage is either charged to the
+	// quota or excluded as idle time; the rest of the session is charged on stop.
+	accountedUntil time.Time
+
+	// pin is the one-time password the child logs in with during this session.
+	pin string
+
+	// Paused sessions keep their remaining time; the clock stopped at PausedAt.
+	Paused   bool
+	PausedAt time.Time
+}
+
+// Remaining returns how much of the session is left at now, or at the moment it was paused.
+func (s *ActiveSession) Remaining(now time.Time) time.Duration {
+	if s.Paused {
+		now = s.PausedAt
+	}
+	return s.StartTime.Add(s.Duration).Sub(now)
 }
 
 type Manager struct {
 	childAccounts  []config.ChildAccount
 	activeSessions map[string]*ActiveSession
 	timers         map[string]*time.Timer
+	warnings       map[string][]*time.Timer // pre-expiry warnings, see warnings.go
+	warningOffsets []time.Duration          // longest first
+	quota          *QuotaTracker
+	holidays       map[string]bool // date -> holiday
+	statePath      string
+	system         platform.Platform
+	pinLength      int
+	pinAlphabet    string
+	audit          *audit.Log
 	mutex          sync.RWMutex
 }
 
-var (
-	user32   = windows.NewLazySystemDLL("user32.dll")
-	wtsapi32 = windows.NewLazySystemDLL("wtsapi32.dll")
-	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
-	userenv  = windows.NewLazySystemDLL("userenv.dll")
-	kernel32 = windows.NewLazySystemDLL("kernel32.dll")
-
-	procLockWorkStation              = user32.NewProc("LockWorkStation")
-	procWTSEnumerateSessions         = wtsapi32.NewProc("WTSEnumerateSessionsW")
-	procWTSQuerySessionInformation   = wtsapi32.NewProc("WTSQuerySessionInformationW")
-	procLogonUser                    = advapi32.NewProc("LogonUserW")
-	procCreateProcessAsUser          = advapi32.NewProc("CreateProcessAsUserW")
-	procCreateProcessWithLogon       = advapi32.NewProc("CreateProcessWithLogonW")
-	procWTSFreeMemory                = wtsapi32.NewProc("WTSFreeMemory")
-	procWTSLogonUser                 = wtsapi32.NewProc("WTSLogonUserW")
-	procWTSLogoffSession             = wtsapi32.NewProc("WTSLogoffSession")
-	procWTSDisconnectSession         = wtsapi32.NewProc("WTSDisconnectSession")
-	procDuplicateTokenEx             = advapi32.NewProc("DuplicateTokenEx")
-	procSetTokenInformation          = advapi32.NewProc("SetTokenInformation")
-	procCreateEnvironmentBlock       = userenv.NewProc("CreateEnvironmentBlock")
-	procDestroyEnvironmentBlock      = userenv.NewProc("DestroyEnvironmentBlock")
-	procLoadUserProfile              = userenv.NewProc("LoadUserProfileW")
-	procUnloadUserProfile            = userenv.NewProc("UnloadUserProfileW")
-	procWTSGetActiveConsoleSessionId = kernel32.NewProc("WTSGetActiveConsoleSessionId")
-)
+// NewManager creates the session manager keeping its state files in dataDir and
+// restores sessions persisted before a restart. Session changes and enforcement are
+// recorded in auditLog, which may be nil.
+func NewManager(dataDir string, childAccounts []config.ChildAccount, system platform.Platform, auditLog *audit.Log) (*Manager, error) {
+	return newManager(dataDir, childAccounts, system, auditLog), nil
+}
 
-const (
-	WTS_CURRENT_SERVER_HANDLE  = 0
-	WTSActive                  = 0
-	WTSDisconnected            = 1
-	WTSConnected               = 2
-	WTSConnectState            = 8
-	WTSUserName                = 5
-	WTSDomainName              = 7
-	LOGON32_LOGON_INTERACTIVE  = 2
-	LOGON32_PROVIDER_DEFAULT   = 0
-	CREATE_UNICODE_ENVIRONMENT = 0x00000400
-	TokenSessionId             = 12
-)
+// newManager creates a session manager keeping its state files in dataDir.
+func newManager(dataDir string, childAccounts []config.ChildAccount, system platform.Platform, auditLog *audit.Log) *Manager {
+	m := &Manager{
+		// Own copy so quota edits from the bot don't race with the config
+		childAccounts:  append([]config.ChildAccount(nil), childAccounts...),
+		activeSessions: make(map[string]*ActiveSession),
+		timers:         make(map[string]*time.Timer),
+		warnings:       make(map[string][]*time.Timer),
+		warningOffsets: defaultWarnings,
+		quota:          NewQuotaTracker(filepath.Join(dataDir, "quota_usage.json")),
+		holidays:       make(map[string]bool),
+		statePath:      filepath.Join(dataDir, "sessions_state.json"),
+		system:         system,
+		pinLength:      6,
+		pinAlphabet:    config.DefaultPinAlphabet,
+		audit:          auditLog,
+	}
 
-type profileInfo struct {
-	Size        uint32
-	Flags       uint32
-	UserName    *uint16
-	ProfilePath *uint16
-	DefaultPath *uint16
-	ServerName  *uint16
-	PolicyPath  *uint16
-	ProfileGuid *uint16
-	hProfile    windows.Handle
-}
+	// Pick up sessions granted before a crash or reboot
+	m.restoreState()
 
-type WTS_SESSION_INFO struct {
-	SessionID      uint32
-	WinStationName *uint16
-	State          uint32
+	return m
 }
 
-func NewManager(childAccounts []config.ChildAccount) (*Manager, error) {
-	return &Manager{
-		childAccounts:  childAccounts,
-		activeSessions: make(map[string]*ActiveSession),
-		timers:         make(map[string]*time.Timer),
-	}, nil
+// SetPINPolicy sets the length and alphabet of the one-time PINs issued by GrantAccess.
+func (m *Manager) SetPINPolicy(length int, alphabet string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pinLength = length
+	m.pinAlphabet = alphabet
 }
 
-func (m *Manager) GrantAccess(username string, duration time.Duration) error {
+// GrantAccess starts a session for username and returns the duration actually granted,
+// which may be shorter than requested when the child's daily quota is nearly used up
+// or the allowed hours end sooner, together with the one-time PIN the child logs in with.
+// The PIN stops working when the session is locked or expires. actor is the Telegram
+// user who granted access, for the audit log.
+func (m *Manager) GrantAccess(actor int64, username string, duration time.Duration) (granted time.Duration, pin string, err error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// Find child account
-	var account *config.ChildAccount
-	for _, acc := range m.childAccounts {
-		if acc.Username == username {
-			account = &acc
-			break
-		}
+	requested := duration
+	defer func() {
+		m.audit.Record(actor, username, audit.ActionGrant, map[string]interface{}{
+			"requested_minutes": int(requested / time.Minute),
+			"granted_minutes":   int(granted / time.Minute),
+		}, err)
+	}()
+
+	if m.findAccount(username) == nil {
+		return 0, "", fmt.Errorf("child account %s not found", username)
 	}
 
-	if account == nil {
-		return fmt.Errorf("child account %s not found", username)
+	now := time.Now()
+
+	// The running session is only replaced once the new grant is certain to succeed,
+	// so a refused grant leaves it, its PIN and its expiry in place. Its time so far
+	// counts against the quota here and is charged when it is stopped below.
+	if end, limited, reason := m.sessionLimitLocked(username, now); limited {
+		if !end.After(now) {
+			return 0, "", reason
+		}
+		if now.Add(duration).After(end) {
+			log.Printf("Trimming grant for %s from %v to %v (%v)", username, duration, end.Sub(now), reason)
+			duration = end.Sub(now)
+		}
 	}
 
 	// We no longer try to create/login the session automatically
 
-	// Change password to temporary simple one for manual login flow
-	if err := config.SetUserPassword(username, "123456"); err != nil {
-		return fmt.Errorf("failed to set temporary password: %v")
+	// Change password to a fresh one-time PIN for manual login flow
+	pin, err = config.GenerateRandomString(m.pinLength, m.pinAlphabet)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to generate PIN: %v", err)
+	}
+	if err := m.system.SetPassword(username, pin); err != nil {
+		// The password may or may not have changed; only the running session's PIN stays valid
+		if session, exists := m.activeSessions[username]; exists && session.IsActive && !session.Paused && session.pin != "" {
+			_ = m.system.SetPassword(username, session.pin)
+		} else {
+			m.restorePasswordLocked(username)
+		}
+		return 0, "", fmt.Errorf("failed to set temporary password: %v", err)
 	}
 
+	// A new grant replaces the running session; charge what was used so far
+	m.stopSessionLocked(username, now)
+
 	// Create/update active session record
 	m.activeSessions[username] = &ActiveSession{
-		Username:  username,
-		StartTime: time.Now(),
-		Duration:  duration,
-		IsActive:  true,
+		Username:       username,
+		StartTime:      now,
+		Duration:       duration,
+		IsActive:       true,
+		accountedUntil: now,
+		pin:            pin,
 	}
 
-	// Cancel existing timer if any
-	if t, ok := m.timers[username]; ok {
-		t.Stop()
-		delete(m.timers, username)
-	}
 	// Schedule exact expiry lock
 	m.timers[username] = time.AfterFunc(duration, func() {
-		_ = m.LockSession(username)
+		_ = m.EnforceLock(username, audit.ActionExpired)
 	})
+	m.scheduleWarningsLocked(username, now.Add(duration), now)
+	m.persistLocked()
 
-	log.Printf("Granted access to user %s for %v (temporary password set)", username, duration)
-	return nil
+	log.Printf("Granted access to user %s for %v (one-time PIN set)", username, duration)
+	return duration, pin, nil
 }
 
 // ExtendSession increases the remaining time for an active session and reschedules the timer.
-func (m *Manager) ExtendSession(username string, extra time.Duration) error {
+// It returns the extension actually applied after the daily quota and allowed hours are taken into account.
+func (m *Manager) ExtendSession(actor int64, username string, extra time.Duration) (extended time.Duration, err error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	requested := extra
+	defer func() {
+		m.audit.Record(actor, username, audit.ActionExtend, map[string]interface{}{
+			"requested_minutes": int(requested / time.Minute),
+			"extended_minutes":  int(extended / time.Minute),
+		}, err)
+	}()
+
 	session, exists := m.activeSessions[username]
 	if !exists || !session.IsActive {
-		return fmt.Errorf("no active session for %s", username)
+		return 0, fmt.Errorf("no active session for %s", username)
+	}
+
+	// Limits are applied when a paused session is resumed
+	if session.Paused {
+		session.Duration += extra
+		m.persistLocked()
+		return extra, nil
+	}
+
+	now := time.Now()
+	if end, limited, reason := m.sessionLimitLocked(username, now); limited {
+		sessionEnd := session.StartTime.Add(session.Duration)
+		if !end.After(sessionEnd) {
+			return 0, reason
+		}
+		if sessionEnd.Add(extra).After(end) {
+			extra = end.Sub(sessionEnd)
+		}
 	}
 
 	// Increase total allowed duration
 	session.Duration += extra
+	m.rescheduleLocked(username, now)
+	return extra, nil
+}
+
+// AddIdleTime excludes the idle interval [from, to) from the running session of username:
+// the interval is not charged against the daily quota and the session end moves later by its length.
+func (m *Manager) AddIdleTime(username string, from, to time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.activeSessions[username]
+	if !exists || !session.IsActive || session.Paused {
+		return
+	}
+	if from.Before(session.accountedUntil) {
+		from = session.accountedUntil
+	}
+	if !to.After(from) {
+		return
+	}
+
+	// Charge the active part before the idle interval, then skip the interval itself
+	m.quota.AddUsage(username, session.accountedUntil, from)
+	session.accountedUntil = to
+	session.Duration += to.Sub(from)
+
+	now := time.Now()
+	m.rescheduleLocked(username, now)
+	m.applyLimitsLocked(username, now)
+}
+
+// SetDailyQuota replaces the quota of username. A running session is shortened
+// if it would otherwise outlast the new allowance.
+func (m *Manager) SetDailyQuota(username string, quota *config.DailyQuota) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account := m.findAccount(username)
+	if account == nil {
+		return fmt.Errorf("child account %s not found", username)
+	}
+	account.Quota = quota
+
+	m.applyLimitsLocked(username, time.Now())
+	return nil
+}
+
+// sessionLimitLocked returns the latest time a session of username may run until, given
+// its daily quota and allowed hours. reason is the error describing the limit that binds.
+func (m *Manager) sessionLimitLocked(username string, now time.Time) (end time.Time, limited bool, reason error) {
+	if remaining, ok := m.remainingQuotaLocked(username, now); ok {
+		end, limited, reason = now.Add(remaining), true, ErrQuotaExhausted
+	}
+	if until, ok := m.allowedUntilLocked(username, now); ok && (!limited || until.Before(end)) {
+		end, limited, reason = until, true, ErrOutsideSchedule
+	}
+	return end, limited, reason
+}
+
+// applyLimitsLocked shortens the running session of username so that it ends
+// no later than its quota and allowed hours permit.
+func (m *Manager) applyLimitsLocked(username string, now time.Time) {
+	session, exists := m.activeSessions[username]
+	if !exists || !session.IsActive || session.Paused {
+		return
+	}
+	if end, limited, _ := m.sessionLimitLocked(username, now); limited && end.Before(session.StartTime.Add(session.Duration)) {
+		session.Duration = end.Sub(session.StartTime)
+		m.rescheduleLocked(username, now)
+	}
+}
+
+// QuotaStatus reports today's allowance and usage (including the running session) for username.
+// limited is false when the child has no quota configured.
+func (m *Manager) QuotaStatus(username string) (allowance, used time.Duration, limited bool) {
+	return m.QuotaOn(username, time.Now())
+}
+
+// QuotaOn reports the allowance and usage of username on the calendar day containing
+// day; today's usage includes the running session. limited is false when the child
+// has no quota configured.
+func (m *Manager) QuotaOn(username string, day time.Time) (allowance, used time.Duration, limited bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	account := m.findAccount(username)
+	if account == nil || account.Quota == nil {
+		return 0, 0, false
+	}
+	allowance = time.Duration(account.Quota.MinutesFor(day)) * time.Minute
+	used = m.quota.Used(username, day)
+	if now := time.Now(); day.Format("2006-01-02") == now.Format("2006-01-02") {
+		used += m.activeElapsedTodayLocked(username, now)
+	}
+	return allowance, used, true
+}
+
+func (m *Manager) findAccount(username string) *config.ChildAccount {
+	for i := range m.childAccounts {
+		if m.childAccounts[i].Username == username {
+			return &m.childAccounts[i]
+		}
+	}
+	return nil
+}
+
+// remainingQuotaLocked returns how much of today's allowance is left for username,
+// counting the running session. limited is false when no quota is configured.
+func (m *Manager) remainingQuotaLocked(username string, now time.Time) (remaining time.Duration, limited bool) {
+	account := m.findAccount(username)
+	if account == nil || account.Quota == nil {
+		return 0, false
+	}
+	allowance := time.Duration(account.Quota.MinutesFor(now)) * time.Minute
+	used := m.quota.Used(username, now) + m.activeElapsedTodayLocked(username, now)
+	return allowance - used, true
+}
+
+// activeElapsedTodayLocked returns how long the running session of username has lasted since midnight.
+func (m *Manager) activeElapsedTodayLocked(username string, now time.Time) time.Duration {
+	session, exists := m.activeSessions[username]
+	if !exists || !session.IsActive || session.Paused {
+		return 0
+	}
+	y, mo, d := now.Date()
+	start := session.accountedUntil
+	if midnight := time.Date(y, mo, d, 0, 0, 0, 0, now.Location()); start.Before(midnight) {
+		start = midnight
+	}
+	// An expired session waiting for its lock uses no more time
+	end := now
+	if expiry := session.StartTime.Add(session.Duration); expiry.Before(end) {
+		end = expiry
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
 
-	// Reschedule timer
+// rescheduleLocked restarts the expiry and warning timers of username from the session's current end time.
+func (m *Manager) rescheduleLocked(username string, now time.Time) {
 	if t, ok := m.timers[username]; ok {
 		t.Stop()
 		delete(m.timers, username)
 	}
-	remaining := session.StartTime.Add(session.Duration).Sub(time.Now())
+	m.persistLocked()
+
+	session := m.activeSessions[username]
+	remaining := session.StartTime.Add(session.Duration).Sub(now)
 	if remaining <= 0 {
-		// If already expired after extension calculation, immediately lock
-		go func(u string) { _ = m.LockSession(u) }(username)
-		return nil
+		// If already expired after recalculation, immediately lock
+		m.stopWarningsLocked(username)
+		go func(u string) { _ = m.EnforceLock(u, audit.ActionExpired) }(username)
+		return
 	}
-	m.timers[username] = time.AfterFunc(remaining, func() { _ = m.LockSession(username) })
-	return nil
+	m.timers[username] = time.AfterFunc(remaining, func() { _ = m.EnforceLock(username, audit.ActionExpired) })
+	m.scheduleWarningsLocked(username, now.Add(remaining), now)
 }
 
-func (m *Manager) LockSession(username string) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	// Remove from active sessions
+// stopSessionLocked ends the tracked session of username, charging the time used
+// against the daily quota, and cancels its timers.
+func (m *Manager) stopSessionLocked(username string, now time.Time) {
 	if session, exists := m.activeSessions[username]; exists {
+		// Paused sessions were charged when they were paused
+		if session.IsActive && !session.Paused {
+			end := now
+			if expiry := session.StartTime.Add(session.Duration); expiry.Before(end) {
+				end = expiry
+			}
+			m.quota.AddUsage(username, session.accountedUntil, end)
+		}
 		session.IsActive = false
 		delete(m.activeSessions, username)
+		m.persistLocked()
 	}
 
-	// Stop and remove any timer
 	if t, ok := m.timers[username]; ok {
 		t.Stop()
 		delete(m.timers, username)
 	}
+	m.stopWarningsLocked(username)
+}
+
+// LockSession ends the session of username on behalf of actor.
+func (m *Manager) LockSession(actor int64, username string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	err := m.lockLocked(username)
+	m.audit.Record(actor, username, audit.ActionLock, nil, err)
+	return err
+}
+
+// EnforceLock ends the session of username because a limit was reached; action
+// records which one in the audit log.
+func (m *Manager) EnforceLock(username, action string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	err := m.lockLocked(username)
+	m.audit.Record(audit.System, username, action, nil, err)
+	return err
+}
+
+func (m *Manager) lockLocked(username string) error {
+	// Remove from active sessions and stop any timer
+	m.stopSessionLocked(username, time.Now())
+
+	return m.disconnectLocked(username)
+}
 
+// disconnectLocked locks the desktop session of username, keeping its applications
+// running, and reverts the password to the configured one.
+func (m *Manager) disconnectLocked(username string) error {
 	// Get active sessions to find the user's session
-	sessions, err := m.getActiveSessions()
+	sessions, err := m.system.ListSessions()
 	if err != nil {
 		return fmt.Errorf("failed to get active sessions: %v", err)
 	}
 
 	for _, session := range sessions {
-		if session.State == WTSActive {
-			sessionUser, err := m.getSessionUsername(session.SessionID)
-			if err != nil {
-				continue
+		if session.Active && session.Username == username {
+			// Disconnect (lock) this session so apps keep running
+			if err := m.system.DisconnectSession(session.ID); err != nil {
+				// Even if disconnect fails, still revert password below
+				log.Printf("Disconnect failed for %s: %v", username, err)
 			}
-			if sessionUser == username {
-				// Disconnect (lock) this session so apps keep running
-				if err := m.disconnectSessionByID(session.SessionID); err != nil {
-					// Even if logoff fails, still revert password below
-					log.Printf("Disconnect failed for %s: %v", username, err)
-				}
-				// Revert password to configured one (always)
-				var configured string
-				for _, acc := range m.childAccounts {
-					if acc.Username == username {
-						configured = acc.Password
-						break
-					}
-				}
-				if configured != "" {
-					_ = config.SetUserPassword(username, configured)
-				}
-				return nil
-			}
-		}
-	}
-
-	// Session not found: just revert password and succeed
-	var configured string
-	for _, acc := range m.childAccounts {
-		if acc.Username == username {
-			configured = acc.Password
 			break
 		}
 	}
-	if configured != "" {
-		_ = config.SetUserPassword(username, configured)
-	}
+
+	// Revert password to configured one (always)
+	m.restorePasswordLocked(username)
 	return nil
 }
 
@@ -236,45 +450,31 @@ func (m *Manager) LockAllSessions() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// Clear all active sessions
-	for username, session := range m.activeSessions {
-		session.IsActive = false
+	// Clear all active sessions and timers
+	now := time.Now()
+	for username := range m.activeSessions {
+		m.stopSessionLocked(username, now)
 		log.Printf("Locked session for user %s", username)
 	}
-	m.activeSessions = make(map[string]*ActiveSession)
-
-	// Stop and clear all timers
 	for u, t := range m.timers {
 		t.Stop()
 		delete(m.timers, u)
 	}
 
 	// Lock all child account sessions
-	sessions, err := m.getActiveSessions()
+	sessions, err := m.system.ListSessions()
 	if err != nil {
 		return fmt.Errorf("failed to get active sessions: %v", err)
 	}
 
 	for _, session := range sessions {
-		if session.State == WTSActive {
-			sessionUser, err := m.getSessionUsername(session.SessionID)
-			if err != nil {
-				continue
-			}
-
-			// Check if this is a child account
-			for _, account := range m.childAccounts {
-				if account.Username == sessionUser {
-					if err := m.disconnectSessionByID(session.SessionID); err != nil {
-						log.Printf("Failed to disconnect session for %s: %v", sessionUser, err)
-					}
-					if account.Password != "" {
-						_ = config.SetUserPassword(sessionUser, account.Password)
-					}
-					break
-				}
-			}
+		if !session.Active || m.findAccount(session.Username) == nil {
+			continue
 		}
+		if err := m.system.DisconnectSession(session.ID); err != nil {
+			log.Printf("Failed to disconnect session for %s: %v", session.Username, err)
+		}
+		m.restorePasswordLocked(session.Username)
 	}
 
 	return nil
@@ -283,46 +483,73 @@ func (m *Manager) LockAllSessions() error {
 // ForceLogoffAllChildSessions logs off all sessions for configured child accounts,
 // regardless of whether they are tracked internally. It also reverts passwords and
 // clears timers and active session records.
-func (m *Manager) ForceLogoffAllChildSessions() error {
+func (m *Manager) ForceLogoffAllChildSessions(actor int64) (err error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	defer func() { m.audit.Record(actor, "", audit.ActionLockAll, nil, err) }()
+
 	// Stop timers and clear in-memory sessions
+	now := time.Now()
+	for username := range m.activeSessions {
+		m.stopSessionLocked(username, now)
+	}
 	for u, t := range m.timers {
 		t.Stop()
 		delete(m.timers, u)
 	}
-	m.activeSessions = make(map[string]*ActiveSession)
 
 	// Enumerate all sessions and logoff child accounts
-	sessions, err := m.getActiveSessions()
+	sessions, err := m.system.ListSessions()
 	if err != nil {
 		return fmt.Errorf("failed to enumerate sessions: %v", err)
 	}
 
 	for _, s := range sessions {
-		sessionUser, err := m.getSessionUsername(s.SessionID)
-		if err != nil || sessionUser == "" {
+		if s.Username == "" || m.findAccount(s.Username) == nil {
 			continue
 		}
-		for _, acc := range m.childAccounts {
-			if acc.Username == sessionUser {
-				_ = m.logoffSessionByID(s.SessionID)
-				break
-			}
-		}
+		_ = m.system.LogoffSession(s.ID)
 	}
 
 	// Revert passwords for all children to configured values
 	for _, acc := range m.childAccounts {
-		if acc.Password != "" {
-			_ = config.SetUserPassword(acc.Username, acc.Password)
-		}
+		m.restorePasswordLocked(acc.Username)
 	}
 
 	return nil
 }
 
+// RestorePassword sets the configured password for username.
+func (m *Manager) RestorePassword(actor int64, username string) (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	defer func() { m.audit.Record(actor, username, audit.ActionResetPassword, nil, err) }()
+
+	account := m.findAccount(username)
+	if account == nil {
+		return fmt.Errorf("child account %s not found", username)
+	}
+	if account.Password == "" {
+		return fmt.Errorf("no password configured for %s", username)
+	}
+	return m.system.SetPassword(username, account.Password)
+}
+
+// restorePasswordLocked reverts username to the configured password, logging failures.
+func (m *Manager) restorePasswordLocked(username string) {
+	account := m.findAccount(username)
+	if account == nil || account.Password == "" {
+		return
+	}
+	if err := m.system.SetPassword(username, account.Password); err != nil {
+		log.Printf("Failed to restore password for %s: %v", username, err)
+	}
+}
+
+// GetActiveSessions returns copies of the active sessions by username. The manager
+// keeps changing its own sessions, so callers never see them.
 func (m *Manager) GetActiveSessions() map[string]*ActiveSession {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
@@ -330,13 +557,15 @@ func (m *Manager) GetActiveSessions() map[string]*ActiveSession {
 	result := make(map[string]*ActiveSession)
 	for username, session := range m.activeSessions {
 		if session.IsActive {
-			result[username] = session
+			snapshot := *session
+			result[username] = &snapshot
 		}
 	}
 
 	return result
 }
 
+// GetExpiredSessions returns copies of the running sessions whose time is up.
 func (m *Manager) GetExpiredSessions() []*ActiveSession {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
@@ -345,140 +574,27 @@ func (m *Manager) GetExpiredSessions() []*ActiveSession {
 	now := time.Now()
 
 	for _, session := range m.activeSessions {
-		if session.IsActive && now.Sub(session.StartTime) >= session.Duration {
-			expired = append(expired, session)
+		if session.IsActive && !session.Paused && now.Sub(session.StartTime) >= session.Duration {
+			snapshot := *session
+			expired = append(expired, &snapshot)
 		}
 	}
 
 	return expired
 }
 
-func (m *Manager) getActiveSessions() ([]WTS_SESSION_INFO, error) {
-	var sessionInfo *WTS_SESSION_INFO
-	var count uint32
-
-	ret, _, _ := procWTSEnumerateSessions.Call(
-		WTS_CURRENT_SERVER_HANDLE,
-		0, // Reserved
-		1, // Version
-		uintptr(unsafe.Pointer(&sessionInfo)),
-		uintptr(unsafe.Pointer(&count)),
-	)
-
-	if ret == 0 {
-		return nil, fmt.Errorf("WTSEnumerateSessions failed")
-	}
-
-	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(sessionInfo)))
-
-	sessions := make([]WTS_SESSION_INFO, count)
-	for i := uint32(0); i < count; i++ {
-		sessions[i] = *(*WTS_SESSION_INFO)(unsafe.Pointer(uintptr(unsafe.Pointer(sessionInfo)) + uintptr(i)*unsafe.Sizeof(*sessionInfo)))
-	}
-
-	return sessions, nil
-}
-
-func (m *Manager) getSessionUsername(sessionID uint32) (string, error) {
-	var buffer *uint16
-	var bytesReturned uint32
-
-	ret, _, _ := procWTSQuerySessionInformation.Call(
-		WTS_CURRENT_SERVER_HANDLE,
-		uintptr(sessionID),
-		WTSUserName,
-		uintptr(unsafe.Pointer(&buffer)),
-		uintptr(unsafe.Pointer(&bytesReturned)),
-	)
-
-	if ret == 0 {
-		return "", fmt.Errorf("WTSQuerySessionInformation failed")
-	}
-
-	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(buffer)))
-
-	return windows.UTF16PtrToString(buffer), nil
-}
-
-func (m *Manager) logInUser(account *config.ChildAccount) error {
-	u, _ := windows.UTF16PtrFromString(account.Username)
-	p, _ := windows.UTF16PtrFromString(account.Password)
-	app, _ := windows.UTF16PtrFromString("C:\\Windows\\explorer.exe")
-
-	var si windows.StartupInfo
-	si.Cb = uint32(unsafe.Sizeof(si))
-	desktop, _ := windows.UTF16PtrFromString("winsta0\\default")
-	si.Desktop = desktop
-	var pi windows.ProcessInformation
-
-	const LOGON_WITH_PROFILE = 1
-	const CREATE_UNICODE_ENVIRONMENT = 0x00000400
-	const CREATE_NEW_CONSOLE = 0x00000010
-
-	r, _, err := procCreateProcessWithLogon.Call(
-		uintptr(unsafe.Pointer(u)),
-		0,
-		uintptr(unsafe.Pointer(p)),
-		LOGON_WITH_PROFILE,
-		uintptr(unsafe.Pointer(app)),
-		0,
-		CREATE_UNICODE_ENVIRONMENT|CREATE_NEW_CONSOLE,
-		0, 0,
-		uintptr(unsafe.Pointer(&si)),
-		uintptr(unsafe.Pointer(&pi)),
-	)
-	if r == 0 {
-		return fmt.Errorf("CreateProcessWithLogonW failed: %v", err)
-	}
-
-	windows.CloseHandle(pi.Process)
-	windows.CloseHandle(pi.Thread)
-	return nil
-}
-
-func (m *Manager) lockSessionByID(sessionID uint32) error {
-	// For now, we'll use LockWorkStation which locks the current session
-	// In a more sophisticated implementation, you would target specific sessions
-	ret, _, _ := procLockWorkStation.Call()
-	if ret == 0 {
-		return fmt.Errorf("LockWorkStation failed")
-	}
-	return nil
-}
-
-func (m *Manager) logoffSessionByID(sessionID uint32) error {
-	r, _, _ := procWTSLogoffSession.Call(
-		WTS_CURRENT_SERVER_HANDLE,
-		uintptr(sessionID),
-		0,
-	)
-	if r == 0 {
-		return fmt.Errorf("WTSLogoffSession failed")
-	}
-	return nil
-}
-
-func (m *Manager) disconnectSessionByID(sessionID uint32) error {
-	r, _, _ := procWTSDisconnectSession.Call(
-		WTS_CURRENT_SERVER_HANDLE,
-		uintptr(sessionID),
-		0,
-	)
-	if r == 0 {
-		// Best-effort fallback to local lock
-		_, _, _ = procLockWorkStation.Call()
-	}
-	return nil
-}
-
 func (m *Manager) Cleanup() {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// Clear all active sessions
-	for username, session := range m.activeSessions {
-		session.IsActive = false
-		log.Printf("Cleaned up session for user %s", username)
+	// Stop timers but keep sessions persisted so they resume after a restart
+	for username, t := range m.timers {
+		t.Stop()
+		delete(m.timers, username)
+		log.Printf("Cleaned up session timer for user %s", username)
+	}
+	for username := range m.warnings {
+		m.stopWarningsLocked(username)
 	}
-	m.activeSessions = make(map[string]*ActiveSession)
+	m.persistLocked()
 }
@@ -0,0 +1,399 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// newTestManager returns a manager of accounts backed by a fake platform on which
+// every account exists, keeping its state in a temporary directory.
+func newTestManager(t *testing.T, accounts ...config.ChildAccount) (*Manager, *platform.Fake) {
+	t.Helper()
+	fake := platform.NewFake()
+	for _, account := range accounts {
+		if err := fake.CreateUser(account.Username, account.FullName, account.Password); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := newManager(t.TempDir(), accounts, fake, nil)
+	t.Cleanup(m.Cleanup)
+	return m, fake
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func sessionActive(t *testing.T, fake *platform.Fake, id uint32) bool {
+	t.Helper()
+	sessions, err := fake.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sessions {
+		if s.ID == id {
+			return s.Active
+		}
+	}
+	return false
+}
+
+func TestGrantRefusedKeepsRunningSession(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+	id := fake.Login("alice")
+
+	_, pin, err := m.GrantAccess(1, "alice", 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The quota runs out while the session is running
+	now := time.Now()
+	m.mutex.Lock()
+	m.findAccount("alice").Quota = &config.DailyQuota{WeekdayMinutes: 1, WeekendMinutes: 1}
+	m.quota.AddUsage("alice", now.Add(-5*time.Minute), now)
+	m.mutex.Unlock()
+
+	if _, _, err := m.GrantAccess(1, "alice", time.Hour); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("GrantAccess() error = %v, want %v", err, ErrQuotaExhausted)
+	}
+	if m.GetActiveSessions()["alice"] == nil {
+		t.Fatal("refused grant stopped the running session")
+	}
+	if got := fake.Password("alice"); got != pin {
+		t.Errorf("password = %q, want the running session's PIN %q", got, pin)
+	}
+
+	// The running session still expires and locks the child out
+	waitFor(t, "the session to expire", func() bool { return len(m.GetActiveSessions()) == 0 })
+	if sessionActive(t, fake, id) {
+		t.Error("desktop session still active after expiry")
+	}
+	if got := fake.Password("alice"); got != "secret" {
+		t.Errorf("password after expiry = %q, want the configured one", got)
+	}
+}
+
+func TestGrantPasswordFailureKeepsRunningSession(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+
+	_, pin, err := m.GrantAccess(1, "alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.FailCalls["SetPassword"] = errors.New("access denied")
+	if _, _, err := m.GrantAccess(1, "alice", 2*time.Hour); err == nil {
+		t.Fatal("GrantAccess() succeeded although the password could not be set")
+	}
+	delete(fake.FailCalls, "SetPassword")
+
+	session := m.GetActiveSessions()["alice"]
+	if session == nil {
+		t.Fatal("failed grant stopped the running session")
+	}
+	if session.Duration != time.Hour || session.pin != pin {
+		t.Errorf("running session changed to %v with PIN %q, want %v with %q", session.Duration, session.pin, time.Hour, pin)
+	}
+	if got := fake.Password("alice"); got != pin {
+		t.Errorf("password = %q, want the running session's PIN %q", got, pin)
+	}
+}
+
+func TestGrantReplacesRunningSession(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+
+	if _, _, err := m.GrantAccess(1, "alice", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	granted, pin, err := m.GrantAccess(1, "alice", 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if granted != 30*time.Minute {
+		t.Errorf("granted %v, want %v", granted, 30*time.Minute)
+	}
+	if got := fake.Password("alice"); got != pin {
+		t.Errorf("password = %q, want the new PIN %q", got, pin)
+	}
+	if session := m.GetActiveSessions()["alice"]; session == nil || session.Duration != 30*time.Minute {
+		t.Errorf("active session = %+v, want the new grant", session)
+	}
+}
+
+// rewindSession moves the running session of username d into the past, as if it
+// had been granted that much earlier.
+func rewindSession(m *Manager, username string, d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session := m.activeSessions[username]
+	session.StartTime = session.StartTime.Add(-d)
+	session.accountedUntil = session.accountedUntil.Add(-d)
+}
+
+// charged returns the quota usage of username recorded today and yesterday, so
+// that tests running around midnight see all of it.
+func charged(m *Manager, username string) time.Duration {
+	now := time.Now()
+	return m.quota.Used(username, now) + m.quota.Used(username, now.AddDate(0, 0, -1))
+}
+
+func assertDuration(t *testing.T, what string, got, want time.Duration) {
+	t.Helper()
+	if got < want-2*time.Second || got > want+2*time.Second {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestAddIdleTime(t *testing.T) {
+	m, _ := newTestManager(t, config.ChildAccount{
+		Username: "alice",
+		Password: "secret",
+		Quota:    &config.DailyQuota{WeekdayMinutes: 180, WeekendMinutes: 180},
+	})
+	if _, _, err := m.GrantAccess(1, "alice", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	rewindSession(m, "alice", 30*time.Minute)
+
+	now := time.Now()
+	m.AddIdleTime("alice", now.Add(-20*time.Minute), now.Add(-10*time.Minute))
+	// The tracker may report a part of the same idle period again
+	m.AddIdleTime("alice", now.Add(-15*time.Minute), now.Add(-5*time.Minute))
+	m.AddIdleTime("alice", now.Add(-12*time.Minute), now.Add(-8*time.Minute))
+
+	// Only the active 10 minutes before the first idle interval are charged so far
+	assertDuration(t, "charged", charged(m, "alice"), 10*time.Minute)
+	// The session ends later by the 15 minutes of idle time
+	session := m.GetActiveSessions()["alice"]
+	if session == nil {
+		t.Fatal("session ended")
+	}
+	assertDuration(t, "session duration", session.Duration, 75*time.Minute)
+	assertDuration(t, "remaining", session.Remaining(time.Now()), 45*time.Minute)
+
+	// Stopping the session charges the active time since the last idle interval
+	if err := m.LockSession(1, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	assertDuration(t, "charged after lock", charged(m, "alice"), 15*time.Minute)
+}
+
+func TestAddIdleTimeIgnoredWhilePaused(t *testing.T) {
+	m, _ := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+	if _, _, err := m.GrantAccess(1, "alice", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	rewindSession(m, "alice", 30*time.Minute)
+	if _, err := m.PauseSession(1, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	m.AddIdleTime("alice", now.Add(-20*time.Minute), now)
+
+	session := m.GetActiveSessions()["alice"]
+	if session == nil || session.Duration != time.Hour {
+		t.Errorf("paused session = %+v, want it unchanged", session)
+	}
+	assertDuration(t, "charged", charged(m, "alice"), 30*time.Minute)
+}
+
+func TestGrantAccess(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+	m.SetPINPolicy(8, "ABC")
+
+	granted, pin, err := m.GrantAccess(1, "alice", 45*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if granted != 45*time.Minute {
+		t.Errorf("granted %v, want %v", granted, 45*time.Minute)
+	}
+	if len(pin) != 8 || strings.Trim(pin, "ABC") != "" {
+		t.Errorf("PIN %q does not follow the policy", pin)
+	}
+	if got := fake.Password("alice"); got != pin {
+		t.Errorf("password = %q, want the PIN %q", got, pin)
+	}
+	session := m.GetActiveSessions()["alice"]
+	if session == nil {
+		t.Fatal("no active session after grant")
+	}
+	assertDuration(t, "remaining", session.Remaining(time.Now()), 45*time.Minute)
+
+	if _, _, err := m.GrantAccess(1, "bob", time.Hour); err == nil {
+		t.Error("GrantAccess() succeeded for an unknown child")
+	}
+}
+
+func TestExtendSession(t *testing.T) {
+	m, _ := newTestManager(t, config.ChildAccount{
+		Username: "alice",
+		Password: "secret",
+		Quota:    &config.DailyQuota{WeekdayMinutes: 60, WeekendMinutes: 60},
+	})
+
+	if _, err := m.ExtendSession(1, "alice", 10*time.Minute); err == nil {
+		t.Error("ExtendSession() succeeded without a session")
+	}
+	if _, _, err := m.GrantAccess(1, "alice", 30*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	extended, err := m.ExtendSession(1, "alice", 20*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extended != 20*time.Minute {
+		t.Errorf("extended by %v, want %v", extended, 20*time.Minute)
+	}
+	assertDuration(t, "remaining", m.GetActiveSessions()["alice"].Remaining(time.Now()), 50*time.Minute)
+
+	// Only 10 minutes of the quota are left
+	extended, err = m.ExtendSession(1, "alice", 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDuration(t, "trimmed extension", extended, 10*time.Minute)
+	assertDuration(t, "remaining", m.GetActiveSessions()["alice"].Remaining(time.Now()), 60*time.Minute)
+
+	if _, err := m.ExtendSession(1, "alice", 10*time.Minute); !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("ExtendSession() beyond the quota error = %v, want %v", err, ErrQuotaExhausted)
+	}
+}
+
+func TestGetActiveSessionsReturnsCopies(t *testing.T) {
+	m, _ := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+	if _, _, err := m.GrantAccess(1, "alice", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bot reads sessions while they are paused, resumed and extended
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			if _, err := m.PauseSession(1, "alice"); err != nil {
+				t.Error(err)
+			}
+			m.AddIdleTime("alice", time.Now().Add(-time.Second), time.Now())
+			if _, _, err := m.ResumeSession(1, "alice"); err != nil {
+				t.Error(err)
+			}
+			if _, err := m.ExtendSession(1, "alice", time.Second); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for _, session := range m.GetActiveSessions() {
+			if session.Remaining(time.Now()) <= 0 || (session.Paused && session.PausedAt.IsZero()) {
+				t.Errorf("inconsistent session %+v", session)
+			}
+		}
+	}
+
+	// Changing a returned session leaves the manager's alone
+	session := m.GetActiveSessions()["alice"]
+	session.Duration = 0
+	session.Paused = true
+	if session := m.GetActiveSessions()["alice"]; session.Duration == 0 || session.Paused {
+		t.Errorf("session changed through a returned copy: %+v", session)
+	}
+}
+
+func TestLockSession(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+	alice := fake.Login("alice")
+	parent := fake.Login("parent")
+
+	if _, _, err := m.GrantAccess(1, "alice", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	rewindSession(m, "alice", 20*time.Minute)
+
+	if err := m.LockSession(1, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.GetActiveSessions()) != 0 {
+		t.Error("session still active after lock")
+	}
+	if sessionActive(t, fake, alice) {
+		t.Error("child's desktop session not disconnected")
+	}
+	if !sessionActive(t, fake, parent) {
+		t.Error("other user's desktop session disconnected")
+	}
+	if got := fake.Password("alice"); got != "secret" {
+		t.Errorf("password after lock = %q, want the configured one", got)
+	}
+	assertDuration(t, "charged", charged(m, "alice"), 20*time.Minute)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice", Password: "secret"})
+	id := fake.Login("alice")
+
+	if _, _, err := m.GrantAccess(1, "alice", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if !sessionActive(t, fake, id) {
+		t.Fatal("desktop session disconnected right after grant")
+	}
+
+	waitFor(t, "the session to expire", func() bool { return len(m.GetActiveSessions()) == 0 })
+	waitFor(t, "the desktop session to be locked", func() bool { return !sessionActive(t, fake, id) })
+	if got := fake.Password("alice"); got != "secret" {
+		t.Errorf("password after expiry = %q, want the configured one", got)
+	}
+}
+
+func TestSessionRestoredAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	accounts := []config.ChildAccount{{Username: "alice", Password: "secret"}, {Username: "bob", Password: "hunter2"}}
+	fake := platform.NewFake()
+	for _, account := range accounts {
+		fake.CreateUser(account.Username, "", account.Password)
+	}
+
+	m := newManager(dir, accounts, fake, nil)
+	_, pin, err := m.GrantAccess(1, "alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Cleanup()
+	fake.SetPassword("alice", "changed while the service was down")
+
+	restored := newManager(dir, accounts, fake, nil)
+	t.Cleanup(restored.Cleanup)
+	session := restored.GetActiveSessions()["alice"]
+	if session == nil {
+		t.Fatal("session not restored")
+	}
+	assertDuration(t, "remaining", session.Remaining(time.Now()), time.Hour)
+	if got := fake.Password("alice"); got != pin {
+		t.Errorf("password after restart = %q, want the PIN %q", got, pin)
+	}
+	if got := fake.Password("bob"); got != "hunter2" {
+		t.Errorf("password of a child without session = %q, want the configured one", got)
+	}
+}
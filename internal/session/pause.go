@@ -0,0 +1,98 @@
+package session
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
+)
+
+// PauseSession stops the clock of the running session of username: the child's desktop
+// is locked, the configured password is restored and the remaining time is kept until
+// ResumeSession is called. It returns the remaining time.
+func (m *Manager) PauseSession(actor int64, username string) (remaining time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	defer func() {
+		m.audit.Record(actor, username, audit.ActionPause, map[string]interface{}{"remaining_minutes": int(remaining / time.Minute)}, err)
+	}()
+
+	session, exists := m.activeSessions[username]
+	if !exists || !session.IsActive {
+		return 0, fmt.Errorf("no active session for %s", username)
+	}
+	if session.Paused {
+		return session.Remaining(time.Now()), fmt.Errorf("session for %s is already paused", username)
+	}
+
+	now := time.Now()
+	remaining = session.Remaining(now)
+	if remaining <= 0 {
+		return 0, fmt.Errorf("session for %s has already expired", username)
+	}
+
+	// Charge the time used so far; nothing is charged while paused
+	m.quota.AddUsage(username, session.accountedUntil, now)
+	session.accountedUntil = now
+	session.Paused = true
+	session.PausedAt = now
+
+	if t, ok := m.timers[username]; ok {
+		t.Stop()
+		delete(m.timers, username)
+	}
+	m.stopWarningsLocked(username)
+	m.persistLocked()
+
+	log.Printf("Paused session for %s with %v remaining", username, remaining.Round(time.Second))
+	return remaining, m.disconnectLocked(username)
+}
+
+// ResumeSession restarts a paused session of username with the time that was left when
+// it was paused, trimmed to the daily quota and allowed hours. The one-time PIN of the
+// session is set again and returned.
+func (m *Manager) ResumeSession(actor int64, username string) (remaining time.Duration, pin string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	defer func() {
+		m.audit.Record(actor, username, audit.ActionResume, map[string]interface{}{"remaining_minutes": int(remaining / time.Minute)}, err)
+	}()
+
+	session, exists := m.activeSessions[username]
+	if !exists || !session.IsActive || !session.Paused {
+		return 0, "", fmt.Errorf("no paused session for %s", username)
+	}
+
+	now := time.Now()
+	if end, limited, reason := m.sessionLimitLocked(username, now); limited && !end.After(now) {
+		return 0, "", reason
+	}
+
+	pin = session.pin
+	if pin == "" {
+		if pin, err = config.GenerateRandomString(m.pinLength, m.pinAlphabet); err != nil {
+			return 0, "", fmt.Errorf("failed to generate PIN: %v", err)
+		}
+	}
+	if err := m.system.SetPassword(username, pin); err != nil {
+		return 0, "", fmt.Errorf("failed to set temporary password: %v", err)
+	}
+
+	// Move the end of the session by the length of the pause
+	session.Duration += now.Sub(session.PausedAt)
+	session.accountedUntil = now
+	session.Paused = false
+	session.PausedAt = time.Time{}
+	session.pin = pin
+
+	m.rescheduleLocked(username, now)
+	m.applyLimitsLocked(username, now)
+
+	remaining = session.Remaining(now)
+	log.Printf("Resumed session for %s with %v remaining", username, remaining.Round(time.Second))
+	return remaining, pin, nil
+}
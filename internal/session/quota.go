@@ -0,0 +1,121 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+)
+
+// ErrQuotaExhausted is returned when a child has no daily allowance left.
+var ErrQuotaExhausted = errors.New("daily quota exhausted")
+
+const quotaRetentionDays = 7
+
+// QuotaTracker records how much time each child has consumed per calendar day. The
+// time is kept exactly and stored in whole seconds.
+type QuotaTracker struct {
+	dataPath string
+	usage    map[string]map[string]time.Duration // date -> username -> time used
+	mutex    sync.Mutex
+}
+
+func NewQuotaTracker(dataPath string) *QuotaTracker {
+	q := &QuotaTracker{
+		dataPath: dataPath,
+		usage:    make(map[string]map[string]time.Duration),
+	}
+	if err := q.load(); err != nil {
+		log.Printf("Failed to load quota usage: %v", err)
+	}
+	return q
+}
+
+// AddUsage records the interval [start, end) for username, splitting it at midnight
+// so that every calendar day is charged only for its own part of the interval.
+func (q *QuotaTracker) AddUsage(username string, start, end time.Time) {
+	if !end.After(start) {
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for start.Before(end) {
+		y, m, d := start.Date()
+		nextMidnight := time.Date(y, m, d+1, 0, 0, 0, 0, start.Location())
+		chunkEnd := end
+		if nextMidnight.Before(end) {
+			chunkEnd = nextMidnight
+		}
+
+		date := start.Format("2006-01-02")
+		if q.usage[date] == nil {
+			q.usage[date] = make(map[string]time.Duration)
+		}
+		q.usage[date][username] += chunkEnd.Sub(start)
+
+		start = chunkEnd
+	}
+
+	if err := q.save(); err != nil {
+		log.Printf("Failed to save quota usage: %v", err)
+	}
+}
+
+// Used returns the recorded usage of username on the calendar day containing day.
+func (q *QuotaTracker) Used(username string, day time.Time) time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.usage[day.Format("2006-01-02")][username]
+}
+
+func (q *QuotaTracker) load() error {
+	data, err := os.ReadFile(q.dataPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var seconds map[string]map[string]int64 // date -> username -> seconds
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return err
+	}
+	for date, users := range seconds {
+		q.usage[date] = make(map[string]time.Duration)
+		for username, s := range users {
+			q.usage[date][username] = time.Duration(s) * time.Second
+		}
+	}
+	return nil
+}
+
+func (q *QuotaTracker) save() error {
+	cutoffDate := time.Now().AddDate(0, 0, -quotaRetentionDays).Format("2006-01-02")
+	for date := range q.usage {
+		if date < cutoffDate {
+			delete(q.usage, date)
+		}
+	}
+
+	seconds := make(map[string]map[string]int64)
+	for date, users := range q.usage {
+		seconds[date] = make(map[string]int64)
+		for username, used := range users {
+			seconds[date][username] = int64(used.Round(time.Second) / time.Second)
+		}
+	}
+	data, err := json.MarshalIndent(seconds, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Replaced atomically: a torn file would give the child a full allowance again
+	return atomicfile.WriteFile(q.dataPath, data, 0600)
+}
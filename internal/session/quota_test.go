@@ -0,0 +1,52 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaTrackerCountsFractionalSeconds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota_usage.json")
+	q := NewQuotaTracker(path)
+
+	// Many short chunks, as idle time and session ends add them, sum up exactly
+	now := time.Now()
+	y, m, d := now.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	for i := 0; i < 100; i++ {
+		q.AddUsage("alice", start, start.Add(1500*time.Millisecond))
+		start = start.Add(2 * time.Second)
+	}
+	if used := q.Used("alice", now); used != 150*time.Second {
+		t.Errorf("Used() = %v, want 2m30s", used)
+	}
+
+	// Stored in whole seconds, replaced atomically
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	q.AddUsage("alice", start, start.Add(600*time.Millisecond))
+	if used := NewQuotaTracker(path).Used("alice", now); used != 151*time.Second {
+		t.Errorf("Used() after reload = %v, want 2m31s", used)
+	}
+}
+
+func TestQuotaTrackerSplitsAtMidnight(t *testing.T) {
+	q := NewQuotaTracker(filepath.Join(t.TempDir(), "quota_usage.json"))
+	now := time.Now()
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	q.AddUsage("alice", midnight.Add(-90*time.Second), midnight.Add(30*time.Second))
+	if used := q.Used("alice", midnight.Add(-time.Hour)); used != 90*time.Second {
+		t.Errorf("yesterday's usage = %v, want 1m30s", used)
+	}
+	if used := q.Used("alice", now); used != 30*time.Second {
+		t.Errorf("today's usage = %v, want 30s", used)
+	}
+	if used := q.Used("bob", now); used != 0 {
+		t.Errorf("bob's usage = %v, want none", used)
+	}
+}
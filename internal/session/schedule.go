@@ -0,0 +1,115 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+// ErrOutsideSchedule is returned when a child is outside their allowed hours.
+var ErrOutsideSchedule = errors.New("outside allowed hours")
+
+// scheduleLookaheadDays bounds how far adjacent windows (e.g. 20:00-24:00 followed
+// by 00:00-01:00 next day) are merged when computing where an allowed period ends.
+const scheduleLookaheadDays = 7
+
+// SetHolidays replaces the list of holiday dates (YYYY-MM-DD).
+func (m *Manager) SetHolidays(dates []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.holidays = make(map[string]bool, len(dates))
+	for _, d := range dates {
+		m.holidays[d] = true
+	}
+}
+
+// SetSchedule replaces the allowed hours of username. A running session is shortened
+// if it would otherwise continue past the end of the allowed period.
+func (m *Manager) SetSchedule(username string, schedule *config.Schedule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account := m.findAccount(username)
+	if account == nil {
+		return fmt.Errorf("child account %s not found", username)
+	}
+	account.Schedule = schedule
+
+	m.applyLimitsLocked(username, time.Now())
+	return nil
+}
+
+// AllowedUntil reports whether username is inside their allowed hours right now and,
+// if so, when the allowed period ends. limited is false when no schedule is configured.
+func (m *Manager) AllowedUntil(username string) (until time.Time, allowed, limited bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := time.Now()
+	until, limited = m.allowedUntilLocked(username, now)
+	return until, !limited || until.After(now), limited
+}
+
+// GetOutOfScheduleSessions returns active sessions whose child is outside their allowed hours.
+func (m *Manager) GetOutOfScheduleSessions() []*ActiveSession {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var result []*ActiveSession
+	now := time.Now()
+
+	for username, session := range m.activeSessions {
+		if !session.IsActive || session.Paused {
+			continue
+		}
+		if until, limited := m.allowedUntilLocked(username, now); limited && !until.After(now) {
+			result = append(result, session)
+		}
+	}
+
+	return result
+}
+
+// allowedUntilLocked returns the end of the allowed period containing now, or now itself
+// when username is outside their allowed hours. limited is false when no schedule is set.
+func (m *Manager) allowedUntilLocked(username string, now time.Time) (time.Time, bool) {
+	account := m.findAccount(username)
+	if account == nil || account.Schedule == nil {
+		return time.Time{}, false
+	}
+
+	type interval struct{ start, end time.Time }
+	var intervals []interval
+
+	y, mo, d := now.Date()
+	for i := 0; i <= scheduleLookaheadDays; i++ {
+		day := time.Date(y, mo, d+i, 0, 0, 0, 0, now.Location())
+		holiday := m.holidays[day.Format("2006-01-02")]
+		for _, w := range account.Schedule.WindowsFor(day, holiday) {
+			start, end, err := w.Minutes()
+			if err != nil {
+				continue
+			}
+			intervals = append(intervals, interval{
+				start: day.Add(time.Duration(start) * time.Minute),
+				end:   day.Add(time.Duration(end) * time.Minute),
+			})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+
+	until := now
+	for _, iv := range intervals {
+		if iv.start.After(until) {
+			break
+		}
+		if iv.end.After(until) {
+			until = iv.end
+		}
+	}
+	return until, true
+}
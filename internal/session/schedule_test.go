@@ -0,0 +1,143 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+)
+
+func TestSessionLimit(t *testing.T) {
+	// Monday, 5 January 2026
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.January, day, hour, minute, 0, 0, time.Local)
+	}
+	// Recorded usage is only kept for recent days
+	y, mo, d := time.Now().Date()
+	today := func(hour, minute int) time.Time {
+		return time.Date(y, mo, d, hour, minute, 0, 0, time.Local)
+	}
+	weekly := []config.ScheduleRule{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, TimeWindow: config.TimeWindow{Start: "16:00", End: "20:00"}},
+		{Days: []string{"sat", "sun"}, TimeWindow: config.TimeWindow{Start: "10:00", End: "21:00"}},
+	}
+	withHoliday := append([]config.ScheduleRule{
+		{Days: []string{"holiday"}, TimeWindow: config.TimeWindow{Start: "12:00", End: "14:00"}},
+	}, weekly...)
+
+	tests := []struct {
+		name     string
+		schedule *config.Schedule
+		quota    *config.DailyQuota
+		used     time.Duration // quota used earlier today
+		holidays []string
+		now      time.Time
+		end      time.Time
+		limited  bool
+		reason   error
+	}{
+		{
+			name: "unlimited",
+			now:  at(5, 17, 0),
+		},
+		{
+			name:     "inside weekday window",
+			schedule: &config.Schedule{Weekly: weekly},
+			now:      at(5, 17, 0), end: at(5, 20, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "after weekday window",
+			schedule: &config.Schedule{Weekly: weekly},
+			now:      at(5, 21, 0), end: at(5, 21, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "holiday uses sunday rules",
+			schedule: &config.Schedule{Weekly: weekly},
+			holidays: []string{"2026-01-05"},
+			now:      at(5, 11, 0), end: at(5, 21, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "holiday rules",
+			schedule: &config.Schedule{Weekly: withHoliday},
+			holidays: []string{"2026-01-05"},
+			now:      at(5, 13, 0), end: at(5, 14, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "holiday rules outside window",
+			schedule: &config.Schedule{Weekly: withHoliday},
+			holidays: []string{"2026-01-05"},
+			now:      at(5, 17, 0), end: at(5, 17, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "exception replaces weekly rules",
+			schedule: &config.Schedule{Weekly: weekly, Exceptions: map[string][]config.TimeWindow{"2026-01-05": {{Start: "10:00", End: "23:00"}}}},
+			now:      at(5, 11, 0), end: at(5, 23, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "exception closes the day",
+			schedule: &config.Schedule{Weekly: weekly, Exceptions: map[string][]config.TimeWindow{"2026-01-05": {}}},
+			now:      at(5, 17, 0), end: at(5, 17, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:     "exception wins over holiday",
+			schedule: &config.Schedule{Weekly: withHoliday, Exceptions: map[string][]config.TimeWindow{"2026-01-05": {{Start: "09:00", End: "10:00"}}}},
+			holidays: []string{"2026-01-05"},
+			now:      at(5, 9, 30), end: at(5, 10, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name: "window continues past midnight",
+			schedule: &config.Schedule{
+				Weekly:     weekly,
+				Exceptions: map[string][]config.TimeWindow{"2026-01-05": {{Start: "20:00", End: "24:00"}}, "2026-01-06": {{Start: "00:00", End: "01:00"}}},
+			},
+			now: at(5, 22, 0), end: at(6, 1, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+		{
+			name:  "quota",
+			quota: &config.DailyQuota{WeekdayMinutes: 60, WeekendMinutes: 60},
+			used:  20 * time.Minute,
+			now:   today(17, 0), end: today(17, 40), limited: true, reason: ErrQuotaExhausted,
+		},
+		{
+			name:  "weekend quota",
+			quota: &config.DailyQuota{WeekdayMinutes: 60, WeekendMinutes: 120},
+			now:   at(10, 12, 0), end: at(10, 14, 0), limited: true, reason: ErrQuotaExhausted,
+		},
+		{
+			name:  "quota used up",
+			quota: &config.DailyQuota{WeekdayMinutes: 60, WeekendMinutes: 60},
+			used:  90 * time.Minute,
+			now:   today(17, 0), end: today(16, 30), limited: true, reason: ErrQuotaExhausted,
+		},
+		{
+			name:     "quota binds before schedule",
+			schedule: &config.Schedule{Weekly: weekly},
+			quota:    &config.DailyQuota{WeekdayMinutes: 60, WeekendMinutes: 120},
+			now:      at(5, 17, 0), end: at(5, 18, 0), limited: true, reason: ErrQuotaExhausted,
+		},
+		{
+			name:     "schedule binds before quota",
+			schedule: &config.Schedule{Weekly: weekly},
+			quota:    &config.DailyQuota{WeekdayMinutes: 60, WeekendMinutes: 120},
+			now:      at(5, 19, 30), end: at(5, 20, 0), limited: true, reason: ErrOutsideSchedule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestManager(t, config.ChildAccount{Username: "alice", Schedule: tt.schedule, Quota: tt.quota})
+			m.SetHolidays(tt.holidays)
+			if tt.used > 0 {
+				start := time.Date(tt.now.Year(), tt.now.Month(), tt.now.Day(), 8, 0, 0, 0, time.Local)
+				m.quota.AddUsage("alice", start, start.Add(tt.used))
+			}
+
+			m.mutex.Lock()
+			end, limited, reason := m.sessionLimitLocked("alice", tt.now)
+			m.mutex.Unlock()
+
+			if limited != tt.limited || !end.Equal(tt.end) || reason != tt.reason {
+				t.Errorf("sessionLimitLocked() = %v, %v, %v, want %v, %v, %v", end, limited, reason, tt.end, tt.limited, tt.reason)
+			}
+		})
+	}
+}
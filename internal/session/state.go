@@ -0,0 +1,138 @@
+package session
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+	"github.com/Hepri/parental/internal/audit"
+)
+
+// persistedSession is the on-disk form of an ActiveSession.
+type persistedSession struct {
+	Username       string        `json:"username"`
+	StartTime      time.Time     `json:"start_time"`
+	Duration       time.Duration `json:"duration"`
+	AccountedUntil time.Time     `json:"accounted_until"`
+	PIN            string        `json:"pin,omitempty"`
+	Paused         bool          `json:"paused,omitempty"`
+	PausedAt       time.Time     `json:"paused_at,omitempty"`
+}
+
+// persistLocked writes the active sessions to the state file so they survive a restart.
+// The file is replaced atomically and synced to avoid leaving a truncated state behind
+// on a crash or power loss.
+func (m *Manager) persistLocked() {
+	var sessions []persistedSession
+	for _, session := range m.activeSessions {
+		if !session.IsActive {
+			continue
+		}
+		sessions = append(sessions, persistedSession{
+			Username:       session.Username,
+			StartTime:      session.StartTime,
+			Duration:       session.Duration,
+			AccountedUntil: session.accountedUntil,
+			PIN:            session.pin,
+			Paused:         session.Paused,
+			PausedAt:       session.PausedAt,
+		})
+	}
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		log.Printf("Failed to marshal session state: %v", err)
+		return
+	}
+
+	if err := atomicfile.WriteFile(m.statePath, data, 0600); err != nil {
+		log.Printf("Failed to write session state: %v", err)
+	}
+}
+
+// restoreState reloads sessions persisted before a restart. Expired sessions are locked
+// immediately, running ones get their one-time PIN and expiry timer back, paused ones
+// stay paused, and every other child has the configured password restored.
+func (m *Manager) restoreState() {
+	var persisted []persistedSession
+	data, err := os.ReadFile(m.statePath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to read session state: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &persisted); err != nil {
+			log.Printf("Failed to parse session state, ignoring it: %v", err)
+			persisted = nil
+		}
+	}
+
+	now := time.Now()
+	var expired []string
+
+	m.mutex.Lock()
+	for _, ps := range persisted {
+		if m.findAccount(ps.Username) == nil {
+			log.Printf("Dropping persisted session for unknown user %s", ps.Username)
+			continue
+		}
+		accountedUntil := ps.AccountedUntil
+		if accountedUntil.Before(ps.StartTime) {
+			accountedUntil = ps.StartTime
+		}
+		m.activeSessions[ps.Username] = &ActiveSession{
+			Username:       ps.Username,
+			StartTime:      ps.StartTime,
+			Duration:       ps.Duration,
+			IsActive:       true,
+			accountedUntil: accountedUntil,
+			pin:            ps.PIN,
+			Paused:         ps.Paused,
+			PausedAt:       ps.PausedAt,
+		}
+		// Paused sessions keep the configured password and wait for a resume
+		if ps.Paused {
+			m.restorePasswordLocked(ps.Username)
+			log.Printf("Restored paused session for %s, %v remaining", ps.Username, m.activeSessions[ps.Username].Remaining(now).Round(time.Second))
+			continue
+		}
+		if !now.Before(ps.StartTime.Add(ps.Duration)) {
+			expired = append(expired, ps.Username)
+			continue
+		}
+
+		// Sessions saved before PINs existed keep the password they were granted with until they expire
+		if ps.PIN != "" {
+			if err := m.system.SetPassword(ps.Username, ps.PIN); err != nil {
+				log.Printf("Failed to restore one-time PIN for %s: %v", ps.Username, err)
+			}
+		}
+		username := ps.Username
+		m.timers[username] = time.AfterFunc(ps.StartTime.Add(ps.Duration).Sub(now), func() {
+			_ = m.EnforceLock(username, audit.ActionExpired)
+		})
+		m.scheduleWarningsLocked(username, ps.StartTime.Add(ps.Duration), now)
+		m.applyLimitsLocked(username, now)
+		log.Printf("Restored session for %s, %v remaining", username, ps.StartTime.Add(ps.Duration).Sub(now).Round(time.Second))
+	}
+
+	// Reconcile: any child without a valid session must have the configured password
+	for _, acc := range m.childAccounts {
+		if _, ok := m.activeSessions[acc.Username]; !ok {
+			m.restorePasswordLocked(acc.Username)
+		}
+	}
+	m.mutex.Unlock()
+
+	for _, username := range expired {
+		log.Printf("Persisted session for %s expired while the service was down, locking", username)
+		if err := m.EnforceLock(username, audit.ActionExpired); err != nil {
+			log.Printf("Failed to lock expired session for %s: %v", username, err)
+		}
+	}
+
+	m.mutex.Lock()
+	m.persistLocked()
+	m.mutex.Unlock()
+}
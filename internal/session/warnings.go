@@ -0,0 +1,98 @@
+package session
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// defaultWarnings are the times before expiry at which the child is warned.
+var defaultWarnings = []time.Duration{10 * time.Minute, 5 * time.Minute, time.Minute}
+
+// warningTitle is the title of messages shown on the child's desktop.
+const warningTitle = "Родительский контроль"
+
+// SetWarnings sets how long before expiry the child's desktop shows a warning.
+// Running sessions are rescheduled; an empty list disables warnings.
+func (m *Manager) SetWarnings(before []time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.warningOffsets = append([]time.Duration(nil), before...)
+	sort.Slice(m.warningOffsets, func(i, j int) bool { return m.warningOffsets[i] > m.warningOffsets[j] })
+
+	now := time.Now()
+	for username, session := range m.activeSessions {
+		if session.IsActive && !session.Paused {
+			m.scheduleWarningsLocked(username, session.StartTime.Add(session.Duration), now)
+		}
+	}
+}
+
+// scheduleWarningsLocked replaces the warning timers of username with ones firing
+// the configured offsets before expiry. Offsets that have already passed are skipped.
+func (m *Manager) scheduleWarningsLocked(username string, expiry, now time.Time) {
+	m.stopWarningsLocked(username)
+
+	for _, before := range m.warningOffsets {
+		at := expiry.Add(-before)
+		if !at.After(now) {
+			continue
+		}
+		timer := time.AfterFunc(at.Sub(now), func() { m.warn(username, expiry) })
+		m.warnings[username] = append(m.warnings[username], timer)
+	}
+}
+
+func (m *Manager) stopWarningsLocked(username string) {
+	for _, t := range m.warnings[username] {
+		t.Stop()
+	}
+	delete(m.warnings, username)
+}
+
+// warn shows the pre-expiry warning in every session of username, provided the
+// session still ends at expiry.
+func (m *Manager) warn(username string, expiry time.Time) {
+	m.mutex.RLock()
+	session, exists := m.activeSessions[username]
+	current := exists && session.IsActive && !session.Paused && session.StartTime.Add(session.Duration).Equal(expiry)
+	m.mutex.RUnlock()
+	if !current {
+		return
+	}
+
+	minutes := int(time.Until(expiry).Round(time.Minute) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	text := fmt.Sprintf("Осталось %d мин. Сохраните свою работу: по окончании времени сеанс будет заблокирован.", minutes)
+
+	if m.NotifyChild(username, text) {
+		log.Printf("Warned %s: %d minutes left", username, minutes)
+	}
+}
+
+// NotifyChild shows text in every session of username and reports whether
+// it was shown in at least one of them.
+func (m *Manager) NotifyChild(username, text string) bool {
+	sessions, err := m.system.ListSessions()
+	if err != nil {
+		log.Printf("Failed to list sessions to notify %s: %v", username, err)
+		return false
+	}
+
+	shown := false
+	for _, s := range sessions {
+		if s.Username != username {
+			continue
+		}
+		if err := m.system.ShowMessage(s.ID, warningTitle, text); err != nil {
+			log.Printf("Failed to show message to %s: %v", username, err)
+			continue
+		}
+		shown = true
+	}
+	return shown
+}
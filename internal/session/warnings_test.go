@@ -0,0 +1,115 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// assertWarnedAt checks that msg is a warning shown in session id no earlier than at.
+// Timers never fire early; a little slack covers the clock reads of the test itself.
+func assertWarnedAt(t *testing.T, msg platform.FakeMessage, id uint32, at time.Time) {
+	t.Helper()
+	if msg.SessionID != id || msg.Title != warningTitle || !strings.Contains(msg.Text, "Осталось 1 мин.") {
+		t.Errorf("message %+v, want a warning in session %d", msg, id)
+	}
+	if msg.At.Before(at.Add(-10 * time.Millisecond)) {
+		t.Errorf("warning shown %v early", at.Sub(msg.At))
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice"})
+	id := fake.Login("alice")
+	m.SetWarnings([]time.Duration{100 * time.Millisecond, 300 * time.Millisecond, time.Hour})
+
+	start := time.Now()
+	if _, _, err := m.GrantAccess(1, "alice", 500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the session to expire", func() bool { return len(m.GetActiveSessions()) == 0 })
+
+	// The hour warning had already passed when the session started
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("shown %d messages, want 2: %+v", len(messages), messages)
+	}
+	assertWarnedAt(t, messages[0], id, start.Add(200*time.Millisecond))
+	assertWarnedAt(t, messages[1], id, start.Add(400*time.Millisecond))
+}
+
+func TestWarningsRescheduledOnExtend(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice"})
+	id := fake.Login("alice")
+	m.SetWarnings([]time.Duration{200 * time.Millisecond})
+
+	start := time.Now()
+	if _, _, err := m.GrantAccess(1, "alice", 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ExtendSession(1, "alice", 400*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the session to expire", func() bool { return len(m.GetActiveSessions()) == 0 })
+
+	// Only the warning before the new end is shown
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("shown %d messages, want 1: %+v", len(messages), messages)
+	}
+	assertWarnedAt(t, messages[0], id, start.Add(500*time.Millisecond))
+}
+
+func TestWarningsRescheduledOnPauseAndResume(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice"})
+	id := fake.Login("alice")
+	m.SetWarnings([]time.Duration{200 * time.Millisecond})
+
+	if _, _, err := m.GrantAccess(1, "alice", 400*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.PauseSession(1, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// No warning while paused, although the original one is long due
+	time.Sleep(400 * time.Millisecond)
+	if messages := fake.Messages(); len(messages) != 0 {
+		t.Fatalf("shown %+v while paused", messages)
+	}
+
+	resumed := time.Now()
+	remaining, _, err := m.ResumeSession(1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the session to expire", func() bool { return len(m.GetActiveSessions()) == 0 })
+
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("shown %d messages, want 1: %+v", len(messages), messages)
+	}
+	assertWarnedAt(t, messages[0], id, resumed.Add(remaining-200*time.Millisecond))
+}
+
+func TestSetWarningsReschedulesRunningSession(t *testing.T) {
+	m, fake := newTestManager(t, config.ChildAccount{Username: "alice"})
+	id := fake.Login("alice")
+	m.SetWarnings(nil)
+
+	start := time.Now()
+	if _, _, err := m.GrantAccess(1, "alice", 400*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	m.SetWarnings([]time.Duration{200 * time.Millisecond})
+	waitFor(t, "the session to expire", func() bool { return len(m.GetActiveSessions()) == 0 })
+
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("shown %d messages, want 1: %+v", len(messages), messages)
+	}
+	assertWarnedAt(t, messages[0], id, start.Add(200*time.Millisecond))
+}
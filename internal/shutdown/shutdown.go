@@ -1,71 +1,45 @@
-//go:build windows
-
 package shutdown
 
 import (
 	"fmt"
 	"log"
 	"time"
-	"unsafe"
-
-	"golang.org/x/sys/windows"
-)
-
-var (
-	advapi32                     = windows.NewLazySystemDLL("advapi32.dll")
-	procInitiateSystemShutdownEx = advapi32.NewProc("InitiateSystemShutdownExW")
-)
 
-const (
-	SHUTDOWN_FORCE_OTHERS        = 0x00000001
-	SHUTDOWN_FORCE_SELF          = 0x00000002
-	SHUTDOWN_RESTART             = 0x00000004
-	SHUTDOWN_POWEROFF            = 0x00000008
-	SHUTDOWN_NOREBOOT            = 0x00000010
-	SHUTDOWN_GRACE_OVERRIDE      = 0x00000020
-	SHUTDOWN_INSTALL_UPDATES     = 0x00000040
-	SHUTDOWN_RESTARTAPPS         = 0x00000080
-	SHUTDOWN_SKIP_SVC_POPUP      = 0x00000100
-	SHUTDOWN_HYBRID              = 0x00000200
-	SHUTDOWN_RESTART_BOOTOPTIONS = 0x00000400
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/platform"
 )
 
 type ShutdownManager struct {
+	power         platform.Power
+	audit         *audit.Log
 	scheduledTime *time.Time
 	cancelled     bool
 }
 
-func NewShutdownManager() *ShutdownManager {
-	return &ShutdownManager{}
+// NewShutdownManager creates a shutdown manager that records every request in auditLog, which may be nil.
+func NewShutdownManager(power platform.Power, auditLog *audit.Log) *ShutdownManager {
+	return &ShutdownManager{power: power, audit: auditLog}
 }
 
-func (sm *ShutdownManager) ScheduleShutdown(delayMinutes int) error {
+// ScheduleShutdown shuts the computer down in delayMinutes on behalf of the Telegram user actor.
+func (sm *ShutdownManager) ScheduleShutdown(actor int64, delayMinutes int) (err error) {
+	defer func() {
+		sm.audit.Record(actor, "", audit.ActionShutdown, map[string]interface{}{"delay_minutes": delayMinutes}, err)
+	}()
+
 	if delayMinutes < 0 {
 		return fmt.Errorf("delay cannot be negative")
 	}
 
-	delaySeconds := int32(delayMinutes * 60)
+	delay := time.Duration(delayMinutes) * time.Minute
 	message := fmt.Sprintf("Computer will shutdown in %d minutes. This shutdown was initiated by Parental Control Bot.", delayMinutes)
 
-	messagePtr, err := windows.UTF16PtrFromString(message)
-	if err != nil {
-		return fmt.Errorf("failed to convert message to UTF16: %v", err)
-	}
-
 	// Schedule shutdown
-	ret, _, _ := procInitiateSystemShutdownEx.Call(
-		0, // Local computer
-		uintptr(unsafe.Pointer(messagePtr)),
-		uintptr(delaySeconds),
-		SHUTDOWN_FORCE_OTHERS|SHUTDOWN_GRACE_OVERRIDE, // Force close applications
-		0, // Don't restart
-	)
-
-	if ret == 0 {
-		return fmt.Errorf("InitiateSystemShutdownEx failed")
+	if err := sm.power.ScheduleShutdown(delay, message); err != nil {
+		return err
 	}
 
-	shutdownTime := time.Now().Add(time.Duration(delayMinutes) * time.Minute)
+	shutdownTime := time.Now().Add(delay)
 	sm.scheduledTime = &shutdownTime
 	sm.cancelled = false
 
@@ -73,23 +47,18 @@ func (sm *ShutdownManager) ScheduleShutdown(delayMinutes int) error {
 	return nil
 }
 
-func (sm *ShutdownManager) ShutdownNow() error {
-	return sm.ScheduleShutdown(0)
+func (sm *ShutdownManager) ShutdownNow(actor int64) error {
+	return sm.ScheduleShutdown(actor, 0)
 }
 
-func (sm *ShutdownManager) CancelShutdown() error {
+func (sm *ShutdownManager) CancelShutdown(actor int64) error {
 	// Cancel scheduled shutdown
-	ret, _, _ := procInitiateSystemShutdownEx.Call(
-		0, // Local computer
-		0, // No message
-		0, // No delay
-		SHUTDOWN_FORCE_OTHERS|SHUTDOWN_GRACE_OVERRIDE, // Force close applications
-		0, // Don't restart
-	)
-
-	if ret == 0 {
-		return fmt.Errorf("Failed to cancel shutdown")
+	if err := sm.power.CancelShutdown(); err != nil {
+		err = fmt.Errorf("Failed to cancel shutdown: %v", err)
+		sm.audit.Record(actor, "", audit.ActionCancelShutdown, nil, err)
+		return err
 	}
+	sm.audit.Record(actor, "", audit.ActionCancelShutdown, nil, nil)
 
 	sm.scheduledTime = nil
 	sm.cancelled = true
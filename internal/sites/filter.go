@@ -0,0 +1,261 @@
+// Package sites blocks websites for children by maintaining a section of the
+// system hosts file that resolves blocked domains to an unroutable address.
+package sites
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// Markers around the part of the hosts file owned by the service.
+const (
+	beginMarker = "# BEGIN parental-control: managed automatically, changes are overwritten"
+	endMarker   = "# END parental-control"
+)
+
+// checkInterval is how often the hosts file is brought in line with the rules,
+// so that blocks start and end on time and follow children logging on and off.
+const checkInterval = time.Minute
+
+// Platform is what the filter needs from the operating system.
+type Platform interface {
+	platform.Network
+	platform.Sessions
+}
+
+// Filter blocks the domains of every child who is logged on, while their rules apply.
+// The hosts file is shared by all users, so while a child is logged on their sites
+// are blocked for everyone using the computer.
+type Filter struct {
+	system   Platform
+	rules    map[string][]config.SiteRule // child username -> rules
+	holidays map[string]bool              // date -> holiday
+	applied  []string                     // domains currently in the hosts file, sorted
+	mutex    sync.Mutex
+}
+
+func NewFilter(system Platform) *Filter {
+	return &Filter{
+		system:   system,
+		rules:    make(map[string][]config.SiteRule),
+		holidays: make(map[string]bool),
+	}
+}
+
+// SetRules replaces the blocked sites of username. The hosts file is updated by
+// the next Refresh.
+func (f *Filter) SetRules(username string, rules []config.SiteRule) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(rules) == 0 {
+		delete(f.rules, username)
+		return
+	}
+	f.rules[username] = config.CloneSiteRules(rules)
+}
+
+// SetHolidays replaces the list of holiday dates (YYYY-MM-DD) used by the rule schedules.
+func (f *Filter) SetHolidays(dates []string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.holidays = make(map[string]bool, len(dates))
+	for _, d := range dates {
+		f.holidays[d] = true
+	}
+}
+
+// Blocked returns the domains blocked for username at t, sorted.
+func (f *Filter) Blocked(username string, t time.Time) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.blockedLocked(username, t)
+}
+
+func (f *Filter) blockedLocked(username string, t time.Time) []string {
+	holiday := f.holidays[t.Format("2006-01-02")]
+
+	set := make(map[string]bool)
+	for child, rules := range f.rules {
+		if !strings.EqualFold(child, username) {
+			continue
+		}
+		for _, rule := range rules {
+			if !rule.ActiveAt(t, holiday) {
+				continue
+			}
+			for _, d := range rule.Domains {
+				if domain, err := config.NormalizeDomain(d); err == nil {
+					set[domain] = true
+				}
+			}
+		}
+	}
+
+	domains := make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Start keeps the hosts file up to date until ctx is cancelled.
+func (f *Filter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	log.Println("Website filter started")
+	if err := f.Refresh(); err != nil {
+		log.Printf("Failed to update blocked sites: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f.Refresh(); err != nil {
+				log.Printf("Failed to update blocked sites: %v", err)
+			}
+		}
+	}
+}
+
+// Refresh writes the domains blocked right now for the children who are logged on
+// to the hosts file. The file is only touched when the list changes.
+func (f *Filter) Refresh() error {
+	sessions, err := f.system.ListSessions()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	set := make(map[string]bool)
+	for _, s := range sessions {
+		for _, domain := range f.blockedLocked(s.Username, now) {
+			set[domain] = true
+		}
+	}
+	domains := make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+
+	if f.applied != nil && strings.Join(domains, " ") == strings.Join(f.applied, " ") {
+		return nil
+	}
+	if err := f.writeLocked(domains); err != nil {
+		return err
+	}
+	log.Printf("Blocked sites updated: %d domain(s)", len(domains))
+	f.applied = domains
+	return nil
+}
+
+// Stop removes the managed section from the hosts file.
+func (f *Filter) Stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.writeLocked(nil); err != nil {
+		log.Printf("Failed to remove blocked sites from the hosts file: %v", err)
+		return
+	}
+	f.applied = nil
+}
+
+// writeLocked replaces the managed section of the hosts file with entries for domains.
+// The file is replaced as a whole, so that name lookups never see it half written.
+func (f *Filter) writeLocked(domains []string) error {
+	path := f.system.HostsFile()
+	if path == "" {
+		return fmt.Errorf("no hosts file")
+	}
+	// Replace the file a symlink points to rather than the symlink
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read hosts file: %v", err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	content := replaceSection(string(data), domains)
+	if content == string(data) {
+		return nil
+	}
+	if err := atomicfile.WriteFile(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("failed to write hosts file: %v", err)
+	}
+
+	if err := f.system.FlushDNSCache(); err != nil {
+		log.Printf("Failed to flush DNS cache: %v", err)
+	}
+	return nil
+}
+
+// replaceSection returns hosts with the managed section replaced by entries for
+// domains, or removed when there are none. Lines outside the section are kept.
+// The hosts file has no wildcards: each domain is blocked with its www. name only,
+// other subdomains have to be listed as domains of their own.
+func replaceSection(hosts string, domains []string) string {
+	newline := "\n"
+	if strings.Contains(hosts, "\r\n") {
+		newline = "\r\n"
+	}
+
+	var lines []string
+	inSection := false
+	for _, line := range strings.Split(strings.ReplaceAll(hosts, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.TrimSpace(line) == beginMarker:
+			inSection = true
+		case strings.TrimSpace(line) == endMarker:
+			inSection = false
+		case !inSection:
+			lines = append(lines, line)
+		}
+	}
+	// Drop trailing empty lines so repeated updates do not grow the file
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(domains) > 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, beginMarker)
+		for _, domain := range domains {
+			for _, name := range []string{domain, "www." + domain} {
+				lines = append(lines, "0.0.0.0 "+name, ":: "+name)
+			}
+		}
+		lines = append(lines, endMarker)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, newline) + newline
+}
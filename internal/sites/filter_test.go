@@ -0,0 +1,198 @@
+package sites
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Hepri/parental/internal/config"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+func TestReplaceSection(t *testing.T) {
+	section := func(newline string, names ...string) string {
+		lines := []string{beginMarker}
+		for _, name := range names {
+			lines = append(lines, "0.0.0.0 "+name, ":: "+name)
+		}
+		lines = append(lines, endMarker)
+		return strings.Join(lines, newline) + newline
+	}
+
+	tests := []struct {
+		name    string
+		hosts   string
+		domains []string
+		want    string
+	}{
+		{
+			name:    "empty file",
+			domains: []string{"tiktok.com"},
+			want:    section("\n", "tiktok.com", "www.tiktok.com"),
+		},
+		{
+			name:    "section appended after other entries",
+			hosts:   "127.0.0.1 localhost\n\n\n",
+			domains: []string{"tiktok.com", "m.youtube.com"},
+			want:    "127.0.0.1 localhost\n\n" + section("\n", "tiktok.com", "www.tiktok.com", "m.youtube.com", "www.m.youtube.com"),
+		},
+		{
+			name:    "section replaced, other entries kept",
+			hosts:   "# comment\n127.0.0.1 localhost\n\n" + section("\n", "old.com", "www.old.com") + "10.0.0.1 nas\n",
+			domains: []string{"new.com"},
+			want:    "# comment\n127.0.0.1 localhost\n\n10.0.0.1 nas\n\n" + section("\n", "new.com", "www.new.com"),
+		},
+		{
+			name:  "section removed",
+			hosts: "127.0.0.1 localhost\n\n" + section("\n", "old.com", "www.old.com"),
+			want:  "127.0.0.1 localhost\n",
+		},
+		{
+			name:  "only the section",
+			hosts: section("\n", "old.com", "www.old.com"),
+			want:  "",
+		},
+		{
+			name:    "windows line endings",
+			hosts:   "127.0.0.1 localhost\r\n",
+			domains: []string{"tiktok.com"},
+			want:    "127.0.0.1 localhost\r\n\r\n" + section("\r\n", "tiktok.com", "www.tiktok.com"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := replaceSection(tt.hosts, tt.domains)
+			if got != tt.want {
+				t.Errorf("replaceSection() =\n%q\nwant\n%q", got, tt.want)
+			}
+			// Applying the same domains again changes nothing
+			if again := replaceSection(got, tt.domains); again != got {
+				t.Errorf("second replaceSection() =\n%q\nwant it unchanged", again)
+			}
+		})
+	}
+}
+
+// resolve looks name up in the hosts file at path the way resolvers do (hosts(5)):
+// the addresses of every line listing the name, comments ignored, case-insensitive.
+// The resolver of the net package always reads the system hosts file, so the
+// lookup is done here against the file the filter wrote.
+func resolve(t *testing.T, path, name string) []net.IP {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var addrs []net.IP
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			t.Fatalf("invalid address in hosts line %q", scanner.Text())
+		}
+		for _, host := range fields[1:] {
+			if strings.EqualFold(host, name) {
+				addrs = append(addrs, ip)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return addrs
+}
+
+func TestFilterBlocksNames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	const original = "127.0.0.1 localhost\n::1 localhost\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := platform.NewFake()
+	fake.SetHostsFile(path)
+	filter := NewFilter(fake)
+	filter.SetRules("alice", []config.SiteRule{{Domains: []string{"https://www.TikTok.com/foryou", "m.youtube.com"}}})
+	filter.SetRules("bob", []config.SiteRule{{Domains: []string{"roblox.com"}}})
+	fake.Login("alice")
+
+	if err := filter.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := []string{"tiktok.com", "www.tiktok.com", "TIKTOK.COM", "m.youtube.com", "www.m.youtube.com"}
+	for _, name := range blocked {
+		addrs := resolve(t, path, name)
+		if len(addrs) != 2 || !addrs[0].IsUnspecified() || !addrs[1].IsUnspecified() {
+			t.Errorf("%s resolves to %v, want 0.0.0.0 and ::", name, addrs)
+		}
+	}
+	// Subdomains not listed, the parent domain of a listed one and other children's sites
+	for _, name := range []string{"vm.tiktok.com", "youtube.com", "www.youtube.com", "roblox.com"} {
+		if addrs := resolve(t, path, name); len(addrs) != 0 {
+			t.Errorf("%s resolves to %v, want it left to DNS", name, addrs)
+		}
+	}
+	if addrs := resolve(t, path, "localhost"); len(addrs) != 2 || !addrs[0].IsLoopback() {
+		t.Errorf("localhost resolves to %v, want the original entries", addrs)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("hosts file mode = %v, want 0644 kept", info.Mode().Perm())
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	filter.Stop()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != original {
+		t.Errorf("hosts file after Stop =\n%q\nwant the original\n%q", data, original)
+	}
+}
+
+func TestFilterKeepsHostsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "hosts.real")
+	if err := os.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "hosts")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	fake := platform.NewFake()
+	fake.SetHostsFile(link)
+	filter := NewFilter(fake)
+	filter.SetRules("alice", []config.SiteRule{{Domains: []string{"tiktok.com"}}})
+	fake.Login("alice")
+	if err := filter.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("hosts symlink replaced: %v, %v", info, err)
+	}
+	if addrs := resolve(t, target, "tiktok.com"); len(addrs) != 2 {
+		t.Errorf("tiktok.com resolves to %v in the linked file, want it blocked", addrs)
+	}
+}
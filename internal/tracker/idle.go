@@ -0,0 +1,59 @@
+package tracker
+
+import (
+	"log"
+	"time"
+)
+
+// IdleApp is the bucket that receives time spent without keyboard or mouse input.
+const IdleApp = "idle"
+
+// IdleDetector reports how long the user has been away from the keyboard and mouse.
+// platform.Input satisfies it.
+type IdleDetector interface {
+	IdleTime() (time.Duration, error)
+}
+
+// IdleHandler is called with each idle interval [from, to) of username as it is observed.
+type IdleHandler func(username string, from, to time.Time)
+
+// SetIdleDetector replaces the source of idle information.
+func (t *TimeTracker) SetIdleDetector(detector IdleDetector) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.idleDetector = detector
+}
+
+// SetIdleThreshold sets how long without input counts as idle; 0 disables idle detection.
+func (t *TimeTracker) SetIdleThreshold(threshold time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.idleThreshold = threshold
+}
+
+// SetIdleHandler registers a callback notified about idle intervals as they are observed.
+func (t *TimeTracker) SetIdleHandler(handler IdleHandler) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.idleHandler = handler
+}
+
+// currentIdle returns the idle time if it exceeds the threshold, otherwise 0.
+func (t *TimeTracker) currentIdle() time.Duration {
+	t.mutex.RLock()
+	detector, threshold := t.idleDetector, t.idleThreshold
+	t.mutex.RUnlock()
+
+	if detector == nil || threshold <= 0 {
+		return 0
+	}
+	idle, err := detector.IdleTime()
+	if err != nil {
+		log.Printf("Failed to get idle time: %v", err)
+		return 0
+	}
+	if idle < threshold {
+		return 0
+	}
+	return idle
+}
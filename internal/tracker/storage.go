@@ -0,0 +1,393 @@
+package tracker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+)
+
+// Files of FileStorage in its directory.
+const (
+	UsageFileName   = "time_tracking.json"
+	TitlesFileName  = "window_titles.json"
+	JournalFileName = "time_tracking.journal"
+	TimelineDirName = "timeline"
+)
+
+// Interval is a span of time a user spent in an app, with the window title if it is
+// recorded. Intervals never cross midnight.
+type Interval struct {
+	Seq   int64     `json:"seq"` // assigned by the storage, increasing
+	User  string    `json:"user"`
+	App   string    `json:"app"` // IdleApp for idle time
+	Title string    `json:"title,omitempty"`
+	Idle  bool      `json:"idle,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Usage is the tracked time summed per day.
+type Usage struct {
+	Apps   map[string]map[string]map[string]int64            // date -> user -> app -> seconds
+	Titles map[string]map[string]map[string]map[string]int64 // date -> user -> app -> title -> seconds
+}
+
+func NewUsage() *Usage {
+	return &Usage{
+		Apps:   make(map[string]map[string]map[string]int64),
+		Titles: make(map[string]map[string]map[string]map[string]int64),
+	}
+}
+
+// Add adds the length of iv to the day it started on.
+func (u *Usage) Add(iv Interval) {
+	u.addApp(iv)
+	u.addTitle(iv)
+}
+
+func (u *Usage) addApp(iv Interval) {
+	date := iv.Start.Format("2006-01-02")
+	if u.Apps[date] == nil {
+		u.Apps[date] = make(map[string]map[string]int64)
+	}
+	if u.Apps[date][iv.User] == nil {
+		u.Apps[date][iv.User] = make(map[string]int64)
+	}
+	u.Apps[date][iv.User][iv.App] += int64(iv.End.Sub(iv.Start).Seconds())
+}
+
+func (u *Usage) addTitle(iv Interval) {
+	if iv.Title == "" {
+		return
+	}
+	date := iv.Start.Format("2006-01-02")
+	if u.Titles[date] == nil {
+		u.Titles[date] = make(map[string]map[string]map[string]int64)
+	}
+	if u.Titles[date][iv.User] == nil {
+		u.Titles[date][iv.User] = make(map[string]map[string]int64)
+	}
+	if u.Titles[date][iv.User][iv.App] == nil {
+		u.Titles[date][iv.User][iv.App] = make(map[string]int64)
+	}
+	u.Titles[date][iv.User][iv.App][iv.Title] += int64(iv.End.Sub(iv.Start).Seconds())
+}
+
+// Storage keeps tracked time across restarts. Intervals are appended as they are
+// recorded and folded into a snapshot of the daily totals by Compact.
+type Storage interface {
+	// Load returns the last snapshot with every interval appended after it. It is
+	// called once, before any other method.
+	Load() (*Usage, error)
+	// Append durably records iv before it returns, assigning its Seq.
+	Append(iv Interval) error
+	// Compact replaces the snapshot with usage, which must include every appended
+	// interval. Intervals are kept for the days usage has, with titles only for
+	// the days usage has titles for.
+	Compact(usage *Usage) error
+	// Intervals returns the intervals overlapping [from, to), ordered by start.
+	Intervals(from, to time.Time) ([]Interval, error)
+}
+
+// FileStorage keeps daily totals in JSON snapshots and appends intervals to a JSON
+// lines journal, synced to disk on every append. Snapshots are replaced atomically
+// and remember the last interval they include, so a crash at any point neither loses
+// nor double counts time. Compaction moves the journal to one JSON lines file of
+// intervals per day.
+type FileStorage struct {
+	usagePath   string
+	titlesPath  string
+	journalPath string
+	timelineDir string
+	seq         int64 // last assigned interval number
+}
+
+func NewFileStorage(dir string) *FileStorage {
+	return &FileStorage{
+		usagePath:   filepath.Join(dir, UsageFileName),
+		titlesPath:  filepath.Join(dir, TitlesFileName),
+		journalPath: filepath.Join(dir, JournalFileName),
+		timelineDir: filepath.Join(dir, TimelineDirName),
+	}
+}
+
+// TimeData is one day of the usage snapshot.
+type TimeData struct {
+	Date  string                      `json:"date"`
+	Users map[string]map[string]int64 `json:"users"`          // user -> app -> seconds
+	Apps  map[string]int64            `json:"apps,omitempty"` // legacy format, migrated on load
+}
+
+// TitleData is one day of the window titles snapshot.
+type TitleData struct {
+	Date  string                                 `json:"date"`
+	Users map[string]map[string]map[string]int64 `json:"users"` // user -> app -> title -> seconds
+}
+
+// usageSnapshot is the content of time_tracking.json.
+type usageSnapshot struct {
+	JournalSeq int64      `json:"journal_seq"` // last journal interval included
+	Days       []TimeData `json:"days"`
+}
+
+// titlesSnapshot is the content of window_titles.json.
+type titlesSnapshot struct {
+	JournalSeq int64       `json:"journal_seq"`
+	Days       []TitleData `json:"days"`
+}
+
+func (s *FileStorage) Load() (*Usage, error) {
+	return s.load(true)
+}
+
+// Read returns what Load would without changing any file, for tools that run while
+// the service is recording. A snapshot that cannot be parsed is an error instead of
+// being moved aside.
+func (s *FileStorage) Read() (*Usage, error) {
+	return s.load(false)
+}
+
+// load reads the snapshots and replays the journal. With repair, an unreadable
+// snapshot is moved aside and a journal line cut short is ended so that appending
+// can go on.
+func (s *FileStorage) load(repair bool) (*Usage, error) {
+	usage := NewUsage()
+
+	var apps usageSnapshot
+	if _, err := readSnapshot(s.usagePath, &apps, &apps.Days, repair); err != nil {
+		return nil, err
+	}
+	for _, day := range apps.Days {
+		users := day.Users
+		if users == nil {
+			users = make(map[string]map[string]int64)
+		}
+		// Migrate files written before per-user attribution
+		if len(day.Apps) > 0 {
+			if users[UnknownUser] == nil {
+				users[UnknownUser] = make(map[string]int64)
+			}
+			for app, seconds := range day.Apps {
+				users[UnknownUser][app] += seconds
+			}
+		}
+		usage.Apps[day.Date] = users
+	}
+
+	var titles titlesSnapshot
+	found, err := readSnapshot(s.titlesPath, &titles, &titles.Days, repair)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// Compact removes the file when there are no titles, so none were recorded
+		// up to the usage snapshot
+		titles.JournalSeq = apps.JournalSeq
+	}
+	for _, day := range titles.Days {
+		if day.Users != nil {
+			usage.Titles[day.Date] = day.Users
+		}
+	}
+
+	// Intervals are numbered after every archived one as well, in case a snapshot was
+	// lost, since reading the timeline skips repeated numbers
+	archived, err := s.lastArchivedSeq()
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay what was recorded after each snapshot
+	s.seq = max(apps.JournalSeq, titles.JournalSeq, archived)
+	replayed := 0
+	err = readIntervals(s.journalPath, func(iv Interval) {
+		if iv.Seq > apps.JournalSeq {
+			usage.addApp(iv)
+			replayed++
+		}
+		if iv.Seq > titles.JournalSeq {
+			usage.addTitle(iv)
+		}
+		s.seq = max(s.seq, iv.Seq)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !repair {
+		return usage, nil
+	}
+	if err := endLine(s.journalPath); err != nil {
+		return nil, fmt.Errorf("failed to repair %s: %v", filepath.Base(s.journalPath), err)
+	}
+	if replayed > 0 {
+		log.Printf("Recovered %d usage interval(s) from the journal", replayed)
+	}
+	return usage, nil
+}
+
+// readSnapshot decodes the snapshot at path into v and reports whether the file exists.
+// Files written before the journal existed hold just the list of days, which is
+// decoded into days. With moveAside, an unreadable file is moved aside so that
+// tracking starts over instead of failing on every start; without, it is an error.
+func readSnapshot(path string, v interface{}, days interface{}, moveAside bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	target := v
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		target = days
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		if !moveAside {
+			return false, fmt.Errorf("failed to parse %s: %v", filepath.Base(path), err)
+		}
+		aside := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+		log.Printf("Failed to parse %s, moving it to %s: %v", filepath.Base(path), filepath.Base(aside), err)
+		if err := os.Rename(path, aside); err != nil {
+			return false, fmt.Errorf("failed to move aside corrupt %s: %v", filepath.Base(path), err)
+		}
+	}
+	return true, nil
+}
+
+// readIntervals calls fn with every interval in the JSON lines file at path, in file
+// order. Lines that cannot be parsed, such as one cut short by a power loss, are skipped.
+func readIntervals(path string, fn func(iv Interval)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var iv Interval
+		if err := json.Unmarshal(scanner.Bytes(), &iv); err != nil {
+			log.Printf("Skipping malformed interval on line %d of %s: %v", line, filepath.Base(path), err)
+			continue
+		}
+		fn(iv)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// endLine ends the file at path with a newline if it does not, so that intervals
+// appended after a line cut short by a power loss start on a line of their own.
+func endLine(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	return appendSynced(path, []byte{'\n'})
+}
+
+func (s *FileStorage) Append(iv Interval) error {
+	s.seq++
+	iv.Seq = s.seq
+	data, err := json.Marshal(iv)
+	if err != nil {
+		return err
+	}
+
+	return appendSynced(s.journalPath, append(data, '\n'))
+}
+
+// appendSynced appends data to the file at path and waits until it is on disk.
+func appendSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileStorage) Compact(usage *Usage) error {
+	// The intervals are archived before the journal goes away; archiving them twice
+	// after a crash is harmless, as reading skips repeated numbers
+	if err := s.archiveJournal(); err != nil {
+		return fmt.Errorf("failed to archive intervals: %v", err)
+	}
+
+	apps := usageSnapshot{JournalSeq: s.seq, Days: []TimeData{}}
+	for date, users := range usage.Apps {
+		apps.Days = append(apps.Days, TimeData{Date: date, Users: users})
+	}
+	if err := writeSnapshot(s.usagePath, apps); err != nil {
+		return err
+	}
+
+	if len(usage.Titles) == 0 {
+		if err := os.Remove(s.titlesPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		titles := titlesSnapshot{JournalSeq: s.seq}
+		for date, users := range usage.Titles {
+			titles.Days = append(titles.Days, TitleData{Date: date, Users: users})
+		}
+		if err := writeSnapshot(s.titlesPath, titles); err != nil {
+			return err
+		}
+	}
+
+	// Both snapshots include the whole journal now
+	if err := os.Remove(s.journalPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.pruneTimeline(usage)
+}
+
+// writeSnapshot replaces path with v as indented JSON.
+func writeSnapshot(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
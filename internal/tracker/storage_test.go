@@ -0,0 +1,123 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// appendIntervals appends an interval of a minute per app, one after another from start.
+func appendIntervals(t *testing.T, storage Storage, usage *Usage, start time.Time, apps ...string) {
+	t.Helper()
+	for _, app := range apps {
+		iv := Interval{User: "alice", App: app, Start: start, End: start.Add(time.Minute)}
+		if err := storage.Append(iv); err != nil {
+			t.Fatal(err)
+		}
+		usage.Add(iv)
+		start = iv.End
+	}
+}
+
+// assertApps checks that the stored intervals of the day of start are those of apps, in order.
+func assertApps(t *testing.T, storage Storage, start time.Time, apps ...string) {
+	t.Helper()
+	intervals, err := storage.Intervals(start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, iv := range intervals {
+		got = append(got, iv.App)
+	}
+	if len(got) != len(apps) {
+		t.Fatalf("stored intervals of %v, want %v", got, apps)
+	}
+	for i := range apps {
+		if got[i] != apps[i] {
+			t.Fatalf("stored intervals of %v, want %v", got, apps)
+		}
+	}
+}
+
+func TestStorageCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 10, 10, 0, 0, 0, time.Local)
+
+	storage := NewFileStorage(dir)
+	if _, err := storage.Load(); err != nil {
+		t.Fatal(err)
+	}
+	usage := NewUsage()
+	appendIntervals(t, storage, usage, start, "chrome.exe", "notepad.exe")
+	if err := storage.Compact(usage); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, UsageFileName), []byte(`{"journal_seq": 2, "da`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The corrupt snapshot is moved aside and tracking starts over
+	reloaded := NewFileStorage(dir)
+	usage, err := reloaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Apps) != 0 {
+		t.Errorf("loaded usage %v from a corrupt snapshot", usage.Apps)
+	}
+	aside, err := filepath.Glob(filepath.Join(dir, UsageFileName+".corrupt-*"))
+	if err != nil || len(aside) != 1 {
+		t.Errorf("corrupt snapshot moved to %v, %v, want one file", aside, err)
+	}
+
+	// New intervals are numbered after the archived ones, so none hides another
+	appendIntervals(t, reloaded, usage, start.Add(time.Hour), "Minecraft.exe")
+	assertApps(t, reloaded, start, "chrome.exe", "notepad.exe", "Minecraft.exe")
+	if err := reloaded.Compact(usage); err != nil {
+		t.Fatal(err)
+	}
+	assertApps(t, reloaded, start, "chrome.exe", "notepad.exe", "Minecraft.exe")
+}
+
+func TestStorageTruncatedJournal(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 10, 10, 0, 0, 0, time.Local)
+
+	storage := NewFileStorage(dir)
+	if _, err := storage.Load(); err != nil {
+		t.Fatal(err)
+	}
+	appendIntervals(t, storage, NewUsage(), start, "chrome.exe", "notepad.exe")
+	// A power loss in the middle of the next append
+	f, err := os.OpenFile(filepath.Join(dir, JournalFileName), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"seq":3,"user":"alice","app":"Mine`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	reloaded := NewFileStorage(dir)
+	usage, err := reloaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := start.Format("2006-01-02")
+	if got := usage.Apps[date]["alice"]; got["chrome.exe"] != 60 || got["notepad.exe"] != 60 || len(got) != 2 {
+		t.Errorf("recovered usage %v, want a minute of chrome and notepad", got)
+	}
+
+	// The interval appended after the cut line is kept as well
+	appendIntervals(t, reloaded, usage, start.Add(time.Hour), "Minecraft.exe")
+	assertApps(t, reloaded, start, "chrome.exe", "notepad.exe", "Minecraft.exe")
+	again, err := NewFileStorage(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.Apps[date]["alice"]["Minecraft.exe"]; got != 60 {
+		t.Errorf("recovered Minecraft usage = %ds, want 60s", got)
+	}
+}
@@ -0,0 +1,211 @@
+package tracker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hepri/parental/internal/atomicfile"
+)
+
+// SplitAtMidnight splits iv into parts that each lie within one calendar day.
+func SplitAtMidnight(iv Interval) []Interval {
+	var parts []Interval
+	for iv.End.After(iv.Start) {
+		y, m, d := iv.Start.Date()
+		midnight := time.Date(y, m, d+1, 0, 0, 0, 0, iv.Start.Location())
+		if !iv.End.After(midnight) {
+			break
+		}
+		part := iv
+		part.End = midnight
+		parts = append(parts, part)
+		iv.Start = midnight
+	}
+	return append(parts, iv)
+}
+
+// timelinePath returns the file with the intervals of date (YYYY-MM-DD).
+func (s *FileStorage) timelinePath(date string) string {
+	return filepath.Join(s.timelineDir, date+".jsonl")
+}
+
+// archiveJournal appends the intervals in the journal to the files of their days.
+func (s *FileStorage) archiveJournal() error {
+	byDate := make(map[string][]byte)
+	err := readIntervals(s.journalPath, func(iv Interval) {
+		data, err := json.Marshal(iv)
+		if err != nil {
+			return
+		}
+		date := iv.Start.Format("2006-01-02")
+		byDate[date] = append(append(byDate[date], data...), '\n')
+	})
+	if err != nil || len(byDate) == 0 {
+		return err
+	}
+
+	if err := os.MkdirAll(s.timelineDir, 0700); err != nil {
+		return err
+	}
+	for date, data := range byDate {
+		if err := appendSynced(s.timelinePath(date), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastArchivedSeq returns the highest interval number in the timeline, 0 if it is empty.
+func (s *FileStorage) lastArchivedSeq() (int64, error) {
+	entries, err := os.ReadDir(s.timelineDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var seq int64
+	for _, entry := range entries {
+		date, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok {
+			continue
+		}
+		err := readIntervals(s.timelinePath(date), func(iv Interval) {
+			seq = max(seq, iv.Seq)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return seq, nil
+}
+
+// pruneTimeline removes the interval files of days usage no longer has and the
+// window titles of days it has no titles for.
+func (s *FileStorage) pruneTimeline(usage *Usage) error {
+	entries, err := os.ReadDir(s.timelineDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		date, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok {
+			continue
+		}
+		path := s.timelinePath(date)
+		if _, kept := usage.Apps[date]; !kept {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, kept := usage.Titles[date]; !kept {
+			if err := stripTitles(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stripTitles rewrites the interval file at path without window titles, if it has any.
+func stripTitles(path string) error {
+	var intervals []Interval
+	hasTitles := false
+	err := readIntervals(path, func(iv Interval) {
+		hasTitles = hasTitles || iv.Title != ""
+		iv.Title = ""
+		intervals = append(intervals, iv)
+	})
+	if err != nil || !hasTitles {
+		return err
+	}
+
+	var data []byte
+	for _, iv := range intervals {
+		line, err := json.Marshal(iv)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+func (s *FileStorage) Intervals(from, to time.Time) ([]Interval, error) {
+	seen := make(map[int64]bool)
+	var result []Interval
+	collect := func(iv Interval) {
+		if seen[iv.Seq] || !iv.End.After(from) || !iv.Start.Before(to) {
+			return
+		}
+		seen[iv.Seq] = true
+		result = append(result, iv)
+	}
+
+	y, m, d := from.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if err := readIntervals(s.timelinePath(day.Format("2006-01-02")), collect); err != nil {
+			return nil, err
+		}
+	}
+	if err := readIntervals(s.journalPath, collect); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
+
+// Timeline returns the intervals of username overlapping [from, to), clipped to it
+// and ordered by start, including the one in progress. An empty username means all users.
+func (t *TimeTracker) Timeline(username string, from, to time.Time) ([]Interval, error) {
+	t.mutex.RLock()
+	intervals, err := t.storage.Intervals(from, to)
+	var current Interval
+	if t.currentApp != "" {
+		current = Interval{
+			User:  t.currentUser,
+			App:   t.currentApp,
+			Title: t.currentTitle,
+			Idle:  t.currentApp == IdleApp,
+			Start: t.recordedUntil,
+			End:   time.Now(),
+		}
+	}
+	t.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if current.App != "" && current.End.After(from) && current.Start.Before(to) {
+		intervals = append(intervals, SplitAtMidnight(current)...)
+	}
+
+	var result []Interval
+	for _, iv := range intervals {
+		if username != "" && !strings.EqualFold(iv.User, username) {
+			continue
+		}
+		if iv.Start.Before(from) {
+			iv.Start = from
+		}
+		if iv.End.After(to) {
+			iv.End = to
+		}
+		if iv.End.After(iv.Start) {
+			result = append(result, iv)
+		}
+	}
+	return result, nil
+}
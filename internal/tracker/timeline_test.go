@@ -0,0 +1,191 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/platform"
+)
+
+func TestSplitAtMidnight(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	local := func(day, hh, mm int) time.Time {
+		return time.Date(2026, 10, day, hh, mm, 0, 0, time.Local)
+	}
+	// Clocks in Berlin go forward from 02:00 to 03:00 on March 29, 2026 and back
+	// from 03:00 to 02:00 on October 25, 2026
+	inBerlin := func(month time.Month, day, hh, mm int) time.Time {
+		return time.Date(2026, month, day, hh, mm, 0, 0, berlin)
+	}
+
+	type span struct{ start, end time.Time }
+	tests := []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		want  []span
+	}{
+		{
+			name:  "within a day",
+			start: local(12, 10, 0), end: local(12, 11, 0),
+			want: []span{{local(12, 10, 0), local(12, 11, 0)}},
+		},
+		{
+			name:  "across midnight",
+			start: local(12, 23, 30), end: local(13, 0, 45),
+			want: []span{{local(12, 23, 30), local(13, 0, 0)}, {local(13, 0, 0), local(13, 0, 45)}},
+		},
+		{
+			name:  "several days",
+			start: local(12, 22, 0), end: local(15, 1, 0),
+			want: []span{
+				{local(12, 22, 0), local(13, 0, 0)},
+				{local(13, 0, 0), local(14, 0, 0)},
+				{local(14, 0, 0), local(15, 0, 0)},
+				{local(15, 0, 0), local(15, 1, 0)},
+			},
+		},
+		{
+			name:  "ending at midnight",
+			start: local(12, 23, 0), end: local(13, 0, 0),
+			want: []span{{local(12, 23, 0), local(13, 0, 0)}},
+		},
+		{
+			name:  "starting at midnight",
+			start: local(13, 0, 0), end: local(13, 0, 10),
+			want: []span{{local(13, 0, 0), local(13, 0, 10)}},
+		},
+		{
+			name:  "over a 23 hour day",
+			start: inBerlin(time.March, 28, 22, 0), end: inBerlin(time.March, 30, 2, 0),
+			want: []span{
+				{inBerlin(time.March, 28, 22, 0), inBerlin(time.March, 29, 0, 0)},
+				{inBerlin(time.March, 29, 0, 0), inBerlin(time.March, 30, 0, 0)},
+				{inBerlin(time.March, 30, 0, 0), inBerlin(time.March, 30, 2, 0)},
+			},
+		},
+		{
+			name:  "over a 25 hour day",
+			start: inBerlin(time.October, 24, 23, 0), end: inBerlin(time.October, 26, 0, 30),
+			want: []span{
+				{inBerlin(time.October, 24, 23, 0), inBerlin(time.October, 25, 0, 0)},
+				{inBerlin(time.October, 25, 0, 0), inBerlin(time.October, 26, 0, 0)},
+				{inBerlin(time.October, 26, 0, 0), inBerlin(time.October, 26, 0, 30)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iv := Interval{User: "alice", App: "chrome.exe", Title: "Cats", Start: tt.start, End: tt.end}
+			parts := SplitAtMidnight(iv)
+			if len(parts) != len(tt.want) {
+				t.Fatalf("split into %d parts, want %d: %+v", len(parts), len(tt.want), parts)
+			}
+			var total time.Duration
+			for i, part := range parts {
+				if !part.Start.Equal(tt.want[i].start) || !part.End.Equal(tt.want[i].end) {
+					t.Errorf("part %d = %v - %v, want %v - %v", i, part.Start, part.End, tt.want[i].start, tt.want[i].end)
+				}
+				if part.User != iv.User || part.App != iv.App || part.Title != iv.Title {
+					t.Errorf("part %d = %+v, want the user, app and title of the interval", i, part)
+				}
+				total += part.End.Sub(part.Start)
+			}
+			if total != tt.end.Sub(tt.start) {
+				t.Errorf("parts last %v, want %v", total, tt.end.Sub(tt.start))
+			}
+		})
+	}
+}
+
+func TestTimelineClipsRange(t *testing.T) {
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	storage := NewFileStorage(t.TempDir())
+	if _, err := storage.Load(); err != nil {
+		t.Fatal(err)
+	}
+	stored := []Interval{
+		{User: "alice", App: "chrome.exe", Start: day.Add(9 * time.Hour), End: day.Add(10 * time.Hour)},
+		{User: "alice", App: "chrome.exe", Start: day.Add(10 * time.Hour), End: day.Add(11 * time.Hour)},
+		{User: "bob", App: "chrome.exe", Start: day.Add(10 * time.Hour), End: day.Add(11 * time.Hour)},
+		{User: "alice", App: "Minecraft.exe", Start: day.Add(11 * time.Hour), End: day.Add(12 * time.Hour)},
+		{User: "alice", App: "notepad.exe", Start: day.Add(13 * time.Hour), End: day.Add(14 * time.Hour)},
+	}
+	for _, iv := range stored {
+		if err := storage.Append(iv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tracker, err := NewTracker(platform.NewFake(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cuts the intervals at both ends, leaves out the ones outside and bob's
+	from, to := day.Add(10*time.Hour+15*time.Minute), day.Add(11*time.Hour+45*time.Minute)
+	intervals, err := tracker.Timeline("ALICE", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Interval{
+		{App: "chrome.exe", Start: from, End: day.Add(11 * time.Hour)},
+		{App: "Minecraft.exe", Start: day.Add(11 * time.Hour), End: to},
+	}
+	if len(intervals) != len(want) {
+		t.Fatalf("Timeline() = %+v, want %d intervals", intervals, len(want))
+	}
+	for i, iv := range intervals {
+		if iv.User != "alice" || iv.App != want[i].App || !iv.Start.Equal(want[i].Start) || !iv.End.Equal(want[i].End) {
+			t.Errorf("interval %d = %s %v - %v, want %s %v - %v", i, iv.App, iv.Start, iv.End, want[i].App, want[i].Start, want[i].End)
+		}
+	}
+
+	// An empty range and all users
+	if intervals, err := tracker.Timeline("", day.Add(12*time.Hour), day.Add(13*time.Hour)); err != nil || len(intervals) != 0 {
+		t.Errorf("Timeline() of a gap = %+v, %v, want none", intervals, err)
+	}
+	if intervals, err := tracker.Timeline("", day, day.AddDate(0, 0, 1)); err != nil || len(intervals) != len(stored) {
+		t.Errorf("Timeline() of the day for all users = %d intervals, %v, want %d", len(intervals), err, len(stored))
+	}
+}
+
+func TestTimelineSplitsIntervalInProgress(t *testing.T) {
+	tracker, fake := newTestTracker(t)
+	fake.SetForeground("chrome.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 10*time.Minute)
+
+	// The interval in progress is cut at the start of the range
+	now := time.Now()
+	from := now.Add(-4 * time.Minute)
+	intervals, err := tracker.Timeline("alice", from, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total time.Duration
+	for _, iv := range intervals {
+		if iv.App != "chrome.exe" || iv.Start.Before(from) {
+			t.Errorf("interval %+v, want chrome from %v on", iv, from)
+		}
+		total += iv.End.Sub(iv.Start)
+	}
+	assertSeconds(t, "chrome in range", int64(total.Seconds()), 4*60)
+
+	// Its parts lie within a day each, even when it started yesterday
+	rewind(tracker, 24*time.Hour)
+	intervals, err = tracker.Timeline("alice", time.Now().Add(-48*time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(intervals) < 2 {
+		t.Fatalf("Timeline() = %+v, want the interval in progress split at midnight", intervals)
+	}
+	for _, iv := range intervals {
+		if y, m, d := iv.Start.Date(); iv.End.After(time.Date(y, m, d+1, 0, 0, 0, 0, iv.Start.Location())) {
+			t.Errorf("interval %v - %v crosses midnight", iv.Start, iv.End)
+		}
+	}
+}
@@ -1,69 +1,86 @@
-//go:build windows
-
 package tracker
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"strings"
 	"sync"
 	"time"
-	"unsafe"
 
-	"golang.org/x/sys/windows"
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// UnknownUser is used for time whose owner could not be resolved,
+// including data recorded before per-user attribution existed.
+const UnknownUser = "unknown"
+
+const (
+	// tickInterval is how often the foreground window is sampled.
+	tickInterval = 5 * time.Second
+	// checkpointInterval is how much of a long interval may be left unrecorded;
+	// it bounds the time lost on a crash.
+	checkpointInterval = time.Minute
+	// compactInterval is how often the journal is folded into the daily totals.
+	compactInterval = 10 * time.Minute
 )
 
 type TimeTracker struct {
-	dataPath      string
+	storage       Storage
+	foreground    platform.Foreground
 	currentApp    string
+	currentUser   string
+	currentTitle  string
 	startTime     time.Time
-	dailyData     map[string]map[string]int64 // date -> app -> seconds
+	recordedUntil time.Time // end of the part of the current interval already recorded
+	usage         *Usage
 	mutex         sync.RWMutex
 	retentionDays int
-}
 
-type TimeData struct {
-	Date string           `json:"date"`
-	Apps map[string]int64 `json:"apps"`
-}
+	titleFunc          TitleFunc
+	titleRetentionDays int
 
-var (
-	user32                        = windows.NewLazySystemDLL("user32.dll")
-	kernel32                      = windows.NewLazySystemDLL("kernel32.dll")
-	procGetForegroundWindow       = user32.NewProc("GetForegroundWindow")
-	procGetWindowThreadProcessId  = user32.NewProc("GetWindowThreadProcessId")
-	procOpenProcess               = kernel32.NewProc("OpenProcess")
-	procQueryFullProcessImageName = kernel32.NewProc("QueryFullProcessImageNameW")
-)
+	idleDetector   IdleDetector
+	idleThreshold  time.Duration
+	idleHandler    IdleHandler
+	lastIdleReport time.Time
 
-const (
-	PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
-	MAX_PATH                          = 260
-)
+	foregroundHandler ForegroundHandler
+}
 
-func NewTracker() (*TimeTracker, error) {
-	dataPath := filepath.Join(filepath.Dir(os.Args[0]), "time_tracking.json")
+// ForegroundHandler is called with the foreground process on every tracker tick.
+type ForegroundHandler func(process platform.Process)
 
+// TitleFunc returns the window title of the foreground process to record, or ""
+// to record only the application.
+type TitleFunc func(process platform.Process) string
+
+// NewTracker creates a tracker that keeps its data in storage.
+func NewTracker(foreground platform.Foreground, storage Storage) (*TimeTracker, error) {
 	tracker := &TimeTracker{
-		dataPath:      dataPath,
-		dailyData:     make(map[string]map[string]int64),
-		retentionDays: 7, // Will be updated from config
+		storage:            storage,
+		foreground:         foreground,
+		usage:              NewUsage(),
+		retentionDays:      7,               // Will be updated from config
+		idleThreshold:      5 * time.Minute, // Will be updated from config
+		titleRetentionDays: 7,               // Will be updated from config
 	}
 
 	// Load existing data
-	if err := tracker.loadData(); err != nil {
+	usage, err := storage.Load()
+	if err != nil {
 		log.Printf("Failed to load existing time data: %v", err)
+	} else {
+		tracker.usage = usage
 	}
 
 	return tracker, nil
 }
 
 func (t *TimeTracker) Start(ctx context.Context) error {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(tickInterval)
 	defer ticker.Stop()
+	compactTicker := time.NewTicker(compactInterval)
+	defer compactTicker.Stop()
 
 	log.Println("Time tracker started")
 
@@ -74,93 +91,155 @@ func (t *TimeTracker) Start(ctx context.Context) error {
 			return nil
 		case <-ticker.C:
 			t.updateActiveWindow()
+		case <-compactTicker.C:
+			if err := t.compact(); err != nil {
+				log.Printf("Failed to compact time data: %v", err)
+			}
 		}
 	}
 }
 
 func (t *TimeTracker) Stop() {
 	t.saveCurrentSession()
-	t.saveData()
+	if err := t.compact(); err != nil {
+		log.Printf("Failed to save time data: %v", err)
+	}
 	log.Println("Time tracker stopped")
 }
 
 func (t *TimeTracker) updateActiveWindow() {
-	appName, err := t.getActiveWindowProcess()
+	process, err := t.getActiveWindowProcess()
 	if err != nil {
 		log.Printf("Failed to get active window process: %v", err)
 		return
 	}
+	appName, username := process.Name, process.Username
+
+	now := time.Now()
+	switchAt := now
+
+	// Without input for longer than the threshold the time goes to the idle bucket,
+	// starting from the moment of the last input rather than from this tick
+	if idle := t.currentIdle(); idle > 0 {
+		appName = IdleApp
+		switchAt = now.Add(-idle)
+	}
 
 	t.mutex.Lock()
-	defer t.mutex.Unlock()
 
-	now := time.Now()
+	// Time already recorded is not taken back
+	if switchAt.Before(t.recordedUntil) {
+		switchAt = t.recordedUntil
+	}
+	if switchAt.Before(t.startTime) {
+		switchAt = t.startTime
+	}
+
+	title := ""
+	if t.titleFunc != nil && appName != IdleApp {
+		title = t.titleFunc(process)
+	}
+	changed := t.currentApp != appName || t.currentUser != username || t.currentTitle != title
 
-	// Save previous session if app changed
-	if t.currentApp != "" && t.currentApp != appName {
-		duration := now.Sub(t.startTime).Seconds()
-		t.addTimeToApp(t.currentApp, int64(duration))
+	// Save previous session if app, window title or owning user changed
+	if t.currentApp != "" && changed {
+		t.recordLocked(switchAt)
 	}
 
 	// Start new session
-	t.currentApp = appName
-	t.startTime = now
-}
+	if changed {
+		t.currentApp = appName
+		t.currentUser = username
+		t.currentTitle = title
+		t.startTime = switchAt
+		t.recordedUntil = switchAt
+	} else if until := now.Add(-t.idleThreshold - tickInterval); until.Sub(t.recordedUntil) >= checkpointInterval {
+		// Record long intervals in parts so that a crash loses little. The last
+		// idle threshold stays open, as it may still turn out to be idle.
+		t.recordLocked(until)
+	}
 
-func (t *TimeTracker) getActiveWindowProcess() (string, error) {
-	// Get foreground window
-	hWnd, _, _ := procGetForegroundWindow.Call()
-	if hWnd == 0 {
-		return "", fmt.Errorf("no foreground window")
+	// Report the idle interval observed since the previous report
+	var idleFrom time.Time
+	handler := t.idleHandler
+	foregroundHandler := t.foregroundHandler
+	if appName == IdleApp && handler != nil {
+		idleFrom = t.startTime
+		if t.lastIdleReport.After(idleFrom) {
+			idleFrom = t.lastIdleReport
+		}
+		t.lastIdleReport = now
 	}
 
-	// Get process ID
-	var processID uint32
-	procGetWindowThreadProcessId.Call(hWnd, uintptr(unsafe.Pointer(&processID)))
-	if processID == 0 {
-		return "", fmt.Errorf("failed to get process ID")
+	t.mutex.Unlock()
+
+	// Called without the lock held: the handlers may call into other components
+	if !idleFrom.IsZero() && now.After(idleFrom) {
+		handler(username, idleFrom, now)
 	}
+	if foregroundHandler != nil {
+		foregroundHandler(process)
+	}
+}
 
-	// Open process
-	hProcess, _, _ := procOpenProcess.Call(
-		PROCESS_QUERY_LIMITED_INFORMATION,
-		0,
-		uintptr(processID),
-	)
-	if hProcess == 0 {
-		return "", fmt.Errorf("failed to open process")
+// getActiveWindowProcess returns the process owning the foreground window, with
+// UnknownUser as the user if the owner of its session could not be resolved.
+func (t *TimeTracker) getActiveWindowProcess() (platform.Process, error) {
+	process, err := t.foreground.ForegroundProcess()
+	if err != nil {
+		return platform.Process{}, err
 	}
-	defer windows.CloseHandle(windows.Handle(hProcess))
-
-	// Get process image name
-	var size uint32 = MAX_PATH
-	buf := make([]uint16, MAX_PATH)
-	ret, _, _ := procQueryFullProcessImageName.Call(
-		hProcess,
-		0, // Win32 path format
-		uintptr(unsafe.Pointer(&buf[0])),
-		uintptr(unsafe.Pointer(&size)),
-	)
-
-	if ret == 0 {
-		return "", fmt.Errorf("failed to get process image name")
+
+	if process.Username == "" {
+		process.Username = UnknownUser
 	}
+	return process, nil
+}
 
-	// Convert to string and extract filename
-	imagePath := windows.UTF16ToString(buf)
-	appName := filepath.Base(imagePath)
+// SetForegroundHandler registers a callback notified about the foreground process on every tick.
+func (t *TimeTracker) SetForegroundHandler(handler ForegroundHandler) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.foregroundHandler = handler
+}
 
-	return appName, nil
+// SetTitleFunc enables recording of window titles as chosen by fn.
+func (t *TimeTracker) SetTitleFunc(fn TitleFunc) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.titleFunc = fn
 }
 
-func (t *TimeTracker) addTimeToApp(appName string, seconds int64) {
-	date := time.Now().Format("2006-01-02")
+// SetTitleRetentionDays sets how many days window titles are kept.
+func (t *TimeTracker) SetTitleRetentionDays(days int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.titleRetentionDays = days
+}
 
-	if t.dailyData[date] == nil {
-		t.dailyData[date] = make(map[string]int64)
+// recordLocked records the current interval up to until and appends it to the journal,
+// split at midnight so that each day gets its own part.
+func (t *TimeTracker) recordLocked(until time.Time) {
+	if !until.After(t.recordedUntil) {
+		return
 	}
+	iv := Interval{
+		User:  t.currentUser,
+		App:   t.currentApp,
+		Title: t.currentTitle,
+		Idle:  t.currentApp == IdleApp,
+		Start: t.recordedUntil,
+		End:   until,
+	}
+	t.recordedUntil = until
 
-	t.dailyData[date][appName] += seconds
+	for _, part := range SplitAtMidnight(iv) {
+		t.usage.Add(part)
+		// The time stays in memory and reaches the disk with the next compaction
+		if err := t.storage.Append(part); err != nil {
+			log.Printf("Failed to journal usage of %s by %s: %v", part.App, part.User, err)
+		}
+	}
 }
 
 func (t *TimeTracker) saveCurrentSession() {
@@ -168,95 +247,191 @@ func (t *TimeTracker) saveCurrentSession() {
 	defer t.mutex.Unlock()
 
 	if t.currentApp != "" {
-		duration := time.Now().Sub(t.startTime).Seconds()
-		t.addTimeToApp(t.currentApp, int64(duration))
+		t.recordLocked(time.Now())
 		t.currentApp = ""
+		t.currentUser = ""
+		t.currentTitle = ""
 	}
 }
 
-func (t *TimeTracker) loadData() error {
-	if _, err := os.Stat(t.dataPath); os.IsNotExist(err) {
-		return nil // File doesn't exist, start fresh
-	}
+// compact drops data past retention and folds the journal into the daily totals.
+func (t *TimeTracker) compact() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 
-	data, err := os.ReadFile(t.dataPath)
-	if err != nil {
-		return err
-	}
+	t.cleanOldData()
+	return t.storage.Compact(t.usage)
+}
+
+func (t *TimeTracker) cleanOldData() {
+	cutoffDate := time.Now().AddDate(0, 0, -t.retentionDays).Format("2006-01-02")
 
-	var timeData []TimeData
-	if err := json.Unmarshal(data, &timeData); err != nil {
-		return err
+	for date := range t.usage.Apps {
+		if date < cutoffDate {
+			delete(t.usage.Apps, date)
+		}
 	}
 
-	t.dailyData = make(map[string]map[string]int64)
-	for _, day := range timeData {
-		t.dailyData[day.Date] = day.Apps
+	// Titles are more private than application names and have their own retention
+	titleCutoff := time.Now().AddDate(0, 0, -t.titleRetentionDays).Format("2006-01-02")
+	for date := range t.usage.Titles {
+		if date < titleCutoff {
+			delete(t.usage.Titles, date)
+		}
 	}
+}
+
+// GetTodayReport returns today's usage per app summed over all users.
+func (t *TimeTracker) GetTodayReport() map[string]int64 {
+	return t.report(1, "")
+}
+
+// GetWeekReport returns the last 7 days of usage per app summed over all users.
+func (t *TimeTracker) GetWeekReport() map[string]int64 {
+	return t.report(7, "")
+}
+
+// GetTodayReportForUser returns today's usage per app for a single Windows user.
+func (t *TimeTracker) GetTodayReportForUser(username string) map[string]int64 {
+	return t.report(1, username)
+}
 
-	return nil
+// GetWeekReportForUser returns the last 7 days of usage per app for a single Windows user.
+func (t *TimeTracker) GetWeekReportForUser(username string) map[string]int64 {
+	return t.report(7, username)
 }
 
-func (t *TimeTracker) saveData() error {
+// report sums usage per app over the last days days; an empty username means all users.
+func (t *TimeTracker) report(days int, username string) map[string]int64 {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 
-	// Clean old data
-	t.cleanOldData()
+	result := make(map[string]int64)
+	now := time.Now()
 
-	// Convert to array format
-	var timeData []TimeData
-	for date, apps := range t.dailyData {
-		timeData = append(timeData, TimeData{
-			Date: date,
-			Apps: apps,
-		})
+	for i := 0; i < days; i++ {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		for user, apps := range t.usage.Apps[date] {
+			if username != "" && !strings.EqualFold(user, username) {
+				continue
+			}
+			for app, seconds := range apps {
+				result[app] += seconds
+			}
+		}
 	}
 
-	data, err := json.MarshalIndent(timeData, "", "  ")
-	if err != nil {
-		return err
+	return result
+}
+
+// TitleReport returns the recorded usage per app and window title over the last
+// days days; an empty username means all users. Apps without titles are left out.
+func (t *TimeTracker) TitleReport(days int, username string) map[string]map[string]int64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	result := make(map[string]map[string]int64)
+	now := time.Now()
+
+	for i := 0; i < days; i++ {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		for user, apps := range t.usage.Titles[date] {
+			if username != "" && !strings.EqualFold(user, username) {
+				continue
+			}
+			for app, titles := range apps {
+				if result[app] == nil {
+					result[app] = make(map[string]int64)
+				}
+				for title, seconds := range titles {
+					result[app][title] += seconds
+				}
+			}
+		}
 	}
 
-	return os.WriteFile(t.dataPath, data, 0644)
+	return result
 }
 
-func (t *TimeTracker) cleanOldData() {
-	cutoffDate := time.Now().AddDate(0, 0, -t.retentionDays).Format("2006-01-02")
+// DailyUsage returns a copy of the recorded usage as date -> user -> app -> seconds.
+func (t *TimeTracker) DailyUsage() map[string]map[string]map[string]int64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
 
-	for date := range t.dailyData {
-		if date < cutoffDate {
-			delete(t.dailyData, date)
+	result := make(map[string]map[string]map[string]int64, len(t.usage.Apps))
+	for date, users := range t.usage.Apps {
+		result[date] = make(map[string]map[string]int64, len(users))
+		for user, apps := range users {
+			result[date][user] = make(map[string]int64, len(apps))
+			for app, seconds := range apps {
+				result[date][user][app] = seconds
+			}
 		}
 	}
+	return result
 }
 
-func (t *TimeTracker) GetTodayReport() map[string]int64 {
+// TodayUsage returns how long username has used each app today, including the
+// interval still in progress.
+func (t *TimeTracker) TodayUsage(username string) map[string]time.Duration {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 
-	date := time.Now().Format("2006-01-02")
-	return t.dailyData[date]
+	now := time.Now()
+	usage := make(map[string]time.Duration)
+	for user, apps := range t.usage.Apps[now.Format("2006-01-02")] {
+		if !strings.EqualFold(user, username) {
+			continue
+		}
+		for app, seconds := range apps {
+			usage[app] += time.Duration(seconds) * time.Second
+		}
+	}
+
+	if t.currentApp != "" && strings.EqualFold(t.currentUser, username) {
+		start := t.recordedUntil
+		if midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()); start.Before(midnight) {
+			start = midnight
+		}
+		usage[t.currentApp] += now.Sub(start)
+	}
+	return usage
 }
 
-func (t *TimeTracker) GetWeekReport() map[string]int64 {
+// TodayTitleUsage returns how long username has spent in each window title of each
+// app today, including the interval still in progress. Only titles recorded for the
+// user are included, see SetTitleFunc.
+func (t *TimeTracker) TodayTitleUsage(username string) map[string]map[string]time.Duration {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 
-	weekData := make(map[string]int64)
 	now := time.Now()
-
-	// Get last 7 days
-	for i := 0; i < 7; i++ {
-		date := now.AddDate(0, 0, -i).Format("2006-01-02")
-		if dayData, exists := t.dailyData[date]; exists {
-			for app, seconds := range dayData {
-				weekData[app] += seconds
+	usage := make(map[string]map[string]time.Duration)
+	add := func(app, title string, d time.Duration) {
+		if usage[app] == nil {
+			usage[app] = make(map[string]time.Duration)
+		}
+		usage[app][title] += d
+	}
+	for user, apps := range t.usage.Titles[now.Format("2006-01-02")] {
+		if !strings.EqualFold(user, username) {
+			continue
+		}
+		for app, titles := range apps {
+			for title, seconds := range titles {
+				add(app, title, time.Duration(seconds)*time.Second)
 			}
 		}
 	}
 
-	return weekData
+	if t.currentApp != "" && t.currentTitle != "" && strings.EqualFold(t.currentUser, username) {
+		start := t.recordedUntil
+		if midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()); start.Before(midnight) {
+			start = midnight
+		}
+		add(t.currentApp, t.currentTitle, now.Sub(start))
+	}
+	return usage
 }
 
 func (t *TimeTracker) SetRetentionDays(days int) {
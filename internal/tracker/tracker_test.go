@@ -0,0 +1,231 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/platform"
+)
+
+// newTestTracker returns a tracker of the foreground of a fake platform that keeps
+// its data in a temporary directory.
+func newTestTracker(t *testing.T) (*TimeTracker, *platform.Fake) {
+	t.Helper()
+	fake := platform.NewFake()
+	tracker, err := NewTracker(fake, NewFileStorage(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.SetIdleDetector(fake)
+	return tracker, fake
+}
+
+// rewind moves the start of the interval in progress d into the past, as if
+// that much time had passed since the last tick.
+func rewind(tracker *TimeTracker, d time.Duration) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.startTime = tracker.startTime.Add(-d)
+	tracker.recordedUntil = tracker.recordedUntil.Add(-d)
+	if !tracker.lastIdleReport.IsZero() {
+		tracker.lastIdleReport = tracker.lastIdleReport.Add(-d)
+	}
+}
+
+// recorded returns the seconds recorded for app of user over all days.
+func recorded(tracker *TimeTracker, user, app string) int64 {
+	var total int64
+	for _, users := range tracker.DailyUsage() {
+		total += users[user][app]
+	}
+	return total
+}
+
+// assertSeconds checks that got is want give or take the time the test itself takes.
+func assertSeconds(t *testing.T, what string, got, want int64) {
+	t.Helper()
+	if got < want-2 || got > want+2 {
+		t.Errorf("%s = %ds, want %ds", what, got, want)
+	}
+}
+
+func TestTrackerIdleTime(t *testing.T) {
+	tracker, fake := newTestTracker(t)
+	tracker.SetIdleThreshold(5 * time.Minute)
+
+	type idleInterval struct {
+		user     string
+		from, to time.Time
+	}
+	var reported []idleInterval
+	tracker.SetIdleHandler(func(username string, from, to time.Time) {
+		reported = append(reported, idleInterval{username, from, to})
+	})
+
+	fake.SetForeground("chrome.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 20*time.Minute)
+
+	// Away for 8 minutes: the time since the last input is idle, not chrome
+	fake.SetIdle(8 * time.Minute)
+	tracker.updateActiveWindow()
+	assertSeconds(t, "chrome", recorded(tracker, "alice", "chrome.exe"), 12*60)
+	if len(reported) != 1 || reported[0].user != "alice" {
+		t.Fatalf("reported idle intervals %+v, want one of alice", reported)
+	}
+	assertSeconds(t, "reported idle", int64(reported[0].to.Sub(reported[0].from).Seconds()), 8*60)
+
+	// Still away a few minutes later: only the new part is reported
+	rewind(tracker, 3*time.Minute)
+	fake.SetIdle(11 * time.Minute)
+	tracker.updateActiveWindow()
+	if len(reported) != 2 {
+		t.Fatalf("reported %d idle intervals, want 2", len(reported))
+	}
+	assertSeconds(t, "second reported idle", int64(reported[1].to.Sub(reported[1].from).Seconds()), 3*60)
+	if !reported[1].from.Equal(reported[0].to.Add(-3 * time.Minute)) {
+		t.Errorf("second idle interval starts at %v, want the end of the first one", reported[1].from)
+	}
+
+	// Back at the keyboard: the idle time is recorded in its own bucket
+	fake.SetIdle(0)
+	tracker.updateActiveWindow()
+	assertSeconds(t, "idle", recorded(tracker, "alice", IdleApp), 11*60)
+	assertSeconds(t, "chrome", recorded(tracker, "alice", "chrome.exe"), 12*60)
+	if len(reported) != 2 {
+		t.Errorf("reported %d idle intervals after input, want 2", len(reported))
+	}
+}
+
+func TestTrackerIdleBelowThreshold(t *testing.T) {
+	tracker, fake := newTestTracker(t)
+	tracker.SetIdleThreshold(5 * time.Minute)
+	tracker.SetIdleHandler(func(username string, from, to time.Time) {
+		t.Errorf("reported idle interval of %v although below the threshold", to.Sub(from))
+	})
+
+	fake.SetForeground("chrome.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 10*time.Minute)
+
+	fake.SetIdle(4 * time.Minute)
+	fake.SetForeground("notepad.exe", "alice")
+	tracker.updateActiveWindow()
+
+	assertSeconds(t, "chrome", recorded(tracker, "alice", "chrome.exe"), 10*60)
+	if idle := recorded(tracker, "alice", IdleApp); idle != 0 {
+		t.Errorf("idle = %ds, want 0", idle)
+	}
+}
+
+func TestTrackerIdleDisabled(t *testing.T) {
+	tracker, fake := newTestTracker(t)
+	tracker.SetIdleThreshold(0)
+
+	fake.SetForeground("chrome.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 10*time.Minute)
+
+	fake.SetIdle(8 * time.Minute)
+	fake.SetForeground("notepad.exe", "alice")
+	tracker.updateActiveWindow()
+
+	assertSeconds(t, "chrome", recorded(tracker, "alice", "chrome.exe"), 10*60)
+	if idle := recorded(tracker, "alice", IdleApp); idle != 0 {
+		t.Errorf("idle = %ds, want 0", idle)
+	}
+}
+
+func TestTrackerAttribution(t *testing.T) {
+	tracker, fake := newTestTracker(t)
+
+	// Nothing is recorded while there is no foreground window
+	tracker.updateActiveWindow()
+	if usage := tracker.DailyUsage(); len(usage) != 0 {
+		t.Fatalf("recorded %v without a foreground window", usage)
+	}
+
+	fake.SetForeground("chrome.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 10*time.Minute)
+
+	// Same application, another user: the time so far belongs to alice
+	fake.SetForeground("chrome.exe", "bob")
+	tracker.updateActiveWindow()
+	rewind(tracker, 4*time.Minute)
+
+	// A process whose owner cannot be resolved
+	fake.SetForeground("svchost.exe", "")
+	tracker.updateActiveWindow()
+	rewind(tracker, 2*time.Minute)
+
+	fake.SetForeground("Minecraft.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 3*time.Minute)
+
+	assertSeconds(t, "alice chrome", recorded(tracker, "alice", "chrome.exe"), 10*60)
+	assertSeconds(t, "bob chrome", recorded(tracker, "bob", "chrome.exe"), 4*60)
+	assertSeconds(t, "unknown svchost", recorded(tracker, UnknownUser, "svchost.exe"), 2*60)
+	if got := recorded(tracker, "alice", "Minecraft.exe"); got != 0 {
+		t.Errorf("Minecraft recorded as %ds before the interval ended", got)
+	}
+
+	// The interval in progress counts for today, for its user only
+	today := tracker.TodayUsage("ALICE")
+	if today["Minecraft.exe"] < 3*time.Minute-2*time.Second {
+		t.Errorf("today's Minecraft usage = %v, want the interval in progress", today["Minecraft.exe"])
+	}
+	if bob := tracker.TodayUsage("bob"); bob["Minecraft.exe"] != 0 {
+		t.Errorf("bob's usage includes alice's interval in progress: %v", bob)
+	}
+
+	// Stopping records the interval in progress
+	tracker.Stop()
+	assertSeconds(t, "alice Minecraft", recorded(tracker, "alice", "Minecraft.exe"), 3*60)
+}
+
+func TestTrackerAttributionPersisted(t *testing.T) {
+	dir := t.TempDir()
+	fake := platform.NewFake()
+	tracker, err := NewTracker(fake, NewFileStorage(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.SetForeground("chrome.exe", "alice")
+	tracker.updateActiveWindow()
+	rewind(tracker, 5*time.Minute)
+	fake.SetForeground("chrome.exe", "bob")
+	tracker.updateActiveWindow()
+	rewind(tracker, 7*time.Minute)
+	tracker.Stop()
+
+	reloaded, err := NewTracker(fake, NewFileStorage(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeconds(t, "alice chrome", recorded(reloaded, "alice", "chrome.exe"), 5*60)
+	assertSeconds(t, "bob chrome", recorded(reloaded, "bob", "chrome.exe"), 7*60)
+}
+
+func TestTrackerTodayTitleUsage(t *testing.T) {
+	tracker, fake := newTestTracker(t)
+	tracker.SetTitleFunc(func(process platform.Process) string { return process.Title })
+
+	fake.SetForeground("chrome.exe", "alice")
+	fake.SetForegroundTitle("Cats - YouTube")
+	tracker.updateActiveWindow()
+	rewind(tracker, 10*time.Minute)
+
+	fake.SetForegroundTitle("Wikipedia")
+	tracker.updateActiveWindow()
+	rewind(tracker, 4*time.Minute)
+
+	// The interval in progress counts as well, for its user only
+	titles := tracker.TodayTitleUsage("ALICE")
+	assertSeconds(t, "YouTube", int64(titles["chrome.exe"]["Cats - YouTube"].Seconds()), 10*60)
+	assertSeconds(t, "Wikipedia", int64(titles["chrome.exe"]["Wikipedia"].Seconds()), 4*60)
+	if bob := tracker.TodayTitleUsage("bob"); len(bob) != 0 {
+		t.Errorf("bob's title usage = %v, want none", bob)
+	}
+}
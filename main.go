@@ -1,37 +1,69 @@
-//go:build windows
-
 package main
 
 import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
-
+	"github.com/Hepri/parental/internal/apps"
+	"github.com/Hepri/parental/internal/audit"
+	"github.com/Hepri/parental/internal/config"
 	"github.com/Hepri/parental/internal/logger"
+	"github.com/Hepri/parental/internal/report"
+	"github.com/Hepri/parental/internal/request"
 	"github.com/Hepri/parental/internal/service"
-)
-
-const (
-	serviceName = "ParentalControlBot"
-	serviceDesc = "Parental Control Telegram Bot Service"
+	"github.com/Hepri/parental/internal/tracker"
 )
 
 func main() {
 	var (
-		install   = flag.Bool("install", false, "Install the service")
-		uninstall = flag.Bool("uninstall", false, "Uninstall the service")
-		debugFlag = flag.Bool("debug", false, "Run in debug mode (not as service)")
-		testFlag  = flag.Bool("test", false, "Test configuration and exit")
+		install        = flag.Bool("install", false, "Install the service")
+		uninstall      = flag.Bool("uninstall", false, "Uninstall the service")
+		debugFlag      = flag.Bool("debug", false, "Run in debug mode (not as service)")
+		testFlag       = flag.Bool("test", false, "Test configuration and exit")
+		requestMinutes = flag.Int("request", 0, "Ask the parents for this many extra minutes (run in the child's session)")
+		reason         = flag.String("reason", "", "Reason sent with -request")
+		requestPort    = flag.Int("port", request.DefaultPort, "Port of the time request endpoint, for -request")
+		exportAudit    = flag.Bool("export-audit", false, "Print audit log entries as JSON lines and exit")
+		exportUsage    = flag.String("export-usage", "", "Print a usage report in this format (csv, json or html) and exit")
+		exportUser     = flag.String("user", "", "Child account for -export-usage, default: all users")
+		exportFrom     = flag.String("from", "", "First day (YYYY-MM-DD) for -export-audit and -export-usage, default: the beginning of the data")
+		exportTo       = flag.String("to", "", "Last day (YYYY-MM-DD) for -export-audit and -export-usage, default: today")
 	)
 	flag.Parse()
 
+	if *exportAudit {
+		if err := exportAuditLog(*exportFrom, *exportTo); err != nil {
+			fmt.Fprintf(os.Stderr, "Audit export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *exportUsage != "" {
+		if err := exportUsageReport(os.Stdout, filepath.Dir(os.Args[0]), *exportUsage, *exportUser, *exportFrom, *exportTo); err != nil {
+			fmt.Fprintf(os.Stderr, "Usage export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *requestMinutes != 0 {
+		if err := requestMoreTime(*requestPort, *requestMinutes, *reason); err != nil {
+			fmt.Printf("Request failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *install {
 		if err := installService(); err != nil {
 			log.Fatalf("Failed to install service: %v", err)
@@ -67,12 +99,15 @@ func main() {
 		fmt.Println("Parental Control Bot")
 		fmt.Println("===================")
 		fmt.Println("Available commands:")
-		fmt.Println("  -install   : Install as Windows service")
-		fmt.Println("  -uninstall : Remove Windows service")
+		fmt.Printf("  -install   : Install as %s\n", serviceKind)
+		fmt.Printf("  -uninstall : Remove %s\n", serviceKind)
 		fmt.Println("  -debug     : Run in debug mode (not as service)")
 		fmt.Println("  -test      : Test configuration and exit")
+		fmt.Println("  -request N : Ask the parents for N more minutes (with optional -reason)")
+		fmt.Println("  -export-audit [-from YYYY-MM-DD] [-to YYYY-MM-DD] : Print the audit log as JSON lines")
+		fmt.Println("  -export-usage csv|json|html [-user NAME] [-from YYYY-MM-DD] [-to YYYY-MM-DD] : Print a usage report")
 		fmt.Println()
-		fmt.Println("For debugging, use: parental-control-bot.exe -debug")
+		fmt.Printf("For debugging, use: %s -debug\n", debugCommand)
 	}
 }
 
@@ -118,26 +153,30 @@ func runService(debug bool) {
 		}
 		defer logger.CloseLogger()
 
-		// Run as Windows service
-		err = svc.Run(serviceName, &service.ParentalControlService{})
+		// Run under the system service manager
+		err = runAsService(&service.ParentalControlService{})
 	}
 	if err != nil {
 		log.Fatalf("Service failed: %v", err)
 	}
 }
 
-// isInteractiveSession determines if the application is running in an interactive session
-func isInteractiveSession() bool {
-	// Check if stdout is connected to a console
-	var mode uint32
-	err := syscall.GetConsoleMode(syscall.Stdout, &mode)
-	return err == nil
-}
-
 // testConfiguration tests the configuration without starting the service
 func testConfiguration() error {
 	fmt.Println("Testing configuration...")
 
+	// Check that only administrators can access the configuration and data
+	dataDir := filepath.Dir(os.Args[0])
+	problems := append(config.CheckDataDir(dataDir), config.CheckPermissions(config.ProtectedPaths(dataDir))...)
+	if len(problems) == 0 {
+		fmt.Printf("✓ File permissions: only administrators can access configuration and data\n")
+	} else {
+		for _, problem := range problems {
+			fmt.Printf("✗ Insecure permissions: %s\n", problem)
+		}
+		fmt.Printf("  The service repairs them on startup\n")
+	}
+
 	// Load configuration
 	configPath := "config.json"
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -152,7 +191,7 @@ func testConfiguration() error {
 
 	fmt.Printf("✓ Configuration loaded successfully\n")
 	fmt.Printf("✓ Telegram bot token configured\n")
-	fmt.Printf("✓ Authorized users: %d\n", len(cfg.AuthorizedUserIDs))
+	fmt.Printf("✓ Authorized users: %d\n", len(cfg.AllParents()))
 	fmt.Printf("✓ Child accounts: %d\n", len(cfg.ChildAccounts))
 	fmt.Printf("✓ Data retention: %d days\n", cfg.DataRetentionDays)
 
@@ -168,78 +207,81 @@ func testConfiguration() error {
 	return nil
 }
 
-func installService() error {
-	exepath, err := os.Executable()
+// requestMoreTime sends a request for more time on behalf of the current user
+func requestMoreTime(port, minutes int, reason string) error {
+	req, err := request.Send(port, minutes, reason)
 	if err != nil {
 		return err
 	}
 
-	m, err := mgr.Connect()
-	if err != nil {
-		return err
-	}
-	defer m.Disconnect()
+	fmt.Printf("Your request for %d more minutes was sent to your parents.\n", minutes)
+	fmt.Printf("It is valid until %s; you will see a message when they answer.\n", req.ExpiresAt.Local().Format("15:04"))
+	return nil
+}
 
-	s, err := m.OpenService(serviceName)
-	if err == nil {
-		s.Close()
-		return fmt.Errorf("service %s already exists", serviceName)
+// parseDayRange parses the -from and -to days; an empty day gives a zero time.
+func parseDayRange(from, to string) (first, last time.Time, err error) {
+	if from != "" {
+		if first, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return first, last, fmt.Errorf("invalid -from date %q", from)
+		}
 	}
-
-	s, err = m.CreateService(serviceName, exepath, mgr.Config{
-		DisplayName:      serviceDesc,
-		Description:      serviceDesc,
-		StartType:        mgr.StartAutomatic,
-		ServiceStartName: "LocalSystem",
-	}, "is", "auto-started")
-	if err != nil {
-		return err
+	if to != "" {
+		if last, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return first, last, fmt.Errorf("invalid -to date %q", to)
+		}
 	}
-	defer s.Close()
+	return first, last, nil
+}
 
-	// Configure service recovery options
-	err = s.SetRecoveryActions([]mgr.RecoveryAction{
-		{Type: mgr.ServiceRestart, Delay: 0},
-		{Type: mgr.ServiceRestart, Delay: 0},
-		{Type: mgr.ServiceRestart, Delay: 0},
-	}, 0)
+// exportAuditLog writes the audit entries recorded between the days from and to
+// (inclusive) to stdout, one JSON object per line.
+func exportAuditLog(from, to string) error {
+	start, end, err := parseDayRange(from, to)
 	if err != nil {
 		return err
 	}
+	if !end.IsZero() {
+		end = end.AddDate(0, 0, 1)
+	}
 
-	// Set service description
-	// Note: SetDescription might not be available in all versions
-	// err = s.SetDescription(serviceDesc)
-	// if err != nil {
-	//     return err
-	// }
+	auditLog := audit.New(filepath.Join(filepath.Dir(os.Args[0]), audit.FileName))
+	return auditLog.Export(os.Stdout, start, end)
+}
 
-	// Start the service
-	err = s.Start()
+// exportUsageReport writes the usage of username (all users if empty) between the
+// days from and to (inclusive) to w in format. The files in dataDir are only read,
+// as the service may be recording at the same time.
+func exportUsageReport(w io.Writer, dataDir, format, username, from, to string) error {
+	reportFormat, err := report.ParseFormat(format)
 	if err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func uninstallService() error {
-	m, err := mgr.Connect()
+	first, last, err := parseDayRange(from, to)
 	if err != nil {
 		return err
 	}
-	defer m.Disconnect()
 
-	s, err := m.OpenService(serviceName)
+	// Friendly names and categories come from the configuration when it can be read
+	classifier := apps.NewClassifier(nil, nil)
+	cfg, err := config.ReadConfig(filepath.Join(dataDir, "config.json"))
 	if err != nil {
-		return fmt.Errorf("service %s is not installed", serviceName)
+		fmt.Fprintf(os.Stderr, "Using built-in application names: %v\n", err)
+	} else {
+		classifier = apps.NewClassifier(cfg.AppLabels, cfg.AppCategories)
 	}
-	defer s.Close()
 
-	err = s.Delete()
+	usage, err := tracker.NewFileStorage(dataDir).Read()
 	if err != nil {
 		return err
 	}
-
-	return nil
+	r := report.Build(usage.Apps, classifier, username, first, last)
+	if cfg != nil {
+		for _, account := range cfg.ChildAccounts {
+			if strings.EqualFold(account.Username, username) {
+				r.Name = account.FullName
+			}
+		}
+	}
+	return r.Write(w, reportFormat)
 }
@@ -0,0 +1,104 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Hepri/parental/internal/service"
+)
+
+const (
+	serviceName = "parental-control-bot"
+	serviceDesc = "Parental Control Telegram Bot Service"
+	unitPath    = "/etc/systemd/system/" + serviceName + ".service"
+
+	serviceKind  = "systemd service"
+	debugCommand = "sudo ./parental-control-bot"
+)
+
+const unitTemplate = `[Unit]
+Description=%s
+Wants=network-online.target systemd-logind.service
+After=network-online.target systemd-logind.service
+
+[Service]
+Type=simple
+ExecStart=%s
+WorkingDirectory=%s
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// isInteractiveSession determines if the application is running in an interactive session
+func isInteractiveSession() bool {
+	// systemd sets INVOCATION_ID for every unit it starts
+	if os.Getenv("INVOCATION_ID") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runAsService runs s until systemd asks it to stop
+func runAsService(s *service.ParentalControlService) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return s.Run(ctx)
+}
+
+func installService() error {
+	exepath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(unitPath); err == nil {
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+
+	unit := fmt.Sprintf(unitTemplate, serviceDesc, exepath, filepath.Dir(exepath))
+	if err := os.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return err
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+
+	// Enable at boot and start the service
+	return systemctl("enable", "--now", serviceName)
+}
+
+func uninstallService() error {
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+
+	if err := systemctl("disable", "--now", serviceName); err != nil {
+		return err
+	}
+
+	if err := os.Remove(unitPath); err != nil {
+		return err
+	}
+
+	return systemctl("daemon-reload")
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %v failed: %v: %s", args, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hepri/parental/internal/tracker"
+)
+
+// readTree returns the content of every file under dir by relative path.
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestExportUsageReportReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	const cfg = `{
+  "telegram_bot_token": "123456:plain-token",
+  "authorized_user_ids": [42],
+  "child_accounts": [{"username": "alice", "full_name": "Alice", "password": "alice-password"}]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A snapshot, a journal after it and a journal line cut short, as the service
+	// leaves them while it is recording
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	storage := tracker.NewFileStorage(dir)
+	usage, err := storage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	intervals := []tracker.Interval{
+		{User: "alice", App: "chrome.exe", Start: day.Add(10 * time.Hour), End: day.Add(11 * time.Hour)},
+		{User: "alice", App: "Minecraft.exe", Start: day.Add(12 * time.Hour), End: day.Add(12*time.Hour + 30*time.Minute)},
+	}
+	if err := storage.Append(intervals[0]); err != nil {
+		t.Fatal(err)
+	}
+	usage.Add(intervals[0])
+	if err := storage.Compact(usage); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Append(intervals[1]); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := os.OpenFile(filepath.Join(dir, tracker.JournalFileName), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := journal.WriteString(`{"seq":3,"user":"alice","app":"note`); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	before := readTree(t, dir)
+	var out bytes.Buffer
+	if err := exportUsageReport(&out, dir, "csv", "alice", "2026-10-12", "2026-10-12"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Minecraft") || !strings.Contains(out.String(), "Chrome") {
+		t.Errorf("report without the snapshot and journal usage:\n%s", out.String())
+	}
+
+	after := readTree(t, dir)
+	if len(after) != len(before) {
+		t.Errorf("files %v after the export, want %v", keys(after), keys(before))
+	}
+	for name, data := range before {
+		if !bytes.Equal(after[name], data) {
+			t.Errorf("%s changed by the export", name)
+		}
+	}
+
+	// A snapshot that cannot be parsed fails the export and stays where it is
+	usagePath := filepath.Join(dir, tracker.UsageFileName)
+	if err := os.WriteFile(usagePath, []byte(`{"journal_seq": 1, "da`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	before = readTree(t, dir)
+	if err := exportUsageReport(&out, dir, "csv", "alice", "", ""); err == nil {
+		t.Error("export of a corrupt snapshot succeeded")
+	}
+	if after := readTree(t, dir); len(after) != len(before) || !bytes.Equal(after[tracker.UsageFileName], before[tracker.UsageFileName]) {
+		t.Errorf("files %v after the failed export, want %v", keys(after), keys(before))
+	}
+}
+
+func keys(files map[string][]byte) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	return names
+}
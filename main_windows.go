@@ -0,0 +1,111 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/Hepri/parental/internal/service"
+)
+
+const (
+	serviceName = "ParentalControlBot"
+	serviceDesc = "Parental Control Telegram Bot Service"
+
+	serviceKind  = "Windows service"
+	debugCommand = "parental-control-bot.exe"
+)
+
+// isInteractiveSession determines if the application is running in an interactive session
+func isInteractiveSession() bool {
+	// Check if stdout is connected to a console
+	var mode uint32
+	err := syscall.GetConsoleMode(syscall.Stdout, &mode)
+	return err == nil
+}
+
+// runAsService runs s under the Windows service control manager
+func runAsService(s *service.ParentalControlService) error {
+	return svc.Run(serviceName, s)
+}
+
+func installService() error {
+	exepath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+
+	s, err = m.CreateService(serviceName, exepath, mgr.Config{
+		DisplayName:      serviceDesc,
+		Description:      serviceDesc,
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: "LocalSystem",
+	}, "is", "auto-started")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// Configure service recovery options
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 0},
+		{Type: mgr.ServiceRestart, Delay: 0},
+		{Type: mgr.ServiceRestart, Delay: 0},
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	// Set service description
+	// Note: SetDescription might not be available in all versions
+	// err = s.SetDescription(serviceDesc)
+	// if err != nil {
+	//     return err
+	// }
+
+	// Start the service
+	err = s.Start()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	err = s.Delete()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}